@@ -14,7 +14,11 @@ import (
 	"vcx/agent/internal/infra/fsmonitor"
 	server "vcx/agent/internal/infra/http"
 	"vcx/agent/internal/services/account"
+	blobService "vcx/agent/internal/services/blob"
+	"vcx/agent/internal/services/encryption"
+	"vcx/agent/internal/services/gc"
 	"vcx/agent/internal/services/migrations"
+	"vcx/agent/internal/services/repack"
 	"vcx/agent/internal/session"
 	"vcx/pkg/logging"
 )
@@ -38,6 +42,16 @@ func main() {
         log.Fatalf("Failed to run migrations: %v", err)
     }
 
+    // Select where blob files are kept
+    if err := blobService.ConfigureStore(context.Background()); err != nil {
+        log.Fatalf("Failed to configure blob store: %v", err)
+    }
+
+    // Load the key of encrypted blobs
+    if err := encryption.Configure(context.Background()); err != nil {
+        log.Fatalf("Failed to load encryption key: %v", err)
+    }
+
 	// Get or create default account
 	baseCtx := context.Background()
 	acc, err := account.GetOrCreateDefaultAccount(baseCtx)
@@ -52,6 +66,7 @@ func main() {
 
 	startServer(appCtx, &wg)
 	startMonitor(appCtx, &wg)
+	startCollector(appCtx, &wg)
 
 	waitForShutdown()
 
@@ -73,6 +88,15 @@ func startMonitor(ctx context.Context, wg *sync.WaitGroup) {
 	})
 }
 
+func startCollector(ctx context.Context, wg *sync.WaitGroup) {
+	wg.Go(func() {
+		gc.Start(ctx, gc.DEFAULT_INTERVAL)
+	})
+	wg.Go(func() {
+		repack.Start(ctx, repack.DEFAULT_INTERVAL)
+	})
+}
+
 func waitForShutdown() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
@@ -9,10 +9,12 @@ const (
 	BRANCH
 	FILE
 	TAG
+	MERGE
+	RENAME
 )
 
 func (ct ChangeType) ToString() string {
-	return [...]string{"INVALID", "ACCOUNT", "PROJECT", "BRANCH", "FILE", "TAG"}[ct]
+	return [...]string{"INVALID", "ACCOUNT", "PROJECT", "BRANCH", "FILE", "TAG", "MERGE", "RENAME"}[ct]
 }
 
 func FromString(s string) ChangeType {
@@ -27,6 +29,10 @@ func FromString(s string) ChangeType {
 		return FILE
 	case "TAG":
 		return TAG
+	case "MERGE":
+		return MERGE
+	case "RENAME":
+		return RENAME
 	default:
 		return INVALID
 	}
@@ -6,10 +6,11 @@ const (
     INVALID FileType = iota
     FILE
     SYMLINK
+    DIR
 )
 
 func (ft FileType) ToString() string {
-    return [...]string{"INVALID", "FILE", "SYMLINK"}[ft]
+    return [...]string{"INVALID", "FILE", "SYMLINK", "DIR"}[ft]
 }
 
 
@@ -19,6 +20,8 @@ func FromString(s string) FileType {
         return FILE
     case "SYMLINK":
         return SYMLINK
+    case "DIR":
+        return DIR
     default:
         return INVALID
     }
@@ -4,4 +4,32 @@ package keys
 const (
 	DEFAULT_ACCOUNT = "DEFAULT_ACCOUNT"
 	DEFAULT_PROJECT = "DEFAULT_PROJECT"
+
+	// Store new file versions as deltas against the previous one ("on"/"off", default on)
+	DELTA_STORAGE = "DELTA_STORAGE"
+	// Split large files into content-defined chunks ("on"/"off", default on)
+	CHUNKED_STORAGE = "CHUNKED_STORAGE"
+	// Record and restore the owner and group of files ("on"/"off", default off)
+	OWNER_TRACKING = "OWNER_TRACKING"
+	// Rewrite absolute symlink targets inside the project to relative ones ("on"/"off", default off)
+	RELATIVE_SYMLINKS = "RELATIVE_SYMLINKS"
+
+	// Where loose blob files and packs are kept ("local"/"s3", default local)
+	BLOB_STORE      = "BLOB_STORE"
+	BLOB_STORE_PATH = "BLOB_STORE_PATH"
+	S3_ENDPOINT     = "S3_ENDPOINT"
+	S3_BUCKET       = "S3_BUCKET"
+	S3_REGION       = "S3_REGION"
+	S3_PREFIX       = "S3_PREFIX"
+
+	// Encryption at rest of blob contents ("off"/"aes-gcm"/"xchacha20", default off)
+	ENCRYPTION         = "ENCRYPTION"
+	// Key source: a keyfile, or a passphrase with this salt (hex)
+	ENCRYPTION_KEYFILE = "ENCRYPTION_KEYFILE"
+	ENCRYPTION_SALT    = "ENCRYPTION_SALT"
+	// Fingerprint of the key, to refuse a wrong passphrase or keyfile
+	ENCRYPTION_KEY_ID  = "ENCRYPTION_KEY_ID"
+
+	// Per project keys, suffixed with ":<projectID>"
+	AUTOSNAPSHOT_POLICY = "AUTOSNAPSHOT_POLICY"
 )
\ No newline at end of file
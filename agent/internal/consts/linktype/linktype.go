@@ -0,0 +1,29 @@
+package linktype
+
+// LinkType tells where a symlink points relative to its project.
+type LinkType int
+
+const (
+    NONE              LinkType = iota // not a symlink, or not classified yet
+    INTERNAL_RELATIVE                 // relative target inside the project
+    INTERNAL_ABSOLUTE                 // absolute target inside the project
+    EXTERNAL                          // target outside the project
+)
+
+func (lt LinkType) ToString() string {
+    return [...]string{"NONE", "INTERNAL_RELATIVE", "INTERNAL_ABSOLUTE", "EXTERNAL"}[lt]
+}
+
+
+func FromString(s string) LinkType {
+    switch s {
+    case "INTERNAL_RELATIVE":
+        return INTERNAL_RELATIVE
+    case "INTERNAL_ABSOLUTE":
+        return INTERNAL_ABSOLUTE
+    case "EXTERNAL":
+        return EXTERNAL
+    default:
+        return NONE
+    }
+}
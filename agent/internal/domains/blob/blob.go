@@ -6,6 +6,21 @@
 // Storage strategy:
 //   - Small blobs (≤512KB): Stored in database Blob field, FilePath is empty
 //   - Large blobs (>512KB): Stored on filesystem, FilePath contains storage location
+//
+// A blob may be stored as a delta against another blob (BaseBlobID). Its
+// stored data is then the (optionally compressed) delta, not the content.
+// Large content may be split into chunk blobs; its blob is then a manifest
+// (IsManifest) listing the chunks in order.
+//
+// Small text blobs may be compressed with a project's trained dictionary
+// (DictID), which is then needed to decompress them.
+//
+// Repacking moves stored data into pack files: PackID, PackOffset and
+// PackLength then locate it, and both Blob and FilePath are empty.
+//
+// With encryption at rest the stored data is sealed with the key KeyID
+// names. The ID stays the hash of the plaintext content, so deduplication
+// works the same.
 package blob
 
 import (
@@ -30,6 +45,15 @@ const Domain = "Blob"
 //   - IsCompressed: Whether content is zstd compressed
 //   - IsBinary: Whether content is binary (detected by null bytes)
 //   - RefCounter: Number of files referencing this blob
+//   - Size: Length of the original content in bytes
+//   - BaseBlobID: Blob the stored data is a delta against (empty for full blobs)
+//   - DeltaDepth: Number of deltas to apply to reach the content (0 for full blobs)
+//   - IsManifest: Stored data lists chunk blobs instead of holding the content
+//   - PackID: Pack file holding the stored data (empty if not packed)
+//   - PackOffset: Offset of the blob's entry in the pack
+//   - PackLength: Length of the stored data in the pack
+//   - DictID: Compression dictionary the stored data needs (0 for none)
+//   - KeyID: Key the stored data is encrypted with (empty if not encrypted)
 type Blob struct {
 	domains.Meta
 	Blob         []byte
@@ -37,6 +61,15 @@ type Blob struct {
 	IsCompressed bool
 	IsBinary     bool
 	RefCounter   int
+	Size         int64
+	BaseBlobID   string
+	DeltaDepth   int
+	IsManifest   bool
+	PackID       string
+	PackOffset   int64
+	PackLength   int64
+	DictID       int
+	KeyID        string
 }
 
 func mapToStruct(data map[string]any) *Blob {
@@ -53,6 +86,15 @@ func mapToStruct(data map[string]any) *Blob {
 		IsCompressed: mapkit.GetBool(data, db.COL_ISCOMPRESSED),
 		IsBinary:     mapkit.GetBool(data, db.COL_ISBINARY),
 		RefCounter:   mapkit.GetInt(data, db.COL_REFCOUNTER),
+		Size:         mapkit.GetInt64(data, db.COL_SIZE),
+		BaseBlobID:   mapkit.GetString(data, db.COL_BASEBLOBID),
+		DeltaDepth:   mapkit.GetInt(data, db.COL_DELTADEPTH),
+		IsManifest:   mapkit.GetBool(data, db.COL_ISMANIFEST),
+		PackID:       mapkit.GetString(data, db.COL_PACKID),
+		PackOffset:   mapkit.GetInt64(data, db.COL_PACKOFFSET),
+		PackLength:   mapkit.GetInt64(data, db.COL_PACKLENGTH),
+		DictID:       mapkit.GetInt(data, db.COL_DICTID),
+		KeyID:        mapkit.GetString(data, db.COL_KEYID),
 	}
 }
 
@@ -64,9 +106,34 @@ func mapToStruct(data map[string]any) *Blob {
 //   - filePath: Storage path (empty if stored in DB)
 //   - isCompressed: Whether content is compressed
 //   - isBinary: Whether content is binary
+//   - size: Length of the original (uncompressed) content
+//   - baseBlobID: Delta base (empty if blob holds the full content)
+//   - deltaDepth: Delta chain length down to a full blob
+//   - dictID: Dictionary the content was compressed with (0 for none)
+//   - keyID: Key the stored data is encrypted with (empty for none)
 //
 // The blob is created with RefCounter=1.
-func New(ctx context.Context, id string, blob []byte, filePath string, isCompressed, isBinary bool) (*Blob, error) {
+func New(ctx context.Context, id string, blob []byte, filePath string, isCompressed, isBinary bool, size int64, baseBlobID string, deltaDepth, dictID int, keyID string) (*Blob, error) {
+	data := map[string]any{
+		db.COL_ID:           id,
+		db.COL_BLOB:         blob,
+		db.COL_FILEPATH:     filePath,
+		db.COL_ISCOMPRESSED: isCompressed,
+		db.COL_ISBINARY:     isBinary,
+		db.COL_REFCOUNTER:   1,
+		db.COL_SIZE:         size,
+		db.COL_BASEBLOBID:   baseBlobID,
+		db.COL_DELTADEPTH:   deltaDepth,
+		db.COL_DICTID:       dictID,
+		db.COL_KEYID:        keyID,
+	}
+	return create(ctx, data)
+}
+
+
+// NewManifest creates the blob of chunked content. Its stored data (blob or
+// filePath) is the chunk list; size is the length of the whole content.
+func NewManifest(ctx context.Context, id string, blob []byte, filePath string, isCompressed, isBinary bool, size int64, keyID string) (*Blob, error) {
 	data := map[string]any{
 		db.COL_ID:           id,
 		db.COL_BLOB:         blob,
@@ -74,7 +141,15 @@ func New(ctx context.Context, id string, blob []byte, filePath string, isCompres
 		db.COL_ISCOMPRESSED: isCompressed,
 		db.COL_ISBINARY:     isBinary,
 		db.COL_REFCOUNTER:   1,
+		db.COL_SIZE:         size,
+		db.COL_ISMANIFEST:   true,
+		db.COL_KEYID:        keyID,
 	}
+	return create(ctx, data)
+}
+
+
+func create(ctx context.Context, data map[string]any) (*Blob, error) {
 	result, err := db.Create(ctx, data)
 	if err != nil {
 		domains.LogError(Domain, "Creation", err)
@@ -111,6 +186,18 @@ func (b *Blob) updateRefCounter(ctx context.Context) error {
 	return err
 }
 
+// SetSize records the original content length, used to backfill older blobs.
+func (b *Blob) SetSize(ctx context.Context, size int64) error {
+	_, err := db.Update(ctx, b.ID, map[string]any{db.COL_SIZE: size})
+	if err != nil {
+		domains.LogError(Domain, "Size Update", err)
+		return err
+	}
+	b.Size = size
+	return nil
+}
+
+
 func GetByID(ctx context.Context, id string) (*Blob, error) {
 	data, err := db.GetByID(ctx, id)
 	if err != nil {
@@ -120,3 +207,159 @@ func GetByID(ctx context.Context, id string) (*Blob, error) {
 
 	return mapToStruct(data), nil
 }
+
+
+// Delete removes the blob record. Stored files on disk are left to the caller.
+func (b *Blob) Delete(ctx context.Context) error {
+	err := db.Delete(ctx, b.ID)
+	if err != nil {
+		domains.LogError(Domain, "Deletion", err)
+	}
+	return err
+}
+
+
+// SetRefCounter overwrites the reference counter, used to repair it from
+// the actual number of references.
+func (b *Blob) SetRefCounter(ctx context.Context, refCounter int) error {
+	b.RefCounter = refCounter
+	return b.updateRefCounter(ctx)
+}
+
+
+// IsDelta reports whether the stored data is a delta against BaseBlobID.
+func (b *Blob) IsDelta() bool {
+	return b.BaseBlobID != ""
+}
+
+
+// IsPacked reports whether the stored data lives in a pack file.
+func (b *Blob) IsPacked() bool {
+	return b.PackID != ""
+}
+
+
+// MoveToPack records that the stored data now lives in a pack and drops it
+// from the DB. A previous FilePath is left on disk for the caller to remove.
+func (b *Blob) MoveToPack(ctx context.Context, packID string, offset, length int64) error {
+	data := map[string]any{
+		db.COL_BLOB:       nil,
+		db.COL_FILEPATH:   "",
+		db.COL_PACKID:     packID,
+		db.COL_PACKOFFSET: offset,
+		db.COL_PACKLENGTH: length,
+	}
+	if _, err := db.Update(ctx, b.ID, data); err != nil {
+		domains.LogError(Domain, "Pack Update", err)
+		return err
+	}
+	b.Blob       = nil
+	b.FilePath   = ""
+	b.PackID     = packID
+	b.PackOffset = offset
+	b.PackLength = length
+	return nil
+}
+
+
+// Restore records newly written stored data, kept in the DB (blob) or in
+// the blob store (filePath), e.g. after it was encrypted or decrypted. A
+// previous file or pack entry is left for the caller to remove.
+func (b *Blob) Restore(ctx context.Context, blob []byte, filePath, keyID string) error {
+	data := map[string]any{
+		db.COL_BLOB:       blob,
+		db.COL_FILEPATH:   filePath,
+		db.COL_PACKID:     "",
+		db.COL_PACKOFFSET: 0,
+		db.COL_PACKLENGTH: 0,
+		db.COL_KEYID:      keyID,
+	}
+	if _, err := db.Update(ctx, b.ID, data); err != nil {
+		domains.LogError(Domain, "Storage Update", err)
+		return err
+	}
+	b.Blob       = blob
+	b.FilePath   = filePath
+	b.PackID     = ""
+	b.PackOffset = 0
+	b.PackLength = 0
+	b.KeyID      = keyID
+	return nil
+}
+
+
+// GetSize returns the original content length of a blob without loading it.
+func GetSize(ctx context.Context, id string) (int64, error) {
+	size, err := db.GetSize(ctx, id)
+	if err != nil {
+		domains.LogError(Domain, "Retrieval", err)
+	}
+	return size, err
+}
+
+
+func GetAll(ctx context.Context) ([]*Blob, error) {
+	rows, err := db.GetAll(ctx)
+	if err != nil {
+		domains.LogError(Domain, "Retrieval", err)
+		return nil, err
+	}
+
+	blobs := make([]*Blob, 0, len(rows))
+	for _, row := range rows {
+		blobs = append(blobs, mapToStruct(row))
+	}
+	return blobs, nil
+}
+
+
+// GetUnreferenced returns blobs whose RefCounter dropped to zero.
+func GetUnreferenced(ctx context.Context) ([]*Blob, error) {
+	rows, err := db.GetUnreferenced(ctx)
+	if err != nil {
+		domains.LogError(Domain, "Retrieval", err)
+		return nil, err
+	}
+
+	blobs := make([]*Blob, 0, len(rows))
+	for _, row := range rows {
+		blobs = append(blobs, mapToStruct(row))
+	}
+	return blobs, nil
+}
+
+
+// GetFilePaths returns the storage paths of all blobs kept on the filesystem.
+func GetFilePaths(ctx context.Context) ([]string, error) {
+	paths, err := db.GetFilePaths(ctx)
+	if err != nil {
+		domains.LogError(Domain, "Retrieval", err)
+	}
+	return paths, err
+}
+
+
+// GetAllMetadata returns all blobs without their content (Blob is nil).
+func GetAllMetadata(ctx context.Context) ([]*Blob, error) {
+	rows, err := db.GetAllMetadata(ctx)
+	if err != nil {
+		domains.LogError(Domain, "Retrieval", err)
+		return nil, err
+	}
+
+	blobs := make([]*Blob, 0, len(rows))
+	for _, row := range rows {
+		blobs = append(blobs, mapToStruct(row))
+	}
+	return blobs, nil
+}
+
+
+// GetPackIDs returns the packs that still hold at least one blob.
+func GetPackIDs(ctx context.Context) ([]string, error) {
+	ids, err := db.GetPackIDs(ctx)
+	if err != nil {
+		domains.LogError(Domain, "Retrieval", err)
+	}
+	return ids, err
+}
@@ -10,6 +10,13 @@ import (
 
 const Domain = "Branch"
 
+// Branch is a line of development in a project. Files are recorded per
+// branch; a branch created from another starts with copies of its file
+// records that point into the other branch's version chains.
+//
+// Fields:
+//   - Name: Unique within the project
+//   - ChangeID: Change the branch was created at (the project change for the first branch)
 type Branch struct {
 	domains.Meta
 	Name      string
@@ -73,3 +80,30 @@ func GetByID(ctx context.Context, id string) (*Branch, error) {
 
 	return mapToStruct(data), nil
 }
+
+
+// GetByProject returns the branches of a project, oldest first.
+func GetByProject(ctx context.Context, projectID string) ([]*Branch, error) {
+	rows, err := db.GetByProject(ctx, projectID)
+	if err != nil {
+		domains.LogError(Domain, "Retrieval", err)
+		return nil, err
+	}
+
+	branches := make([]*Branch, 0, len(rows))
+	for _, row := range rows {
+		branches = append(branches, mapToStruct(row))
+	}
+	return branches, nil
+}
+
+
+// GetByName returns the branch called name in a project.
+func GetByName(ctx context.Context, projectID, name string) (*Branch, error) {
+	data, err := db.GetByName(ctx, projectID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapToStruct(data), nil
+}
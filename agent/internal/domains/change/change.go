@@ -3,9 +3,11 @@ package change
 import (
 	"context"
 	"vcx/agent/internal/consts/changetype"
+	"vcx/agent/internal/consts/filetype"
 	"vcx/agent/internal/domains"
 	db "vcx/agent/internal/infra/db/store/change"
 	"vcx/agent/internal/session"
+	"vcx/pkg/toolkit/filekit"
 	"vcx/pkg/toolkit/mapkit"
 )
 
@@ -21,8 +23,18 @@ type Change struct {
 	ChangeBlob    []byte
 	ChangeIDPrev  string
 	ChangeIDNext  string
+	ChangeIDMerge string
 	Summary       string
 	Embedding     []byte
+	BlobID        string
+	Path          string
+	Target        string
+	IsDeleted     bool
+	IsConflicted  bool
+	Mode          uint32            // permission bits, 0 if unknown
+	MTime         int64             // modification time in Unix nanoseconds, 0 if unknown
+	Owner         string            // "uid:gid", empty unless owner tracking was on
+	FileType      filetype.FileType // INVALID for versions recorded before it was kept, see Type
 }
 
 func mapToStruct(data map[string]any) *Change {
@@ -34,16 +46,26 @@ func mapToStruct(data map[string]any) *Change {
 			LMD:          mapkit.GetString(data, db.COL_LMD),
 			GUID:         mapkit.GetString(data, db.COL_GUID),
 		},
-		AccountID:    mapkit.GetString(data, db.COL_ACCOUNTID),
-		FileID:       mapkit.GetString(data, db.COL_FILEID),
-		BranchID:     mapkit.GetString(data, db.COL_BRANCHID),
-		ProjectID:    mapkit.GetString(data, db.COL_PROJECTID),
-		ChangeType:   changetype.FromString( mapkit.GetString(data, db.COL_CHANGETYPE) ),
-		ChangeBlob:   mapkit.GetBytes(data,  db.COL_CHANGEBLOB),
-		ChangeIDPrev: mapkit.GetString(data, db.COL_CHANGEID_PREV),
-		ChangeIDNext: mapkit.GetString(data, db.COL_CHANGEID_NEXT),
-		Summary:      mapkit.GetString(data, db.COL_SUMMARY),
-		Embedding:    mapkit.GetBytes(data,  db.COL_EMBEDDING),
+		AccountID:     mapkit.GetString(data, db.COL_ACCOUNTID),
+		FileID:        mapkit.GetString(data, db.COL_FILEID),
+		BranchID:      mapkit.GetString(data, db.COL_BRANCHID),
+		ProjectID:     mapkit.GetString(data, db.COL_PROJECTID),
+		ChangeType:    changetype.FromString( mapkit.GetString(data, db.COL_CHANGETYPE) ),
+		ChangeBlob:    mapkit.GetBytes(data,  db.COL_CHANGEBLOB),
+		ChangeIDPrev:  mapkit.GetString(data, db.COL_CHANGEID_PREV),
+		ChangeIDNext:  mapkit.GetString(data, db.COL_CHANGEID_NEXT),
+		ChangeIDMerge: mapkit.GetString(data, db.COL_CHANGEID_MERGE),
+		Summary:       mapkit.GetString(data, db.COL_SUMMARY),
+		Embedding:     mapkit.GetBytes(data,  db.COL_EMBEDDING),
+		BlobID:        mapkit.GetString(data, db.COL_BLOBID),
+		Path:          mapkit.GetString(data, db.COL_PATH),
+		Target:        mapkit.GetString(data, db.COL_TARGET),
+		IsDeleted:     mapkit.GetBool(data,   db.COL_ISDELETED),
+		IsConflicted:  mapkit.GetBool(data,   db.COL_ISCONFLICTED),
+		Mode:          uint32(mapkit.GetInt64(data, db.COL_MODE)),
+		MTime:         mapkit.GetInt64(data, db.COL_MTIME),
+		Owner:         mapkit.GetString(data, db.COL_OWNER),
+		FileType:      filetype.FromString(mapkit.GetString(data, db.COL_FILETYPE)),
 	}
 }
 
@@ -53,6 +75,75 @@ func NewProject(ctx context.Context) (*Change, error) {
 }
 
 
+// NewFile records a version of a file on the branch and project in ctx.
+// The change captures the file state after the edit: the content blob, the
+// path and symlink target at that point, what kind of entry it was, its
+// attributes, and whether the file was deleted. prevID is the file's
+// previous change, empty for its first version.
+func NewFile(ctx context.Context, fileID, prevID string, fileType filetype.FileType, blobID, path, target string, attrs filekit.Attributes, isDeleted bool, summary string) (*Change, error) {
+	return newFileState(ctx, changetype.FILE, fileID, prevID, fileType, blobID, path, target, attrs, isDeleted, summary)
+}
+
+
+// NewRename records that a file moved to path on the branch and project in
+// ctx, like NewFile. The path before the move is that of the change prevID.
+func NewRename(ctx context.Context, fileID, prevID string, fileType filetype.FileType, blobID, path, target string, attrs filekit.Attributes, summary string) (*Change, error) {
+	return newFileState(ctx, changetype.RENAME, fileID, prevID, fileType, blobID, path, target, attrs, false, summary)
+}
+
+
+func newFileState(ctx context.Context, changeType changetype.ChangeType, fileID, prevID string, fileType filetype.FileType, blobID, path, target string, attrs filekit.Attributes, isDeleted bool, summary string) (*Change, error) {
+	data := map[string]any{
+		db.COL_ACCOUNTID:     session.GetAccountID(ctx),
+		db.COL_FILEID:        fileID,
+		db.COL_BRANCHID:      session.GetBranchID(ctx),
+		db.COL_PROJECTID:     session.GetProjectID(ctx),
+		db.COL_CHANGETYPE:    changeType.ToString(),
+		db.COL_CHANGEID_PREV: prevID,
+		db.COL_BLOBID:        blobID,
+		db.COL_PATH:          path,
+		db.COL_TARGET:        target,
+		db.COL_ISDELETED:     isDeleted,
+		db.COL_SUMMARY:       summary,
+		db.COL_MODE:          attrs.Mode,
+		db.COL_MTIME:         attrs.MTime,
+		db.COL_OWNER:         attrs.Owner,
+		db.COL_FILETYPE:      fileType.ToString(),
+	}
+	result, err := db.Create(ctx, data)
+	if err != nil {
+		domains.LogError(Domain, "Creation", err)
+		return nil, err
+	}
+
+	return mapToStruct(result), nil
+}
+
+
+// NewMerge records a merge on the branch and project in ctx. prevID is the
+// head of the branch merged into, mergeID the head of the branch merged;
+// changeBlob carries what the merge left unresolved.
+func NewMerge(ctx context.Context, prevID, mergeID, summary string, changeBlob []byte) (*Change, error) {
+	data := map[string]any{
+		db.COL_ACCOUNTID:      session.GetAccountID(ctx),
+		db.COL_BRANCHID:       session.GetBranchID(ctx),
+		db.COL_PROJECTID:      session.GetProjectID(ctx),
+		db.COL_CHANGETYPE:     changetype.MERGE.ToString(),
+		db.COL_CHANGEBLOB:     changeBlob,
+		db.COL_CHANGEID_PREV:  prevID,
+		db.COL_CHANGEID_MERGE: mergeID,
+		db.COL_SUMMARY:        summary,
+	}
+	result, err := db.Create(ctx, data)
+	if err != nil {
+		domains.LogError(Domain, "Creation", err)
+		return nil, err
+	}
+
+	return mapToStruct(result), nil
+}
+
+
 func New(ctx context.Context, accountID, fileID, branchID, projectID string, _changeType changetype.ChangeType, changeBlob []byte) (*Change, error) {
 	data := map[string]any{
 		db.COL_ACCOUNTID:  accountID,
@@ -74,16 +165,26 @@ func New(ctx context.Context, accountID, fileID, branchID, projectID string, _ch
 
 func (c *Change) Update(ctx context.Context) error {
 	data := map[string]any{
-		db.COL_ACCOUNTID:     c.AccountID,
-		db.COL_FILEID:        c.FileID,
-		db.COL_BRANCHID:      c.BranchID,
-		db.COL_PROJECTID:     c.ProjectID,
-		db.COL_CHANGETYPE:    c.ChangeType.ToString(),
-		db.COL_CHANGEBLOB:    c.ChangeBlob,
-		db.COL_CHANGEID_PREV: c.ChangeIDPrev,
-		db.COL_CHANGEID_NEXT: c.ChangeIDNext,
-		db.COL_SUMMARY:       c.Summary,
-		db.COL_EMBEDDING:     c.Embedding,
+		db.COL_ACCOUNTID:      c.AccountID,
+		db.COL_FILEID:         c.FileID,
+		db.COL_BRANCHID:       c.BranchID,
+		db.COL_PROJECTID:      c.ProjectID,
+		db.COL_CHANGETYPE:     c.ChangeType.ToString(),
+		db.COL_CHANGEBLOB:     c.ChangeBlob,
+		db.COL_CHANGEID_PREV:  c.ChangeIDPrev,
+		db.COL_CHANGEID_NEXT:  c.ChangeIDNext,
+		db.COL_CHANGEID_MERGE: c.ChangeIDMerge,
+		db.COL_SUMMARY:        c.Summary,
+		db.COL_EMBEDDING:      c.Embedding,
+		db.COL_BLOBID:         c.BlobID,
+		db.COL_PATH:           c.Path,
+		db.COL_TARGET:         c.Target,
+		db.COL_ISDELETED:      c.IsDeleted,
+		db.COL_ISCONFLICTED:   c.IsConflicted,
+		db.COL_MODE:           c.Mode,
+		db.COL_MTIME:          c.MTime,
+		db.COL_OWNER:          c.Owner,
+		db.COL_FILETYPE:       c.FileType.ToString(),
 	}
 	_, err := db.Update(ctx, c.ID, data)
 	if err != nil {
@@ -93,6 +194,51 @@ func (c *Change) Update(ctx context.Context) error {
 	return err
 }
 
+// Attributes returns the file attributes recorded with the version.
+func (c *Change) Attributes() filekit.Attributes {
+	return filekit.Attributes{Mode: c.Mode, MTime: c.MTime, Owner: c.Owner}
+}
+
+
+// Type returns what kind of entry the version is. Versions recorded before
+// the type was kept hold no directories: a target without a blob is a
+// symlink, anything else a file.
+func (c *Change) Type() filetype.FileType {
+	if c.FileType != filetype.INVALID {
+		return c.FileType
+	}
+	if c.BlobID == "" && c.Target != "" {
+		return filetype.SYMLINK
+	}
+	return filetype.FILE
+}
+
+
+// SetNext points the change's ChangeIDNext at nextID.
+func (c *Change) SetNext(ctx context.Context, nextID string) error {
+	_, err := db.Update(ctx, c.ID, map[string]any{db.COL_CHANGEID_NEXT: nextID})
+	if err != nil {
+		domains.LogError(Domain, "Update", err)
+		return err
+	}
+	c.ChangeIDNext = nextID
+	return nil
+}
+
+
+// MarkConflicted flags the change as a version recorded while its path had
+// an unresolved conflict.
+func (c *Change) MarkConflicted(ctx context.Context) error {
+	_, err := db.Update(ctx, c.ID, map[string]any{db.COL_ISCONFLICTED: true})
+	if err != nil {
+		domains.LogError(Domain, "Update", err)
+		return err
+	}
+	c.IsConflicted = true
+	return nil
+}
+
+
 func GetByID(ctx context.Context, id string) (*Change, error) {
 	data, err := db.GetByID(ctx, id)
 	if err != nil {
@@ -102,3 +248,57 @@ func GetByID(ctx context.Context, id string) (*Change, error) {
 
 	return mapToStruct(data), nil
 }
+
+
+// GetWithBlob returns every change that references a blob.
+func GetWithBlob(ctx context.Context) ([]*Change, error) {
+	rows, err := db.GetWithBlob(ctx)
+	if err != nil {
+		domains.LogError(Domain, "Retrieval", err)
+		return nil, err
+	}
+
+	changes := make([]*Change, 0, len(rows))
+	for _, row := range rows {
+		changes = append(changes, mapToStruct(row))
+	}
+	return changes, nil
+}
+
+
+// GetProjectBlobIDs returns the blobs recorded in a project, most recently
+// recorded first.
+func GetProjectBlobIDs(ctx context.Context, projectID string) ([]string, error) {
+	ids, err := db.GetProjectBlobIDs(ctx, projectID)
+	if err != nil {
+		domains.LogError(Domain, "Retrieval", err)
+	}
+	return ids, err
+}
+
+
+// GetLatestOnBranch returns the newest change recorded on a branch.
+func GetLatestOnBranch(ctx context.Context, branchID string) (*Change, error) {
+	data, err := db.GetLatestOnBranch(ctx, branchID)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapToStruct(data), nil
+}
+
+
+// GetMerges returns the merges recorded on a branch, oldest first.
+func GetMerges(ctx context.Context, branchID string) ([]*Change, error) {
+	rows, err := db.GetByType(ctx, branchID, changetype.MERGE.ToString())
+	if err != nil {
+		domains.LogError(Domain, "Retrieval", err)
+		return nil, err
+	}
+
+	changes := make([]*Change, 0, len(rows))
+	for _, row := range rows {
+		changes = append(changes, mapToStruct(row))
+	}
+	return changes, nil
+}
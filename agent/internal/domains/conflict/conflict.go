@@ -0,0 +1,149 @@
+package conflict
+
+import (
+	"context"
+	"vcx/agent/internal/domains"
+	db "vcx/agent/internal/infra/db/store/conflict"
+	"vcx/agent/internal/session"
+	"vcx/pkg/toolkit/mapkit"
+)
+
+const Domain = "Conflict"
+
+
+// Side is one version of a conflicting path: the change that recorded it and
+// its content blob. Both are empty when the path is absent on that side; the
+// blob is empty for a deletion or a symlink.
+type Side struct {
+	ChangeID string `json:"changeID,omitempty"`
+	BlobID   string `json:"blobID,omitempty"`
+}
+
+
+// Conflict is a path on a branch with versions that could not be reconciled,
+// e.g. by a merge.
+//
+// Fields:
+//   - ChangeID: Change that produced the conflict (the merge)
+//   - Reason: Why the versions could not be reconciled
+//   - Base: Version both sides started from
+//   - Ours: Version on the branch when the conflict arose
+//   - Theirs: Version brought in
+//   - Resolution: Side picked or "manual", set once resolved
+type Conflict struct {
+	domains.Meta
+	ProjectID  string
+	BranchID   string
+	ChangeID   string
+	Path       string
+	Reason     string
+	Base       Side
+	Ours       Side
+	Theirs     Side
+	IsResolved bool
+	Resolution string
+}
+
+func mapToStruct(data map[string]any) *Conflict {
+	return &Conflict{
+		Meta: domains.Meta{
+			ID:           mapkit.GetString(data, db.COL_ID),
+			CreationDate: mapkit.GetString(data, db.COL_CREATIONDATE),
+			LMU:          mapkit.GetString(data, db.COL_LMU),
+			LMD:          mapkit.GetString(data, db.COL_LMD),
+			GUID:         mapkit.GetString(data, db.COL_GUID),
+		},
+		ProjectID:  mapkit.GetString(data, db.COL_PROJECTID),
+		BranchID:   mapkit.GetString(data, db.COL_BRANCHID),
+		ChangeID:   mapkit.GetString(data, db.COL_CHANGEID),
+		Path:       mapkit.GetString(data, db.COL_PATH),
+		Reason:     mapkit.GetString(data, db.COL_REASON),
+		Base:       Side{mapkit.GetString(data, db.COL_BASE_CHANGEID), mapkit.GetString(data, db.COL_BASE_BLOBID)},
+		Ours:       Side{mapkit.GetString(data, db.COL_OURS_CHANGEID), mapkit.GetString(data, db.COL_OURS_BLOBID)},
+		Theirs:     Side{mapkit.GetString(data, db.COL_THEIRS_CHANGEID), mapkit.GetString(data, db.COL_THEIRS_BLOBID)},
+		IsResolved: mapkit.GetBool(data,   db.COL_ISRESOLVED),
+		Resolution: mapkit.GetString(data, db.COL_RESOLUTION),
+	}
+}
+
+
+// New records an unresolved conflict of path on the branch, project and
+// change in ctx.
+func New(ctx context.Context, path, reason string, base, ours, theirs Side) (*Conflict, error) {
+	data := map[string]any{
+		db.COL_PROJECTID:       session.GetProjectID(ctx),
+		db.COL_BRANCHID:        session.GetBranchID(ctx),
+		db.COL_CHANGEID:        session.GetChangeID(ctx),
+		db.COL_PATH:            path,
+		db.COL_REASON:          reason,
+		db.COL_BASE_CHANGEID:   base.ChangeID,
+		db.COL_BASE_BLOBID:     base.BlobID,
+		db.COL_OURS_CHANGEID:   ours.ChangeID,
+		db.COL_OURS_BLOBID:     ours.BlobID,
+		db.COL_THEIRS_CHANGEID: theirs.ChangeID,
+		db.COL_THEIRS_BLOBID:   theirs.BlobID,
+		db.COL_ISRESOLVED:      false,
+	}
+	result, err := db.Create(ctx, data)
+	if err != nil {
+		domains.LogError(Domain, "Creation", err)
+		return nil, err
+	}
+
+	return mapToStruct(result), nil
+}
+
+
+// Resolve marks the conflict resolved with resolution.
+func (c *Conflict) Resolve(ctx context.Context, resolution string) error {
+	data := map[string]any{
+		db.COL_ISRESOLVED: true,
+		db.COL_RESOLUTION: resolution,
+	}
+	_, err := db.Update(ctx, c.ID, data)
+	if err != nil {
+		domains.LogError(Domain, "Update", err)
+		return err
+	}
+	c.IsResolved = true
+	c.Resolution = resolution
+	return nil
+}
+
+
+func GetByID(ctx context.Context, id string) (*Conflict, error) {
+	data, err := db.GetByID(ctx, id)
+	if err != nil {
+		domains.LogError(Domain, "Retrieval", err)
+		return nil, err
+	}
+
+	return mapToStruct(data), nil
+}
+
+
+// GetOpen returns the unresolved conflicts of a branch ordered by path.
+func GetOpen(ctx context.Context, branchID string) ([]*Conflict, error) {
+	rows, err := db.GetOpen(ctx, branchID)
+	if err != nil {
+		domains.LogError(Domain, "Retrieval", err)
+		return nil, err
+	}
+
+	conflicts := make([]*Conflict, 0, len(rows))
+	for _, row := range rows {
+		conflicts = append(conflicts, mapToStruct(row))
+	}
+	return conflicts, nil
+}
+
+
+// GetOpenByPath returns the unresolved conflict of a path on a branch.
+func GetOpenByPath(ctx context.Context, branchID, path string) (*Conflict, error) {
+	data, err := db.GetOpenByPath(ctx, branchID, path)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapToStruct(data), nil
+}
@@ -0,0 +1,142 @@
+// Package dictionary provides domain models for trained compression
+// dictionaries.
+//
+// A dictionary is trained per project from a sample of its text blobs and
+// identified by the zstd dictionary ID (DictID) written into every frame it
+// compresses. Blobs record that ID; dictionaries are kept as long as the
+// database, since any blob may still need one to be read. Like blobs, the
+// data is sealed when encryption at rest is on.
+package dictionary
+
+import (
+	"context"
+	"vcx/agent/internal/domains"
+	db "vcx/agent/internal/infra/db/store/dictionary"
+	"vcx/pkg/toolkit/mapkit"
+)
+
+
+const Domain = "Dictionary"
+
+
+// Dictionary is a trained zstd dictionary.
+//
+// Fields:
+//   - ProjectID: Project whose blobs it was trained on
+//   - DictID: zstd dictionary ID, recorded on the blobs using it
+//   - Data: The dictionary in zstd format (nil when listed without data)
+//   - Samples: Number of blobs it was trained on
+//   - KeyID: Key the data is encrypted with (empty if stored in plain)
+type Dictionary struct {
+	domains.Meta
+	ProjectID string
+	DictID    int
+	Data      []byte
+	Samples   int
+	KeyID     string
+}
+
+func mapToStruct(data map[string]any) *Dictionary {
+	return &Dictionary{
+		Meta: domains.Meta{
+			ID:           mapkit.GetString(data, db.COL_ID),
+			CreationDate: mapkit.GetString(data, db.COL_CREATIONDATE),
+			LMU:          mapkit.GetString(data, db.COL_LMU),
+			LMD:          mapkit.GetString(data, db.COL_LMD),
+			GUID:         mapkit.GetString(data, db.COL_GUID),
+		},
+		ProjectID: mapkit.GetString(data, db.COL_PROJECTID),
+		DictID:    mapkit.GetInt(data, db.COL_DICTID),
+		Data:      mapkit.GetBytes(data, db.COL_DATA),
+		Samples:   mapkit.GetInt(data, db.COL_SAMPLES),
+		KeyID:     mapkit.GetString(data, db.COL_KEYID),
+	}
+}
+
+
+// New stores a dictionary trained for projectID.
+func New(ctx context.Context, projectID string, dictID int, data []byte, samples int, keyID string) (*Dictionary, error) {
+	values := map[string]any{
+		db.COL_PROJECTID: projectID,
+		db.COL_DICTID:    dictID,
+		db.COL_DATA:      data,
+		db.COL_SAMPLES:   samples,
+		db.COL_KEYID:     keyID,
+	}
+	result, err := db.Create(ctx, values)
+	if err != nil {
+		domains.LogError(Domain, "Creation", err)
+		return nil, err
+	}
+
+	return mapToStruct(result), nil
+}
+
+
+// GetAll returns every dictionary without its data.
+func GetAll(ctx context.Context) ([]*Dictionary, error) {
+	rows, err := db.GetAllMetadata(ctx)
+	if err != nil {
+		domains.LogError(Domain, "Retrieval", err)
+		return nil, err
+	}
+
+	dictionaries := make([]*Dictionary, len(rows))
+	for i, row := range rows {
+		dictionaries[i] = mapToStruct(row)
+	}
+	return dictionaries, nil
+}
+
+
+func GetByID(ctx context.Context, id string) (*Dictionary, error) {
+	data, err := db.GetByID(ctx, id)
+	if err != nil {
+		domains.LogError(Domain, "Retrieval", err)
+		return nil, err
+	}
+
+	return mapToStruct(data), nil
+}
+
+
+// GetByDictID returns the dictionary with a zstd dictionary ID.
+func GetByDictID(ctx context.Context, dictID int) (*Dictionary, error) {
+	data, err := db.GetByDictID(ctx, dictID)
+	if err != nil {
+		domains.LogError(Domain, "Retrieval", err)
+		return nil, err
+	}
+
+	return mapToStruct(data), nil
+}
+
+
+// GetLatestForProject returns the newest dictionary of a project without
+// its data, or an error if none was trained yet.
+func GetLatestForProject(ctx context.Context, projectID string) (*Dictionary, error) {
+	data, err := db.GetLatestForProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapToStruct(data), nil
+}
+
+
+// SetData replaces the stored data, e.g. when it is encrypted with another
+// key. The dictionary itself stays the same.
+func (d *Dictionary) SetData(ctx context.Context, data []byte, keyID string) error {
+	values := map[string]any{
+		db.COL_DATA:  data,
+		db.COL_KEYID: keyID,
+	}
+	if _, err := db.Update(ctx, d.ID, values); err != nil {
+		domains.LogError(Domain, "Update", err)
+		return err
+	}
+
+	d.Data  = data
+	d.KeyID = keyID
+	return nil
+}
@@ -2,7 +2,9 @@ package file
 
 import (
 	"context"
+	"time"
 	"vcx/agent/internal/consts/filetype"
+	"vcx/agent/internal/consts/linktype"
 	"vcx/agent/internal/domains"
 	db "vcx/agent/internal/infra/db/store/file"
 	"vcx/agent/internal/session"
@@ -20,6 +22,8 @@ type File struct {
 	BranchID  string
 	ChangeID  string
     IsDeleted bool
+	Mode      uint32            // permission bits of the current version, 0 if unknown
+	LinkType  linktype.LinkType // where a symlink points, as classified on ingest
 }
 
 func mapToStruct(data map[string]any) *File {
@@ -38,14 +42,17 @@ func mapToStruct(data map[string]any) *File {
 		BranchID:  mapkit.GetString(data, db.COL_BRANCHID),
 		ChangeID:  mapkit.GetString(data, db.COL_CHANGEID),
 		IsDeleted: mapkit.GetBool(data, db.COL_ISDELETED),
+		Mode:      uint32(mapkit.GetInt64(data, db.COL_MODE)),
+		LinkType:  linktype.FromString(mapkit.GetString(data, db.COL_LINKTYPE)),
 	}
 }
 
-func New(ctx context.Context, path, blobID string) (*File, error) {
+func New(ctx context.Context, path, blobID string, mode uint32) (*File, error) {
 	data := map[string]any{
 		db.COL_PATH:      path,
 		db.COL_TYPE:      filetype.FILE.ToString(),
 		db.COL_BLOBID:    blobID,
+		db.COL_MODE:      mode,
 		db.COL_BRANCHID:  session.GetBranchID(ctx),
 		db.COL_CHANGEID:  session.GetChangeID(ctx),
         db.COL_ISDELETED: false,
@@ -78,6 +85,26 @@ func NewSymlink(ctx context.Context, path, target string) (*File, error) {
 }
 
 
+// NewDir records a directory on the branch in ctx.
+func NewDir(ctx context.Context, path string, mode uint32) (*File, error) {
+	data := map[string]any{
+		db.COL_PATH:      path,
+		db.COL_TYPE:      filetype.DIR.ToString(),
+		db.COL_BRANCHID:  session.GetBranchID(ctx),
+		db.COL_CHANGEID:  session.GetChangeID(ctx),
+		db.COL_ISDELETED: false,
+		db.COL_MODE:      mode,
+	}
+	result, err := db.Create(ctx, data)
+	if err != nil {
+		domains.LogError(Domain, "Creation", err)
+		return nil, err
+	}
+
+	return mapToStruct(result), nil
+}
+
+
 func (f *File) Update(ctx context.Context) error {
 	data := map[string]any{
 		db.COL_PATH:      f.Path,
@@ -87,6 +114,8 @@ func (f *File) Update(ctx context.Context) error {
 		db.COL_BRANCHID:  f.BranchID,
 		db.COL_CHANGEID:  f.ChangeID,
 		db.COL_ISDELETED: f.IsDeleted,
+		db.COL_MODE:      f.Mode,
+		db.COL_LINKTYPE:  f.LinkType.ToString(),
 	}
 	_, err := db.Update(ctx, f.ID, data)
 	if err != nil {
@@ -96,6 +125,18 @@ func (f *File) Update(ctx context.Context) error {
 	return err
 }
 
+// SetLinkType stores how the file's symlink target was classified.
+func (f *File) SetLinkType(ctx context.Context, linkType linktype.LinkType) error {
+	_, err := db.Update(ctx, f.ID, map[string]any{db.COL_LINKTYPE: linkType.ToString()})
+	if err != nil {
+		domains.LogError(Domain, "Update", err)
+		return err
+	}
+	f.LinkType = linkType
+	return nil
+}
+
+
 func GetByID(ctx context.Context, id string) (*File, error) {
 	data, err := db.GetByID(ctx, id)
 	if err != nil {
@@ -105,3 +146,113 @@ func GetByID(ctx context.Context, id string) (*File, error) {
 
 	return mapToStruct(data), nil
 }
+
+
+// GetByPath returns the file recorded at path on a branch, including deleted files.
+func GetByPath(ctx context.Context, branchID, path string) (*File, error) {
+	data, err := db.GetByPath(ctx, branchID, path)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapToStruct(data), nil
+}
+
+
+// GetByBranch returns every file recorded on a branch, including deleted files.
+func GetByBranch(ctx context.Context, branchID string) ([]*File, error) {
+	rows, err := db.GetByBranch(ctx, branchID)
+	if err != nil {
+		domains.LogError(Domain, "Retrieval", err)
+		return nil, err
+	}
+
+	files := make([]*File, 0, len(rows))
+	for _, row := range rows {
+		files = append(files, mapToStruct(row))
+	}
+	return files, nil
+}
+
+
+// GetCurrent returns the live files on a branch, the current tree. Deleted
+// files are left out but stay restorable through GetByPath.
+func GetCurrent(ctx context.Context, branchID string) ([]*File, error) {
+	rows, err := db.GetCurrentByBranch(ctx, branchID)
+	if err != nil {
+		domains.LogError(Domain, "Retrieval", err)
+		return nil, err
+	}
+
+	files := make([]*File, 0, len(rows))
+	for _, row := range rows {
+		files = append(files, mapToStruct(row))
+	}
+	return files, nil
+}
+
+
+// GetUnderPath returns the live files on a branch that sit below dirPath.
+func GetUnderPath(ctx context.Context, branchID, dirPath string) ([]*File, error) {
+	rows, err := db.GetUnderPath(ctx, branchID, dirPath)
+	if err != nil {
+		domains.LogError(Domain, "Retrieval", err)
+		return nil, err
+	}
+
+	files := make([]*File, 0, len(rows))
+	for _, row := range rows {
+		files = append(files, mapToStruct(row))
+	}
+	return files, nil
+}
+
+
+// GetWithBlob returns every file that references a blob.
+func GetWithBlob(ctx context.Context) ([]*File, error) {
+	rows, err := db.GetWithBlob(ctx)
+	if err != nil {
+		domains.LogError(Domain, "Retrieval", err)
+		return nil, err
+	}
+
+	files := make([]*File, 0, len(rows))
+	for _, row := range rows {
+		files = append(files, mapToStruct(row))
+	}
+	return files, nil
+}
+
+
+// GetByBlob returns the files on a branch whose content is blobID, including
+// deleted files.
+func GetByBlob(ctx context.Context, branchID, blobID string) ([]*File, error) {
+	rows, err := db.GetByBlob(ctx, branchID, blobID)
+	if err != nil {
+		domains.LogError(Domain, "Retrieval", err)
+		return nil, err
+	}
+
+	files := make([]*File, 0, len(rows))
+	for _, row := range rows {
+		files = append(files, mapToStruct(row))
+	}
+	return files, nil
+}
+
+
+// GetDeletedSince returns the files on a branch deleted at or after since,
+// most recently deleted first.
+func GetDeletedSince(ctx context.Context, branchID string, since time.Time) ([]*File, error) {
+	rows, err := db.GetDeletedSince(ctx, branchID, since.Format(time.RFC3339))
+	if err != nil {
+		domains.LogError(Domain, "Retrieval", err)
+		return nil, err
+	}
+
+	files := make([]*File, 0, len(rows))
+	for _, row := range rows {
+		files = append(files, mapToStruct(row))
+	}
+	return files, nil
+}
@@ -76,4 +76,19 @@ func GetByID(ctx context.Context, id string) (*Instance, error) {
 	}
 
 	return mapToStruct(data), nil
-}
\ No newline at end of file
+}
+
+
+func GetAll(ctx context.Context) ([]*Instance, error) {
+	rows, err := db.GetAll(ctx)
+	if err != nil {
+		domains.LogError(Domain, "Retrieval", err)
+		return nil, err
+	}
+
+	instances := make([]*Instance, 0, len(rows))
+	for _, row := range rows {
+		instances = append(instances, mapToStruct(row))
+	}
+	return instances, nil
+}
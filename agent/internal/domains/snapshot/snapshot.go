@@ -13,6 +13,16 @@ var log = logging.GetLogger()
 
 const Domain = "Snapshot"
 
+// Snapshot is what was last indexed of a file. Entries of an instance's
+// working copy form its stat cache: a path whose size, mtime and inode are
+// unchanged still has the content Hash and need not be read again.
+//
+// Fields:
+//   - InstanceID: Working copy the entry was indexed from
+//   - Path: Path relative to the instance root
+//   - MTime: Modification time in Unix nanoseconds
+//   - Inode: Inode number (0 where the platform has none)
+//   - LastIndexed: When the entry was indexed (RFC3339)
 type Snapshot struct {
 	domains.Meta
 	FileID      string
@@ -22,6 +32,10 @@ type Snapshot struct {
 	Size        int
 	Summary     string
 	LastIndexed string
+	InstanceID  string
+	Path        string
+	MTime       int64
+	Inode       int64
 }
 
 func mapToStruct(data map[string]any) *Snapshot {
@@ -40,6 +54,10 @@ func mapToStruct(data map[string]any) *Snapshot {
 		Size:        mapkit.GetInt(data,    db.COL_SIZE),
 		Summary:     mapkit.GetString(data, db.COL_SUMMARY),
 		LastIndexed: mapkit.GetString(data, db.COL_LASTINDEXED),
+		InstanceID:  mapkit.GetString(data, db.COL_INSTANCEID),
+		Path:        mapkit.GetString(data, db.COL_PATH),
+		MTime:       mapkit.GetInt64(data,  db.COL_MTIME),
+		Inode:       mapkit.GetInt64(data,  db.COL_INODE),
 	}
 }
 
@@ -60,6 +78,29 @@ func New(ctx context.Context, fileID, changeID, blobID, hash string, size int) (
 	return mapToStruct(result), nil
 }
 
+// NewStat caches the stat of path in the working copy of instanceID, which
+// holds content hash recorded as changeID of fileID.
+func NewStat(ctx context.Context, instanceID, path, fileID, changeID, hash string, size int, mtime, inode int64, lastIndexed string) (*Snapshot, error) {
+	data := map[string]any{
+		db.COL_INSTANCEID:  instanceID,
+		db.COL_PATH:        path,
+		db.COL_FILEID:      fileID,
+		db.COL_CHANGEID:    changeID,
+		db.COL_HASH:        hash,
+		db.COL_SIZE:        size,
+		db.COL_MTIME:       mtime,
+		db.COL_INODE:       inode,
+		db.COL_LASTINDEXED: lastIndexed,
+	}
+	result, err := db.Create(ctx, data)
+	if err != nil {
+		log.Error(fmt.Sprintf("%s Creation Failed: %v", Domain, err))
+		return nil, err
+	}
+
+	return mapToStruct(result), nil
+}
+
 func (s *Snapshot) Update(ctx context.Context) error {
 	data := map[string]any{
 		db.COL_FILEID:      s.FileID,
@@ -69,6 +110,10 @@ func (s *Snapshot) Update(ctx context.Context) error {
 		db.COL_SIZE:        s.Size,
 		db.COL_SUMMARY:     s.Summary,
 		db.COL_LASTINDEXED: s.LastIndexed,
+		db.COL_INSTANCEID:  s.InstanceID,
+		db.COL_PATH:        s.Path,
+		db.COL_MTIME:       s.MTime,
+		db.COL_INODE:       s.Inode,
 	}
 	_, err := db.Update(ctx, s.ID, data)
 	if err != nil {
@@ -87,3 +132,50 @@ func GetByID(ctx context.Context, id string) (*Snapshot, error) {
 
 	return mapToStruct(data), nil
 }
+
+
+// Available reports whether snapshots are stored in this database.
+func Available() bool {
+	return db.TableExists()
+}
+
+
+// GetWithBlob returns every snapshot that references a blob.
+func GetWithBlob(ctx context.Context) ([]*Snapshot, error) {
+	rows, err := db.GetWithBlob(ctx)
+	if err != nil {
+		log.Error(fmt.Sprintf("%s Retrieval Failed: %v", Domain, err))
+		return nil, err
+	}
+
+	snapshots := make([]*Snapshot, 0, len(rows))
+	for _, row := range rows {
+		snapshots = append(snapshots, mapToStruct(row))
+	}
+	return snapshots, nil
+}
+
+
+func (s *Snapshot) Delete(ctx context.Context) error {
+	err := db.Delete(ctx, s.ID)
+	if err != nil {
+		log.Error(fmt.Sprintf("%s Deletion Failed: %v", Domain, err))
+	}
+	return err
+}
+
+
+// GetByInstance returns the stat cache of an instance's working copy.
+func GetByInstance(ctx context.Context, instanceID string) ([]*Snapshot, error) {
+	rows, err := db.GetByInstance(ctx, instanceID)
+	if err != nil {
+		log.Error(fmt.Sprintf("%s Retrieval Failed: %v", Domain, err))
+		return nil, err
+	}
+
+	snapshots := make([]*Snapshot, 0, len(rows))
+	for _, row := range rows {
+		snapshots = append(snapshots, mapToStruct(row))
+	}
+	return snapshots, nil
+}
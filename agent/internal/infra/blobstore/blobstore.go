@@ -0,0 +1,141 @@
+// Package blobstore keeps stored blob data as objects under string keys.
+//
+// The blob service decides what to store (content, deltas, manifests,
+// packs) and records the keys on the blob rows; a BlobStore only keeps the
+// bytes. Keys are slash separated, e.g. "ab/cdef..." for a loose blob or
+// "packs/<id>.pack" for a pack.
+//
+// Implementations:
+//   - Local: a directory tree, by default dbsetup.BlobStorePath (or a NAS
+//     mount)
+//   - S3: a bucket on any S3-compatible object store
+//
+// Stores may implement RangeGetter and Importer to serve parts of objects
+// and take over local files without a copy; GetRange and PutFile fall back
+// to the basic methods otherwise.
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+
+var ErrNotFound = errors.New("object not found")
+
+
+// ObjectInfo describes a stored object.
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+
+// BlobStore keeps objects by key. Put replaces an object atomically:
+// readers see the old or the new content, never a partial one. Delete of
+// a missing object is not an error.
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Has(ctx context.Context, key string) (bool, error)
+	Delete(ctx context.Context, key string) error
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+}
+
+
+// RangeGetter is implemented by stores that can read part of an object.
+type RangeGetter interface {
+	GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+}
+
+
+// Importer is implemented by stores that can take over a local file, e.g.
+// by renaming it. The file is gone afterwards.
+type Importer interface {
+	Import(ctx context.Context, key, path string) error
+}
+
+
+// GetRange returns length bytes of an object starting at offset.
+func GetRange(ctx context.Context, store BlobStore, key string, offset, length int64) (io.ReadCloser, error) {
+	if ranged, ok := store.(RangeGetter); ok {
+		return ranged.GetRange(ctx, key, offset, length)
+	}
+
+	reader, err := store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.CopyN(io.Discard, reader, offset); err != nil {
+		reader.Close()
+		return nil, fmt.Errorf("failed to seek in %s: %w", key, err)
+	}
+	return &limitedReader{Reader: io.LimitReader(reader, length), closer: reader}, nil
+}
+
+
+// PutFile moves a local file into the store under key.
+func PutFile(ctx context.Context, store BlobStore, key, path string) error {
+	if importer, ok := store.(Importer); ok {
+		return importer.Import(ctx, key, path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if err := store.Put(ctx, key, file, info.Size()); err != nil {
+		return err
+	}
+	file.Close()
+	return os.Remove(path)
+}
+
+
+// Size returns the size of an object.
+func Size(ctx context.Context, store BlobStore, key string) (int64, error) {
+	objects, err := store.List(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	for _, object := range objects {
+		if object.Key == key {
+			return object.Size, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %s", ErrNotFound, key)
+}
+
+
+// checkKey refuses keys that are empty or would leave the store's root.
+func checkKey(key string) error {
+	clean := path.Clean(key)
+	if key == "" || path.IsAbs(key) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return fmt.Errorf("invalid object key %q", key)
+	}
+	return nil
+}
+
+
+// limitedReader closes the underlying object along with the limit.
+type limitedReader struct {
+	io.Reader
+	closer io.Closer
+}
+
+func (r *limitedReader) Close() error {
+	return r.closer.Close()
+}
@@ -0,0 +1,224 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+
+// fakeS3 is an in-memory stand-in for an S3-compatible server, covering
+// the requests the S3 store sends. Listings are paged in pages of two to
+// exercise continuation.
+type fakeS3 struct {
+	mu      sync.Mutex
+	bucket  string
+	objects map[string][]byte
+}
+
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=test-key/") ||
+		!strings.Contains(auth, "SignedHeaders=host;") || !strings.Contains(auth, "x-amz-date, Signature=") ||
+		r.Header.Get("X-Amz-Date") == "" {
+		http.Error(w, "<Error><Code>AccessDenied</Code></Error>", http.StatusForbidden)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/"+f.bucket+"/")
+	if path == r.URL.Path {
+		http.Error(w, "<Error><Code>NoSuchBucket</Code></Error>", http.StatusNotFound)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if path == "" && r.Method == http.MethodGet {
+		f.list(w, r)
+		return
+	}
+
+	data, ok := f.objects[path]
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		if int64(len(body)) != r.ContentLength {
+			http.Error(w, "length mismatch", http.StatusBadRequest)
+			return
+		}
+		f.objects[path] = body
+	case http.MethodDelete:
+		delete(f.objects, path)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodGet, http.MethodHead:
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+			return
+		}
+		var start, end int64 = 0, int64(len(data)) - 1
+		if rng := r.Header.Get("Range"); rng != "" {
+			fmt.Sscanf(rng, "bytes=%d-%d", &start, &end)
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+			w.WriteHeader(http.StatusPartialContent)
+		}
+		if r.Method == http.MethodGet {
+			w.Write(data[start : end+1])
+		}
+	}
+}
+
+
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	start, _ := strconv.Atoi(r.URL.Query().Get("continuation-token"))
+	end      := min(start+2, len(keys))
+
+	result := listBucketResult{}
+	for _, key := range keys[start:end] {
+		result.Contents = append(result.Contents, struct {
+			Key          string
+			Size         int64
+			LastModified time.Time
+		}{key, int64(len(f.objects[key])), time.Now()})
+	}
+	if end < len(keys) {
+		result.IsTruncated           = true
+		result.NextContinuationToken = strconv.Itoa(end)
+	}
+	xml.NewEncoder(w).Encode(result)
+}
+
+
+func newFakeS3(t *testing.T) *S3 {
+	t.Helper()
+	server := httptest.NewServer(&fakeS3{bucket: "blobs", objects: map[string][]byte{}})
+	t.Cleanup(server.Close)
+
+	store, err := NewS3(S3Config{Endpoint: server.URL, Bucket: "blobs", Prefix: "vcx/", AccessKey: "test-key", SecretKey: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+
+func TestStores(t *testing.T) {
+	stores := map[string]BlobStore{
+		"local": NewLocal(t.TempDir()),
+		"s3":    newFakeS3(t),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			testStore(t, store)
+		})
+	}
+}
+
+
+func testStore(t *testing.T, store BlobStore) {
+	ctx  := context.Background()
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+
+	if _, err := store.Get(ctx, "ab/missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if ok, err := store.Has(ctx, "ab/missing"); ok || err != nil {
+		t.Fatalf("missing object reported present: %v %v", ok, err)
+	}
+
+	keys := []string{"ab/one", "ab/two", "cd/three", "packs/p1.pack", "empty/e"}
+	for _, key := range keys {
+		content := data
+		if key == "empty/e" {
+			content = nil
+		}
+		if err := store.Put(ctx, key, bytes.NewReader(content), int64(len(content))); err != nil {
+			t.Fatalf("put %s: %v", key, err)
+		}
+	}
+
+	reader, err := store.Get(ctx, "ab/one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(reader)
+	reader.Close()
+	if !bytes.Equal(got, data) {
+		t.Fatal("object does not round-trip")
+	}
+
+	reader, err = GetRange(ctx, store, "packs/p1.pack", 1234, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ = io.ReadAll(reader)
+	reader.Close()
+	if !bytes.Equal(got, data[1234:1334]) {
+		t.Fatalf("range read returned %q", got)
+	}
+
+	objects, err := store.List(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != len(keys) {
+		t.Fatalf("expected %d objects, got %+v", len(keys), objects)
+	}
+	if objects, _ = store.List(ctx, "ab/"); len(objects) != 2 {
+		t.Fatalf("expected 2 objects below ab/, got %+v", objects)
+	}
+	if size, err := Size(ctx, store, "cd/three"); err != nil || size != int64(len(data)) {
+		t.Fatalf("wrong size %d: %v", size, err)
+	}
+
+	if err := store.Delete(ctx, "ab/one"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(ctx, "ab/one"); err != nil {
+		t.Fatalf("deleting a missing object failed: %v", err)
+	}
+	if ok, _ := store.Has(ctx, "ab/one"); ok {
+		t.Fatal("deleted object still present")
+	}
+
+	source := filepath.Join(t.TempDir(), "staged")
+	if err := os.WriteFile(source, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := PutFile(ctx, store, "ef/imported", source); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(source); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("staged file left behind")
+	}
+	if ok, _ := store.Has(ctx, "ef/imported"); !ok {
+		t.Fatal("imported object missing")
+	}
+
+	if err := store.Put(ctx, "../escape", bytes.NewReader(data), int64(len(data))); err == nil {
+		t.Fatal("expected a key leaving the store to be refused")
+	}
+}
@@ -0,0 +1,175 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"vcx/pkg/toolkit/filekit"
+)
+
+
+// Local keeps objects as files below a root directory, the key being the
+// slash separated path relative to it.
+type Local struct {
+	root string
+}
+
+
+func NewLocal(root string) *Local {
+	return &Local{root: root}
+}
+
+
+// Root returns the directory the objects are kept in.
+func (l *Local) Root() string {
+	return l.root
+}
+
+
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return filekit.WriteReaderAtomic(path, io.LimitReader(r, size), 0644)
+}
+
+
+func (l *Local) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	return file, err
+}
+
+
+func (l *Local) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	reader, err := l.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	file := reader.(*os.File)
+	return &limitedReader{Reader: io.NewSectionReader(file, offset, length), closer: file}, nil
+}
+
+
+func (l *Local) Has(ctx context.Context, key string) (bool, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+
+// List returns the objects whose key starts with prefix. Temp files of
+// writes in progress are left out.
+func (l *Local) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	// only the directory the prefix points into needs walking
+	start := l.root
+	if i := strings.LastIndex(prefix, "/"); i > 0 {
+		dir, err := l.path(prefix[:i])
+		if err != nil {
+			return nil, err
+		}
+		start = dir
+	}
+
+	var objects []ObjectInfo
+	err := filepath.WalkDir(start, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), filekit.TEMP_PREFIX) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(l.root, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		objects = append(objects, ObjectInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	return objects, err
+}
+
+
+// Import renames a local file into the store, copying it when it is on
+// another filesystem.
+func (l *Local) Import(ctx context.Context, key, source string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(source, path); err == nil {
+		return nil
+	}
+
+	file, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := filekit.WriteReaderAtomic(path, file, 0644); err != nil {
+		return err
+	}
+	file.Close()
+	return os.Remove(source)
+}
+
+
+// path maps a key to its file.
+func (l *Local) path(key string) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(l.root, filepath.FromSlash(path.Clean(key))), nil
+}
@@ -0,0 +1,351 @@
+package blobstore
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+
+const (
+	S3_DEFAULT_REGION = "us-east-1"
+	S3_LIST_PAGE_SIZE = 1000
+
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+	amzDateFormat   = "20060102T150405Z"
+)
+
+
+// S3Config locates a bucket on an S3-compatible object store (AWS, MinIO,
+// Ceph, Garage, ...). Objects are kept under Prefix + key; the prefix must
+// not be shared, since the garbage collector removes objects no blob points
+// to.
+type S3Config struct {
+	Endpoint     string // e.g. https://s3.eu-west-1.amazonaws.com or http://localhost:9000
+	Bucket       string
+	Region       string
+	Prefix       string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+}
+
+
+// S3 keeps objects in an S3 bucket. Requests use path-style addressing and
+// are signed with AWS Signature Version 4, which every compatible server
+// accepts; payloads are sent unsigned so uploads can stream.
+type S3 struct {
+	config   S3Config
+	endpoint *url.URL
+	client   *http.Client
+	now      func() time.Time
+}
+
+
+func NewS3(config S3Config) (*S3, error) {
+	if config.Endpoint == "" || config.Bucket == "" {
+		return nil, fmt.Errorf("S3 endpoint and bucket are required")
+	}
+	endpoint, err := url.Parse(strings.TrimSuffix(config.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", config.Endpoint)
+	}
+	if config.Region == "" {
+		config.Region = S3_DEFAULT_REGION
+	}
+	return &S3{config: config, endpoint: endpoint, client: http.DefaultClient, now: time.Now}, nil
+}
+
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	request, err := s.request(ctx, http.MethodPut, key, nil, io.LimitReader(r, size))
+	if err != nil {
+		return err
+	}
+	request.ContentLength = size
+	if size == 0 {
+		request.Body = http.NoBody
+	}
+
+	response, err := s.do(request, key)
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
+
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	request, err := s.request(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := s.do(request, key)
+	if err != nil {
+		return nil, err
+	}
+	return response.Body, nil
+}
+
+
+func (s *S3) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	if length <= 0 {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+	request, err := s.request(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	s.sign(request)
+
+	response, err := s.do(request, key)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusPartialContent {
+		// the server ignored the range and sent the whole object
+		if _, err := io.CopyN(io.Discard, response.Body, offset); err != nil {
+			response.Body.Close()
+			return nil, fmt.Errorf("failed to seek in %s: %w", key, err)
+		}
+	}
+	return &limitedReader{Reader: io.LimitReader(response.Body, length), closer: response.Body}, nil
+}
+
+
+func (s *S3) Has(ctx context.Context, key string) (bool, error) {
+	request, err := s.request(ctx, http.MethodHead, key, nil, nil)
+	if err != nil {
+		return false, err
+	}
+	response, err := s.client.Do(request)
+	if err != nil {
+		return false, err
+	}
+	response.Body.Close()
+
+	switch {
+	case response.StatusCode == http.StatusNotFound:
+		return false, nil
+	case response.StatusCode/100 != 2:
+		return false, fmt.Errorf("S3 HEAD %s: %s", key, response.Status)
+	}
+	return true, nil
+}
+
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	request, err := s.request(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	response, err := s.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode/100 != 2 && response.StatusCode != http.StatusNotFound {
+		return s.responseError(response, key)
+	}
+	return nil
+}
+
+
+// List pages through ListObjectsV2 for every object below prefix.
+func (s *S3) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("max-keys", strconv.Itoa(S3_LIST_PAGE_SIZE))
+		query.Set("prefix", s.config.Prefix+prefix)
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		request, err := s.request(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		response, err := s.do(request, "")
+		if err != nil {
+			return nil, err
+		}
+
+		var page listBucketResult
+		err = xml.NewDecoder(response.Body).Decode(&page)
+		response.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse S3 listing: %w", err)
+		}
+
+		for _, object := range page.Contents {
+			objects = append(objects, ObjectInfo{
+				Key:     strings.TrimPrefix(object.Key, s.config.Prefix),
+				Size:    object.Size,
+				ModTime: object.LastModified,
+			})
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+
+// listBucketResult is the part of a ListObjectsV2 response List uses.
+type listBucketResult struct {
+	IsTruncated           bool
+	NextContinuationToken string
+	Contents              []struct {
+		Key          string
+		Size         int64
+		LastModified time.Time
+	}
+}
+
+
+// request builds a signed request for an object, or for the bucket when
+// key is empty.
+func (s *S3) request(ctx context.Context, method, key string, query url.Values, body io.Reader) (*http.Request, error) {
+	if key != "" {
+		if err := checkKey(key); err != nil {
+			return nil, err
+		}
+	}
+
+	target := *s.endpoint
+	target.Path = s.endpoint.Path + "/" + s.config.Bucket + "/"
+	if key != "" {
+		target.Path += s.config.Prefix + key
+	}
+	target.RawPath = ""
+	target.RawQuery = canonicalQuery(query)
+
+	request, err := http.NewRequestWithContext(ctx, method, target.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	s.sign(request)
+	return request, nil
+}
+
+
+// do sends a request and turns non-2xx responses into errors.
+func (s *S3) do(request *http.Request, key string) (*http.Response, error) {
+	response, err := s.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode/100 != 2 {
+		defer response.Body.Close()
+		return nil, s.responseError(response, key)
+	}
+	return response, nil
+}
+
+
+func (s *S3) responseError(response *http.Response, key string) error {
+	var body struct {
+		Code    string
+		Message string
+	}
+	xml.NewDecoder(io.LimitReader(response.Body, 64*1024)).Decode(&body)
+
+	if response.StatusCode == http.StatusNotFound && body.Code != "NoSuchBucket" {
+		return fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	if body.Code != "" {
+		return fmt.Errorf("S3 %s %s: %s: %s", response.Request.Method, key, body.Code, body.Message)
+	}
+	return fmt.Errorf("S3 %s %s: %s", response.Request.Method, key, response.Status)
+}
+
+
+// sign adds an AWS Signature Version 4 Authorization header covering the
+// host, the x-amz-* headers and Range.
+func (s *S3) sign(request *http.Request) {
+	now  := s.now().UTC()
+	date := now.Format(amzDateFormat)
+	day  := date[:8]
+
+	request.Header.Set("X-Amz-Date", date)
+	request.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+	if s.config.SessionToken != "" {
+		request.Header.Set("X-Amz-Security-Token", s.config.SessionToken)
+	}
+
+	headers := map[string]string{"host": request.URL.Host}
+	for name, values := range request.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "range" {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		request.URL.EscapedPath(),
+		request.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		request.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+
+	scope        := day + "/" + s.config.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + date + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretKey), day)
+	key  = hmacSHA256(key, s.config.Region)
+	key  = hmacSHA256(key, "s3")
+	key  = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.config.AccessKey, scope, signedHeaders, signature))
+}
+
+
+// canonicalQuery encodes a query the way SigV4 expects: sorted by key,
+// spaces as %20.
+func canonicalQuery(query url.Values) string {
+	return strings.ReplaceAll(query.Encode(), "+", "%20")
+}
+
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
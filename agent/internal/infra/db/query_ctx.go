@@ -45,7 +45,7 @@ func SelectWithContext(ctx context.Context, tableName string, columns []string,
 	}
 	log.Debug(sqlStmt, "args", fmt.Sprintf("%v", finalArgs))
 
-	rows, err := db.QueryContext(ctx, sqlStmt, finalArgs...)
+	rows, err := conn(ctx).QueryContext(ctx, sqlStmt, finalArgs...)
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %v", err)
 	}
@@ -181,3 +181,32 @@ func GetAsIntWithContext(ctx context.Context, tableName, column string, conditio
 func GetAsFloatWithContext(ctx context.Context, tableName, column string, conditions map[string]any) (float64, error) {
 	return getColumnValueWithContext[float64](ctx, tableName, column, conditions)
 }
+
+// QueryWithContext executes a raw SELECT statement and returns the rows as a slice of maps.
+// Use it for queries that need more than equality conditions (ranges, ordering, limits, joins).
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout control
+//   - sqlStmt: Complete SQL statement with ? placeholders
+//   - args: Values for the placeholders
+//
+// Returns:
+//   - []map[string]any: Slice of maps, each containing a row's data
+//   - error: Non-nil if any error occurs during query execution or row scanning
+//
+// Example:
+//   ctx := context.Background()
+//   results, err := QueryWithContext(ctx,
+//       "SELECT * FROM change WHERE fileID = ? ORDER BY creation_date DESC LIMIT ?",
+//       fileID, 10)
+func QueryWithContext(ctx context.Context, sqlStmt string, args ...any) ([]map[string]any, error) {
+	log.Debug(sqlStmt, "args", fmt.Sprintf("%v", args))
+
+	rows, err := conn(ctx).QueryContext(ctx, sqlStmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	return rowsAsMap(rows, []string{"*"})
+}
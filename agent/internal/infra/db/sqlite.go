@@ -51,3 +51,33 @@ func tableExists(tableName string) bool {
     err := db.QueryRow(query, tableName).Scan(&tableName)
     return err == nil
 }
+
+
+// TableExists reports whether tableName exists in the database.
+func TableExists(tableName string) bool {
+    return tableExists(tableName)
+}
+
+
+// ColumnExists reports whether tableName has a column named column.
+func ColumnExists(tableName, column string) bool {
+    if db == nil {
+        return false
+    }
+    rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", tableName))
+    if err != nil {
+        return false
+    }
+    defer rows.Close()
+
+    results, err := rowsAsMap(rows, []string{"*"})
+    if err != nil {
+        return false
+    }
+    for _, row := range results {
+        if name, ok := row["name"].(string); ok && name == column {
+            return true
+        }
+    }
+    return false
+}
@@ -2,6 +2,7 @@ package blob
 
 import (
 	"context"
+	"fmt"
 
 	"vcx/agent/internal/infra/db"
 	"vcx/agent/internal/infra/db/consts"
@@ -22,6 +23,15 @@ const /**Columns*/ (
     COL_ISCOMPRESSED = "isCompressed"
     COL_ISBINARY     = "isBinary"
     COL_REFCOUNTER   = "refCounter"
+    COL_SIZE         = "size"
+    COL_BASEBLOBID   = "baseBlobID"
+    COL_DELTADEPTH   = "deltaDepth"
+    COL_ISMANIFEST   = "isManifest"
+    COL_PACKID       = "packID"
+    COL_PACKOFFSET   = "packOffset"
+    COL_PACKLENGTH   = "packLength"
+    COL_DICTID       = "dictID"
+    COL_KEYID        = "keyID"
 )
 
 
@@ -31,6 +41,15 @@ var schema = map[string]string{
 	COL_ISCOMPRESSED: consts.TYPE_BOOL,
 	COL_ISBINARY:     consts.TYPE_BOOL,
 	COL_REFCOUNTER:   consts.TYPE_INT,
+	COL_SIZE:         consts.TYPE_INT,
+	COL_BASEBLOBID:   consts.TYPE_STRING,
+	COL_DELTADEPTH:   consts.TYPE_INT,
+	COL_ISMANIFEST:   consts.TYPE_BOOL,
+	COL_PACKID:       consts.TYPE_STRING,
+	COL_PACKOFFSET:   consts.TYPE_INT,
+	COL_PACKLENGTH:   consts.TYPE_INT,
+	COL_DICTID:       consts.TYPE_INT,
+	COL_KEYID:        consts.TYPE_STRING,
 }
 
 
@@ -52,3 +71,76 @@ func Update(ctx context.Context, id string, data map[string]any) (map[string]any
 func GetByID(ctx context.Context, id string) (map[string]any, error) {
     return store.GetByID(ctx, tableName, id)
 }
+
+
+// GetSize reads only the size column, without loading the content.
+func GetSize(ctx context.Context, id string) (int64, error) {
+    result, err := db.SelectOneWithContext(ctx, tableName, []string{COL_SIZE}, map[string]any{COL_ID: id})
+    if err != nil {
+        return 0, err
+    }
+    size, _ := result[COL_SIZE].(int64)
+    return size, nil
+}
+
+
+func GetAll(ctx context.Context) ([]map[string]any, error) {
+    return store.GetAll(ctx, tableName)
+}
+
+
+func Delete(ctx context.Context, id string) error {
+    return store.Delete(ctx, tableName, id)
+}
+
+
+// GetUnreferenced returns the blob rows no file version refers to anymore.
+func GetUnreferenced(ctx context.Context) ([]map[string]any, error) {
+    sqlStmt := fmt.Sprintf("SELECT * FROM %s WHERE COALESCE(%s, 0) <= 0", tableName, COL_REFCOUNTER)
+    return db.QueryWithContext(ctx, sqlStmt)
+}
+
+
+// GetFilePaths returns the storage path of every blob kept on the filesystem.
+func GetFilePaths(ctx context.Context) ([]string, error) {
+    sqlStmt := fmt.Sprintf("SELECT %s FROM %s WHERE COALESCE(%s, '') != ''", COL_FILEPATH, tableName, COL_FILEPATH)
+    rows, err := db.QueryWithContext(ctx, sqlStmt)
+    if err != nil {
+        return nil, err
+    }
+    paths := make([]string, 0, len(rows))
+    for _, row := range rows {
+        if path, ok := row[COL_FILEPATH].(string); ok {
+            paths = append(paths, path)
+        }
+    }
+    return paths, nil
+}
+
+
+// GetAllMetadata returns every blob row without the blob column, so no
+// content is loaded.
+func GetAllMetadata(ctx context.Context) ([]map[string]any, error) {
+    columns := []string{COL_ID, COL_CREATIONDATE, COL_LMU, COL_LMD, COL_GUID,
+                        COL_FILEPATH, COL_ISCOMPRESSED, COL_ISBINARY, COL_REFCOUNTER,
+                        COL_SIZE, COL_BASEBLOBID, COL_DELTADEPTH, COL_ISMANIFEST,
+                        COL_PACKID, COL_PACKOFFSET, COL_PACKLENGTH, COL_DICTID, COL_KEYID}
+    return db.SelectWithContext(ctx, tableName, columns, nil)
+}
+
+
+// GetPackIDs returns the distinct packs holding at least one blob.
+func GetPackIDs(ctx context.Context) ([]string, error) {
+    sqlStmt := fmt.Sprintf("SELECT DISTINCT %s FROM %s WHERE COALESCE(%s, '') != ''", COL_PACKID, tableName, COL_PACKID)
+    rows, err := db.QueryWithContext(ctx, sqlStmt)
+    if err != nil {
+        return nil, err
+    }
+    ids := make([]string, 0, len(rows))
+    for _, row := range rows {
+        if id, ok := row[COL_PACKID].(string); ok {
+            ids = append(ids, id)
+        }
+    }
+    return ids, nil
+}
@@ -2,6 +2,7 @@ package branch
 
 import (
 	"context"
+	"fmt"
 	"vcx/agent/internal/infra/db"
 	"vcx/agent/internal/infra/db/consts"
 	"vcx/agent/internal/infra/db/store"
@@ -47,3 +48,19 @@ func Update(ctx context.Context, id string, data map[string]any) (map[string]any
 func GetByID(ctx context.Context, id string) (map[string]any, error) {
     return store.GetByID(ctx, tableName, id)
 }
+
+
+// GetByProject returns the branches of a project, oldest first.
+func GetByProject(ctx context.Context, projectID string) ([]map[string]any, error) {
+    sqlStmt := fmt.Sprintf("SELECT * FROM %s WHERE %s = ? ORDER BY %s", tableName, COL_PROJECTID, COL_ID)
+    return db.QueryWithContext(ctx, sqlStmt, projectID)
+}
+
+
+// GetByName returns the branch called name in a project.
+func GetByName(ctx context.Context, projectID, name string) (map[string]any, error) {
+    return db.SelectOneWithContext(ctx, tableName, []string{"*"}, map[string]any{
+        COL_PROJECTID: projectID,
+        COL_NAME:      name,
+    })
+}
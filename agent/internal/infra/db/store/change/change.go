@@ -2,6 +2,7 @@ package change
 
 import (
 	"context"
+	"fmt"
 	"vcx/agent/internal/infra/db"
 	"vcx/agent/internal/infra/db/consts"
 	"vcx/agent/internal/infra/db/store"
@@ -9,36 +10,56 @@ import (
 
 const tableName = "change"
 const /**Columns*/ (
-    COL_ID            = consts.ID
-    COL_CREATIONDATE  = consts.CREATIONDATE
-    COL_LMU           = consts.LMU
-    COL_LMD           = consts.LMD
-    COL_GUID          = consts.GUID
-
-    COL_ACCOUNTID     = consts.ACCOUNTID
-    COL_FILEID        = consts.FILEID
-    COL_BRANCHID      = consts.BRANCHID
-    COL_PROJECTID     = consts.PROJECTID
-    COL_CHANGETYPE    = "changeType"
-    COL_CHANGEBLOB    = "changeBlob"
-    COL_CHANGEID_PREV = "changeID_prev"
-    COL_CHANGEID_NEXT = "changeID_next"
-    COL_SUMMARY       = "summary"
-    COL_EMBEDDING     = "embedding"
+    COL_ID             = consts.ID
+    COL_CREATIONDATE   = consts.CREATIONDATE
+    COL_LMU            = consts.LMU
+    COL_LMD            = consts.LMD
+    COL_GUID           = consts.GUID
+
+    COL_ACCOUNTID      = consts.ACCOUNTID
+    COL_FILEID         = consts.FILEID
+    COL_BRANCHID       = consts.BRANCHID
+    COL_PROJECTID      = consts.PROJECTID
+    COL_CHANGETYPE     = "changeType"
+    COL_CHANGEBLOB     = "changeBlob"
+    COL_CHANGEID_PREV  = "changeID_prev"
+    COL_CHANGEID_NEXT  = "changeID_next"
+    COL_CHANGEID_MERGE = "changeID_merge"
+    COL_SUMMARY        = "summary"
+    COL_EMBEDDING      = "embedding"
+    COL_BLOBID         = consts.BLOBID
+    COL_PATH           = consts.PATH
+    COL_TARGET         = consts.TARGET
+    COL_ISDELETED      = "isDeleted"
+    COL_ISCONFLICTED   = "isConflicted"
+    COL_MODE           = "mode"
+    COL_MTIME          = "mtime"
+    COL_OWNER          = "owner"
+    COL_FILETYPE       = "fileType"
 )
 
 
 var schema = map[string]string{
-    COL_ACCOUNTID:     consts.TYPE_FOREIGNKEY,
-    COL_FILEID:        consts.TYPE_FOREIGNKEY,
-    COL_BRANCHID:      consts.TYPE_FOREIGNKEY,
-    COL_PROJECTID:     consts.TYPE_FOREIGNKEY,
-    COL_CHANGETYPE:    consts.TYPE_ENUM,
-    COL_CHANGEBLOB:    consts.TYPE_BLOB,
-    COL_CHANGEID_PREV: consts.TYPE_FOREIGNKEY,
-    COL_CHANGEID_NEXT: consts.TYPE_FOREIGNKEY,
-    COL_SUMMARY:       consts.TYPE_STRING,
-    COL_EMBEDDING:     consts.TYPE_BLOB,
+    COL_ACCOUNTID:      consts.TYPE_FOREIGNKEY,
+    COL_FILEID:         consts.TYPE_FOREIGNKEY,
+    COL_BRANCHID:       consts.TYPE_FOREIGNKEY,
+    COL_PROJECTID:      consts.TYPE_FOREIGNKEY,
+    COL_CHANGETYPE:     consts.TYPE_ENUM,
+    COL_CHANGEBLOB:     consts.TYPE_BLOB,
+    COL_CHANGEID_PREV:  consts.TYPE_FOREIGNKEY,
+    COL_CHANGEID_NEXT:  consts.TYPE_FOREIGNKEY,
+    COL_CHANGEID_MERGE: consts.TYPE_FOREIGNKEY,
+    COL_SUMMARY:        consts.TYPE_STRING,
+    COL_EMBEDDING:      consts.TYPE_BLOB,
+    COL_BLOBID:         consts.TYPE_FOREIGNKEY,
+    COL_PATH:           consts.TYPE_STRING,
+    COL_TARGET:         consts.TYPE_STRING,
+    COL_ISDELETED:      consts.TYPE_BOOL,
+    COL_ISCONFLICTED:   consts.TYPE_BOOL,
+    COL_MODE:           consts.TYPE_INT,
+    COL_MTIME:          consts.TYPE_INT,
+    COL_OWNER:          consts.TYPE_STRING,
+    COL_FILETYPE:       consts.TYPE_STRING,
 }
 
 
@@ -62,3 +83,50 @@ func Update(ctx context.Context, id string, data map[string]any) (map[string]any
 func GetByID(ctx context.Context, id string) (map[string]any, error) {
     return store.GetByID(ctx, tableName, id)
 }
+
+
+// GetWithBlob returns the rows that reference a blob.
+func GetWithBlob(ctx context.Context) ([]map[string]any, error) {
+    sqlStmt := fmt.Sprintf("SELECT * FROM %s WHERE COALESCE(%s, '') != ''", tableName, COL_BLOBID)
+    return db.QueryWithContext(ctx, sqlStmt)
+}
+
+
+// GetProjectBlobIDs returns the distinct blobs recorded in a project, most
+// recently recorded first.
+func GetProjectBlobIDs(ctx context.Context, projectID string) ([]string, error) {
+    sqlStmt := fmt.Sprintf("SELECT %s FROM %s WHERE %s = ? AND COALESCE(%s, '') != '' GROUP BY %s ORDER BY MAX(%s) DESC",
+                           COL_BLOBID, tableName, COL_PROJECTID, COL_BLOBID, COL_BLOBID, COL_CREATIONDATE)
+    rows, err := db.QueryWithContext(ctx, sqlStmt, projectID)
+    if err != nil {
+        return nil, err
+    }
+    ids := make([]string, 0, len(rows))
+    for _, row := range rows {
+        if id, ok := row[COL_BLOBID].(string); ok {
+            ids = append(ids, id)
+        }
+    }
+    return ids, nil
+}
+
+
+// GetLatestOnBranch returns the newest change recorded on a branch.
+func GetLatestOnBranch(ctx context.Context, branchID string) (map[string]any, error) {
+    sqlStmt := fmt.Sprintf("SELECT * FROM %s WHERE %s = ? ORDER BY %s DESC LIMIT 1", tableName, COL_BRANCHID, COL_ID)
+    rows, err := db.QueryWithContext(ctx, sqlStmt, branchID)
+    if err != nil {
+        return nil, err
+    }
+    if len(rows) == 0 {
+        return nil, fmt.Errorf("no rows found")
+    }
+    return rows[0], nil
+}
+
+
+// GetByType returns the changes of one type recorded on a branch, oldest first.
+func GetByType(ctx context.Context, branchID, changeType string) ([]map[string]any, error) {
+    sqlStmt := fmt.Sprintf("SELECT * FROM %s WHERE %s = ? AND %s = ? ORDER BY %s", tableName, COL_BRANCHID, COL_CHANGETYPE, COL_ID)
+    return db.QueryWithContext(ctx, sqlStmt, branchID, changeType)
+}
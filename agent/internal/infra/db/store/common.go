@@ -50,3 +50,27 @@ func GetByID( ctx        context.Context,
 	}
 	return result, nil
 }
+
+
+func GetWhere( ctx        context.Context,
+               tableName  string,
+               conditions map[string]any,
+             ) ([]map[string]any, error) {
+	return db.SelectWithContext(ctx, tableName, []string{"*"}, conditions)
+}
+
+
+func GetAll( ctx        context.Context,
+             tableName  string,
+           ) ([]map[string]any, error) {
+	return GetWhere(ctx, tableName, nil)
+}
+
+
+func Delete( ctx        context.Context,
+             tableName  string,
+             id         string,
+           ) error {
+	_, err := db.DeleteWithContext(ctx, tableName, map[string]any{consts.ID: id})
+	return err
+}
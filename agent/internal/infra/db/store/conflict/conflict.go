@@ -0,0 +1,95 @@
+package conflict
+
+import (
+	"context"
+	"fmt"
+
+	"vcx/agent/internal/infra/db"
+	"vcx/agent/internal/infra/db/consts"
+	"vcx/agent/internal/infra/db/store"
+)
+
+
+const tableName = "conflict"
+const /**Columns*/ (
+    COL_ID              = consts.ID
+    COL_CREATIONDATE    = consts.CREATIONDATE
+    COL_LMU             = consts.LMU
+    COL_LMD             = consts.LMD
+    COL_GUID            = consts.GUID
+
+    COL_PROJECTID       = consts.PROJECTID
+    COL_BRANCHID        = consts.BRANCHID
+    COL_CHANGEID        = consts.CHANGEID
+    COL_PATH            = consts.PATH
+    COL_REASON          = "reason"
+    COL_BASE_BLOBID     = "baseBlobID"
+    COL_OURS_BLOBID     = "oursBlobID"
+    COL_THEIRS_BLOBID   = "theirsBlobID"
+    COL_BASE_CHANGEID   = "baseChangeID"
+    COL_OURS_CHANGEID   = "oursChangeID"
+    COL_THEIRS_CHANGEID = "theirsChangeID"
+    COL_ISRESOLVED      = "isResolved"
+    COL_RESOLUTION      = "resolution"
+)
+
+
+var schema = map[string]string{
+    COL_PROJECTID:       consts.TYPE_FOREIGNKEY,
+    COL_BRANCHID:        consts.TYPE_FOREIGNKEY,
+    COL_CHANGEID:        consts.TYPE_FOREIGNKEY,
+    COL_PATH:            consts.TYPE_STRING,
+    COL_REASON:          consts.TYPE_STRING,
+    COL_BASE_BLOBID:     consts.TYPE_FOREIGNKEY,
+    COL_OURS_BLOBID:     consts.TYPE_FOREIGNKEY,
+    COL_THEIRS_BLOBID:   consts.TYPE_FOREIGNKEY,
+    COL_BASE_CHANGEID:   consts.TYPE_FOREIGNKEY,
+    COL_OURS_CHANGEID:   consts.TYPE_FOREIGNKEY,
+    COL_THEIRS_CHANGEID: consts.TYPE_FOREIGNKEY,
+    COL_ISRESOLVED:      consts.TYPE_BOOL,
+    COL_RESOLUTION:      consts.TYPE_STRING,
+}
+
+
+func CreateTable()  {
+    db.CreateTable(tableName, schema)
+    db.CreateIndex("idx_conflict_branch_path", tableName, []string{COL_BRANCHID, COL_PATH})
+}
+
+
+func Create(ctx context.Context, data map[string]any,) (map[string]any, error) {
+    return store.Create(ctx, tableName, data, schema)
+}
+
+
+func Update(ctx context.Context, id string, data map[string]any) (map[string]any, error) {
+    return store.Update(ctx, tableName, id, data)
+}
+
+
+func GetByID(ctx context.Context, id string) (map[string]any, error) {
+    return store.GetByID(ctx, tableName, id)
+}
+
+
+// GetOpen returns the unresolved conflicts of a branch ordered by path.
+func GetOpen(ctx context.Context, branchID string) ([]map[string]any, error) {
+    sqlStmt := fmt.Sprintf("SELECT * FROM %s WHERE %s = ? AND COALESCE(%s, 0) = 0 ORDER BY %s",
+                           tableName, COL_BRANCHID, COL_ISRESOLVED, COL_PATH)
+    return db.QueryWithContext(ctx, sqlStmt, branchID)
+}
+
+
+// GetOpenByPath returns the unresolved conflict of a path on a branch.
+func GetOpenByPath(ctx context.Context, branchID, path string) (map[string]any, error) {
+    sqlStmt := fmt.Sprintf("SELECT * FROM %s WHERE %s = ? AND %s = ? AND COALESCE(%s, 0) = 0 ORDER BY %s DESC LIMIT 1",
+                           tableName, COL_BRANCHID, COL_PATH, COL_ISRESOLVED, COL_ID)
+    rows, err := db.QueryWithContext(ctx, sqlStmt, branchID, path)
+    if err != nil {
+        return nil, err
+    }
+    if len(rows) == 0 {
+        return nil, fmt.Errorf("no rows found")
+    }
+    return rows[0], nil
+}
@@ -0,0 +1,86 @@
+package dictionary
+
+import (
+	"context"
+	"fmt"
+
+	"vcx/agent/internal/infra/db"
+	"vcx/agent/internal/infra/db/consts"
+	"vcx/agent/internal/infra/db/store"
+)
+
+
+const tableName = "dictionary"
+const /**Columns*/ (
+    COL_ID           = consts.ID
+    COL_CREATIONDATE = consts.CREATIONDATE
+    COL_LMU          = consts.LMU
+    COL_LMD          = consts.LMD
+    COL_GUID         = consts.GUID
+
+    COL_PROJECTID    = consts.PROJECTID
+    COL_DICTID       = "dictID"
+    COL_DATA         = "data"
+    COL_SAMPLES      = "samples"
+    COL_KEYID        = "keyID"
+)
+
+
+var schema = map[string]string{
+    COL_PROJECTID: consts.TYPE_FOREIGNKEY,
+    COL_DICTID:    consts.TYPE_INT,
+    COL_DATA:      consts.TYPE_BLOB,
+    COL_SAMPLES:   consts.TYPE_INT,
+    COL_KEYID:     consts.TYPE_STRING,
+}
+
+
+func CreateTable()  {
+    db.CreateTable(tableName, schema)
+}
+
+
+func Create(ctx context.Context, data map[string]any,) (map[string]any, error) {
+    return store.Create(ctx, tableName, data, schema)
+}
+
+
+func Update(ctx context.Context, id string, data map[string]any) (map[string]any, error) {
+    return store.Update(ctx, tableName, id, data)
+}
+
+
+func GetByID(ctx context.Context, id string) (map[string]any, error) {
+    return store.GetByID(ctx, tableName, id)
+}
+
+
+// GetAllMetadata returns every dictionary row without the data column.
+func GetAllMetadata(ctx context.Context) ([]map[string]any, error) {
+    columns := []string{COL_ID, COL_CREATIONDATE, COL_LMU, COL_LMD, COL_GUID,
+                        COL_PROJECTID, COL_DICTID, COL_SAMPLES, COL_KEYID}
+    return db.SelectWithContext(ctx, tableName, columns, nil)
+}
+
+
+// GetByDictID returns the dictionary whose zstd frames carry dictID.
+func GetByDictID(ctx context.Context, dictID int) (map[string]any, error) {
+    return db.SelectOneWithContext(ctx, tableName, []string{"*"}, map[string]any{COL_DICTID: dictID})
+}
+
+
+// GetLatestForProject returns the newest dictionary trained for a project,
+// without its data.
+func GetLatestForProject(ctx context.Context, projectID string) (map[string]any, error) {
+    sqlStmt := fmt.Sprintf("SELECT %s, %s, %s, %s, %s, %s FROM %s WHERE %s = ? ORDER BY %s DESC LIMIT 1",
+                           COL_ID, COL_CREATIONDATE, COL_PROJECTID, COL_DICTID, COL_SAMPLES, COL_KEYID,
+                           tableName, COL_PROJECTID, COL_CREATIONDATE)
+    rows, err := db.QueryWithContext(ctx, sqlStmt, projectID)
+    if err != nil {
+        return nil, err
+    }
+    if len(rows) == 0 {
+        return nil, fmt.Errorf("no rows found")
+    }
+    return rows[0], nil
+}
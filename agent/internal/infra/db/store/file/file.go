@@ -2,6 +2,8 @@ package file
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"vcx/agent/internal/infra/db"
 	"vcx/agent/internal/infra/db/consts"
 	"vcx/agent/internal/infra/db/store"
@@ -22,6 +24,8 @@ const /*Columns*/ (
     COL_BRANCHID     = consts.BRANCHID
     COL_CHANGEID     = consts.CHANGEID
     COL_ISDELETED    = "isDeleted"
+    COL_MODE         = "mode"
+    COL_LINKTYPE     = "linkType"
 )
 
 
@@ -33,11 +37,22 @@ var schema = map[string]string{
     COL_BRANCHID:  consts.TYPE_FOREIGNKEY,
     COL_CHANGEID:  consts.TYPE_FOREIGNKEY,
     COL_ISDELETED: consts.TYPE_BOOL,
+    COL_MODE:      consts.TYPE_INT,
+    COL_LINKTYPE:  consts.TYPE_STRING,
 }
 
 
 func CreateTable()  {
     db.CreateTable(tableName, schema)
+    CreateIndexes()
+}
+
+
+func CreateIndexes() error {
+    if err := db.CreateIndex("idx_file_branch_path", tableName, []string{COL_BRANCHID, COL_PATH}); err != nil {
+        return err
+    }
+    return db.CreateIndex("idx_file_branch_blob", tableName, []string{COL_BRANCHID, COL_BLOBID})
 }
 
 
@@ -54,3 +69,64 @@ func Update(ctx context.Context, id string, data map[string]any) (map[string]any
 func GetByID(ctx context.Context, id string) (map[string]any, error) {
     return store.GetByID(ctx, tableName, id)
 }
+
+
+// GetByPath returns the file row for path on a branch, including tombstoned rows.
+func GetByPath(ctx context.Context, branchID, path string) (map[string]any, error) {
+    return db.SelectOneWithContext(ctx, tableName, []string{"*"}, map[string]any{
+        COL_BRANCHID: branchID,
+        COL_PATH:     path,
+    })
+}
+
+
+// GetByBranch returns every file row on a branch, including tombstoned rows.
+func GetByBranch(ctx context.Context, branchID string) ([]map[string]any, error) {
+    return store.GetWhere(ctx, tableName, map[string]any{COL_BRANCHID: branchID})
+}
+
+
+// GetCurrentByBranch returns the live file rows on a branch, the current tree.
+func GetCurrentByBranch(ctx context.Context, branchID string) ([]map[string]any, error) {
+    sqlStmt := fmt.Sprintf("SELECT * FROM %s WHERE %s = ? AND COALESCE(%s, 0) = 0", tableName, COL_BRANCHID, COL_ISDELETED)
+    return db.QueryWithContext(ctx, sqlStmt, branchID)
+}
+
+
+// GetUnderPath returns the live file rows on a branch whose path is below dirPath.
+func GetUnderPath(ctx context.Context, branchID, dirPath string) ([]map[string]any, error) {
+    sqlStmt := fmt.Sprintf( "SELECT * FROM %s WHERE %s = ? AND %s LIKE ? ESCAPE '\\' AND COALESCE(%s, 0) = 0",
+                            tableName, COL_BRANCHID, COL_PATH, COL_ISDELETED )
+    return db.QueryWithContext(ctx, sqlStmt, branchID, escapeLike(dirPath)+"/%")
+}
+
+
+func escapeLike(s string) string {
+    return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+
+// GetWithBlob returns the rows that reference a blob.
+func GetWithBlob(ctx context.Context) ([]map[string]any, error) {
+    sqlStmt := fmt.Sprintf("SELECT * FROM %s WHERE COALESCE(%s, '') != ''", tableName, COL_BLOBID)
+    return db.QueryWithContext(ctx, sqlStmt)
+}
+
+
+// GetByBlob returns the file rows on a branch whose content is blobID,
+// including tombstoned rows.
+func GetByBlob(ctx context.Context, branchID, blobID string) ([]map[string]any, error) {
+    return store.GetWhere(ctx, tableName, map[string]any{
+        COL_BRANCHID: branchID,
+        COL_BLOBID:   blobID,
+    })
+}
+
+
+// GetDeletedSince returns the tombstoned rows on a branch last modified at
+// or after since (an RFC3339 timestamp), most recent first.
+func GetDeletedSince(ctx context.Context, branchID, since string) ([]map[string]any, error) {
+    sqlStmt := fmt.Sprintf( "SELECT * FROM %s WHERE %s = ? AND COALESCE(%s, 0) = 1 AND %s >= ? ORDER BY %s DESC",
+                            tableName, COL_BRANCHID, COL_ISDELETED, COL_LMD, COL_LMD )
+    return db.QueryWithContext(ctx, sqlStmt, branchID, since)
+}
@@ -56,3 +56,8 @@ func Update(ctx context.Context, id string, data map[string]any) (map[string]any
 func GetByID(ctx context.Context, id string) (map[string]any, error) {
     return store.GetByID(ctx, tableName, id)
 }
+
+
+func GetAll(ctx context.Context) ([]map[string]any, error) {
+    return store.GetAll(ctx, tableName)
+}
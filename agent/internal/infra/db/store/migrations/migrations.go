@@ -35,13 +35,14 @@ func Create(ctx context.Context, data map[string]any) (map[string]any, error) {
 }
 
 func GetMaxVersion(ctx context.Context) (int, error) {
-	// Try to get max version using a simple select
-	results, err := db.SelectWithContext(ctx, tableName, []string{"COALESCE(MAX(version), 0) as max_version"}, map[string]any{})
+	// Rows are keyed by the selected expression, not by its alias
+	const maxVersionCol = "COALESCE(MAX(" + COL_VERSION + "), 0)"
+	results, err := db.SelectWithContext(ctx, tableName, []string{maxVersionCol}, map[string]any{})
 	if err != nil || len(results) == 0 {
 		return 0, nil // Table empty or doesn't exist
 	}
 	
-	if maxVersion, ok := results[0]["max_version"]; ok && maxVersion != nil {
+	if maxVersion, ok := results[0][maxVersionCol]; ok && maxVersion != nil {
 		switch v := maxVersion.(type) {
 		case int:
 			return v, nil
@@ -30,22 +30,22 @@ func CreateTable() {
 
 
 func GetAsString(ctx context.Context, key string) (string, error) {
-	return db.GetAsString(tableName, COL_VALUE, map[string]any{COL_KEY: key})
+	return db.GetAsStringWithContext(ctx, tableName, COL_VALUE, map[string]any{COL_KEY: key})
 }
 
 
 func GetAsBytes(ctx context.Context, key string) ([]byte, error) {
-	return db.GetAsBytes(tableName, COL_VALUE, map[string]any{COL_KEY: key})
+	return db.GetAsBytesWithContext(ctx, tableName, COL_VALUE, map[string]any{COL_KEY: key})
 }
 
 
 func GetAsInt(ctx context.Context, key string) (int, error) {
-	return db.GetAsInt(tableName, COL_VALUE, map[string]any{COL_KEY: key})
+	return db.GetAsIntWithContext(ctx, tableName, COL_VALUE, map[string]any{COL_KEY: key})
 }
 
 
 func GetAsFloat(ctx context.Context, key string) (float64, error) {
-	return db.GetAsFloat(tableName, COL_VALUE, map[string]any{COL_KEY: key})
+	return db.GetAsFloatWithContext(ctx, tableName, COL_VALUE, map[string]any{COL_KEY: key})
 }
 
 
@@ -53,11 +53,16 @@ func SetValue(ctx context.Context, key string, value any) error {
 	if key == "" {
 		return fmt.Errorf("key cannot be empty")
 	}
+	// key is not a unique column, so an upsert would add a second row
+	updated, err := db.UpdateWithContext(ctx, tableName, map[string]any{COL_VALUE: value}, map[string]any{COL_KEY: key})
+	if err != nil || updated > 0 {
+		return err
+	}
 	data := map[string]any{
 		COL_KEY:   key,
 		COL_VALUE: value,
 	}
-	_, err := db.Upsert(tableName, data, schema)
+	_, err = db.Upsert(tableName, data, schema)
 	return err
 }
 
@@ -2,6 +2,7 @@ package snapshot
 
 import (
 	"context"
+	"fmt"
 
 	"vcx/agent/internal/infra/db"
 	"vcx/agent/internal/infra/db/consts"
@@ -24,6 +25,10 @@ const /**Columns*/ (
 	COL_SIZE        = "size"
 	COL_SUMMARY     = "summary"
 	COL_LASTINDEXED = "lastIndexed"
+	COL_INSTANCEID  = "instanceID"
+	COL_PATH        = consts.PATH
+	COL_MTIME       = "mtime"
+	COL_INODE       = "inode"
 )
 
 
@@ -35,11 +40,27 @@ var schema = map[string]string{
 	COL_SIZE:        consts.TYPE_INT,
 	COL_SUMMARY:     consts.TYPE_STRING,
 	COL_LASTINDEXED: consts.TYPE_STRING,
+	COL_INSTANCEID:  consts.TYPE_FOREIGNKEY,
+	COL_PATH:        consts.TYPE_STRING,
+	COL_MTIME:       consts.TYPE_INT,
+	COL_INODE:       consts.TYPE_INT,
+}
+
+
+// TableExists reports whether the snapshot table has been created.
+func TableExists() bool {
+	return db.TableExists(tableName)
 }
 
 
 func CreateTable() {
 	db.CreateTable(tableName, schema)
+	CreateIndexes()
+}
+
+
+func CreateIndexes() error {
+	return db.CreateIndex("idx_snapshot_instance_path", tableName, []string{COL_INSTANCEID, COL_PATH})
 }
 
 
@@ -56,3 +77,21 @@ func Update(ctx context.Context, id string, data map[string]any) (map[string]any
 func GetByID(ctx context.Context, id string) (map[string]any, error) {
 	return store.GetByID(ctx, tableName, id)
 }
+
+
+func Delete(ctx context.Context, id string) error {
+	return store.Delete(ctx, tableName, id)
+}
+
+
+// GetByInstance returns the rows cached for the working copy of an instance.
+func GetByInstance(ctx context.Context, instanceID string) ([]map[string]any, error) {
+	return store.GetWhere(ctx, tableName, map[string]any{COL_INSTANCEID: instanceID})
+}
+
+
+// GetWithBlob returns the rows that reference a blob.
+func GetWithBlob(ctx context.Context) ([]map[string]any, error) {
+    sqlStmt := fmt.Sprintf("SELECT * FROM %s WHERE COALESCE(%s, '') != ''", tableName, COL_BLOBID)
+    return db.QueryWithContext(ctx, sqlStmt)
+}
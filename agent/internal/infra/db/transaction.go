@@ -6,10 +6,33 @@ import (
 	"fmt"
 )
 
+
+// txKey marks a context that carries an open transaction.
+type txKey struct{}
+
+
+// executor is implemented by both *sql.DB and *sql.Tx.
+type executor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+
+// conn returns the transaction carried by ctx, or the shared database handle.
+// The pool holds a single connection, so statements issued inside a
+// transaction must go through it or they would wait on themselves.
+func conn(ctx context.Context) executor {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return db
+}
+
+
 // WithTransaction executes a function within a database transaction.
 // If the function returns an error or panics, the transaction is rolled back.
 // Otherwise, the transaction is committed.
-func WithTransaction(fn func(*sql.Tx) error) error {
+func WithTransaction(fn func(*sql.Tx) error) (err error) {
 	tx, err := db.Begin()
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
@@ -31,7 +54,14 @@ func WithTransaction(fn func(*sql.Tx) error) error {
 }
 
 // WithTransactionContext executes a function within a database transaction with context support.
-func WithTransactionContext(ctx context.Context, fn func(context.Context, *sql.Tx) error) error {
+// The context passed to fn carries the transaction, so every *WithContext
+// call made with it (and therefore every store call) joins the transaction.
+// Nested calls run inside the outer transaction.
+func WithTransactionContext(ctx context.Context, fn func(context.Context, *sql.Tx) error) (err error) {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx, tx)
+	}
+
 	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
@@ -48,6 +78,6 @@ func WithTransactionContext(ctx context.Context, fn func(context.Context, *sql.T
 		}
 	}()
 
-	err = fn(ctx, tx)
+	err = fn(context.WithValue(ctx, txKey{}, tx), tx)
 	return err
 }
@@ -112,14 +112,12 @@ func Update( tableName string,
     columns, _, values      := extractColumnsAndValues(data)
     setPairs                := buildSetClause(columns, nil)
     wherePairs, whereValues := buildWhereClause(conditions)
-    // values                   = append(values, whereValues...)
-    // TODO: check if the above append wherevalues is in original code
     sqlStmt                 := fmt.Sprintf( "UPDATE %s SET %s",
                                             tableName,
                                             strings.Join(setPairs, ", ") )
     if len(conditions) > 0 {
         sqlStmt += fmt.Sprintf(" WHERE %s", strings.Join(wherePairs, " AND "))
-        values = append(values, whereValues) }
+        values = append(values, whereValues...) }
     return executeAndGetRowsAffected(sqlStmt, values)
 }
 
@@ -12,7 +12,7 @@ import (
 func executeWithContext(ctx context.Context, sqlStmt string, args []any) (sql.Result, error) {
 	log.Debug("Executing SQL statement", "statement", sqlStmt)
 
-	result, err := db.ExecContext(ctx, sqlStmt, args...)
+	result, err := conn(ctx).ExecContext(ctx, sqlStmt, args...)
 	if err != nil {
 		log.Error( "Execution Failed",
                    "ERROR", err,
@@ -60,17 +60,32 @@ func UpdateWithContext(ctx context.Context, tableName string, data map[string]an
 	columns, _, values      := extractColumnsAndValues(data)
 	setPairs                := buildSetClause(columns, nil)
 	wherePairs, whereValues := buildWhereClause(conditions)
-	values                   = append(values, whereValues...)
 	sqlStmt                 := fmt.Sprintf( "UPDATE %s SET %s",
                                             tableName,
                                             strings.Join(setPairs, ", "))
 	if len(conditions) > 0 {
 		sqlStmt += fmt.Sprintf(" WHERE %s", strings.Join(wherePairs, " AND "))
-		values   = append(values, whereValues)
+		values   = append(values, whereValues...)
 	}
 	return executeAndGetRowsAffectedWithContext(ctx, sqlStmt, values)
 }
 
+// DeleteWithContext removes the rows matching conditions. Conditions are
+// required so a table is never emptied by accident.
+func DeleteWithContext(ctx context.Context, tableName string, conditions map[string]any) (int64, error) {
+	if err := hasRequiredParams(tableName, conditions); err != nil {
+		return 0, err
+	}
+	if len(conditions) == 0 {
+		return 0, fmt.Errorf("delete from %s without conditions", tableName)
+	}
+	wherePairs, whereValues := buildWhereClause(conditions)
+	sqlStmt                 := fmt.Sprintf( "DELETE FROM %s WHERE %s",
+                                            tableName,
+                                            strings.Join(wherePairs, " AND "))
+	return executeAndGetRowsAffectedWithContext(ctx, sqlStmt, whereValues)
+}
+
 func UpsertWithContext(ctx context.Context, tableName string, data map[string]any, schema map[string]string) (string, error) {
 	if err := hasRequiredParams(tableName, data); err != nil {
 		return "", err
@@ -115,3 +130,10 @@ func UpsertWithContext(ctx context.Context, tableName string, data map[string]an
 	}
 	return schema[consts.ID], nil
 }
+
+// VacuumWithContext rebuilds the database file, returning the space of
+// deleted data to the filesystem. It cannot run inside a transaction.
+func VacuumWithContext(ctx context.Context) error {
+	_, err := executeWithContext(ctx, "VACUUM", nil)
+	return err
+}
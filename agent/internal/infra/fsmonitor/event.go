@@ -0,0 +1,28 @@
+package fsmonitor
+
+
+type Op int
+
+const (
+	INVALID Op = iota
+	CREATE      // entry created (files are recorded on WRITE instead)
+	WRITE       // file closed after being written
+	REMOVE      // entry deleted
+	MOVEDFROM   // entry moved away from path
+	MOVEDTO     // entry moved onto path
+	OVERFLOW    // kernel queue overflowed, events were lost
+	ATTRIB      // metadata changed, e.g. chmod
+)
+
+func (op Op) ToString() string {
+	return [...]string{"INVALID", "CREATE", "WRITE", "REMOVE", "MOVEDFROM", "MOVEDTO", "OVERFLOW", "ATTRIB"}[op]
+}
+
+
+// Event is a single filesystem notification for an absolute path.
+type Event struct {
+	Op     Op
+	Path   string
+	IsDir  bool
+	Cookie uint32 // pairs MOVEDFROM/MOVEDTO events of the same rename
+}
@@ -1,8 +1,80 @@
+// Package fsmonitor watches the directory of every registered project
+// instance and records file changes as they happen.
+//
+// Each instance gets its own watcher. New instances (e.g. from `vcx init`)
+// are picked up on the next poll of the instance table, which also applies
+// changes to a project's auto-snapshot policy.
 package fsmonitor
 
-import "context"
+import (
+	"context"
+	"sync"
+	"time"
 
+	instanceService "vcx/agent/internal/services/instance"
+	"vcx/agent/internal/services/policy"
+	"vcx/pkg/logging"
+	"vcx/pkg/toolkit/pathkit"
+)
 
+
+var log = logging.GetLogger()
+
+
+const INSTANCE_POLL_INTERVAL = 30 * time.Second
+
+
+// Start watches all registered instances and blocks until ctx is cancelled.
+// All watchers have stopped when Start returns.
 func Start(ctx context.Context) {
+	log = logging.GetLogger()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	watched := make(map[string]*instanceWatcher)
+	ticker  := time.NewTicker(INSTANCE_POLL_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		watchNewInstances(ctx, &wg, watched)
+
+		select {
+		case <-ctx.Done():
+			log.Info("File monitor shutting down")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+
+func watchNewInstances(ctx context.Context, wg *sync.WaitGroup, watched map[string]*instanceWatcher) {
+	instances, err := instanceService.GetAll(ctx)
+	if err != nil {
+		log.Error("Failed to load instances", "error", err)
+		return
+	}
+
+	for _, instance := range instances {
+		snapshotPolicy := policy.GetForProject(ctx, instance.ProjectID)
+		if watcher, ok := watched[instance.ID]; ok {
+			watcher.engine.SetPolicy(snapshotPolicy)
+			continue
+		}
+		if !pathkit.IsDir(instance.Path) {
+			continue
+		}
+
+		watcher, err := newInstanceWatcher(instance, snapshotPolicy)
+		if err != nil {
+			log.Error("Failed to start watcher", "path", instance.Path, "error", err)
+			continue
+		}
+		watched[instance.ID] = watcher
 
+		wg.Go(func() {
+			watcher.run(ctx)
+		})
+	}
 }
@@ -0,0 +1,157 @@
+package fsmonitor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+
+const watchMask = unix.IN_CLOSE_WRITE | unix.IN_CREATE | unix.IN_DELETE | unix.IN_ATTRIB |
+	unix.IN_MOVED_FROM | unix.IN_MOVED_TO |
+	unix.IN_ONLYDIR | unix.IN_DONT_FOLLOW | unix.IN_EXCL_UNLINK
+
+
+// notifier wraps an inotify instance. Watches are per directory, so callers
+// add every directory of a tree they want to observe.
+type notifier struct {
+	fd      int
+	file    *os.File
+	mu      sync.Mutex
+	watches map[int]string // watch descriptor -> directory
+	paths   map[string]int // directory -> watch descriptor
+}
+
+
+func newNotifier() (*notifier, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("inotify init failed: %w", err)
+	}
+	return &notifier{
+		fd:      fd,
+		// Non-blocking fd wrapped in os.File so reads go through the runtime
+		// poller and Close unblocks a pending Read. Never call file.Fd(),
+		// it switches the descriptor back to blocking mode.
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		watches: make(map[int]string),
+		paths:   make(map[string]int),
+	}, nil
+}
+
+
+func (n *notifier) add(dir string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	wd, err := unix.InotifyAddWatch(n.fd, dir, watchMask)
+	if err != nil {
+		return fmt.Errorf("failed to watch %s: %w", dir, err)
+	}
+	n.watches[wd] = dir
+	n.paths[dir]  = wd
+	return nil
+}
+
+
+// removeTree drops the watches of dir and every directory below it.
+func (n *notifier) removeTree(dir string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	prefix := dir + string(filepath.Separator)
+	for path, wd := range n.paths {
+		if path == dir || strings.HasPrefix(path, prefix) {
+			unix.InotifyRmWatch(n.fd, uint32(wd))
+			delete(n.paths, path)
+			delete(n.watches, wd)
+		}
+	}
+}
+
+
+func (n *notifier) close() error {
+	return n.file.Close()
+}
+
+
+// read blocks decoding kernel events into out until the notifier is closed.
+func (n *notifier) read(out chan<- Event) {
+	defer close(out)
+
+	buf := make([]byte, 64*1024)
+	for {
+		count, err := n.file.Read(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				log.Error("inotify read failed", "error", err)
+			}
+			return
+		}
+
+		offset := 0
+		for offset+unix.SizeofInotifyEvent <= count {
+			raw    := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			start  := offset + unix.SizeofInotifyEvent
+			offset  = start + int(raw.Len)
+			if offset > count {
+				break
+			}
+			name := strings.TrimRight(string(buf[start:offset]), "\x00")
+
+			if event, ok := n.decode(raw, name); ok {
+				out <- event
+			}
+		}
+	}
+}
+
+
+func (n *notifier) decode(raw *unix.InotifyEvent, name string) (Event, bool) {
+	if raw.Mask&unix.IN_Q_OVERFLOW != 0 {
+		return Event{Op: OVERFLOW}, true
+	}
+
+	n.mu.Lock()
+	dir, ok := n.watches[int(raw.Wd)]
+	if raw.Mask&unix.IN_IGNORED != 0 {
+		delete(n.watches, int(raw.Wd))
+		if n.paths[dir] == int(raw.Wd) {
+			delete(n.paths, dir)
+		}
+		ok = false
+	}
+	n.mu.Unlock()
+	if !ok || name == "" {
+		return Event{}, false
+	}
+
+	event := Event{
+		Path:   filepath.Join(dir, name),
+		IsDir:  raw.Mask&unix.IN_ISDIR != 0,
+		Cookie: raw.Cookie,
+	}
+	switch {
+	case raw.Mask&unix.IN_CLOSE_WRITE != 0:
+		event.Op = WRITE
+	case raw.Mask&unix.IN_CREATE != 0:
+		event.Op = CREATE
+	case raw.Mask&unix.IN_DELETE != 0:
+		event.Op = REMOVE
+	case raw.Mask&unix.IN_MOVED_FROM != 0:
+		event.Op = MOVEDFROM
+	case raw.Mask&unix.IN_MOVED_TO != 0:
+		event.Op = MOVEDTO
+	case raw.Mask&unix.IN_ATTRIB != 0:
+		event.Op = ATTRIB
+	default:
+		return Event{}, false
+	}
+	return event, true
+}
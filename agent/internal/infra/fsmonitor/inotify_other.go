@@ -0,0 +1,34 @@
+//go:build !linux
+
+package fsmonitor
+
+import "errors"
+
+
+var errUnsupported = errors.New("filesystem monitoring is only implemented on linux")
+
+
+type notifier struct{}
+
+
+func newNotifier() (*notifier, error) {
+	return nil, errUnsupported
+}
+
+
+func (n *notifier) add(dir string) error {
+	return errUnsupported
+}
+
+
+func (n *notifier) removeTree(dir string) {}
+
+
+func (n *notifier) close() error {
+	return nil
+}
+
+
+func (n *notifier) read(out chan<- Event) {
+	close(out)
+}
@@ -0,0 +1,216 @@
+package fsmonitor
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	instanceDomain "vcx/agent/internal/domains/instance"
+	fileService "vcx/agent/internal/services/file"
+	"vcx/agent/internal/services/filters"
+	"vcx/agent/internal/services/fs/walk"
+	"vcx/agent/internal/services/policy"
+	"vcx/agent/internal/session"
+	"vcx/pkg/toolkit/filekit"
+)
+
+
+// instanceWatcher follows one instance directory tree and records every
+// change in it on the instance's project and current branch. File events go
+// through the policy engine so a burst of saves is recorded as one version;
+// directory watches are added and dropped immediately.
+type instanceWatcher struct {
+	instance *instanceDomain.Instance
+	filter   filters.FilterInterface
+	notify   *notifier
+	engine   *policy.Engine
+}
+
+
+func newInstanceWatcher(instance *instanceDomain.Instance, snapshotPolicy policy.Policy) (*instanceWatcher, error) {
+	notify, err := newNotifier()
+	if err != nil {
+		return nil, err
+	}
+	return &instanceWatcher{
+		instance: instance,
+		filter:   filters.ForInstance(instance.Path),
+		notify:   notify,
+		engine:   policy.NewEngine(snapshotPolicy),
+	}, nil
+}
+
+
+// run watches the instance until ctx is cancelled.
+func (w *instanceWatcher) run(ctx context.Context) {
+	ctx = session.WithProjectID(ctx, w.instance.ProjectID)
+	ctx = session.WithBranchID(ctx, w.instance.BranchID)
+
+	w.addTree(ctx, w.instance.Path, false)
+	log.Info("Watching instance", "path", w.instance.Path, "instanceID", w.instance.ID)
+
+	events := make(chan Event, 256)
+	go w.notify.read(events)
+
+	for {
+		select {
+		case <-ctx.Done():
+			// pending paths are dropped, their content is still on disk
+			w.engine.Stop()
+			w.notify.close()
+			// drain so the reader can observe the close and exit
+			for range events {
+			}
+			log.Info("Stopped watching instance", "path", w.instance.Path)
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			w.handle(ctx, event)
+		case path := <-w.engine.Ready():
+			w.record(ctx, path)
+		}
+	}
+}
+
+
+func (w *instanceWatcher) handle(ctx context.Context, event Event) {
+	if event.Op == OVERFLOW {
+		log.Warn("Watch queue overflowed, some changes were not recorded", "path", w.instance.Path)
+		return
+	}
+	if w.filter.ShouldSkip(event.Path, event.IsDir) {
+		return
+	}
+	// temporary files of vcx's own atomic writes
+	if strings.HasPrefix(filepath.Base(event.Path), filekit.TEMP_PREFIX) {
+		return
+	}
+
+	switch event.Op {
+	case CREATE, MOVEDTO:
+		if event.IsDir {
+			w.addTree(ctx, event.Path, true)
+			return
+		}
+		// regular files created in place are recorded once closed (WRITE)
+		if event.Op == MOVEDTO || isSymlink(event.Path) {
+			w.engine.Submit(event.Path)
+		}
+	case WRITE:
+		w.engine.Submit(event.Path)
+	case ATTRIB:
+		// a changed mode is recorded, anything else leaves the version as is
+		w.engine.Submit(event.Path)
+	case REMOVE, MOVEDFROM:
+		if event.IsDir {
+			w.notify.removeTree(event.Path)
+			w.remove(ctx, event.Path)
+			return
+		}
+		w.engine.Submit(event.Path)
+	}
+}
+
+
+// record stores the current state of a path released by the policy engine.
+// Events only tell that something happened; what is on disk now decides
+// whether the path is recorded as content, a symlink, a directory or a
+// removal.
+func (w *instanceWatcher) record(ctx context.Context, path string) {
+	info, err := os.Lstat(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		w.remove(ctx, path)
+	case err != nil:
+		log.Error("Failed to stat path", "path", path, "error", err)
+	case info.Mode()&os.ModeSymlink != 0:
+		w.ingestSymlink(ctx, path)
+	case info.Mode().IsRegular():
+		w.ingest(ctx, path)
+	case info.IsDir():
+		w.ingestDir(ctx, path)
+	}
+}
+
+
+// addTree watches root and every unfiltered directory below it. When ingest
+// is set the directories and files found are recorded too, which covers
+// content written to a new directory before its watch was in place.
+func (w *instanceWatcher) addTree(ctx context.Context, root string, ingest bool) {
+	eventChan := make(chan walk.Event, 100)
+	go walk.Stream(root, eventChan, w.filter)
+
+	for event := range eventChan {
+		switch event.Type {
+		case walk.ERROR:
+			log.Error("Walk error", "error", event.Data)
+		case walk.DIR:
+			if err := w.notify.add(event.Data); err != nil {
+				log.Error("Failed to watch directory", "path", event.Data, "error", err)
+			}
+			if ingest {
+				w.engine.Submit(event.Data)
+			}
+		case walk.FILE, walk.SYM:
+			if ingest {
+				w.engine.Submit(event.Data)
+			}
+		}
+	}
+}
+
+
+// onBranch returns ctx on the branch the instance has checked out now, which
+// `vcx switch` changes while the watcher runs.
+func (w *instanceWatcher) onBranch(ctx context.Context) context.Context {
+	instance, err := instanceDomain.GetByID(ctx, w.instance.ID)
+	if err != nil {
+		log.Warn("Failed to reload instance, recording on its previous branch", "path", w.instance.Path, "error", err)
+		return ctx
+	}
+	return session.WithBranchID(ctx, instance.BranchID)
+}
+
+
+func (w *instanceWatcher) ingest(ctx context.Context, path string) {
+	if _, err := fileService.Ingest(w.onBranch(ctx), w.instance.Path, path); err != nil {
+		// the file may already be gone again, e.g. an editor's temp file
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Error("Failed to record file", "path", path, "error", err)
+		}
+	}
+}
+
+
+func (w *instanceWatcher) ingestSymlink(ctx context.Context, path string) {
+	if _, err := fileService.IngestSymlink(w.onBranch(ctx), w.instance.Path, path); err != nil {
+		log.Error("Failed to record symlink", "path", path, "error", err)
+	}
+}
+
+
+func (w *instanceWatcher) ingestDir(ctx context.Context, path string) {
+	if _, err := fileService.IngestDir(w.onBranch(ctx), w.instance.Path, path); err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Error("Failed to record directory", "path", path, "error", err)
+		}
+	}
+}
+
+
+func (w *instanceWatcher) remove(ctx context.Context, path string) {
+	if _, err := fileService.Remove(w.onBranch(ctx), w.instance.Path, path); err != nil {
+		log.Error("Failed to record removal", "path", path, "error", err)
+	}
+}
+
+
+func isSymlink(path string) bool {
+	info, err := os.Lstat(filepath.Clean(path))
+	return err == nil && info.Mode()&os.ModeSymlink != 0
+}
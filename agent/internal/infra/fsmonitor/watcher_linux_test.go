@@ -0,0 +1,107 @@
+package fsmonitor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	fileDomain "vcx/agent/internal/domains/file"
+	"vcx/agent/internal/services/policy"
+	"vcx/agent/internal/services/servicetest"
+)
+
+
+var quickPolicy = policy.Policy{QuietWindow: 20 * time.Millisecond, MaxWait: 200 * time.Millisecond}
+
+
+// watch returns a function that polls the instance table like Start does.
+// The watchers it starts are stopped when the test ends.
+func watch(t *testing.T, ctx context.Context) func() map[string]*instanceWatcher {
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	watched := make(map[string]*instanceWatcher)
+	t.Cleanup(func() {
+		cancel()
+		wg.Wait()
+	})
+	return func() map[string]*instanceWatcher {
+		watchNewInstances(ctx, &wg, watched)
+		return watched
+	}
+}
+
+
+// watching reports whether the watcher has put a watch on dir, which it
+// does in the background once started.
+func watching(watcher *instanceWatcher, dir string) bool {
+	watcher.notify.mu.Lock()
+	defer watcher.notify.mu.Unlock()
+	_, ok := watcher.notify.paths[dir]
+	return ok
+}
+
+
+func waitFor(t *testing.T, what string, done func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+
+func TestWatcherRecordsOnCurrentBranch(t *testing.T) {
+	ctx     := servicetest.Context(t)
+	project := servicetest.NewProject(t, ctx, map[string]string{"a.txt": "a\n"})
+	if err := policy.SetForProject(ctx, project.ID, quickPolicy); err != nil {
+		t.Fatal(err)
+	}
+	watcher := watch(t, ctx)()[project.Instance().ID]
+	waitFor(t, "the instance to be watched", func() bool { return watching(watcher, project.Dir) })
+
+	recorded := func(branchID, relPath string) bool {
+		_, err := fileDomain.GetByPath(project.Context(), branchID, relPath)
+		return err == nil
+	}
+	mainID := project.Instance().BranchID
+	project.WriteFile("before.txt", "written on main\n")
+	waitFor(t, "before.txt to be recorded on main", func() bool { return recorded(mainID, "before.txt") })
+
+	project.Fork("feature")
+	project.Switch("feature")
+	featureID := project.Instance().BranchID
+	project.WriteFile("after.txt", "written on feature\n")
+	waitFor(t, "after.txt to be recorded on feature", func() bool { return recorded(featureID, "after.txt") })
+	if recorded(mainID, "after.txt") {
+		t.Error("after.txt recorded on main after switching to feature")
+	}
+}
+
+
+func TestWatcherFollowsPolicyChanges(t *testing.T) {
+	ctx     := servicetest.Context(t)
+	project := servicetest.NewProject(t, ctx, map[string]string{"a.txt": "a\n"})
+	poll    := watch(t, ctx)
+
+	watcher, ok := poll()[project.Instance().ID]
+	if !ok {
+		t.Fatal("instance not watched")
+	}
+	if got := watcher.engine.Policy(); got != policy.Default() {
+		t.Errorf("watching with %+v, want the default policy", got)
+	}
+
+	if err := policy.SetForProject(ctx, project.ID, quickPolicy); err != nil {
+		t.Fatal(err)
+	}
+	if again := poll()[project.Instance().ID]; again != watcher {
+		t.Error("instance watched twice")
+	}
+	if got := watcher.engine.Policy(); got != quickPolicy {
+		t.Errorf("watching with %+v after the policy changed, want %+v", got, quickPolicy)
+	}
+}
@@ -0,0 +1,69 @@
+package diff
+
+import (
+	"errors"
+	"net/http"
+	"vcx/agent/internal/infra/http/api/request"
+	diffService "vcx/agent/internal/services/diff"
+	"vcx/pkg/logging"
+	"vcx/pkg/toolkit/diffkit"
+	"vcx/pkg/toolkit/httpkit"
+)
+
+var log = logging.GetLogger()
+
+const APIPath = "/api/diff"
+
+
+func Handler() http.Handler {
+    return http.HandlerFunc(diff)
+}
+
+
+// diff compares two versions and returns the hunks as JSON.
+//
+// Either ?fromBlob=&toBlob= (raw blob IDs) or ?path= with optional
+// from/to change IDs (defaults: the current version and the one before it).
+// Optional: context (lines, default 3) and algorithm (myers|patience).
+func diff(w http.ResponseWriter, r *http.Request) {
+    query   := r.URL.Query()
+    options := diffService.DefaultOptions()
+
+    var err error
+    if query.Has("context") {
+        if options.Context, err = request.Int(r, "context"); err != nil {
+            httpkit.WriteError(w, http.StatusBadRequest, err)
+            return
+        }
+    }
+    if options.Algorithm, err = diffkit.AlgorithmFromString(query.Get("algorithm")); err != nil {
+        httpkit.WriteError(w, http.StatusBadRequest, err)
+        return
+    }
+
+    var result *diffService.Result
+    if query.Has("fromBlob") || query.Has("toBlob") {
+        result, err = diffService.Blobs(r.Context(), query.Get("fromBlob"), query.Get("toBlob"), options)
+    } else {
+        instance, relPath, ok := request.Instance(w, r)
+        if !ok {
+            return
+        }
+        result, err = diffService.Versions(r.Context(), instance.BranchID, relPath, query.Get("from"), query.Get("to"), options)
+    }
+
+    if err != nil {
+        status := http.StatusInternalServerError
+        switch {
+        case errors.Is(err, diffService.ErrFileNotFound):
+            status = http.StatusNotFound
+        case errors.Is(err, diffService.ErrWrongFile):
+            status = http.StatusBadRequest
+        default:
+            log.Error("Failed to diff", "error", err)
+        }
+        httpkit.WriteError(w, status, err)
+        return
+    }
+    httpkit.WriteJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,168 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"vcx/agent/internal/infra/http/api/request"
+	conflictService "vcx/agent/internal/services/conflict"
+	"vcx/agent/internal/services/history"
+	"vcx/agent/internal/services/restore"
+	"vcx/pkg/logging"
+	"vcx/pkg/toolkit/httpkit"
+)
+
+var log = logging.GetLogger()
+
+const APIPath = "/api/file"
+
+
+func Handler() http.Handler {
+    // Create submux for file routes
+    mux := http.NewServeMux()
+
+    // Register routes
+    mux.HandleFunc("/history", fileHistory)
+    mux.HandleFunc("/restore", restoreFile)
+    mux.HandleFunc("/resolve", resolveFile)
+
+    return http.StripPrefix(APIPath, mux)
+}
+
+
+type historyResponse struct {
+    ProjectID string            `json:"projectID"`
+    BranchID  string            `json:"branchID"`
+    Path      string            `json:"path"`
+    Versions  []history.Version `json:"versions"`
+}
+
+
+// fileHistory lists the versions of ?path=, newest first.
+// Optional: limit, since and until (RFC3339, YYYY-MM-DD[ HH:MM] or an age like 2h, 3d).
+func fileHistory(w http.ResponseWriter, r *http.Request) {
+    instance, relPath, ok := request.Instance(w, r)
+    if !ok {
+        return
+    }
+
+    var query history.Query
+    var err error
+    if query.Limit, err = request.Int(r, "limit"); err != nil {
+        httpkit.WriteError(w, http.StatusBadRequest, err)
+        return
+    }
+    if query.Since, err = request.Time(r, "since"); err != nil {
+        httpkit.WriteError(w, http.StatusBadRequest, err)
+        return
+    }
+    if query.Until, err = request.Time(r, "until"); err != nil {
+        httpkit.WriteError(w, http.StatusBadRequest, err)
+        return
+    }
+
+    versions, err := history.FileHistory(r.Context(), instance.BranchID, relPath, query)
+    if err != nil {
+        status := http.StatusInternalServerError
+        if errors.Is(err, history.ErrFileNotFound) {
+            status = http.StatusNotFound
+        } else {
+            log.Error("Failed to load file history", "path", relPath, "error", err)
+        }
+        httpkit.WriteError(w, status, err)
+        return
+    }
+
+    httpkit.WriteJSON(w, http.StatusOK, historyResponse{
+        ProjectID: instance.ProjectID,
+        BranchID:  instance.BranchID,
+        Path:      relPath,
+        Versions:  versions,
+    })
+}
+
+
+type restoreResponse struct {
+    Path         string `json:"path"`
+    RestoredFrom string `json:"restoredFrom"`
+    ChangeID     string `json:"changeID"`
+}
+
+
+// restoreFile restores ?path= to the version recorded by ?to=<changeID>.
+func restoreFile(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        httpkit.WriteError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+        return
+    }
+    instance, relPath, ok := request.Instance(w, r)
+    if !ok {
+        return
+    }
+    changeID := r.URL.Query().Get("to")
+    if changeID == "" {
+        httpkit.WriteError(w, http.StatusBadRequest, fmt.Errorf("missing to"))
+        return
+    }
+
+    file, err := restore.File(r.Context(), instance, relPath, changeID)
+    if err != nil {
+        status := http.StatusInternalServerError
+        switch {
+        case errors.Is(err, restore.ErrChangeNotFound):
+            status = http.StatusNotFound
+        case errors.Is(err, restore.ErrWrongFile):
+            status = http.StatusBadRequest
+        case errors.Is(err, restore.ErrUnsafePath):
+            status = http.StatusConflict
+            log.Warn("Refused to restore file", "path", relPath, "changeID", changeID, "error", err)
+        default:
+            log.Error("Failed to restore file", "path", relPath, "changeID", changeID, "error", err)
+        }
+        httpkit.WriteError(w, status, err)
+        return
+    }
+
+    httpkit.WriteJSON(w, http.StatusOK, restoreResponse{
+        Path:         relPath,
+        RestoredFrom: changeID,
+        ChangeID:     file.ChangeID,
+    })
+}
+
+
+type resolveResponse struct {
+    Path       string `json:"path"`
+    Resolution string `json:"resolution"`
+}
+
+
+// resolveFile closes the conflict of ?path=, taking ?side=ours|theirs or,
+// without a side, the working copy as it is.
+func resolveFile(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        httpkit.WriteError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+        return
+    }
+    instance, relPath, ok := request.Instance(w, r)
+    if !ok {
+        return
+    }
+
+    conflict, err := conflictService.Resolve(r.Context(), instance, relPath, r.URL.Query().Get("side"))
+    if err != nil {
+        status := http.StatusInternalServerError
+        switch {
+        case errors.Is(err, conflictService.ErrNoConflict):
+            status = http.StatusNotFound
+        case errors.Is(err, conflictService.ErrUnknownSide):
+            status = http.StatusBadRequest
+        default:
+            log.Error("Failed to resolve conflict", "path", relPath, "error", err)
+        }
+        httpkit.WriteError(w, status, err)
+        return
+    }
+
+    httpkit.WriteJSON(w, http.StatusOK, resolveResponse{Path: relPath, Resolution: conflict.Resolution})
+}
@@ -0,0 +1,156 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"vcx/agent/internal/infra/http/api/request"
+	branchService "vcx/agent/internal/services/branch"
+	"vcx/agent/internal/services/merge"
+	"vcx/agent/internal/services/restore"
+	"vcx/pkg/toolkit/httpkit"
+)
+
+
+type branchResponse struct {
+    ID           string `json:"id"`
+    Name         string `json:"name"`
+    ChangeID     string `json:"changeID"`
+    CreationDate string `json:"creationDate"`
+    Current      bool   `json:"current"`
+}
+
+
+// branches lists the branches of the project containing ?path= (GET) or
+// creates one (POST ?name=, optional from=<changeID>, default the latest
+// state of the instance's branch).
+func branches(w http.ResponseWriter, r *http.Request) {
+    instance, _, ok := request.Instance(w, r)
+    if !ok {
+        return
+    }
+
+    switch r.Method {
+    case http.MethodGet:
+        list, err := branchService.List(r.Context(), instance.ProjectID)
+        if err != nil {
+            httpkit.WriteError(w, http.StatusInternalServerError, err)
+            return
+        }
+        response := make([]branchResponse, 0, len(list))
+        for _, branch := range list {
+            response = append(response, branchResponse{
+                ID:           branch.ID,
+                Name:         branch.Name,
+                ChangeID:     branch.ChangeID,
+                CreationDate: branch.CreationDate,
+                Current:      branch.ID == instance.BranchID,
+            })
+        }
+        httpkit.WriteJSON(w, http.StatusOK, response)
+
+    case http.MethodPost:
+        name := r.URL.Query().Get("name")
+        branch, err := branchService.Fork(r.Context(), instance, name, r.URL.Query().Get("from"))
+        switch {
+        case err == nil:
+            httpkit.WriteJSON(w, http.StatusCreated, branchResponse{
+                ID:           branch.ID,
+                Name:         branch.Name,
+                ChangeID:     branch.ChangeID,
+                CreationDate: branch.CreationDate,
+            })
+        case errors.Is(err, branchService.ErrInvalidName):
+            httpkit.WriteError(w, http.StatusBadRequest, err)
+        case errors.Is(err, branchService.ErrBranchExists):
+            httpkit.WriteError(w, http.StatusConflict, err)
+        case errors.Is(err, restore.ErrChangeNotFound):
+            httpkit.WriteError(w, http.StatusNotFound, err)
+        default:
+            log.Error("Failed to create branch", "name", name, "error", err)
+            httpkit.WriteError(w, http.StatusInternalServerError, err)
+        }
+
+    default:
+        httpkit.WriteError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+    }
+}
+
+
+// switchBranch rewrites the working copy of the instance containing ?path=
+// to the latest state of branch ?name= and makes it the instance's branch.
+// Flags: dryRun, force.
+func switchBranch(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        httpkit.WriteError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+        return
+    }
+    instance, _, ok := request.Instance(w, r)
+    if !ok {
+        return
+    }
+
+    options := restore.SwitchOptions{
+        DryRun: request.Bool(r, "dryRun"),
+        Force:  request.Bool(r, "force"),
+    }
+    plan, err := branchService.Switch(r.Context(), instance, r.URL.Query().Get("name"), options)
+    switch {
+    case err == nil:
+        httpkit.WriteJSON(w, http.StatusOK, plan)
+    case errors.Is(err, restore.ErrLocalModifications):
+        httpkit.WriteJSON(w, http.StatusConflict, restoreErrorResponse{Error: err.Error(), Plan: plan})
+    case errors.Is(err, restore.ErrUnsafePath):
+        log.Warn("Refused to switch branch", "path", instance.Path, "error", err)
+        httpkit.WriteJSON(w, http.StatusConflict, restoreErrorResponse{Error: err.Error(), Plan: plan})
+    case errors.Is(err, branchService.ErrBranchNotFound):
+        httpkit.WriteError(w, http.StatusNotFound, err)
+    default:
+        log.Error("Failed to switch branch", "path", instance.Path, "error", err)
+        httpkit.WriteJSON(w, http.StatusInternalServerError, restoreErrorResponse{Error: err.Error(), Plan: plan})
+    }
+}
+
+
+type mergeErrorResponse struct {
+    Error  string        `json:"error"`
+    Result *merge.Result `json:"result"`
+}
+
+
+// mergeBranch merges branch ?name= into the branch of the instance
+// containing ?path= and its working copy. Flags: dryRun, force.
+func mergeBranch(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        httpkit.WriteError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+        return
+    }
+    instance, _, ok := request.Instance(w, r)
+    if !ok {
+        return
+    }
+
+    options := merge.Options{
+        DryRun: request.Bool(r, "dryRun"),
+        Force:  request.Bool(r, "force"),
+    }
+    result, err := branchService.Merge(r.Context(), instance, r.URL.Query().Get("name"), options)
+    switch {
+    case err == nil:
+        httpkit.WriteJSON(w, http.StatusOK, result)
+    case errors.Is(err, restore.ErrLocalModifications):
+        httpkit.WriteJSON(w, http.StatusConflict, mergeErrorResponse{Error: err.Error(), Result: result})
+    case errors.Is(err, restore.ErrUnsafePath):
+        log.Warn("Refused to merge branch", "path", instance.Path, "error", err)
+        httpkit.WriteJSON(w, http.StatusConflict, mergeErrorResponse{Error: err.Error(), Result: result})
+    case errors.Is(err, merge.ErrUnresolvedConflicts):
+        httpkit.WriteError(w, http.StatusConflict, err)
+    case errors.Is(err, merge.ErrSameBranch):
+        httpkit.WriteError(w, http.StatusBadRequest, err)
+    case errors.Is(err, branchService.ErrBranchNotFound):
+        httpkit.WriteError(w, http.StatusNotFound, err)
+    default:
+        log.Error("Failed to merge branch", "path", instance.Path, "error", err)
+        httpkit.WriteJSON(w, http.StatusInternalServerError, mergeErrorResponse{Error: err.Error(), Result: result})
+    }
+}
@@ -0,0 +1,56 @@
+package project
+
+import (
+	"fmt"
+	"net/http"
+	conflictDomain "vcx/agent/internal/domains/conflict"
+	"vcx/agent/internal/infra/http/api/request"
+	conflictService "vcx/agent/internal/services/conflict"
+	"vcx/pkg/toolkit/httpkit"
+)
+
+
+type conflictResponse struct {
+    ID           string              `json:"id"`
+    Path         string              `json:"path"`
+    Reason       string              `json:"reason"`
+    ChangeID     string              `json:"changeID"`
+    CreationDate string              `json:"creationDate"`
+    Base         conflictDomain.Side `json:"base"`
+    Ours         conflictDomain.Side `json:"ours"`
+    Theirs       conflictDomain.Side `json:"theirs"`
+}
+
+
+// conflicts lists the unresolved conflicts on the current branch of the
+// instance containing ?path=.
+func conflicts(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        httpkit.WriteError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+        return
+    }
+    instance, _, ok := request.Instance(w, r)
+    if !ok {
+        return
+    }
+
+    open, err := conflictService.List(r.Context(), instance.BranchID)
+    if err != nil {
+        httpkit.WriteError(w, http.StatusInternalServerError, err)
+        return
+    }
+    response := make([]conflictResponse, 0, len(open))
+    for _, conflict := range open {
+        response = append(response, conflictResponse{
+            ID:           conflict.ID,
+            Path:         conflict.Path,
+            Reason:       conflict.Reason,
+            ChangeID:     conflict.ChangeID,
+            CreationDate: conflict.CreationDate,
+            Base:         conflict.Base,
+            Ours:         conflict.Ours,
+            Theirs:       conflict.Theirs,
+        })
+    }
+    httpkit.WriteJSON(w, http.StatusOK, response)
+}
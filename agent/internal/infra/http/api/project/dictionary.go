@@ -0,0 +1,45 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"vcx/agent/internal/infra/http/api/request"
+	"vcx/agent/internal/services/dictionary"
+	"vcx/pkg/toolkit/httpkit"
+)
+
+
+// compressionDictionary reports (GET) how the compression dictionary of the
+// project containing ?path= compares with plain compression. POST first
+// trains a new dictionary from the project's text blobs.
+func compressionDictionary(w http.ResponseWriter, r *http.Request) {
+    instance, _, ok := request.Instance(w, r)
+    if !ok {
+        return
+    }
+
+    switch r.Method {
+    case http.MethodGet:
+    case http.MethodPost:
+        if _, err := dictionary.Train(r.Context(), instance.ProjectID); err != nil {
+            status := http.StatusInternalServerError
+            if errors.Is(err, dictionary.ErrTooFewSamples) {
+                status = http.StatusUnprocessableEntity
+            }
+            httpkit.WriteError(w, status, err)
+            return
+        }
+    default:
+        httpkit.WriteError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+        return
+    }
+
+    stats, err := dictionary.GetStats(r.Context(), instance.ProjectID)
+    if err != nil {
+        log.Error("Failed to measure dictionary compression", "project", instance.ProjectID, "error", err)
+        httpkit.WriteError(w, http.StatusInternalServerError, err)
+        return
+    }
+    httpkit.WriteJSON(w, http.StatusOK, stats)
+}
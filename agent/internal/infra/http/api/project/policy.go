@@ -0,0 +1,51 @@
+package project
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"vcx/agent/internal/infra/http/api/request"
+	"vcx/agent/internal/services/policy"
+	"vcx/pkg/toolkit/httpkit"
+)
+
+
+type policyResponse struct {
+    ProjectID string        `json:"projectID"`
+    Policy    policy.Policy `json:"policy"`
+}
+
+
+// snapshotPolicy reads (GET) or updates (POST) the auto-snapshot policy of
+// the project containing ?path=. POST takes a JSON policy; omitted fields
+// keep their current value. Running watchers pick it up on their next poll.
+func snapshotPolicy(w http.ResponseWriter, r *http.Request) {
+    instance, _, ok := request.Instance(w, r)
+    if !ok {
+        return
+    }
+
+    current := policy.GetForProject(r.Context(), instance.ProjectID)
+
+    switch r.Method {
+    case http.MethodGet:
+    case http.MethodPost:
+        if err := json.NewDecoder(r.Body).Decode(&current); err != nil {
+            httpkit.WriteError(w, http.StatusBadRequest, err)
+            return
+        }
+        if err := current.Validate(); err != nil {
+            httpkit.WriteError(w, http.StatusBadRequest, err)
+            return
+        }
+        if err := policy.SetForProject(r.Context(), instance.ProjectID, current); err != nil {
+            httpkit.WriteError(w, http.StatusInternalServerError, err)
+            return
+        }
+    default:
+        httpkit.WriteError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+        return
+    }
+
+    httpkit.WriteJSON(w, http.StatusOK, policyResponse{ProjectID: instance.ProjectID, Policy: current})
+}
@@ -21,6 +21,15 @@ func Handler() http.Handler {
     // Register routes
     mux.HandleFunc("/init", initProject)
     mux.HandleFunc("/init-stream", initProjectStream)
+    mux.HandleFunc("/policy", snapshotPolicy)
+    mux.HandleFunc("/restore", restoreProject)
+    mux.HandleFunc("/dictionary", compressionDictionary)
+    mux.HandleFunc("/branch", branches)
+    mux.HandleFunc("/switch", switchBranch)
+    mux.HandleFunc("/merge", mergeBranch)
+    mux.HandleFunc("/conflicts", conflicts)
+    mux.HandleFunc("/files", files)
+    mux.HandleFunc("/rescan", rescanProject)
 
     return http.StripPrefix(APIPath, mux)
 }
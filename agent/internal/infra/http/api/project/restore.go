@@ -0,0 +1,61 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"vcx/agent/internal/infra/http/api/request"
+	"vcx/agent/internal/services/restore"
+	"vcx/pkg/toolkit/httpkit"
+)
+
+
+type restoreErrorResponse struct {
+    Error string        `json:"error"`
+    Plan  *restore.Plan `json:"plan"`
+}
+
+
+// restoreProject rolls the project instance containing ?path= back to
+// ?to=<changeID> or ?at=<time>. Flags: dryRun, force.
+func restoreProject(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        httpkit.WriteError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+        return
+    }
+    instance, _, ok := request.Instance(w, r)
+    if !ok {
+        return
+    }
+
+    options := restore.ProjectOptions{
+        ChangeID: r.URL.Query().Get("to"),
+        DryRun:   request.Bool(r, "dryRun"),
+        Force:    request.Bool(r, "force"),
+    }
+    var err error
+    if options.At, err = request.Time(r, "at"); err != nil {
+        httpkit.WriteError(w, http.StatusBadRequest, err)
+        return
+    }
+    if options.ChangeID == "" && options.At.IsZero() {
+        httpkit.WriteError(w, http.StatusBadRequest, fmt.Errorf("missing to or at"))
+        return
+    }
+
+    plan, err := restore.Project(r.Context(), instance, options)
+    switch {
+    case err == nil:
+        httpkit.WriteJSON(w, http.StatusOK, plan)
+    case errors.Is(err, restore.ErrLocalModifications):
+        httpkit.WriteJSON(w, http.StatusConflict, restoreErrorResponse{Error: err.Error(), Plan: plan})
+    case errors.Is(err, restore.ErrUnsafePath):
+        log.Warn("Refused to restore project", "path", instance.Path, "error", err)
+        httpkit.WriteJSON(w, http.StatusConflict, restoreErrorResponse{Error: err.Error(), Plan: plan})
+    case errors.Is(err, restore.ErrChangeNotFound):
+        httpkit.WriteError(w, http.StatusNotFound, err)
+    default:
+        log.Error("Failed to restore project", "path", instance.Path, "error", err)
+        httpkit.WriteJSON(w, http.StatusInternalServerError, restoreErrorResponse{Error: err.Error(), Plan: plan})
+    }
+}
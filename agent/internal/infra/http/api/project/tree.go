@@ -0,0 +1,90 @@
+package project
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"vcx/agent/internal/consts/linktype"
+	fileDomain "vcx/agent/internal/domains/file"
+	"vcx/agent/internal/infra/http/api/request"
+	"vcx/agent/internal/services/rescan"
+	"vcx/pkg/toolkit/httpkit"
+)
+
+
+type fileResponse struct {
+    Path      string `json:"path"`
+    Type      string `json:"type"`
+    BlobID    string `json:"blobID,omitempty"`
+    Target    string `json:"target,omitempty"`
+    LinkType  string `json:"linkType,omitempty"`
+    ChangeID  string `json:"changeID"`
+    IsDeleted bool   `json:"isDeleted"`
+}
+
+
+// files lists the current tree of the branch checked out in the instance
+// containing ?path=, ordered by path. Flag: deleted, to include deleted
+// files.
+func files(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        httpkit.WriteError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+        return
+    }
+    instance, _, ok := request.Instance(w, r)
+    if !ok {
+        return
+    }
+
+    var tree []*fileDomain.File
+    var err error
+    if request.Bool(r, "deleted") {
+        tree, err = fileDomain.GetByBranch(r.Context(), instance.BranchID)
+    } else {
+        tree, err = fileDomain.GetCurrent(r.Context(), instance.BranchID)
+    }
+    if err != nil {
+        httpkit.WriteError(w, http.StatusInternalServerError, err)
+        return
+    }
+    sort.Slice(tree, func(i, j int) bool { return tree[i].Path < tree[j].Path })
+
+    response := make([]fileResponse, 0, len(tree))
+    for _, file := range tree {
+        entry := fileResponse{
+            Path:      file.Path,
+            Type:      file.Type.ToString(),
+            BlobID:    file.BlobID,
+            Target:    file.Target,
+            ChangeID:  file.ChangeID,
+            IsDeleted: file.IsDeleted,
+        }
+        if file.LinkType != linktype.NONE {
+            entry.LinkType = file.LinkType.ToString()
+        }
+        response = append(response, entry)
+    }
+    httpkit.WriteJSON(w, http.StatusOK, response)
+}
+
+
+// rescanProject records what changed in the working copy of the instance
+// containing ?path= without the file monitor noticing, deletions included.
+func rescanProject(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        httpkit.WriteError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+        return
+    }
+    instance, _, ok := request.Instance(w, r)
+    if !ok {
+        return
+    }
+
+    result, err := rescan.Instance(r.Context(), instance)
+    if err != nil {
+        log.Error("Failed to rescan project", "path", instance.Path, "error", err)
+        httpkit.WriteError(w, http.StatusInternalServerError, err)
+        return
+    }
+    httpkit.WriteJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,74 @@
+// Package request holds helpers shared by the API handlers for reading
+// common query parameters.
+package request
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	instanceDomain "vcx/agent/internal/domains/instance"
+	instanceService "vcx/agent/internal/services/instance"
+	"vcx/pkg/toolkit/httpkit"
+	"vcx/pkg/toolkit/timekit"
+)
+
+
+// Instance resolves the absolute ?path= parameter to the instance that
+// contains it and the path relative to the instance root. On failure the
+// error response has been written and ok is false.
+func Instance(w http.ResponseWriter, r *http.Request) (instance *instanceDomain.Instance, relPath string, ok bool) {
+    path := r.URL.Query().Get("path")
+    if path == "" {
+        httpkit.WriteError(w, http.StatusBadRequest, fmt.Errorf("missing path"))
+        return nil, "", false
+    }
+
+    instance, relPath, err := instanceService.FindByPath(r.Context(), path)
+    if err != nil {
+        status := http.StatusInternalServerError
+        if errors.Is(err, instanceService.ErrNoInstance) {
+            status = http.StatusNotFound
+        }
+        httpkit.WriteError(w, status, err)
+        return nil, "", false
+    }
+    return instance, relPath, true
+}
+
+
+// Int reads an optional non-negative integer parameter.
+func Int(r *http.Request, name string) (int, error) {
+    value := r.URL.Query().Get(name)
+    if value == "" {
+        return 0, nil
+    }
+    n, err := strconv.Atoi(value)
+    if err != nil || n < 0 {
+        return 0, fmt.Errorf("invalid %s %q", name, value)
+    }
+    return n, nil
+}
+
+
+// Time reads an optional point in time, see timekit.ParseTime for the
+// accepted forms. A missing parameter yields the zero time.
+func Time(r *http.Request, name string) (time.Time, error) {
+    value := r.URL.Query().Get(name)
+    if value == "" {
+        return time.Time{}, nil
+    }
+    t, err := timekit.ParseTime(value, time.Now())
+    if err != nil {
+        return time.Time{}, fmt.Errorf("invalid %s: %w", name, err)
+    }
+    return t, nil
+}
+
+
+// Bool reads an optional boolean parameter ("1", "true", ...).
+func Bool(r *http.Request, name string) bool {
+    value, _ := strconv.ParseBool(r.URL.Query().Get(name))
+    return value
+}
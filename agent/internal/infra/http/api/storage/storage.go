@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"vcx/agent/internal/infra/http/api/request"
+	"vcx/agent/internal/services/encryption"
+	"vcx/agent/internal/services/fsck"
+	"vcx/agent/internal/services/gc"
+	"vcx/agent/internal/services/repack"
+	"vcx/pkg/logging"
+	"vcx/pkg/toolkit/httpkit"
+)
+
+var log = logging.GetLogger()
+
+const APIPath = "/api/storage"
+
+
+func Handler() http.Handler {
+    // Create submux for storage routes
+    mux := http.NewServeMux()
+
+    // Register routes
+    mux.HandleFunc("/gc", collectGarbage)
+    mux.HandleFunc("/fsck", verify)
+    mux.HandleFunc("/repack", repackBlobs)
+    mux.HandleFunc("/encryption", encryptBlobs)
+
+    return http.StripPrefix(APIPath, mux)
+}
+
+
+// collectGarbage runs the blob garbage collector and returns its report.
+// Optional: dryRun, grace (a Go duration such as 1h, default 24h).
+func collectGarbage(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        httpkit.WriteError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+        return
+    }
+
+    options       := gc.DefaultOptions()
+    options.DryRun = request.Bool(r, "dryRun")
+    if grace := r.URL.Query().Get("grace"); grace != "" {
+        duration, err := time.ParseDuration(grace)
+        if err != nil || duration < 0 {
+            httpkit.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid grace %q", grace))
+            return
+        }
+        options.GracePeriod = duration
+    }
+
+    report, err := gc.Run(r.Context(), options)
+    if err != nil {
+        log.Error("Garbage collection failed", "error", err)
+        httpkit.WriteError(w, http.StatusInternalServerError, err)
+        return
+    }
+    httpkit.WriteJSON(w, http.StatusOK, report)
+}
+
+
+// verify checks the integrity of the blob storage and returns the problems
+// found. With POST ?repair=true, sizes and reference counters are fixed.
+func verify(w http.ResponseWriter, r *http.Request) {
+    var options fsck.Options
+    switch r.Method {
+    case http.MethodGet:
+    case http.MethodPost:
+        options.Repair = request.Bool(r, "repair")
+    default:
+        httpkit.WriteError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+        return
+    }
+
+    report, err := fsck.Check(r.Context(), options)
+    if err != nil {
+        log.Error("Storage verification failed", "error", err)
+        httpkit.WriteError(w, http.StatusInternalServerError, err)
+        return
+    }
+    httpkit.WriteJSON(w, http.StatusOK, report)
+}
+
+
+// repackBlobs moves blobs from the DB and loose files into packs and returns
+// the report. Optional: dryRun.
+func repackBlobs(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        httpkit.WriteError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+        return
+    }
+
+    report, err := repack.Run(r.Context(), repack.Options{DryRun: request.Bool(r, "dryRun")})
+    if err != nil {
+        log.Error("Repack failed", "error", err)
+        httpkit.WriteError(w, http.StatusInternalServerError, err)
+        return
+    }
+    httpkit.WriteJSON(w, http.StatusOK, report)
+}
+
+
+type encryptionRequest struct {
+    encryption.Options
+    Off       bool `json:"off"`
+    Reencrypt bool `json:"reencrypt"`
+}
+
+
+// encryptBlobs returns the encryption status (GET) or changes it (POST) and
+// returns the reencryption report. POST takes a JSON body: algorithm with a
+// keyfile or passphrase to enable, off to disable, or reencrypt to reseal
+// what an earlier run left over.
+func encryptBlobs(w http.ResponseWriter, r *http.Request) {
+    switch r.Method {
+    case http.MethodGet:
+        status, err := encryption.GetStatus(r.Context())
+        if err != nil {
+            httpkit.WriteError(w, http.StatusInternalServerError, err)
+            return
+        }
+        httpkit.WriteJSON(w, http.StatusOK, status)
+        return
+    case http.MethodPost:
+    default:
+        httpkit.WriteError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+        return
+    }
+
+    var body encryptionRequest
+    if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+        httpkit.WriteError(w, http.StatusBadRequest, err)
+        return
+    }
+
+    var report *encryption.Report
+    var err error
+    switch {
+    case body.Off:
+        report, err = encryption.Disable(r.Context())
+    case body.Reencrypt:
+        report, err = encryption.Reencrypt(r.Context())
+    default:
+        report, err = encryption.Enable(r.Context(), body.Options)
+    }
+    if errors.Is(err, encryption.ErrNoKey) || errors.Is(err, encryption.ErrWrongKey) || errors.Is(err, encryption.ErrCipher) {
+        httpkit.WriteError(w, http.StatusBadRequest, err)
+        return
+    }
+    if err != nil {
+        log.Error("Changing encryption failed", "error", err)
+        httpkit.WriteError(w, http.StatusInternalServerError, err)
+        return
+    }
+    httpkit.WriteJSON(w, http.StatusOK, report)
+}
@@ -2,11 +2,16 @@ package server
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"time"
+	"vcx/agent/internal/infra/http/api/diff"
+	"vcx/agent/internal/infra/http/api/file"
 	"vcx/agent/internal/infra/http/api/project"
+	"vcx/agent/internal/infra/http/api/storage"
 	"vcx/agent/internal/session"
+	"vcx/pkg/toolkit/httpkit"
 )
 
 func contextMiddleware(appCtx context.Context) func(http.Handler) http.Handler {
@@ -23,10 +28,23 @@ func contextMiddleware(appCtx context.Context) func(http.Handler) http.Handler {
 	}
 }
 
+// corsMiddleware opens reads to every origin. Requests that change state
+// get no CORS headers and must carry httpkit.REQUEST_HEADER, so a page on
+// another origin can neither send them without a refused preflight nor
+// read the response.
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !httpkit.IsSafeMethod(r.Method) {
+			if r.Header.Get(httpkit.REQUEST_HEADER) == "" {
+				httpkit.WriteError(w, http.StatusForbidden, fmt.Errorf("missing %s header", httpkit.REQUEST_HEADER))
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
 		if r.Method == "OPTIONS" {
 			return
@@ -41,6 +59,9 @@ func Start(appCtx context.Context) {
 	// Register routes
 	registerRoutes(mux)
 	mux.Handle(project.APIPath+"/", project.Handler())
+	mux.Handle(file.APIPath+"/", file.Handler())
+	mux.Handle(diff.APIPath, diff.Handler())
+	mux.Handle(storage.APIPath+"/", storage.Handler())
 
 	// Chain middleware
 	handler := corsMiddleware(contextMiddleware(appCtx)(mux))
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"vcx/pkg/toolkit/httpkit"
+)
+
+func TestCorsMiddleware(t *testing.T) {
+	reached := false
+	handler := corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+
+	tests := []struct {
+		name        string
+		method      string
+		header      bool
+		reached     bool
+		status      int
+		allowOrigin string
+	}{
+		{"read", "GET", false, true, http.StatusOK, "*"},
+		{"preflight", "OPTIONS", false, false, http.StatusOK, "*"},
+		{"write without header", "POST", false, false, http.StatusForbidden, ""},
+		{"write with header", "POST", true, true, http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reached = false
+			req := httptest.NewRequest(tt.method, "/api/storage/gc", nil)
+			if tt.header {
+				req.Header.Set(httpkit.REQUEST_HEADER, "1")
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			resp := w.Result()
+			if resp.StatusCode != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, resp.StatusCode)
+			}
+			if reached != tt.reached {
+				t.Errorf("Expected handler reached %v, got %v", tt.reached, reached)
+			}
+			if got := resp.Header.Get("Access-Control-Allow-Origin"); got != tt.allowOrigin {
+				t.Errorf("Expected Access-Control-Allow-Origin %q, got %q", tt.allowOrigin, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package blob
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"vcx/agent/internal/consts/keys"
+	"vcx/agent/internal/infra/blobstore"
+	"vcx/agent/internal/infra/db/dbsetup"
+	"vcx/agent/internal/services/simplekv"
+)
+
+// Loose blob files and packs are kept in a blobstore.BlobStore. The default
+// is a local directory at dbsetup.BlobStorePath; ConfigureStore switches to
+// another directory (e.g. a NAS mount) or an S3-compatible bucket.
+//
+// Files are always staged locally below dbsetup.BlobStorePath first, so an
+// upload only starts once the content and its hash are known.
+
+
+const (
+	STORE_LOCAL = "local"
+	STORE_S3    = "s3"
+)
+
+
+var (
+	storeMu    sync.RWMutex
+	configured blobstore.BlobStore
+)
+
+
+// Store returns the blob store in use.
+func Store() blobstore.BlobStore {
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+
+	if configured != nil {
+		return configured
+	}
+	return blobstore.NewLocal(dbsetup.BlobStorePath)
+}
+
+
+// UseStore makes store the blob store in use; nil restores the default.
+// Blobs already stored elsewhere are not moved.
+func UseStore(store blobstore.BlobStore) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	configured = store
+}
+
+
+// ConfigureStore selects the blob store from the settings:
+//   - BLOB_STORE: "local" (default) or "s3"
+//   - BLOB_STORE_PATH: Directory of a local store
+//   - S3_ENDPOINT, S3_BUCKET, S3_REGION, S3_PREFIX: Location of an S3 store
+//
+// S3 credentials are taken from the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY
+// and AWS_SESSION_TOKEN environment variables rather than the database.
+func ConfigureStore(ctx context.Context) error {
+	kind, _ := simplekv.GetString(ctx, keys.BLOB_STORE)
+
+	switch kind {
+	case "", STORE_LOCAL:
+		path, _ := simplekv.GetString(ctx, keys.BLOB_STORE_PATH)
+		if path == "" {
+			UseStore(nil)
+			return nil
+		}
+		UseStore(blobstore.NewLocal(path))
+		log.Info("Using local blob store", "path", path)
+		return nil
+
+	case STORE_S3:
+		config := blobstore.S3Config{
+			AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		}
+		config.Endpoint, _ = simplekv.GetString(ctx, keys.S3_ENDPOINT)
+		config.Bucket, _   = simplekv.GetString(ctx, keys.S3_BUCKET)
+		config.Region, _   = simplekv.GetString(ctx, keys.S3_REGION)
+		config.Prefix, _   = simplekv.GetString(ctx, keys.S3_PREFIX)
+
+		store, err := blobstore.NewS3(config)
+		if err != nil {
+			return fmt.Errorf("invalid S3 blob store settings: %w", err)
+		}
+		UseStore(store)
+		log.Info("Using S3 blob store", "endpoint", config.Endpoint, "bucket", config.Bucket, "prefix", config.Prefix)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown blob store %q", kind)
+	}
+}
@@ -3,23 +3,36 @@
 // The blob service handles:
 //   - Reading file content
 //   - Binary detection (null byte check)
-//   - Compression (zstd for non-binary files)
-//   - Storage strategy (DB vs filesystem based on size)
+//   - Compression (zstd for non-binary files, small ones with a project dictionary)
+//   - Optional encryption at rest of the stored data (see encryption.go)
+//   - Storage strategy (DB vs blob store based on size)
 //   - Deduplication (content-addressable by SHA256)
-//   - Filesystem sharding (2-character prefix like Git)
+//   - Blob store sharding (2-character prefix like Git) on the local
+//     filesystem or an S3-compatible bucket
+//   - Delta storage against the previous version of the same file
+//   - Streaming ingestion of files too large to hold in memory
+//   - Content-defined chunking of large files (chunk blobs plus a manifest)
+//   - Pack files collecting small blobs out of the DB and shard directories
+//
+// Delta chains are capped at MAX_DELTA_DEPTH: the version after that is
+// stored whole again, which bounds the work needed to read any blob. A delta
+// blob holds a reference on its base and a manifest one on each of its
+// chunks, so neither is collected while still needed.
 package blob
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"os"
-	"path/filepath"
 
+	"vcx/agent/internal/consts/keys"
 	blobDomain "vcx/agent/internal/domains/blob"
-	"vcx/agent/internal/infra/db/dbsetup"
+	"vcx/agent/internal/services/simplekv"
 	"vcx/pkg/logging"
 	"vcx/pkg/toolkit/compressionkit"
 	"vcx/pkg/toolkit/cryptokit"
+	"vcx/pkg/toolkit/deltakit"
 	"vcx/pkg/toolkit/filekit"
 )
 
@@ -29,6 +42,10 @@ var log = logging.GetLogger()
 
 const (
 	MAX_DB_BLOB_SIZE = 512 * 1024 // 512KB - optimal for cloud sync and SQLite performance
+
+	MAX_DELTA_DEPTH = 16       // deltas in a row before a version is stored whole again
+	MIN_DELTA_SIZE  = 4 * 1024 // smaller content is always stored whole
+	MAX_DELTA_RATIO = 0.5      // keep a delta only below this fraction of the full blob
 )
 
 
@@ -41,15 +58,53 @@ const (
 //
 // Storage locations:
 //   - DB: blob.Blob contains data, blob.FilePath is empty
-//   - Filesystem: blob.Blob is nil, blob.FilePath is ~/.vcx/data/blobs/XX/YYYYYY
+//   - Blob store: blob.Blob is nil, blob.FilePath is the key XX/YYYYYY (see
+//     backend.go), by default below ~/.vcx/data/blobs
+//
+// The repack job later moves both into pack files (see storage.go).
 //
 // Returns the blob domain object (existing or newly created).
 func Create(ctx context.Context, filePath string) (*blobDomain.Blob, error) {
+	return CreateVersion(ctx, filePath, "")
+}
+
+
+// CreateVersion works like Create for a new version of a file whose previous
+// content is stored in prevBlobID. When delta storage is enabled, the new
+// blob is stored as a delta against prevBlobID if that is clearly smaller
+// than storing it whole. Files above CHUNK_THRESHOLD are split into chunks
+// (or, with chunked storage off, above STREAM_THRESHOLD streamed to disk
+// whole) and never stored as deltas.
+func CreateVersion(ctx context.Context, filePath, prevBlobID string) (*blobDomain.Blob, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	switch {
+	case info.Size() > CHUNK_THRESHOLD && ChunkedStorageEnabled(ctx):
+		return createChunked(ctx, filePath)
+	case info.Size() > STREAM_THRESHOLD:
+		return createStreamed(ctx, filePath)
+	}
+
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
+	return createFromData(ctx, data, prevBlobID)
+}
+
+
+// CreateFromData works like CreateVersion for content vcx produced itself
+// (a merge result) instead of reading it from a file.
+func CreateFromData(ctx context.Context, data []byte, prevBlobID string) (*blobDomain.Blob, error) {
+	return createFromData(ctx, data, prevBlobID)
+}
 
+
+// createFromData stores content held in memory, deduplicated by its hash and
+// possibly as a delta against prevBlobID.
+func createFromData(ctx context.Context, data []byte, prevBlobID string) (*blobDomain.Blob, error) {
 	// Calculate hash (this will be the blob ID)
 	hashStr := cryptokit.SHA256Hex(data)
 
@@ -64,40 +119,183 @@ func Create(ctx context.Context, filePath string) (*blobDomain.Blob, error) {
 		return existingBlob, nil
 	}
 
+	size         := int64(len(data))
 	isBinary     := filekit.IsBinary(data)
+	stored       := data
 	isCompressed := false
+	dictID       := 0
 
 	// Try compression for non-binary data
 	if !isBinary {
-		data, isCompressed = compressionkit.Compress(data)
+		stored, isCompressed, dictID = compress(ctx, data)
+	}
+
+	if prevBlobID != "" && size >= MIN_DELTA_SIZE && DeltaStorageEnabled(ctx) {
+		base, delta, ok := encodeDelta(ctx, prevBlobID, data, len(stored))
+		if ok {
+			deltaData, deltaCompressed := compressionkit.Compress(delta)
+			blob, err := store(ctx, hashStr, deltaData, deltaCompressed, isBinary, size, base.ID, base.DeltaDepth+1, 0)
+			if err != nil {
+				return nil, err
+			}
+			if err := base.IncrementRefCounter(ctx); err != nil {
+				return nil, fmt.Errorf("failed to reference delta base: %w", err)
+			}
+			log.Debug("Stored blob as delta", "hash", hashStr, "base", base.ID, "depth", blob.DeltaDepth, "stored", len(deltaData), "size", size)
+			return blob, nil
+		}
+	}
+
+	return store(ctx, hashStr, stored, isCompressed, isBinary, size, "", 0, dictID)
+}
+
+
+// DeltaStorageEnabled reports whether new versions may be stored as deltas.
+func DeltaStorageEnabled(ctx context.Context) bool {
+	value, err := simplekv.GetString(ctx, keys.DELTA_STORAGE)
+	return err != nil || value != "off"
+}
+
+
+// SetDeltaStorage turns delta storage for new versions on or off. Existing
+// blobs are not affected.
+func SetDeltaStorage(ctx context.Context, enabled bool) error {
+	value := "on"
+	if !enabled {
+		value = "off"
+	}
+	return simplekv.SetString(ctx, keys.DELTA_STORAGE, value)
+}
+
+
+// encodeDelta returns the delta of data against baseID, or false when a full
+// blob should be stored instead: the base is missing, the chain is at its
+// maximum depth (re-basing), or the delta is not worth it.
+func encodeDelta(ctx context.Context, baseID string, data []byte, fullSize int) (*blobDomain.Blob, []byte, bool) {
+	base, err := blobDomain.GetByID(ctx, baseID)
+	if err != nil || base.DeltaDepth >= MAX_DELTA_DEPTH || base.IsManifest || base.Size > STREAM_THRESHOLD {
+		return nil, nil, false
+	}
+
+	baseContent, err := ReadBlob(ctx, base)
+	if err != nil {
+		log.Warn("Cannot read delta base, storing full blob", "base", baseID, "error", err)
+		return nil, nil, false
+	}
+
+	delta := deltakit.Encode(baseContent, data)
+	if float64(len(delta)) >= float64(fullSize)*MAX_DELTA_RATIO {
+		return nil, nil, false
+	}
+	return base, delta, true
+}
+
+
+// store persists prepared blob data in the DB or in the blob store, sealed
+// if encryption is on.
+func store(ctx context.Context, hashStr string, data []byte, isCompressed, isBinary bool, size int64, baseBlobID string, deltaDepth, dictID int) (*blobDomain.Blob, error) {
+	data, keyID, err := Seal(data)
+	if err != nil {
+		return nil, err
 	}
 
-	// Decide: DB or filesystem
+	// Decide: DB or blob store
 	if len(data) <= MAX_DB_BLOB_SIZE {
 		// Store in DB - no filepath needed
-		return blobDomain.New(ctx, hashStr, data, "", isCompressed, isBinary)
+		return blobDomain.New(ctx, hashStr, data, "", isCompressed, isBinary, size, baseBlobID, deltaDepth, dictID, keyID)
 	}
 
-	// Store on filesystem - filepath points to storage location
-	blobPath, err := writeToDisk(data, hashStr)
+	// Store in the blob store - filepath is the key of the stored file
+	blobKey, err := writeToStore(ctx, data, hashStr, keyID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to write blob to disk: %w", err)
+		return nil, fmt.Errorf("failed to write blob to store: %w", err)
 	}
-	return blobDomain.New(ctx, hashStr, nil, blobPath, isCompressed, isBinary)
+	return blobDomain.New(ctx, hashStr, nil, blobKey, isCompressed, isBinary, size, baseBlobID, deltaDepth, dictID, keyID)
 }
 
 
-func writeToDisk(data []byte, hashStr string) (string, error) {
-	// Shard by first 2 characters (like Git)
-	shardDir := filepath.Join(dbsetup.BlobStorePath, hashStr[:2])
-	if err := os.MkdirAll(shardDir, 0755); err != nil {
-		return "", err
+// Retain adds a reference to an existing blob, for a new version that
+// reuses stored content (e.g. a restore).
+func Retain(ctx context.Context, id string) error {
+	blob, err := blobDomain.GetByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("blob %s not found: %w", id, err)
+	}
+	return blob.IncrementRefCounter(ctx)
+}
+
+
+// HashFile returns the blob ID the file's current content would be stored under,
+// without creating a blob. Used to skip ingestion when content is unchanged.
+func HashFile(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	return cryptokit.SHA256HexReader(f)
+}
+
+
+// Read returns the original content of a blob, wherever it is stored.
+func Read(ctx context.Context, id string) ([]byte, error) {
+	blob, err := blobDomain.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("blob %s not found: %w", id, err)
 	}
+	return ReadBlob(ctx, blob)
+}
+
+
+// ReadBlob returns the original content of an already loaded blob. Delta
+// blobs are rebuilt by applying their chain on top of the full base, chunked
+// content by concatenating its chunks. Use Open for content that may not fit
+// in memory.
+func ReadBlob(ctx context.Context, blob *blobDomain.Blob) ([]byte, error) {
+	if blob.IsManifest {
+		return readChunked(ctx, blob)
+	}
+
+	// collect the chain down to a full blob
+	chain   := []*blobDomain.Blob{blob}
+	visited := map[string]bool{blob.ID: true}
+	for chain[len(chain)-1].IsDelta() {
+		baseID := chain[len(chain)-1].BaseBlobID
+		if visited[baseID] {
+			return nil, fmt.Errorf("delta chain of blob %s loops at %s", blob.ID, baseID)
+		}
+		visited[baseID] = true
+
+		base, err := blobDomain.GetByID(ctx, baseID)
+		if err != nil {
+			return nil, fmt.Errorf("delta base %s of blob %s not found: %w", baseID, blob.ID, err)
+		}
+		chain = append(chain, base)
+	}
+
+	content, err := readStored(ctx, chain[len(chain)-1])
+	if err != nil {
+		return nil, err
+	}
+	for i := len(chain) - 2; i >= 0; i-- {
+		delta, err := readStored(ctx, chain[i])
+		if err != nil {
+			return nil, err
+		}
+		if content, err = deltakit.Apply(content, delta); err != nil {
+			return nil, fmt.Errorf("failed to rebuild blob %s: %w", chain[i].ID, err)
+		}
+	}
+	return content, nil
+}
+
 
-	path := filepath.Join(shardDir, hashStr[2:])
-	if err := os.WriteFile(path, data, 0644); err != nil {
+func writeToStore(ctx context.Context, data []byte, hashStr, keyID string) (string, error) {
+	key := ShardKey(hashStr, keyID)
+	if err := Store().Put(ctx, key, bytes.NewReader(data), int64(len(data))); err != nil {
 		return "", err
 	}
 
-	return path, nil
+	return key, nil
 }
@@ -0,0 +1,264 @@
+package blob
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"vcx/agent/internal/consts/keys"
+	blobDomain "vcx/agent/internal/domains/blob"
+	"vcx/agent/internal/services/simplekv"
+	"vcx/pkg/toolkit/chunkkit"
+	"vcx/pkg/toolkit/compressionkit"
+	"vcx/pkg/toolkit/filekit"
+)
+
+
+const (
+	CHUNK_THRESHOLD = 16 * 1024 * 1024 // larger files are split into content-defined chunks
+	MANIFEST_MAGIC  = "VXM1"
+)
+
+
+// Chunk is one entry of a manifest: a chunk blob and its content length.
+type Chunk struct {
+	ID   string
+	Size int64
+}
+
+
+// ChunkedStorageEnabled reports whether large files are split into chunks.
+func ChunkedStorageEnabled(ctx context.Context) bool {
+	value, err := simplekv.GetString(ctx, keys.CHUNKED_STORAGE)
+	return err != nil || value != "off"
+}
+
+
+// SetChunkedStorage turns chunking of large files on or off. Existing blobs
+// are not affected.
+func SetChunkedStorage(ctx context.Context, enabled bool) error {
+	value := "on"
+	if !enabled {
+		value = "off"
+	}
+	return simplekv.SetString(ctx, keys.CHUNKED_STORAGE, value)
+}
+
+
+// createChunked splits a large file with FastCDC and stores every chunk as
+// its own content-addressed blob, so regions unchanged between versions (or
+// shared with other files) are stored once. The file's blob is a manifest
+// listing the chunks; its ID is still the hash of the whole content. Only
+// one chunk is held in memory at a time.
+func createChunked(ctx context.Context, filePath string) (blob *blobDomain.Blob, err error) {
+	src, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer src.Close()
+
+	hasher := sha256.New()
+	chunker, err := chunkkit.NewChunker(io.TeeReader(src, hasher), chunkkit.DefaultOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	// references taken on chunks are released again if the manifest is not stored
+	var chunks []Chunk
+	defer func() {
+		if err != nil {
+			releaseChunks(ctx, chunks)
+		}
+	}()
+
+	var size int64
+	isBinary := false
+	for {
+		data, err := chunker.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		if len(chunks) == 0 {
+			isBinary = filekit.IsBinary(data)
+		}
+
+		chunk, err := createFromData(ctx, data, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to store chunk: %w", err)
+		}
+		chunks = append(chunks, Chunk{ID: chunk.ID, Size: int64(len(data))})
+		size  += int64(len(data))
+	}
+
+	hashStr := hex.EncodeToString(hasher.Sum(nil))
+
+	if existingBlob, lookupErr := blobDomain.GetByID(ctx, hashStr); lookupErr == nil {
+		// the existing blob already references its chunks
+		releaseChunks(ctx, chunks)
+		if err = existingBlob.IncrementRefCounter(ctx); err != nil {
+			chunks = nil
+			return nil, fmt.Errorf("failed to increment ref counter: %w", err)
+		}
+		log.Debug("Reusing existing blob", "hash", hashStr, "refCounter", existingBlob.RefCounter)
+		return existingBlob, nil
+	}
+
+	manifest, isCompressed := compressionkit.Compress(encodeManifest(chunks))
+	manifest, keyID, err := Seal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	if len(manifest) <= MAX_DB_BLOB_SIZE {
+		blob, err = blobDomain.NewManifest(ctx, hashStr, manifest, "", isCompressed, isBinary, size, keyID)
+	} else {
+		var blobKey string
+		if blobKey, err = writeToStore(ctx, manifest, hashStr, keyID); err != nil {
+			return nil, fmt.Errorf("failed to write manifest to store: %w", err)
+		}
+		blob, err = blobDomain.NewManifest(ctx, hashStr, nil, blobKey, isCompressed, isBinary, size, keyID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug("Stored chunked blob", "hash", hashStr, "chunks", len(chunks), "size", size)
+	return blob, nil
+}
+
+
+// ManifestChunks returns the chunks listed by a manifest blob.
+func ManifestChunks(ctx context.Context, id string) ([]Chunk, error) {
+	blob, err := blobDomain.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("blob %s not found: %w", id, err)
+	}
+	if !blob.IsManifest {
+		return nil, fmt.Errorf("blob %s is not chunked", id)
+	}
+
+	data, err := readStored(ctx, blob)
+	if err != nil {
+		return nil, err
+	}
+	chunks, err := decodeManifest(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", id, err)
+	}
+	return chunks, nil
+}
+
+
+// releaseChunks drops one reference from every listed chunk.
+func releaseChunks(ctx context.Context, chunks []Chunk) {
+	for _, chunk := range chunks {
+		blob, err := blobDomain.GetByID(ctx, chunk.ID)
+		if err != nil {
+			continue
+		}
+		if err := blob.DecrementRefCounter(ctx); err != nil {
+			log.Warn("Failed to release chunk", "chunk", chunk.ID, "error", err)
+		}
+	}
+}
+
+
+func readChunked(ctx context.Context, blob *blobDomain.Blob) ([]byte, error) {
+	chunks, err := ManifestChunks(ctx, blob.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	content := make([]byte, 0, blob.Size)
+	for _, chunk := range chunks {
+		data, err := Read(ctx, chunk.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read chunk of blob %s: %w", blob.ID, err)
+		}
+		content = append(content, data...)
+	}
+	return content, nil
+}
+
+
+// chunkReader streams chunked content, opening one chunk at a time.
+type chunkReader struct {
+	ctx     context.Context
+	chunks  []Chunk
+	current io.ReadCloser
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.chunks) == 0 {
+				return 0, io.EOF
+			}
+			current, err := Open(r.ctx, r.chunks[0].ID)
+			if err != nil {
+				return 0, err
+			}
+			r.current = current
+			r.chunks  = r.chunks[1:]
+		}
+
+		n, err := r.current.Read(p)
+		if errors.Is(err, io.EOF) {
+			r.current.Close()
+			r.current = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (r *chunkReader) Close() error {
+	if r.current != nil {
+		return r.current.Close()
+	}
+	return nil
+}
+
+
+// encodeManifest renders the chunk list as "VXM1" followed by one
+// "<chunkID> <size>" line per chunk.
+func encodeManifest(chunks []Chunk) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(MANIFEST_MAGIC + "\n")
+	for _, chunk := range chunks {
+		fmt.Fprintf(&buf, "%s %d\n", chunk.ID, chunk.Size)
+	}
+	return buf.Bytes()
+}
+
+
+func decodeManifest(data []byte) ([]Chunk, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	if !scanner.Scan() || scanner.Text() != MANIFEST_MAGIC {
+		return nil, fmt.Errorf("bad magic")
+	}
+
+	var chunks []Chunk
+	for scanner.Scan() {
+		id, sizeText, ok := strings.Cut(scanner.Text(), " ")
+		size, err := strconv.ParseInt(sizeText, 10, 64)
+		if !ok || err != nil || id == "" {
+			return nil, fmt.Errorf("bad entry %q", scanner.Text())
+		}
+		chunks = append(chunks, Chunk{ID: id, Size: size})
+	}
+	return chunks, scanner.Err()
+}
@@ -0,0 +1,100 @@
+package blob
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	blobDomain "vcx/agent/internal/domains/blob"
+	dictionaryDomain "vcx/agent/internal/domains/dictionary"
+	"vcx/agent/internal/session"
+	"vcx/pkg/toolkit/compressionkit"
+)
+
+
+const MAX_DICT_INPUT_SIZE = 128 * 1024 // larger content has enough context of its own
+
+
+// dictionaries caches loaded dictionaries by zstd dictionary ID. A trained
+// dictionary never changes, so entries stay valid.
+var dictionaries = struct {
+	sync.Mutex
+	byID map[int]*compressionkit.Dictionary
+}{byID: make(map[int]*compressionkit.Dictionary)}
+
+
+// compress compresses text content, with the newest dictionary of the
+// project in ctx when there is one and the content is small. It returns the
+// data to store, whether it is compressed and the dictionary used (0 for
+// none).
+func compress(ctx context.Context, data []byte) ([]byte, bool, int) {
+	if len(data) <= MAX_DICT_INPUT_SIZE {
+		if dictionary := projectDictionary(ctx); dictionary != nil {
+			if compressed, ok := dictionary.Compress(data); ok {
+				return compressed, true, int(dictionary.ID())
+			}
+		}
+	}
+	stored, isCompressed := compressionkit.Compress(data)
+	return stored, isCompressed, 0
+}
+
+
+// decompress reverses compress for the stored data of blob.
+func decompress(ctx context.Context, blob *blobDomain.Blob, data []byte) ([]byte, error) {
+	if blob.DictID == 0 {
+		return compressionkit.Decompress(data)
+	}
+	dictionary, err := LoadDictionary(ctx, blob.DictID)
+	if err != nil {
+		return nil, err
+	}
+	return dictionary.Decompress(data)
+}
+
+
+// projectDictionary returns the dictionary new blobs of the project in ctx
+// are compressed with, or nil.
+func projectDictionary(ctx context.Context) *compressionkit.Dictionary {
+	projectID, err := session.HasProjectID(ctx)
+	if err != nil || projectID == "" {
+		return nil
+	}
+	latest, err := dictionaryDomain.GetLatestForProject(ctx, projectID)
+	if err != nil {
+		return nil
+	}
+
+	dictionary, err := LoadDictionary(ctx, latest.DictID)
+	if err != nil {
+		log.Warn("Cannot load project dictionary, compressing without", "project", projectID, "dictID", latest.DictID, "error", err)
+		return nil
+	}
+	return dictionary
+}
+
+
+// LoadDictionary returns the dictionary with a zstd dictionary ID.
+func LoadDictionary(ctx context.Context, dictID int) (*compressionkit.Dictionary, error) {
+	dictionaries.Lock()
+	defer dictionaries.Unlock()
+
+	if dictionary, ok := dictionaries.byID[dictID]; ok {
+		return dictionary, nil
+	}
+
+	stored, err := dictionaryDomain.GetByDictID(ctx, dictID)
+	if err != nil {
+		return nil, fmt.Errorf("dictionary %d not found: %w", dictID, err)
+	}
+	data, err := Unseal(stored.Data, stored.KeyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt dictionary %d: %w", dictID, err)
+	}
+	dictionary, err := compressionkit.NewDictionary(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load dictionary %d: %w", dictID, err)
+	}
+	dictionaries.byID[dictID] = dictionary
+	return dictionary, nil
+}
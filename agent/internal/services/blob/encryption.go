@@ -0,0 +1,291 @@
+package blob
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	blobDomain "vcx/agent/internal/domains/blob"
+	"vcx/agent/internal/infra/blobstore"
+	"vcx/agent/internal/infra/db"
+	"vcx/agent/internal/infra/db/dbsetup"
+	"vcx/pkg/toolkit/cryptokit"
+	"vcx/pkg/toolkit/filekit"
+)
+
+// With encryption at rest, stored data is sealed after compression, so the
+// DB, the blob store and packs only ever hold ciphertext. blob.KeyID names
+// the key; the blob ID stays the hash of the plaintext content, so
+// deduplication is unaffected. Repacking copies the sealed data as is.
+//
+// The key is set up by the encryption service (see UseEncryption); without
+// it encrypted blobs cannot be read.
+
+
+var ErrLocked = errors.New("blob is encrypted with a key that is not loaded")
+
+
+var crypto struct {
+	sync.RWMutex
+	key       *cryptokit.Key
+	algorithm string
+}
+
+
+// UseEncryption loads the key encrypted blobs are read with. New blobs are
+// sealed with algorithm, or stored in plain when it is empty.
+func UseEncryption(key *cryptokit.Key, algorithm string) {
+	crypto.Lock()
+	defer crypto.Unlock()
+
+	crypto.key       = key
+	crypto.algorithm = algorithm
+	if key == nil {
+		crypto.algorithm = ""
+	}
+}
+
+
+// EncryptionKeyID returns the key new blobs are sealed with, or "" when
+// they are stored in plain.
+func EncryptionKeyID() string {
+	crypto.RLock()
+	defer crypto.RUnlock()
+
+	if crypto.algorithm == "" {
+		return ""
+	}
+	return crypto.key.ID()
+}
+
+
+// Seal encrypts data for storage with the current settings. It returns the
+// data to store and the key it was sealed with ("" for none).
+func Seal(data []byte) ([]byte, string, error) {
+	crypto.RLock()
+	key, algorithm := crypto.key, crypto.algorithm
+	crypto.RUnlock()
+
+	if algorithm == "" {
+		return data, "", nil
+	}
+	sealed, err := key.Encrypt(algorithm, data)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encrypt: %w", err)
+	}
+	return sealed, key.ID(), nil
+}
+
+
+// Unseal reverses Seal for data sealed with keyID.
+func Unseal(data []byte, keyID string) ([]byte, error) {
+	if keyID == "" {
+		return data, nil
+	}
+	key, err := LoadedKey(keyID)
+	if err != nil {
+		return nil, err
+	}
+	return key.Decrypt(data)
+}
+
+
+// sealingWriter returns a writer sealing into w with the current settings,
+// w itself when they store in plain, and the key used.
+func sealingWriter(w io.Writer) (io.WriteCloser, string, error) {
+	crypto.RLock()
+	key, algorithm := crypto.key, crypto.algorithm
+	crypto.RUnlock()
+
+	if algorithm == "" {
+		return nopWriteCloser{w}, "", nil
+	}
+	sealer, err := key.NewWriter(w, algorithm)
+	if err != nil {
+		return nil, "", err
+	}
+	return sealer, key.ID(), nil
+}
+
+
+// LoadedKey returns the key with keyID if it is the one loaded.
+func LoadedKey(keyID string) (*cryptokit.Key, error) {
+	crypto.RLock()
+	defer crypto.RUnlock()
+
+	if crypto.key == nil || crypto.key.ID() != keyID {
+		return nil, fmt.Errorf("%w (key %s)", ErrLocked, keyID)
+	}
+	return crypto.key, nil
+}
+
+
+// openPayload returns a reader over the stored data of a blob, decrypted
+// but still compressed.
+func openPayload(ctx context.Context, blob *blobDomain.Blob) (io.ReadCloser, error) {
+	stored, err := OpenStored(ctx, blob)
+	if err != nil || blob.KeyID == "" {
+		return stored, err
+	}
+
+	key, err := LoadedKey(blob.KeyID)
+	if err != nil {
+		stored.Close()
+		return nil, err
+	}
+	plain, err := key.NewReader(stored)
+	if err != nil {
+		stored.Close()
+		return nil, fmt.Errorf("failed to decrypt blob %s: %w", blob.ID, err)
+	}
+	return &streamReader{ReadCloser: io.NopCloser(plain), stored: stored}, nil
+}
+
+
+// readPayload returns the stored data of a blob, decrypted but still
+// compressed.
+func readPayload(ctx context.Context, blob *blobDomain.Blob) ([]byte, error) {
+	data := blob.Blob
+	if blob.IsPacked() || blob.FilePath != "" {
+		reader, err := OpenStored(ctx, blob)
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+
+		if data, err = io.ReadAll(reader); err != nil {
+			return nil, fmt.Errorf("failed to read blob %s: %w", blob.ID, err)
+		}
+	}
+
+	plain, err := Unseal(data, blob.KeyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt blob %s: %w", blob.ID, err)
+	}
+	return plain, nil
+}
+
+
+// Reseal stores the data of a blob again under the current settings:
+// sealed with the loaded key, or in plain when encryption is off. Packed
+// data is unpacked; the repack job packs it again. Loose files in the blob
+// store are resealed as a stream, since they can be of any size. It returns
+// false if the blob is already stored that way or changed meanwhile.
+func Reseal(ctx context.Context, id string) (bool, error) {
+	blob, err := blobDomain.GetByID(ctx, id)
+	if err != nil {
+		return false, fmt.Errorf("blob %s not found: %w", id, err)
+	}
+	if blob.KeyID == EncryptionKeyID() {
+		return false, nil
+	}
+
+	var data []byte
+	var blobKey, keyID string
+	if blob.FilePath != "" && !blob.IsPacked() {
+		if blobKey, keyID, err = resealStreamed(ctx, blob); err != nil {
+			return false, err
+		}
+	} else {
+		payload, err := readPayload(ctx, blob)
+		if err != nil {
+			return false, err
+		}
+		if data, keyID, err = Seal(payload); err != nil {
+			return false, err
+		}
+		if len(data) > MAX_DB_BLOB_SIZE {
+			if blobKey, err = writeToStore(ctx, data, id, keyID); err != nil {
+				return false, fmt.Errorf("failed to write blob to store: %w", err)
+			}
+			data = nil
+		}
+	}
+
+	changed := false
+	err = db.WithTransactionContext(ctx, func(ctx context.Context, _ *sql.Tx) error {
+		current, err := blobDomain.GetByID(ctx, id)
+		if err != nil || current.KeyID != blob.KeyID || current.FilePath != blob.FilePath ||
+			current.PackID != blob.PackID || current.PackOffset != blob.PackOffset {
+			return nil // collected or moved meanwhile
+		}
+		changed = true
+		return current.Restore(ctx, data, blobKey, keyID)
+	})
+	if err != nil || !changed {
+		if blobKey != "" && blobKey != blob.FilePath {
+			Store().Delete(ctx, blobKey)
+		}
+		return false, err
+	}
+
+	if blob.FilePath != "" && blob.FilePath != blobKey {
+		if err := Store().Delete(ctx, blob.FilePath); err != nil {
+			log.Warn("Failed to remove resealed blob file", "key", blob.FilePath, "error", err)
+		}
+	}
+	return true, nil
+}
+
+
+// resealStreamed copies the stored data of a blob, unsealed on the way in
+// and sealed with the current settings on the way out, into a temp file
+// that is then moved into the blob store, as createStreamed does. It
+// returns the key of the new file and the key it was sealed with.
+func resealStreamed(ctx context.Context, blob *blobDomain.Blob) (blobKey, keyID string, err error) {
+	payload, err := openPayload(ctx, blob)
+	if err != nil {
+		return "", "", err
+	}
+	defer payload.Close()
+
+	if err := os.MkdirAll(dbsetup.BlobStorePath, 0755); err != nil {
+		return "", "", err
+	}
+	tmp, err := os.CreateTemp(dbsetup.BlobStorePath, filekit.TEMP_PREFIX+"*")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create temp blob: %w", err)
+	}
+	defer func() {
+		tmp.Close()
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	sealer, keyID, err := sealingWriter(tmp)
+	if err != nil {
+		return "", "", err
+	}
+	if _, err = io.CopyBuffer(sealer, payload, make([]byte, STREAM_BUFFER_SIZE)); err != nil {
+		return "", "", fmt.Errorf("failed to reseal blob %s: %w", blob.ID, err)
+	}
+	if err = sealer.Close(); err != nil {
+		return "", "", fmt.Errorf("failed to finish encryption: %w", err)
+	}
+	if err = tmp.Sync(); err != nil {
+		return "", "", err
+	}
+	if err = tmp.Close(); err != nil {
+		return "", "", err
+	}
+
+	blobKey = ShardKey(blob.ID, keyID)
+	if err = blobstore.PutFile(ctx, Store(), blobKey, tmp.Name()); err != nil {
+		return "", "", fmt.Errorf("failed to move blob into place: %w", err)
+	}
+	return blobKey, keyID, nil
+}
+
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,130 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	blobDomain "vcx/agent/internal/domains/blob"
+	"vcx/agent/internal/infra/blobstore"
+	"vcx/pkg/toolkit/packkit"
+)
+
+// The stored data of a blob lives in one of three places:
+//   - The DB: blob.Blob
+//   - A loose file in a shard directory: blob.FilePath, its key in Store()
+//   - An entry in a pack file: blob.PackID, PackOffset and PackLength
+//
+// Everything reading stored data goes through OpenStored or readStored, so
+// no caller needs to know which one it is.
+
+
+const PACK_DIR = "packs"
+
+
+// Maintenance is held by jobs that move or remove stored data (garbage
+// collection, repacking), so they never run at the same time.
+var Maintenance sync.Mutex
+
+
+// ShardKey returns the key of a loose blob file: the first 2 characters of
+// the hash name its shard directory, like Git. Files sealed with a key carry
+// its ID, so resealing a blob never overwrites the file its row points to.
+func ShardKey(hashStr, keyID string) string {
+	if keyID != "" {
+		return hashStr[:2] + "/" + hashStr[2:] + "." + keyID
+	}
+	return hashStr[:2] + "/" + hashStr[2:]
+}
+
+
+// PackKey returns the key of a pack file.
+func PackKey(packID string) string {
+	return PACK_DIR + "/" + packID + ".pack"
+}
+
+
+// OpenStored returns a reader over the stored data of a blob as it is kept:
+// possibly encrypted and compressed, and the delta for delta blobs. Data
+// kept in the DB requires a fully loaded blob.
+func OpenStored(ctx context.Context, blob *blobDomain.Blob) (io.ReadCloser, error) {
+	switch {
+	case blob.IsPacked():
+		size   := packkit.EntrySize(blob.ID, blob.PackLength)
+		stored, err := blobstore.GetRange(ctx, Store(), PackKey(blob.PackID), blob.PackOffset, size)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read blob %s from pack %s: %w", blob.ID, blob.PackID, err)
+		}
+		data, err := packkit.ReadEntry(stored, blob.ID, blob.PackLength)
+		if err != nil {
+			stored.Close()
+			return nil, fmt.Errorf("failed to read blob %s from pack %s: %w", blob.ID, blob.PackID, err)
+		}
+		return &streamReader{ReadCloser: io.NopCloser(data), stored: stored}, nil
+	case blob.FilePath != "":
+		stored, err := Store().Get(ctx, blob.FilePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read blob %s: %w", blob.ID, err)
+		}
+		return stored, nil
+	default:
+		return io.NopCloser(bytes.NewReader(blob.Blob)), nil
+	}
+}
+
+
+// readStored returns the stored data of a blob, decrypted and decompressed:
+// the content for full blobs, the delta for delta blobs.
+func readStored(ctx context.Context, blob *blobDomain.Blob) ([]byte, error) {
+	data, err := readPayload(ctx, blob)
+	if err != nil {
+		return nil, err
+	}
+
+	if !blob.IsCompressed {
+		return data, nil
+	}
+	content, err := decompress(ctx, blob, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress blob %s: %w", blob.ID, err)
+	}
+	return content, nil
+}
+
+
+// StoredSize returns the number of bytes a blob takes up in storage. Data
+// kept in the DB counts only for a fully loaded blob.
+func StoredSize(ctx context.Context, blob *blobDomain.Blob) int64 {
+	switch {
+	case blob.IsPacked():
+		return blob.PackLength
+	case blob.FilePath != "":
+		size, err := blobstore.Size(ctx, Store(), blob.FilePath)
+		if err != nil {
+			return 0
+		}
+		return size
+	default:
+		return int64(len(blob.Blob))
+	}
+}
+
+
+// StoredKeys returns every key in the blob store that a blob points to:
+// loose blob files and the packs still holding blobs.
+func StoredKeys(ctx context.Context) ([]string, error) {
+	keys, err := blobDomain.GetFilePaths(ctx)
+	if err != nil {
+		return nil, err
+	}
+	packIDs, err := blobDomain.GetPackIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, packID := range packIDs {
+		keys = append(keys, PackKey(packID))
+	}
+	return keys, nil
+}
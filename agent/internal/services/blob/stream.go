@@ -0,0 +1,176 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	blobDomain "vcx/agent/internal/domains/blob"
+	"vcx/agent/internal/infra/blobstore"
+	"vcx/agent/internal/infra/db/dbsetup"
+	"vcx/pkg/toolkit/compressionkit"
+	"vcx/pkg/toolkit/filekit"
+)
+
+
+const (
+	STREAM_THRESHOLD   = 32 * 1024 * 1024 // larger files are never loaded into memory
+	STREAM_BUFFER_SIZE = 1024 * 1024
+)
+
+
+// createStreamed ingests a large file in a single pass: the content is hashed,
+// sniffed for binary data and, for text, compressed with a zstd stream
+// (then sealed if encryption is on) into a local temp file. Once the hash is
+// known the temp file is moved into the blob store (renamed into its shard
+// for a local store), or dropped if the blob already exists. Memory use is
+// bounded by the copy buffer and the encoder window.
+//
+// Unlike in-memory ingestion, compressed output is kept even when it saves
+// little, since the ratio is only known at the end. Temp files left behind
+// by a crash are swept by the garbage collector.
+func createStreamed(ctx context.Context, filePath string) (blob *blobDomain.Blob, err error) {
+	src, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(dbsetup.BlobStorePath, 0755); err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(dbsetup.BlobStorePath, filekit.TEMP_PREFIX+"*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp blob: %w", err)
+	}
+	defer func() {
+		tmp.Close()
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	hasher := sha256.New()
+	reader := io.TeeReader(src, hasher)
+
+	// the first bytes decide whether the content is compressed
+	sample := make([]byte, filekit.BINARY_SAMPLE_SIZE)
+	n, err := io.ReadFull(reader, sample)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	sample   = sample[:n]
+	isBinary := filekit.IsBinary(sample)
+
+	sealer, keyID, err := sealingWriter(tmp)
+	if err != nil {
+		return nil, err
+	}
+	var out io.Writer = sealer
+	var encoder io.WriteCloser
+	if !isBinary {
+		if encoder, err = compressionkit.NewWriter(sealer); err != nil {
+			return nil, err
+		}
+		out = encoder
+	}
+
+	size, err := io.CopyBuffer(out, io.MultiReader(bytes.NewReader(sample), reader), make([]byte, STREAM_BUFFER_SIZE))
+	if err != nil {
+		return nil, fmt.Errorf("failed to stream file into blob store: %w", err)
+	}
+	if encoder != nil {
+		if err = encoder.Close(); err != nil {
+			return nil, fmt.Errorf("failed to finish compression: %w", err)
+		}
+	}
+	if err = sealer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish encryption: %w", err)
+	}
+	if err = tmp.Sync(); err != nil {
+		return nil, err
+	}
+	if err = tmp.Close(); err != nil {
+		return nil, err
+	}
+
+	hashStr := hex.EncodeToString(hasher.Sum(nil))
+
+	if existingBlob, lookupErr := blobDomain.GetByID(ctx, hashStr); lookupErr == nil {
+		os.Remove(tmp.Name())
+		if err := existingBlob.IncrementRefCounter(ctx); err != nil {
+			return nil, fmt.Errorf("failed to increment ref counter: %w", err)
+		}
+		log.Debug("Reusing existing blob", "hash", hashStr, "refCounter", existingBlob.RefCounter)
+		return existingBlob, nil
+	}
+
+	blobKey := ShardKey(hashStr, keyID)
+	if err = blobstore.PutFile(ctx, Store(), blobKey, tmp.Name()); err != nil {
+		return nil, fmt.Errorf("failed to move blob into place: %w", err)
+	}
+
+	log.Debug("Streamed blob to store", "hash", hashStr, "size", size, "isBinary", isBinary)
+	return blobDomain.New(ctx, hashStr, nil, blobKey, !isBinary, isBinary, size, "", 0, 0, keyID)
+}
+
+
+// Open returns a reader over the original content of a blob. Full blobs are
+// streamed from wherever they are stored, chunked content chunk by chunk;
+// delta blobs and blobs compressed with a dictionary are rebuilt in memory,
+// which their size limits.
+func Open(ctx context.Context, id string) (io.ReadCloser, error) {
+	blob, err := blobDomain.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("blob %s not found: %w", id, err)
+	}
+
+	if blob.IsManifest {
+		chunks, err := ManifestChunks(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return &chunkReader{ctx: ctx, chunks: chunks}, nil
+	}
+
+	if blob.IsDelta() || blob.DictID != 0 {
+		content, err := ReadBlob(ctx, blob)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(bytes.NewReader(content)), nil
+	}
+
+	stored, err := openPayload(ctx, blob)
+	if err != nil {
+		return nil, err
+	}
+	if !blob.IsCompressed {
+		return stored, nil
+	}
+
+	decoder, err := compressionkit.NewReader(stored)
+	if err != nil {
+		stored.Close()
+		return nil, fmt.Errorf("failed to decompress blob %s: %w", blob.ID, err)
+	}
+	return &streamReader{ReadCloser: decoder, stored: stored}, nil
+}
+
+
+// streamReader closes the underlying stored data along with the decoder.
+type streamReader struct {
+	io.ReadCloser
+	stored io.Closer
+}
+
+func (r *streamReader) Close() error {
+	r.ReadCloser.Close()
+	return r.stored.Close()
+}
+
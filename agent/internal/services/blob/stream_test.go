@@ -0,0 +1,86 @@
+package blob_test
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	blobService "vcx/agent/internal/services/blob"
+	"vcx/agent/internal/services/servicetest"
+)
+
+
+// writeLargeText writes numbered lines past STREAM_THRESHOLD to a temporary
+// file and returns its path, the hash of its content and its size.
+func writeLargeText(t *testing.T) (string, string, int64) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "large.txt")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	out    := bufio.NewWriter(io.MultiWriter(f, hasher))
+	var size int64
+	for line := 0; size <= blobService.STREAM_THRESHOLD; line++ {
+		n, _ := fmt.Fprintf(out, "line %d of a file too large to hold in memory\n", line)
+		size += int64(n)
+	}
+	if err := out.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return path, hex.EncodeToString(hasher.Sum(nil)), size
+}
+
+
+func TestStreamedBlobRoundTrip(t *testing.T) {
+	ctx := servicetest.Context(t)
+	if err := blobService.SetChunkedStorage(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+	path, hash, size := writeLargeText(t)
+
+	blob, err := blobService.CreateVersion(ctx, path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blob.ID != hash || blob.Size != size {
+		t.Fatalf("got blob %s of %d bytes, want %s of %d", blob.ID, blob.Size, hash, size)
+	}
+	if blob.IsManifest || blob.IsBinary || !blob.IsCompressed {
+		t.Errorf("text streamed as manifest %v, binary %v, compressed %v; want compressed only", blob.IsManifest, blob.IsBinary, blob.IsCompressed)
+	}
+	if stored := blobService.StoredSize(ctx, blob); stored <= 0 || stored >= size {
+		t.Errorf("stored %d bytes for %d of text, want it compressed", stored, size)
+	}
+
+	reader, err := blobService.Open(ctx, blob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	hasher := sha256.New()
+	read, err := io.Copy(hasher, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(hasher.Sum(nil)); got != hash || read != size {
+		t.Errorf("read back %d bytes hashing to %s, want %d hashing to %s", read, got, size, hash)
+	}
+
+	// the same content streamed again reuses the blob
+	again, err := blobService.CreateVersion(ctx, path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != blob.ID || again.RefCounter != blob.RefCounter+1 {
+		t.Errorf("got blob %s referenced %d times, want %s referenced %d times", again.ID, again.RefCounter, blob.ID, blob.RefCounter+1)
+	}
+}
@@ -2,14 +2,33 @@ package branch
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 
 	branchDomain "vcx/agent/internal/domains/branch"
+	changeDomain "vcx/agent/internal/domains/change"
+	fileDomain "vcx/agent/internal/domains/file"
+	instanceDomain "vcx/agent/internal/domains/instance"
+	"vcx/agent/internal/infra/db"
+	changeService "vcx/agent/internal/services/change"
+	fileService "vcx/agent/internal/services/file"
+	"vcx/agent/internal/services/merge"
+	"vcx/agent/internal/services/restore"
+	"vcx/agent/internal/session"
 	"vcx/pkg/logging"
 )
 
 var log = logging.GetLogger()
 
 
+var (
+	ErrBranchExists   = errors.New("branch already exists")
+	ErrBranchNotFound = errors.New("branch not found")
+	ErrInvalidName    = errors.New("invalid branch name")
+)
+
+
 func Create(ctx context.Context, name string) (*branchDomain.Branch, error) {
 	return branchDomain.New(ctx, name)
 }
@@ -18,3 +37,123 @@ func Create(ctx context.Context, name string) (*branchDomain.Branch, error) {
 func GetByID(ctx context.Context, id string) (*branchDomain.Branch, error) {
 	return branchDomain.GetByID(ctx, id)
 }
+
+
+// List returns the branches of a project, oldest first.
+func List(ctx context.Context, projectID string) ([]*branchDomain.Branch, error) {
+	return branchDomain.GetByProject(ctx, projectID)
+}
+
+
+// GetByName returns the branch called name in a project.
+func GetByName(ctx context.Context, projectID, name string) (*branchDomain.Branch, error) {
+	branch, err := branchDomain.GetByName(ctx, projectID, name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrBranchNotFound, name)
+	}
+	return branch, nil
+}
+
+
+// Fork creates a branch in the instance's project with the state right
+// after changeID, or the latest state of the instance's branch when changeID
+// is empty. The working copy is not touched (see Switch).
+//
+// The new branch adopts every file live at that point (see fileService.Adopt).
+func Fork(ctx context.Context, instance *instanceDomain.Instance, name, changeID string) (*branchDomain.Branch, error) {
+	if name == "" {
+		return nil, ErrInvalidName
+	}
+	if _, err := branchDomain.GetByName(ctx, instance.ProjectID, name); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrBranchExists, name)
+	}
+
+	sourceID   := instance.BranchID
+	recordedBy := func(*changeDomain.Change) bool { return true }
+	if changeID != "" {
+		from, err := changeDomain.GetByID(ctx, changeID)
+		if err != nil || from.ProjectID != instance.ProjectID {
+			return nil, fmt.Errorf("%w: %s", restore.ErrChangeNotFound, changeID)
+		}
+		if from.BranchID != "" {
+			sourceID = from.BranchID
+		}
+		recordedBy = func(change *changeDomain.Change) bool { return change.ID <= from.ID }
+	}
+
+	files, err := fileDomain.GetByBranch(ctx, sourceID)
+	if err != nil {
+		return nil, err
+	}
+	states := make([]*changeDomain.Change, 0, len(files))
+	forkID := changeID
+	for _, file := range files {
+		state, err := changeService.StateAt(ctx, file.ChangeID, recordedBy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load version of %s: %w", file.Path, err)
+		}
+		if state == nil {
+			continue
+		}
+		if changeID == "" && state.ID > forkID {
+			forkID = state.ID
+		}
+		if !state.IsDeleted {
+			states = append(states, state)
+		}
+	}
+	if forkID == "" {
+		source, err := branchDomain.GetByID(ctx, sourceID)
+		if err != nil {
+			return nil, err
+		}
+		forkID = source.ChangeID
+	}
+
+	var branch *branchDomain.Branch
+	err = db.WithTransactionContext(ctx, func(ctx context.Context, _ *sql.Tx) error {
+		ctx = session.WithProjectID(ctx, instance.ProjectID)
+		ctx = session.WithChangeID(ctx, forkID)
+
+		var err error
+		if branch, err = branchDomain.New(ctx, name); err != nil {
+			return err
+		}
+		ctx = session.WithBranchID(ctx, branch.ID)
+
+		for _, state := range states {
+			if _, err := fileService.Adopt(ctx, state); err != nil {
+				return fmt.Errorf("failed to copy %s: %w", state.Path, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("Created branch", "name", name, "branchID", branch.ID, "from", forkID, "files", len(states))
+	return branch, nil
+}
+
+
+// Switch rewrites the instance's working copy to the latest state of the
+// branch called name and records new edits on it from then on.
+func Switch(ctx context.Context, instance *instanceDomain.Instance, name string, options restore.SwitchOptions) (*restore.Plan, error) {
+	branch, err := GetByName(ctx, instance.ProjectID, name)
+	if err != nil {
+		return nil, err
+	}
+	return restore.Branch(ctx, instance, branch.ID, options)
+}
+
+
+// Merge merges the branch called name into the instance's branch and its
+// working copy.
+func Merge(ctx context.Context, instance *instanceDomain.Instance, name string, options merge.Options) (*merge.Result, error) {
+	branch, err := GetByName(ctx, instance.ProjectID, name)
+	if err != nil {
+		return nil, err
+	}
+	return merge.Branch(ctx, instance, branch.ID, options)
+}
@@ -0,0 +1,82 @@
+package branch_test
+
+import (
+	"errors"
+	"testing"
+
+	fileDomain "vcx/agent/internal/domains/file"
+	branchService "vcx/agent/internal/services/branch"
+	"vcx/agent/internal/services/restore"
+	"vcx/agent/internal/services/servicetest"
+)
+
+
+func TestSwitchRewritesWorkingCopy(t *testing.T) {
+	project := servicetest.NewProject(t, servicetest.Context(t), map[string]string{
+		"a.txt":    "on main\n",
+		"keep.txt": "removed on feature\n",
+	})
+	mainID := project.Instance().BranchID
+	project.Fork("feature")
+	project.Switch("feature")
+	featureID := project.Instance().BranchID
+	if featureID == mainID {
+		t.Fatal("instance still on main after switching to feature")
+	}
+
+	// edits land on the active branch only
+	project.Write("a.txt", "on feature\n")
+	project.Write("feature.txt", "only on feature\n")
+	project.Remove("keep.txt")
+	if _, err := fileDomain.GetByPath(project.Context(), mainID, "feature.txt"); err == nil {
+		t.Error("file added on feature recorded on main")
+	}
+	project.WriteFile("untracked.txt", "never recorded\n")
+
+	project.Switch("main")
+	if project.Instance().BranchID != mainID {
+		t.Fatal("instance not back on main")
+	}
+	for path, want := range map[string]string{"a.txt": "on main\n", "keep.txt": "removed on feature\n", "untracked.txt": "never recorded\n"} {
+		if got, ok := project.Read(path); !ok || got != want {
+			t.Errorf("%s holds %q (exists %v) on main, want %q", path, got, ok, want)
+		}
+	}
+	if _, ok := project.Read("feature.txt"); ok {
+		t.Error("feature.txt left in the working copy on main")
+	}
+
+	project.Switch("feature")
+	for path, want := range map[string]string{"a.txt": "on feature\n", "feature.txt": "only on feature\n"} {
+		if got, _ := project.Read(path); got != want {
+			t.Errorf("%s holds %q on feature, want %q", path, got, want)
+		}
+	}
+	if _, ok := project.Read("keep.txt"); ok {
+		t.Error("keep.txt back in the working copy on feature")
+	}
+}
+
+
+func TestSwitchRefusesLocalModifications(t *testing.T) {
+	project := servicetest.NewProject(t, servicetest.Context(t), map[string]string{"a.txt": "on main\n"})
+	project.Fork("feature")
+	project.Switch("feature")
+	project.Write("a.txt", "on feature\n")
+	project.WriteFile("a.txt", "not recorded yet\n")
+
+	_, err := branchService.Switch(project.Context(), project.Instance(), "main", restore.SwitchOptions{})
+	if !errors.Is(err, restore.ErrLocalModifications) {
+		t.Fatalf("got %v, want %v", err, restore.ErrLocalModifications)
+	}
+	if got, _ := project.Read("a.txt"); got != "not recorded yet\n" {
+		t.Errorf("refused switch touched a.txt: %q", got)
+	}
+
+	if _, err := branchService.Switch(project.Context(), project.Instance(), "main", restore.SwitchOptions{Force: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := project.Read("a.txt"); got != "on main\n" {
+		t.Errorf("forced switch left a.txt as %q", got)
+	}
+}
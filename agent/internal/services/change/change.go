@@ -2,9 +2,14 @@ package change
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
 
+	"vcx/agent/internal/consts/filetype"
 	changeDomain "vcx/agent/internal/domains/change"
+	"vcx/agent/internal/infra/db"
 	"vcx/pkg/logging"
+	"vcx/pkg/toolkit/filekit"
 )
 
 var log = logging.GetLogger()
@@ -18,3 +23,158 @@ func CreateProjectChange(ctx context.Context) (*changeDomain.Change, error) {
 func GetByID(ctx context.Context, id string) (*changeDomain.Change, error) {
 	return changeDomain.GetByID(ctx, id)
 }
+
+
+// CreateFileVersion records a new version of a file and appends it to the
+// file's version chain: the new change points back at prevID and, in the
+// same transaction, the previous change points forward at the new one.
+//
+// A previous change on another branch (the version a branch was forked from)
+// is only linked backwards, so the other branch's chain stays intact.
+func CreateFileVersion(ctx context.Context, fileID, prevID string, fileType filetype.FileType, blobID, path, target string, attrs filekit.Attributes, isDeleted bool, summary string) (*changeDomain.Change, error) {
+	return appendVersion(ctx, prevID, func(ctx context.Context) (*changeDomain.Change, error) {
+		return changeDomain.NewFile(ctx, fileID, prevID, fileType, blobID, path, target, attrs, isDeleted, summary)
+	})
+}
+
+
+// CreateRename records that a file moved to path, as a RENAME change
+// appended to the file's version chain like CreateFileVersion.
+func CreateRename(ctx context.Context, fileID, prevID string, fileType filetype.FileType, blobID, path, target string, attrs filekit.Attributes, summary string) (*changeDomain.Change, error) {
+	return appendVersion(ctx, prevID, func(ctx context.Context) (*changeDomain.Change, error) {
+		return changeDomain.NewRename(ctx, fileID, prevID, fileType, blobID, path, target, attrs, summary)
+	})
+}
+
+
+// appendVersion creates a change with create and links prevID forward to
+// it in the same transaction.
+func appendVersion(ctx context.Context, prevID string, create func(context.Context) (*changeDomain.Change, error)) (*changeDomain.Change, error) {
+	var change *changeDomain.Change
+
+	err := db.WithTransactionContext(ctx, func(ctx context.Context, _ *sql.Tx) error {
+		var err error
+		change, err = create(ctx)
+		if err != nil {
+			return err
+		}
+		if prevID == "" {
+			return nil
+		}
+
+		prev, err := changeDomain.GetByID(ctx, prevID)
+		if err != nil {
+			return fmt.Errorf("failed to load previous change %s: %w", prevID, err)
+		}
+		if prev.BranchID != change.BranchID {
+			return nil
+		}
+		return prev.SetNext(ctx, change.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+
+// Previous returns the version before change, or nil at the start of the chain.
+func Previous(ctx context.Context, change *changeDomain.Change) (*changeDomain.Change, error) {
+	if change.ChangeIDPrev == "" {
+		return nil, nil
+	}
+	return changeDomain.GetByID(ctx, change.ChangeIDPrev)
+}
+
+
+// Next returns the version after change on its branch, or nil at the head.
+func Next(ctx context.Context, change *changeDomain.Change) (*changeDomain.Change, error) {
+	if change.ChangeIDNext == "" {
+		return nil, nil
+	}
+	return changeDomain.GetByID(ctx, change.ChangeIDNext)
+}
+
+
+// WalkBackward returns the chain from changeID towards the file's first
+// version, newest first. At most limit changes are returned; limit <= 0
+// walks the whole chain.
+func WalkBackward(ctx context.Context, changeID string, limit int) ([]*changeDomain.Change, error) {
+	return walk(ctx, changeID, limit, Previous)
+}
+
+
+// WalkForward returns the chain from changeID towards the head of its
+// branch, oldest first. At most limit changes are returned; limit <= 0
+// walks the whole chain.
+func WalkForward(ctx context.Context, changeID string, limit int) ([]*changeDomain.Change, error) {
+	return walk(ctx, changeID, limit, Next)
+}
+
+
+// StateAt walks a version chain back from headID to the newest change
+// recorded by the cutoff. nil means the file did not exist yet.
+func StateAt(ctx context.Context, headID string, recordedBy func(*changeDomain.Change) bool) (*changeDomain.Change, error) {
+	if headID == "" {
+		return nil, nil
+	}
+	change, err := changeDomain.GetByID(ctx, headID)
+	if err != nil {
+		return nil, err
+	}
+
+	visited := make(map[string]bool)
+	for change != nil && !recordedBy(change) {
+		if visited[change.ID] {
+			return nil, fmt.Errorf("version chain loops at %s", change.ID)
+		}
+		visited[change.ID] = true
+
+		if change, err = Previous(ctx, change); err != nil {
+			return nil, fmt.Errorf("broken version chain: %w", err)
+		}
+	}
+	return change, nil
+}
+
+
+// InChain reports whether changeID is a version on the chain ending at
+// headID, including versions inherited from a parent branch.
+func InChain(ctx context.Context, headID, changeID string) (bool, error) {
+	state, err := StateAt(ctx, headID, func(change *changeDomain.Change) bool {
+		return change.ID <= changeID
+	})
+	if err != nil {
+		return false, err
+	}
+	return state != nil && state.ID == changeID, nil
+}
+
+
+func walk(ctx context.Context,
+          changeID string,
+          limit int,
+          step func(context.Context, *changeDomain.Change) (*changeDomain.Change, error),
+         ) ([]*changeDomain.Change, error) {
+	change, err := changeDomain.GetByID(ctx, changeID)
+	if err != nil {
+		return nil, err
+	}
+
+	var chain []*changeDomain.Change
+	visited := make(map[string]bool)
+	for change != nil && (limit <= 0 || len(chain) < limit) {
+		if visited[change.ID] {
+			log.Warn("Version chain loops back on itself", "changeID", change.ID, "fileID", change.FileID)
+			break
+		}
+		visited[change.ID] = true
+		chain = append(chain, change)
+
+		change, err = step(ctx, change)
+		if err != nil {
+			return chain, fmt.Errorf("broken version chain after %s: %w", chain[len(chain)-1].ID, err)
+		}
+	}
+	return chain, nil
+}
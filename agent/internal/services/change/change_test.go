@@ -0,0 +1,104 @@
+package change_test
+
+import (
+	"testing"
+
+	changeDomain "vcx/agent/internal/domains/change"
+	fileDomain "vcx/agent/internal/domains/file"
+	changeService "vcx/agent/internal/services/change"
+	"vcx/agent/internal/services/servicetest"
+	"vcx/agent/internal/session"
+)
+
+
+func head(t *testing.T, project *servicetest.Project, relPath string) *changeDomain.Change {
+	t.Helper()
+	ctx := project.Context()
+	file, err := fileDomain.GetByPath(ctx, session.GetBranchID(ctx), relPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	change, err := changeDomain.GetByID(ctx, file.ChangeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return change
+}
+
+
+func ids(chain []*changeDomain.Change) []string {
+	result := make([]string, len(chain))
+	for i, change := range chain {
+		result[i] = change.ID
+	}
+	return result
+}
+
+
+func TestVersionsAreLinkedBothWays(t *testing.T) {
+	project := servicetest.NewProject(t, servicetest.Context(t), map[string]string{"a.txt": "1\n"})
+	project.Write("a.txt", "2\n")
+	project.Write("a.txt", "3\n")
+	ctx := project.Context()
+
+	last := head(t, project, "a.txt")
+	backward, err := changeService.WalkBackward(ctx, last.ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backward) != 3 || backward[0].ID != last.ID || backward[2].ChangeIDPrev != "" {
+		t.Fatalf("walked back %v from %s, want three versions ending at the first", ids(backward), last.ID)
+	}
+	for i := 0; i < 2; i++ {
+		newer, older := backward[i], backward[i+1]
+		if newer.ChangeIDPrev != older.ID || older.ChangeIDNext != newer.ID {
+			t.Errorf("%s and %s not linked both ways", older.ID, newer.ID)
+		}
+	}
+	if last.ChangeIDNext != "" {
+		t.Errorf("head links forward to %s", last.ChangeIDNext)
+	}
+
+	forward, err := changeService.WalkForward(ctx, backward[2].ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{backward[2].ID, backward[1].ID, backward[0].ID}
+	if got := ids(forward); len(got) != 3 || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("walked forward %v, want %v", got, want)
+	}
+	if limited, err := changeService.WalkBackward(ctx, last.ID, 2); err != nil || len(limited) != 2 {
+		t.Errorf("walk limited to 2 returned %d versions, %v", len(limited), err)
+	}
+
+	if in, err := changeService.InChain(ctx, last.ID, backward[2].ID); err != nil || !in {
+		t.Errorf("first version not found in the chain: %v", err)
+	}
+	if in, _ := changeService.InChain(ctx, backward[1].ID, last.ID); in {
+		t.Error("a later version found in the chain of an earlier one")
+	}
+}
+
+
+func TestBranchVersionsLinkOnlyBackToParent(t *testing.T) {
+	project := servicetest.NewProject(t, servicetest.Context(t), map[string]string{"a.txt": "on main\n"})
+	forkedFrom := head(t, project, "a.txt")
+	project.Fork("feature")
+	project.Switch("feature")
+	project.Write("a.txt", "on feature\n")
+
+	onFeature := head(t, project, "a.txt")
+	if onFeature.ChangeIDPrev != forkedFrom.ID {
+		t.Errorf("feature version follows %s, want %s", onFeature.ChangeIDPrev, forkedFrom.ID)
+	}
+	parent, err := changeDomain.GetByID(project.Context(), forkedFrom.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent.ChangeIDNext != "" {
+		t.Errorf("main's version links forward to %s on another branch", parent.ChangeIDNext)
+	}
+	if in, err := changeService.InChain(project.Context(), onFeature.ID, forkedFrom.ID); err != nil || !in {
+		t.Errorf("inherited version not found in the feature chain: %v", err)
+	}
+}
@@ -0,0 +1,404 @@
+// Package conflict tracks paths whose versions could not be reconciled, e.g.
+// by a merge, until the user resolves them.
+//
+// A conflict keeps the base, ours and theirs versions of the path. While it
+// is open the working copy holds text with conflict markers, or our version
+// with theirs next to it (THEIRS_SUFFIX, numbered when the name is taken)
+// for content that cannot be merged line by line. Every version of the path
+// recorded meanwhile is flagged (Change.IsConflicted). Resolving picks a
+// side or takes the working copy as it is.
+package conflict
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	changeDomain "vcx/agent/internal/domains/change"
+	conflictDomain "vcx/agent/internal/domains/conflict"
+	fileDomain "vcx/agent/internal/domains/file"
+	instanceDomain "vcx/agent/internal/domains/instance"
+	"vcx/agent/internal/infra/db"
+	blobService "vcx/agent/internal/services/blob"
+	fileService "vcx/agent/internal/services/file"
+	"vcx/agent/internal/services/restore"
+	"vcx/agent/internal/session"
+	"vcx/pkg/logging"
+	"vcx/pkg/toolkit/diffkit"
+	"vcx/pkg/toolkit/filekit"
+)
+
+var log = logging.GetLogger()
+
+
+const (
+	SIDE_OURS   = "ours"
+	SIDE_THEIRS = "theirs"
+
+	RESOLUTION_MANUAL     = "manual"     // the working copy as the user left it
+	RESOLUTION_SUPERSEDED = "superseded" // replaced by a newer conflict of the path
+
+	THEIRS_SUFFIX = ".theirs" // their version of unmergeable content, next to ours
+
+	MARKER_OURS   = "<<<<<<<"
+	MARKER_SEP    = "======="
+	MARKER_THEIRS = ">>>>>>>"
+)
+
+
+var (
+	ErrNoConflict  = errors.New("no unresolved conflict")
+	ErrUnknownSide = errors.New("unknown side")
+)
+
+
+// Labels name the two sides in conflict markers, e.g. after their branches.
+type Labels struct {
+	Ours   string
+	Theirs string
+}
+
+
+// Record opens a conflict of relPath on the branch in ctx, caused by the
+// change in ctx, and puts it into the working copy at projectPath. The IDs
+// are the changes holding the three versions, empty where the path is
+// absent. An open conflict of the same path is superseded.
+func Record(ctx context.Context, projectPath, relPath, reason, baseID, oursID, theirsID string, labels Labels) (*conflictDomain.Conflict, error) {
+	base, err := load(ctx, baseID)
+	if err != nil {
+		return nil, err
+	}
+	ours, err := load(ctx, oursID)
+	if err != nil {
+		return nil, err
+	}
+	theirs, err := load(ctx, theirsID)
+	if err != nil {
+		return nil, err
+	}
+
+	if open, err := conflictDomain.GetOpenByPath(ctx, session.GetBranchID(ctx), relPath); err == nil {
+		if err := open.Resolve(ctx, RESOLUTION_SUPERSEDED); err != nil {
+			return nil, err
+		}
+	}
+	conflict, err := conflictDomain.New(ctx, relPath, reason, side(base), side(ours), side(theirs))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeConflict(ctx, projectPath, relPath, base, ours, theirs, labels); err != nil {
+		return conflict, fmt.Errorf("recorded conflict of %s but failed to write it: %w", relPath, err)
+	}
+
+	log.Info("Recorded conflict", "path", relPath, "reason", reason, "conflictID", conflict.ID)
+	return conflict, nil
+}
+
+
+// List returns the unresolved conflicts of a branch ordered by path.
+func List(ctx context.Context, branchID string) ([]*conflictDomain.Conflict, error) {
+	return conflictDomain.GetOpen(ctx, branchID)
+}
+
+
+// Resolve closes the conflict of relPath in the instance. With side ours or
+// theirs that version is restored and recorded; with an empty side the
+// working copy is recorded as it is. Their side-by-side copy is removed.
+// If any of it fails the conflict stays open.
+func Resolve(ctx context.Context, instance *instanceDomain.Instance, relPath, side string) (*conflictDomain.Conflict, error) {
+	ctx = session.WithProjectID(ctx, instance.ProjectID)
+	ctx = session.WithBranchID(ctx, instance.BranchID)
+
+	conflict, err := conflictDomain.GetOpenByPath(ctx, instance.BranchID, relPath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoConflict, relPath)
+	}
+
+	var picked conflictDomain.Side
+	resolution := side
+	switch side {
+	case SIDE_OURS:
+		picked = conflict.Ours
+	case SIDE_THEIRS:
+		picked = conflict.Theirs
+	case "":
+		resolution = RESOLUTION_MANUAL
+	default:
+		return nil, fmt.Errorf("%w %q, expected %s or %s", ErrUnknownSide, side, SIDE_OURS, SIDE_THEIRS)
+	}
+
+	theirs, err := load(ctx, conflict.Theirs.ChangeID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the conflict stays open unless the working copy is written as well
+	absPath := filepath.Join(instance.Path, relPath)
+	err = db.WithTransactionContext(ctx, func(ctx context.Context, _ *sql.Tx) error {
+		// resolve first, so the version recorded next is not flagged
+		if err := conflict.Resolve(ctx, resolution); err != nil {
+			return err
+		}
+		var err error
+		if resolution == RESOLUTION_MANUAL {
+			err = recordWorkingCopy(ctx, instance.Path, absPath)
+		} else {
+			err = restoreSide(ctx, instance.Path, relPath, picked.ChangeID, fmt.Sprintf("resolved using %s", side))
+		}
+		if err != nil {
+			return err
+		}
+		return removeTheirsCopies(ctx, instance, relPath, theirs)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("Resolved conflict", "path", relPath, "resolution", resolution, "conflictID", conflict.ID)
+	return conflict, nil
+}
+
+
+// writeConflict puts a conflict into the working copy. Text changed on both
+// sides gets conflict markers around the stretches that differ, recorded as
+// a (flagged) version; anything else keeps our version in place and gets
+// theirs next to it.
+func writeConflict(ctx context.Context, projectPath, relPath string, base, ours, theirs *changeDomain.Change, labels Labels) error {
+	absPath := filepath.Join(projectPath, relPath)
+
+	marked, ok, err := withMarkers(ctx, base, ours, theirs, labels)
+	if err != nil {
+		return err
+	}
+	if ok {
+		file, err := fileService.RecordContent(ctx, relPath, marked, contentAttributes(ours), fmt.Sprintf("conflict with %s", labels.Theirs))
+		if err != nil {
+			return err
+		}
+		recorded, err := changeDomain.GetByID(ctx, file.ChangeID)
+		if err != nil {
+			return err
+		}
+		_, err = restore.WriteVersion(ctx, projectPath, absPath, recorded)
+		return err
+	}
+
+	if absent(theirs) {
+		return nil
+	}
+	copyPath, err := theirsCopyPath(projectPath, relPath)
+	if err != nil {
+		return err
+	}
+	_, err = restore.WriteVersion(ctx, projectPath, filepath.Join(projectPath, copyPath), theirs)
+	return err
+}
+
+
+// theirsCopyPath returns where their version of relPath goes next to ours:
+// the first of relPath.theirs, relPath.theirs.1, ... that does not exist
+// yet, so nothing in the working copy is overwritten.
+func theirsCopyPath(projectPath, relPath string) (string, error) {
+	for n := 0; ; n++ {
+		copyPath := relPath + THEIRS_SUFFIX
+		if n > 0 {
+			copyPath = fmt.Sprintf("%s.%d", copyPath, n)
+		}
+		_, err := os.Lstat(filepath.Join(projectPath, copyPath))
+		if errors.Is(err, os.ErrNotExist) {
+			return copyPath, nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to inspect %s: %w", copyPath, err)
+		}
+	}
+}
+
+
+// withMarkers merges the text of both sides and marks the stretches that
+// conflict. ok is false unless both sides are text.
+func withMarkers(ctx context.Context, base, ours, theirs *changeDomain.Change, labels Labels) (marked []byte, ok bool, err error) {
+	if absent(ours) || absent(theirs) || ours.BlobID == "" || theirs.BlobID == "" {
+		return nil, false, nil
+	}
+
+	var baseText []byte
+	if !absent(base) && base.BlobID != "" {
+		if baseText, err = blobService.Read(ctx, base.BlobID); err != nil {
+			return nil, false, err
+		}
+	}
+	oursText, err := blobService.Read(ctx, ours.BlobID)
+	if err != nil {
+		return nil, false, err
+	}
+	theirsText, err := blobService.Read(ctx, theirs.BlobID)
+	if err != nil {
+		return nil, false, err
+	}
+	if filekit.IsBinary(baseText) || filekit.IsBinary(oursText) || filekit.IsBinary(theirsText) {
+		return nil, false, nil
+	}
+
+	var text strings.Builder
+	for _, chunk := range diffkit.Merge3(string(baseText), string(oursText), string(theirsText), diffkit.MYERS) {
+		if !chunk.Conflict {
+			writeLines(&text, chunk.Lines)
+			continue
+		}
+		fmt.Fprintf(&text, "%s %s\n", MARKER_OURS, labels.Ours)
+		writeLines(&text, chunk.Ours)
+		fmt.Fprintf(&text, "%s\n", MARKER_SEP)
+		writeLines(&text, chunk.Theirs)
+		fmt.Fprintf(&text, "%s %s\n", MARKER_THEIRS, labels.Theirs)
+	}
+	return []byte(text.String()), true, nil
+}
+
+
+// writeLines writes lines and ends the last one, so a marker after it
+// starts on a line of its own.
+func writeLines(text *strings.Builder, lines []string) {
+	for _, line := range lines {
+		text.WriteString(line)
+	}
+	if n := len(lines); n > 0 && !strings.HasSuffix(lines[n-1], "\n") {
+		text.WriteString("\n")
+	}
+}
+
+
+// restoreSide records the version of changeID as the path's next version
+// and writes it; an empty changeID or a deletion removes the path.
+func restoreSide(ctx context.Context, projectPath, relPath, changeID, summary string) error {
+	absPath := filepath.Join(projectPath, relPath)
+	state, err := load(ctx, changeID)
+	if err != nil {
+		return err
+	}
+
+	if absent(state) {
+		if file, err := fileDomain.GetByPath(ctx, session.GetBranchID(ctx), relPath); err == nil && !file.IsDeleted {
+			if err := fileService.MarkDeleted(ctx, file); err != nil {
+				return err
+			}
+		}
+		if err := restore.RemoveEntry(projectPath, absPath); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", relPath, err)
+		}
+		return nil
+	}
+
+	if _, err := fileService.RecordVersion(ctx, relPath, state.Type(), state.BlobID, state.Target, state.Attributes(), summary); err != nil {
+		return fmt.Errorf("failed to record %s: %w", relPath, err)
+	}
+	_, err = restore.WriteVersion(ctx, projectPath, absPath, state)
+	return err
+}
+
+
+// recordWorkingCopy records the working copy of a resolved path as it is.
+func recordWorkingCopy(ctx context.Context, projectPath, absPath string) error {
+	info, err := os.Lstat(absPath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		_, err = fileService.Remove(ctx, projectPath, absPath)
+	case err != nil:
+		return err
+	case info.Mode()&os.ModeSymlink != 0:
+		_, err = fileService.IngestSymlink(ctx, projectPath, absPath)
+	default:
+		_, err = fileService.Ingest(ctx, projectPath, absPath)
+	}
+	return err
+}
+
+
+// removeTheirsCopies removes their side-by-side copies of relPath (see
+// theirsCopyPath), and their records if the file monitor picked them up.
+// Only copies still holding their version are removed; anything else with
+// such a name was there before or has been edited since, and is kept.
+func removeTheirsCopies(ctx context.Context, instance *instanceDomain.Instance, relPath string, theirs *changeDomain.Change) error {
+	if absent(theirs) {
+		return nil
+	}
+	entries, err := os.ReadDir(filepath.Dir(filepath.Join(instance.Path, relPath)))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	prefix := filepath.Base(relPath) + THEIRS_SUFFIX
+	for _, entry := range entries {
+		name   := entry.Name()
+		suffix := strings.TrimPrefix(name, prefix)
+		if !strings.HasPrefix(name, prefix) || (suffix != "" && !isCopyNumber(suffix)) {
+			continue
+		}
+		copyPath := filepath.Join(filepath.Dir(relPath), name)
+		copied   := &fileDomain.File{Path: copyPath, Type: theirs.Type(), BlobID: theirs.BlobID, Target: theirs.Target}
+		matches, err := fileService.MatchesWorkingCopy(instance.Path, copied)
+		if err != nil {
+			return fmt.Errorf("failed to inspect %s: %w", copyPath, err)
+		}
+		if !matches {
+			log.Info("Kept file that is not their version", "path", copyPath)
+			continue
+		}
+
+		if file, err := fileDomain.GetByPath(ctx, instance.BranchID, copyPath); err == nil && !file.IsDeleted {
+			if err := fileService.MarkDeleted(ctx, file); err != nil {
+				return err
+			}
+		}
+		if err := restore.RemoveEntry(instance.Path, filepath.Join(instance.Path, copyPath)); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", copyPath, err)
+		}
+	}
+	return nil
+}
+
+
+// isCopyNumber reports whether suffix is the ".N" theirsCopyPath appends.
+func isCopyNumber(suffix string) bool {
+	n, err := strconv.Atoi(strings.TrimPrefix(suffix, "."))
+	return strings.HasPrefix(suffix, ".") && err == nil && n > 0
+}
+
+
+func load(ctx context.Context, changeID string) (*changeDomain.Change, error) {
+	if changeID == "" {
+		return nil, nil
+	}
+	change, err := changeDomain.GetByID(ctx, changeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load version %s: %w", changeID, err)
+	}
+	return change, nil
+}
+
+
+func absent(state *changeDomain.Change) bool {
+	return state == nil || state.IsDeleted
+}
+
+
+func side(state *changeDomain.Change) conflictDomain.Side {
+	if state == nil {
+		return conflictDomain.Side{}
+	}
+	return conflictDomain.Side{ChangeID: state.ID, BlobID: state.BlobID}
+}
+
+
+// contentAttributes returns the attributes for content produced from the
+// version state: its mode and owner, but no mtime, so the file is new to
+// build tools.
+func contentAttributes(state *changeDomain.Change) filekit.Attributes {
+	return filekit.Attributes{Mode: state.Mode, Owner: state.Owner}
+}
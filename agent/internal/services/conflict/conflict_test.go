@@ -0,0 +1,84 @@
+package conflict_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	changeDomain "vcx/agent/internal/domains/change"
+	fileDomain "vcx/agent/internal/domains/file"
+	branchService "vcx/agent/internal/services/branch"
+	"vcx/agent/internal/services/conflict"
+	"vcx/agent/internal/services/merge"
+	"vcx/agent/internal/services/servicetest"
+	"vcx/agent/internal/session"
+)
+
+
+func TestConflictStaysOpenUntilResolutionIsWritten(t *testing.T) {
+	project := servicetest.NewProject(t, servicetest.Context(t), map[string]string{"sub/a.txt": "1\n2\n3\n"})
+	project.Fork("feature")
+	project.Switch("feature")
+	project.Write("sub/a.txt", "1\nfeature\n3\n")
+	project.Switch("main")
+	project.Write("sub/a.txt", "1\nmain\n3\n")
+
+	result, err := branchService.Merge(project.Context(), project.Instance(), "feature", merge.Options{})
+	if err != nil || len(result.Conflicts) != 1 {
+		t.Fatalf("got %+v, %v; want one conflict", result, err)
+	}
+	open := func() int {
+		t.Helper()
+		conflicts, err := conflict.List(project.Context(), project.Instance().BranchID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(conflicts)
+	}
+	if marked, _ := project.Read("sub/a.txt"); !strings.Contains(marked, conflict.MARKER_OURS) {
+		t.Fatalf("sub/a.txt holds %q, want conflict markers", marked)
+	}
+
+	// versions recorded while the conflict is open are flagged
+	project.Write("sub/a.txt", "1\nstill undecided\n3\n")
+	ctx := project.Context()
+	file, err := fileDomain.GetByPath(ctx, session.GetBranchID(ctx), "sub/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if change, err := changeDomain.GetByID(ctx, file.ChangeID); err != nil || !change.IsConflicted {
+		t.Errorf("version recorded during the conflict not flagged: %v", err)
+	}
+
+	// the resolution cannot be written where a file blocks the directory
+	if err := os.RemoveAll(project.Path("sub")); err != nil {
+		t.Fatal(err)
+	}
+	project.WriteFile("sub", "in the way\n")
+	if _, err := conflict.Resolve(project.Context(), project.Instance(), "sub/a.txt", conflict.SIDE_THEIRS); err == nil {
+		t.Fatal("resolving into a blocked path succeeded")
+	}
+	if n := open(); n != 1 {
+		t.Fatalf("%d open conflicts after a failed resolution, want 1", n)
+	}
+
+	if err := os.Remove(project.Path("sub")); err != nil {
+		t.Fatal(err)
+	}
+	project.WriteFile("sub/a.txt", "1\nstill undecided\n3\n")
+	if _, err := conflict.Resolve(project.Context(), project.Instance(), "sub/a.txt", conflict.SIDE_THEIRS); err != nil {
+		t.Fatal(err)
+	}
+	if n := open(); n != 0 {
+		t.Errorf("%d open conflicts after resolving, want 0", n)
+	}
+	if got, _ := project.Read("sub/a.txt"); got != "1\nfeature\n3\n" {
+		t.Errorf("sub/a.txt holds %q, want their version", got)
+	}
+	if file, err = fileDomain.GetByPath(ctx, session.GetBranchID(ctx), "sub/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if change, err := changeDomain.GetByID(ctx, file.ChangeID); err != nil || change.IsConflicted {
+		t.Errorf("resolved version flagged as conflicted: %v", err)
+	}
+}
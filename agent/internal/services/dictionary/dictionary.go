@@ -0,0 +1,180 @@
+// Package dictionary trains per-project compression dictionaries and
+// measures what they gain.
+//
+// Small text files compress poorly on their own: below MIN_COMPRESS_SIZE
+// they are not compressed at all, and above it they offer little context to
+// find repetitions in. A zstd dictionary trained on a sample of the
+// project's own text blobs supplies that context. New small text blobs of
+// the project are compressed with its newest dictionary (see the blob
+// service); existing blobs keep the compression they were stored with.
+package dictionary
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	blobDomain "vcx/agent/internal/domains/blob"
+	changeDomain "vcx/agent/internal/domains/change"
+	dictionaryDomain "vcx/agent/internal/domains/dictionary"
+	blobService "vcx/agent/internal/services/blob"
+	"vcx/pkg/logging"
+	"vcx/pkg/toolkit/compressionkit"
+)
+
+
+var log = logging.GetLogger()
+
+
+const (
+	MAX_DICT_SIZE    = compressionkit.DEFAULT_DICT_SIZE
+	MIN_SAMPLES      = 20
+	MAX_SAMPLES      = 2000
+	MAX_SAMPLE_BYTES = 8 * 1024 * 1024
+)
+
+
+var ErrTooFewSamples = errors.New("not enough text blobs to train a dictionary")
+
+
+// Stats compares the compression of a project's small text blobs without
+// and with its newest dictionary. Ratios are compressed / original bytes,
+// so lower is better; content that does not compress counts at full size.
+//
+// Fields:
+//   - DictID: Newest dictionary of the project (0 if none was trained)
+//   - Samples: Text blobs compared
+//   - StoredWithDict: Samples actually stored with a dictionary
+//   - PlainBytes, DictBytes: Their compressed size without and with it
+type Stats struct {
+	ProjectID      string  `json:"projectID"`
+	DictID         int     `json:"dictID"`
+	DictSize       int     `json:"dictSize"`
+	Samples        int     `json:"samples"`
+	StoredWithDict int     `json:"storedWithDict"`
+	OriginalBytes  int64   `json:"originalBytes"`
+	PlainBytes     int64   `json:"plainBytes"`
+	DictBytes      int64   `json:"dictBytes"`
+	PlainRatio     float64 `json:"plainRatio"`
+	DictRatio      float64 `json:"dictRatio"`
+}
+
+
+// sample is the content of one small text blob.
+type sample struct {
+	blob    *blobDomain.Blob
+	content []byte
+}
+
+
+// Train builds a new dictionary from the project's most recent small text
+// blobs. It becomes the one new blobs of the project are compressed with.
+func Train(ctx context.Context, projectID string) (*dictionaryDomain.Dictionary, error) {
+	samples, err := collectSamples(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if len(samples) < MIN_SAMPLES {
+		return nil, fmt.Errorf("%w: %d found, %d needed", ErrTooFewSamples, len(samples), MIN_SAMPLES)
+	}
+
+	contents := make([][]byte, len(samples))
+	for i, s := range samples {
+		contents[i] = s.content
+	}
+	data, err := compressionkit.TrainDictionary(contents, MAX_DICT_SIZE)
+	if err != nil {
+		return nil, fmt.Errorf("failed to train dictionary: %w", err)
+	}
+	trained, err := compressionkit.NewDictionary(data)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, keyID, err := blobService.Seal(data)
+	if err != nil {
+		return nil, err
+	}
+	dictionary, err := dictionaryDomain.New(ctx, projectID, int(trained.ID()), sealed, len(samples), keyID)
+	if err != nil {
+		return nil, err
+	}
+	log.Info("Trained compression dictionary", "project", projectID, "dictID", dictionary.DictID, "size", len(data), "samples", len(samples))
+	return dictionary, nil
+}
+
+
+// GetStats measures the project's newest dictionary against plain
+// compression on the same sample Train would use.
+func GetStats(ctx context.Context, projectID string) (*Stats, error) {
+	samples, err := collectSamples(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{ProjectID: projectID, Samples: len(samples)}
+
+	var dictionary *compressionkit.Dictionary
+	if latest, err := dictionaryDomain.GetLatestForProject(ctx, projectID); err == nil {
+		if dictionary, err = blobService.LoadDictionary(ctx, latest.DictID); err != nil {
+			return nil, err
+		}
+		full, err := dictionaryDomain.GetByID(ctx, latest.ID)
+		if err != nil {
+			return nil, err
+		}
+		stats.DictID   = latest.DictID
+		stats.DictSize = len(full.Data)
+	}
+
+	for _, s := range samples {
+		plain, _ := compressionkit.Compress(s.content)
+		stats.OriginalBytes += int64(len(s.content))
+		stats.PlainBytes    += int64(len(plain))
+		if s.blob.DictID != 0 {
+			stats.StoredWithDict++
+		}
+		if dictionary != nil {
+			compressed, _ := dictionary.Compress(s.content)
+			stats.DictBytes += int64(len(compressed))
+		}
+	}
+
+	if stats.OriginalBytes > 0 {
+		stats.PlainRatio = float64(stats.PlainBytes) / float64(stats.OriginalBytes)
+		if dictionary != nil {
+			stats.DictRatio = float64(stats.DictBytes) / float64(stats.OriginalBytes)
+		}
+	}
+	return stats, nil
+}
+
+
+// collectSamples returns the project's small text blobs with their content,
+// most recently recorded first, within MAX_SAMPLES and MAX_SAMPLE_BYTES.
+func collectSamples(ctx context.Context, projectID string) ([]sample, error) {
+	ids, err := changeDomain.GetProjectBlobIDs(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list project blobs: %w", err)
+	}
+
+	var samples []sample
+	var total int
+	for _, id := range ids {
+		if len(samples) >= MAX_SAMPLES || total >= MAX_SAMPLE_BYTES {
+			break
+		}
+		blob, err := blobDomain.GetByID(ctx, id)
+		if err != nil || blob.IsBinary || blob.IsManifest || blob.Size == 0 || blob.Size > blobService.MAX_DICT_INPUT_SIZE {
+			continue
+		}
+		content, err := blobService.ReadBlob(ctx, blob)
+		if err != nil {
+			log.Warn("Cannot read blob, leaving it out of the sample", "blob", id, "error", err)
+			continue
+		}
+		samples = append(samples, sample{blob: blob, content: content})
+		total  += len(content)
+	}
+	return samples, nil
+}
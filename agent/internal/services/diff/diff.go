@@ -0,0 +1,219 @@
+// Package diff compares recorded content line by line.
+//
+// Blob content is loaded through the blob service (DB or FilePath,
+// decompressed) and compared with diffkit. Blobs flagged IsBinary, and
+// blobs larger than MAX_DIFF_SIZE, are not diffed; the result only reports
+// whether they differ. Versions also compare permission bits, so a
+// mode-only change is reported.
+package diff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	blobDomain "vcx/agent/internal/domains/blob"
+	changeDomain "vcx/agent/internal/domains/change"
+	fileDomain "vcx/agent/internal/domains/file"
+	blobService "vcx/agent/internal/services/blob"
+	changeService "vcx/agent/internal/services/change"
+	"vcx/pkg/logging"
+	"vcx/pkg/toolkit/diffkit"
+)
+
+
+var log = logging.GetLogger()
+
+
+const (
+	DEFAULT_CONTEXT_LINES = 3
+	MAX_DIFF_SIZE         = 4 * 1024 * 1024 // larger content is reported like binary content
+)
+
+
+var (
+	ErrFileNotFound = errors.New("file has no recorded versions")
+	ErrWrongFile    = errors.New("change does not belong to this file")
+)
+
+
+// Options tune how a diff is computed.
+//
+// Fields:
+//   - Context: Unchanged lines shown around each change
+//   - Algorithm: diffkit.MYERS or diffkit.PATIENCE
+type Options struct {
+	Context   int
+	Algorithm diffkit.Algorithm
+}
+
+
+func DefaultOptions() Options {
+	return Options{Context: DEFAULT_CONTEXT_LINES, Algorithm: diffkit.MYERS}
+}
+
+
+// Result is the comparison of two versions. An empty blob ID stands for
+// no content (the file did not exist or was deleted), a zero mode for
+// permissions that are unknown. Identical is about content only;
+// ModeChanged tells whether the permissions differ. IsBinary and TooLarge
+// tell why no hunks were computed.
+type Result struct {
+	Path         string         `json:"path,omitempty"`
+	FromChangeID string         `json:"fromChangeID,omitempty"`
+	ToChangeID   string         `json:"toChangeID,omitempty"`
+	FromBlobID   string         `json:"fromBlobID"`
+	ToBlobID     string         `json:"toBlobID"`
+	FromMode     uint32         `json:"fromMode,omitempty"`
+	ToMode       uint32         `json:"toMode,omitempty"`
+	ModeChanged  bool           `json:"modeChanged"`
+	IsBinary     bool           `json:"isBinary"`
+	TooLarge     bool           `json:"tooLarge"`
+	Identical    bool           `json:"identical"`
+	Hunks        []diffkit.Hunk `json:"hunks"`
+}
+
+
+// Blobs diffs the content of two blobs.
+func Blobs(ctx context.Context, fromBlobID, toBlobID string, options Options) (*Result, error) {
+	result := &Result{FromBlobID: fromBlobID, ToBlobID: toBlobID, Hunks: []diffkit.Hunk{}}
+
+	from, err := blobText(ctx, fromBlobID, result)
+	if err != nil {
+		return nil, err
+	}
+	to, err := blobText(ctx, toBlobID, result)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.IsBinary || result.TooLarge {
+		result.Identical = fromBlobID == toBlobID
+		return result, nil
+	}
+	return compare(result, from, to, options), nil
+}
+
+
+// Versions diffs two versions of the file at relPath on branchID. When
+// toChangeID is empty the file's current version is used; when
+// fromChangeID is empty, the version before "to".
+func Versions(ctx context.Context, branchID, relPath, fromChangeID, toChangeID string, options Options) (*Result, error) {
+	file, err := fileDomain.GetByPath(ctx, branchID, relPath)
+	if err != nil || file.ChangeID == "" {
+		return nil, fmt.Errorf("%w: %s", ErrFileNotFound, relPath)
+	}
+
+	if toChangeID == "" {
+		toChangeID = file.ChangeID
+	}
+	to, err := fileVersion(ctx, file, toChangeID)
+	if err != nil {
+		return nil, err
+	}
+
+	var from *changeDomain.Change
+	if fromChangeID == "" {
+		fromChangeID = to.ChangeIDPrev
+	}
+	if fromChangeID != "" {
+		if from, err = fileVersion(ctx, file, fromChangeID); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &Result{Path: relPath, FromChangeID: fromChangeID, ToChangeID: to.ID, Hunks: []diffkit.Hunk{}}
+	fromText, err := versionText(ctx, from, result)
+	if err != nil {
+		return nil, err
+	}
+	toText, err := versionText(ctx, to, result)
+	if err != nil {
+		return nil, err
+	}
+	if from != nil && !from.IsDeleted {
+		result.FromBlobID = from.BlobID
+		result.FromMode   = from.Mode
+	}
+	if !to.IsDeleted {
+		result.ToBlobID = to.BlobID
+		result.ToMode   = to.Mode
+	}
+	result.ModeChanged = result.FromMode != 0 && result.ToMode != 0 && result.FromMode != result.ToMode
+
+	if result.IsBinary || result.TooLarge {
+		result.Identical = result.FromBlobID == result.ToBlobID
+		return result, nil
+	}
+	return compare(result, fromText, toText, options), nil
+}
+
+
+func compare(result *Result, from, to string, options Options) *Result {
+	if from == to {
+		result.Identical = true
+		return result
+	}
+	edits := diffkit.Lines(from, to, options.Algorithm)
+	result.Hunks = diffkit.Hunks(edits, options.Context)
+	return result
+}
+
+
+func fileVersion(ctx context.Context, file *fileDomain.File, changeID string) (*changeDomain.Change, error) {
+	change, err := changeDomain.GetByID(ctx, changeID)
+	if err != nil {
+		return nil, fmt.Errorf("change %s not found: %w", changeID, err)
+	}
+	if change.FileID != file.ID {
+		// versions from before the branch was created belong to the parent's record
+		inherited, err := changeService.InChain(ctx, file.ChangeID, change.ID)
+		if err != nil || !inherited {
+			return nil, fmt.Errorf("%w: %s is not a version of %s", ErrWrongFile, changeID, file.Path)
+		}
+	}
+	return change, nil
+}
+
+
+// versionText returns what a version contains: nothing when deleted, the
+// link target for symlinks and the blob content otherwise.
+func versionText(ctx context.Context, change *changeDomain.Change, result *Result) (string, error) {
+	if change == nil || change.IsDeleted {
+		return "", nil
+	}
+	if change.BlobID == "" {
+		return change.Target, nil
+	}
+	return blobText(ctx, change.BlobID, result)
+}
+
+
+// blobText loads the content of a blob, unless it is binary or too large
+// to diff, which it flags on result instead. Once either side is flagged
+// nothing more is loaded.
+func blobText(ctx context.Context, blobID string, result *Result) (string, error) {
+	if blobID == "" {
+		return "", nil
+	}
+	blob, err := blobDomain.GetByID(ctx, blobID)
+	if err != nil {
+		return "", fmt.Errorf("blob %s not found: %w", blobID, err)
+	}
+	switch {
+	case blob.IsBinary:
+		result.IsBinary = true
+	case blob.Size > MAX_DIFF_SIZE:
+		result.TooLarge = true
+	}
+	if result.IsBinary || result.TooLarge {
+		return "", nil
+	}
+
+	content, err := blobService.ReadBlob(ctx, blob)
+	if err != nil {
+		log.Error("Failed to load blob for diff", "blobID", blobID, "error", err)
+		return "", err
+	}
+	return string(content), nil
+}
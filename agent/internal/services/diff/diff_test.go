@@ -0,0 +1,36 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+
+	"vcx/agent/internal/services/servicetest"
+	"vcx/agent/internal/session"
+)
+
+
+func TestVersionsReportsContentTooLargeToDiff(t *testing.T) {
+	ctx     := servicetest.Context(t)
+	large   := strings.Repeat("a line of text\n", MAX_DIFF_SIZE/15+1)
+	project := servicetest.NewProject(t, ctx, map[string]string{"small.txt": "one\n", "large.txt": large})
+	project.Write("small.txt", "two\n")
+	project.Write("large.txt", large+"one more\n")
+	branchID := session.GetBranchID(project.Context())
+
+	result, err := Versions(project.Context(), branchID, "large.txt", "", "", DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.TooLarge || result.IsBinary || result.Identical || len(result.Hunks) != 0 {
+		t.Errorf("got tooLarge %v, binary %v, identical %v, %d hunks; want only tooLarge",
+			result.TooLarge, result.IsBinary, result.Identical, len(result.Hunks))
+	}
+
+	result, err = Versions(project.Context(), branchID, "small.txt", "", "", DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.TooLarge || len(result.Hunks) != 1 {
+		t.Errorf("small file: got tooLarge %v, %d hunks; want a diff", result.TooLarge, len(result.Hunks))
+	}
+}
@@ -0,0 +1,386 @@
+// Package encryption manages encryption at rest of blob contents.
+//
+// The key either lives in a keyfile (hex, created on first use) or is
+// derived from a passphrase with a salt kept in the settings. The settings
+// also keep the key ID, so a wrong keyfile or passphrase is refused instead
+// of silently sealing new blobs with a second key. The key itself is never
+// stored in the database.
+//
+// Enabling or disabling encryption reseals the existing blobs and
+// dictionaries (see Reencrypt). Disabling keeps the key in use until no blob
+// needs it anymore. Switching the cipher alone only affects new blobs, as
+// both ciphers are read with the same key.
+package encryption
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	blobDomain "vcx/agent/internal/domains/blob"
+	dictionaryDomain "vcx/agent/internal/domains/dictionary"
+	"vcx/agent/internal/consts/keys"
+	blobService "vcx/agent/internal/services/blob"
+	"vcx/agent/internal/services/simplekv"
+	"vcx/pkg/logging"
+	"vcx/pkg/toolkit/cryptokit"
+)
+
+
+var log = logging.GetLogger()
+
+
+const (
+	OFF            = "off"
+	PASSPHRASE_ENV = "VCX_PASSPHRASE" // the agent reads the passphrase from here at startup
+)
+
+
+var (
+	ErrNoKey    = errors.New("no keyfile or passphrase given")
+	ErrCipher   = errors.New("unknown cipher")
+	ErrWrongKey = errors.New("key does not match the configured encryption key")
+)
+
+
+// Options select how to encrypt. Exactly one of Keyfile and Passphrase is
+// used; a keyfile that does not exist yet is created with a new key.
+//
+// Fields:
+//   - Algorithm: cryptokit.AES_GCM or cryptokit.XCHACHA20
+//   - Keyfile: Path of the keyfile
+//   - Passphrase: Passphrase to derive the key from
+type Options struct {
+	Algorithm  string `json:"algorithm"`
+	Keyfile    string `json:"keyfile"`
+	Passphrase string `json:"passphrase"`
+}
+
+
+// Status describes the encryption settings and how the blobs are stored.
+type Status struct {
+	Algorithm string `json:"algorithm"`
+	KeyID     string `json:"keyID"`
+	Keyfile   string `json:"keyfile"`
+	Loaded    bool   `json:"loaded"`
+	Encrypted int    `json:"encrypted"`
+	Plain     int    `json:"plain"`
+	OtherKeys int    `json:"otherKeys"`
+}
+
+
+// Report describes what a Reencrypt run resealed. Skipped counts blobs
+// sealed with a key that is not loaded.
+type Report struct {
+	Blobs        int `json:"blobs"`
+	Dictionaries int `json:"dictionaries"`
+	Skipped      int `json:"skipped"`
+	Failed       int `json:"failed"`
+}
+
+
+// Configure loads the configured key at startup. Without it encrypted blobs
+// cannot be read, so a missing or wrong key is an error.
+func Configure(ctx context.Context) error {
+	keyID, _ := simplekv.GetString(ctx, keys.ENCRYPTION_KEY_ID)
+	if keyID == "" {
+		blobService.UseEncryption(nil, "")
+		return nil
+	}
+
+	keyfile, _ := simplekv.GetString(ctx, keys.ENCRYPTION_KEYFILE)
+	key, err   := loadKey(ctx, Options{Keyfile: keyfile, Passphrase: os.Getenv(PASSPHRASE_ENV)}, false)
+	if err != nil {
+		return err
+	}
+	if key.ID() != keyID {
+		return fmt.Errorf("%w (expected key %s)", ErrWrongKey, keyID)
+	}
+
+	algorithm, _ := simplekv.GetString(ctx, keys.ENCRYPTION)
+	if algorithm == OFF {
+		algorithm = ""
+	}
+	blobService.UseEncryption(key, algorithm)
+	log.Info("Encryption key loaded", "keyID", keyID, "algorithm", algorithm)
+	return nil
+}
+
+
+// Enable turns on encryption for new blobs and reseals the existing ones.
+func Enable(ctx context.Context, options Options) (*Report, error) {
+	if !cryptokit.IsAlgorithm(options.Algorithm) {
+		return nil, fmt.Errorf("%w %q", ErrCipher, options.Algorithm)
+	}
+
+	configured, _ := simplekv.GetString(ctx, keys.ENCRYPTION_KEY_ID)
+	key, err      := loadKey(ctx, options, configured == "")
+	if err != nil {
+		return nil, err
+	}
+	if configured != "" && key.ID() != configured {
+		return nil, fmt.Errorf("%w (key %s); turn encryption off first to change the key", ErrWrongKey, configured)
+	}
+
+	if err := save(ctx, options.Algorithm, key.ID()); err != nil {
+		return nil, err
+	}
+	blobService.UseEncryption(key, options.Algorithm)
+	log.Info("Encryption enabled", "keyID", key.ID(), "algorithm", options.Algorithm)
+
+	return Reencrypt(ctx)
+}
+
+
+// Disable stores new blobs in plain and reseals the existing ones. The key
+// settings are cleared once no blob needs the key anymore.
+func Disable(ctx context.Context) (*Report, error) {
+	if err := simplekv.SetString(ctx, keys.ENCRYPTION, OFF); err != nil {
+		return nil, err
+	}
+	if key := loadedKey(ctx); key != nil {
+		blobService.UseEncryption(key, "")
+	}
+
+	report, err := Reencrypt(ctx)
+	if err != nil || report.Skipped > 0 || report.Failed > 0 {
+		return report, err
+	}
+
+	for _, key := range []string{keys.ENCRYPTION_KEY_ID, keys.ENCRYPTION_KEYFILE, keys.ENCRYPTION_SALT} {
+		if err := simplekv.SetString(ctx, key, ""); err != nil {
+			return report, err
+		}
+	}
+	blobService.UseEncryption(nil, "")
+	log.Info("Encryption disabled")
+	return report, nil
+}
+
+
+// Reencrypt reseals every blob and dictionary not stored under the current
+// settings: sealed with the loaded key, or in plain when encryption is off.
+// It is safe to run again after an interruption.
+func Reencrypt(ctx context.Context) (*Report, error) {
+	// never while the garbage collector or a repack moves stored data
+	blobService.Maintenance.Lock()
+	defer blobService.Maintenance.Unlock()
+
+	report := &Report{}
+	target := blobService.EncryptionKeyID()
+
+	blobs, err := blobDomain.GetAllMetadata(ctx)
+	if err != nil {
+		return report, fmt.Errorf("failed to list blobs: %w", err)
+	}
+	for _, blob := range blobs {
+		if blob.KeyID == target {
+			continue
+		}
+		resealed, err := blobService.Reseal(ctx, blob.ID)
+		switch {
+		case errors.Is(err, blobService.ErrLocked):
+			report.Skipped++
+		case err != nil:
+			log.Warn("Failed to reseal blob", "blob", blob.ID, "error", err)
+			report.Failed++
+		case resealed:
+			report.Blobs++
+		}
+	}
+
+	dictionaries, err := dictionaryDomain.GetAll(ctx)
+	if err != nil {
+		return report, fmt.Errorf("failed to list dictionaries: %w", err)
+	}
+	for _, dictionary := range dictionaries {
+		if dictionary.KeyID == target {
+			continue
+		}
+		err := resealDictionary(ctx, dictionary.ID)
+		switch {
+		case errors.Is(err, blobService.ErrLocked):
+			report.Skipped++
+		case err != nil:
+			log.Warn("Failed to reseal dictionary", "dictID", dictionary.DictID, "error", err)
+			report.Failed++
+		default:
+			report.Dictionaries++
+		}
+	}
+
+	log.Info("Reencryption finished", "blobs", report.Blobs, "dictionaries", report.Dictionaries,
+		"skipped", report.Skipped, "failed", report.Failed)
+	return report, nil
+}
+
+
+// GetStatus returns the settings and counts the blobs by how they are sealed.
+func GetStatus(ctx context.Context) (*Status, error) {
+	status := &Status{Algorithm: OFF}
+	if algorithm, _ := simplekv.GetString(ctx, keys.ENCRYPTION); algorithm != "" {
+		status.Algorithm = algorithm
+	}
+	status.KeyID, _   = simplekv.GetString(ctx, keys.ENCRYPTION_KEY_ID)
+	status.Keyfile, _ = simplekv.GetString(ctx, keys.ENCRYPTION_KEYFILE)
+	status.Loaded     = loadedKey(ctx) != nil
+
+	blobs, err := blobDomain.GetAllMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, blob := range blobs {
+		switch blob.KeyID {
+		case "":
+			status.Plain++
+		case status.KeyID:
+			status.Encrypted++
+		default:
+			status.OtherKeys++
+		}
+	}
+	return status, nil
+}
+
+
+// resealDictionary stores the data of a dictionary again under the current
+// settings.
+func resealDictionary(ctx context.Context, id string) error {
+	dictionary, err := dictionaryDomain.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	data, err := blobService.Unseal(dictionary.Data, dictionary.KeyID)
+	if err != nil {
+		return err
+	}
+	sealed, keyID, err := blobService.Seal(data)
+	if err != nil {
+		return err
+	}
+	return dictionary.SetData(ctx, sealed, keyID)
+}
+
+
+// loadedKey returns the key blobs are currently read with, or nil.
+func loadedKey(ctx context.Context) *cryptokit.Key {
+	keyID, _ := simplekv.GetString(ctx, keys.ENCRYPTION_KEY_ID)
+	if keyID == "" {
+		return nil
+	}
+	key, err := blobService.LoadedKey(keyID)
+	if err != nil {
+		return nil
+	}
+	return key
+}
+
+
+// loadKey reads the key from the keyfile or derives it from the passphrase
+// of options. With create, a missing keyfile is created with a new key and
+// a passphrase gets a new salt; the key source is then saved.
+func loadKey(ctx context.Context, options Options, create bool) (*cryptokit.Key, error) {
+	switch {
+	case options.Keyfile != "":
+		key, err := readKeyfile(options.Keyfile)
+		if errors.Is(err, os.ErrNotExist) && create {
+			key, err = writeKeyfile(options.Keyfile)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if create {
+			if err := setKeySource(ctx, options.Keyfile, ""); err != nil {
+				return nil, err
+			}
+		}
+		return key, nil
+
+	case options.Passphrase != "":
+		saltHex, _ := simplekv.GetString(ctx, keys.ENCRYPTION_SALT)
+		salt, err  := hex.DecodeString(saltHex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption salt: %w", err)
+		}
+		if len(salt) == 0 {
+			if !create {
+				return nil, fmt.Errorf("%w: it is kept in a keyfile", ErrWrongKey)
+			}
+			if salt, err = cryptokit.NewSalt(); err != nil {
+				return nil, err
+			}
+		}
+		key, err := cryptokit.DeriveKey(options.Passphrase, salt)
+		if err != nil {
+			return nil, err
+		}
+		if create {
+			if err := setKeySource(ctx, "", hex.EncodeToString(salt)); err != nil {
+				return nil, err
+			}
+		}
+		return key, nil
+
+	default:
+		return nil, ErrNoKey
+	}
+}
+
+
+// readKeyfile reads a key written by writeKeyfile.
+func readKeyfile(path string) (*cryptokit.Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid keyfile %s: %w", path, err)
+	}
+	return cryptokit.NewKey(raw)
+}
+
+
+// writeKeyfile creates a keyfile with a new key, readable by the owner only.
+func writeKeyfile(path string) (*cryptokit.Key, error) {
+	key, err := cryptokit.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.WriteString(hex.EncodeToString(key.Bytes()) + "\n"); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		return nil, err
+	}
+	log.Info("Created encryption keyfile; keep a copy, blobs cannot be read without it", "path", path)
+	return key, nil
+}
+
+
+func setKeySource(ctx context.Context, keyfile, salt string) error {
+	if err := simplekv.SetString(ctx, keys.ENCRYPTION_KEYFILE, keyfile); err != nil {
+		return err
+	}
+	return simplekv.SetString(ctx, keys.ENCRYPTION_SALT, salt)
+}
+
+
+func save(ctx context.Context, algorithm, keyID string) error {
+	if err := simplekv.SetString(ctx, keys.ENCRYPTION_KEY_ID, keyID); err != nil {
+		return err
+	}
+	return simplekv.SetString(ctx, keys.ENCRYPTION, algorithm)
+}
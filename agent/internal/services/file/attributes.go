@@ -0,0 +1,45 @@
+package file
+
+import (
+	"context"
+	"io/fs"
+
+	"vcx/agent/internal/consts/keys"
+	fileDomain "vcx/agent/internal/domains/file"
+	"vcx/agent/internal/services/simplekv"
+	"vcx/pkg/toolkit/filekit"
+)
+
+
+// AttributesOf returns the attributes recorded with a version of the file
+// info describes; the owner only while owner tracking is on.
+func AttributesOf(ctx context.Context, info fs.FileInfo) filekit.Attributes {
+	return filekit.AttributesOf(info, OwnerTrackingEnabled(ctx))
+}
+
+
+// ModeChanged reports whether the permission bits of the working copy info
+// describes differ from those recorded for file. Files recorded before
+// modes were kept never report a change.
+func ModeChanged(file *fileDomain.File, info fs.FileInfo) bool {
+	return file.Mode != 0 && file.Mode != filekit.ModeBits(info.Mode())
+}
+
+
+// OwnerTrackingEnabled reports whether owner and group are recorded with
+// new versions and reapplied on restore.
+func OwnerTrackingEnabled(ctx context.Context) bool {
+	value, err := simplekv.GetString(ctx, keys.OWNER_TRACKING)
+	return err == nil && value == "on"
+}
+
+
+// SetOwnerTracking turns recording and restoring of owner and group on or
+// off. Restoring an owner other than the agent's own needs privileges.
+func SetOwnerTracking(ctx context.Context, enabled bool) error {
+	value := "on"
+	if !enabled {
+		value = "off"
+	}
+	return simplekv.SetString(ctx, keys.OWNER_TRACKING, value)
+}
@@ -2,42 +2,95 @@ package file
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 
+	"vcx/agent/internal/consts/filetype"
+	"vcx/agent/internal/consts/linktype"
+	changeDomain "vcx/agent/internal/domains/change"
+	conflictDomain "vcx/agent/internal/domains/conflict"
 	fileDomain "vcx/agent/internal/domains/file"
+	"vcx/agent/internal/infra/db"
 	blobService "vcx/agent/internal/services/blob"
+	changeService "vcx/agent/internal/services/change"
 	tagService "vcx/agent/internal/services/tag"
+	"vcx/agent/internal/session"
 	"vcx/pkg/logging"
+	"vcx/pkg/toolkit/filekit"
 )
 
 var log = logging.GetLogger()
 
-// Ingest creates blob and file record with system tag
-func Ingest(ctx context.Context, projectPath, filePath string) (*fileDomain.File, error) {
-	// Create blob (reads file, detects binary, compresses, stores)
-	blob, err := blobService.Create(ctx, filePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create blob: %w", err)
-	}
 
+const (
+	SUMMARY_CREATED  = "created"
+	SUMMARY_MODIFIED = "modified"
+	SUMMARY_DELETED  = "deleted"
+	SUMMARY_MODE     = "mode changed"
+)
+
+
+// Ingest records the current content of filePath on the branch in ctx.
+//
+// The first time a path is seen a file record (with system tag) is created,
+// unless the path is a rename: then the record of the file it was moved from
+// follows it and a RENAME change is recorded. Afterwards the existing record
+// is updated. Every new version is recorded as a FILE change, with the
+// file's attributes. Nothing is written when the content and mode match
+// the recorded ones; a changed mode alone is recorded as a version sharing
+// the blob, a changed mtime or owner alone is not.
+func Ingest(ctx context.Context, projectPath, filePath string) (*fileDomain.File, error) {
 	// Store path relative to the project root
 	relPath, err := filepath.Rel(projectPath, filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to compute relative path: %w", err)
 	}
+	info, err := os.Lstat(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	attrs := AttributesOf(ctx, info)
+
+	existing, _ := fileDomain.GetByPath(ctx, session.GetBranchID(ctx), relPath)
+	if existing != nil && !existing.IsDeleted && existing.Type == filetype.FILE {
+		hash, err := blobService.HashFile(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash file: %w", err)
+		}
+		if hash == existing.BlobID {
+			if !ModeChanged(existing, info) {
+				return existing, nil
+			}
+			return RecordVersion(ctx, relPath, filetype.FILE, existing.BlobID, "", attrs, SUMMARY_MODE)
+		}
+	}
 
-	// Create file record
-	file, err := fileDomain.New(ctx, relPath, blob.ID)
+	// Create blob (reads file, detects binary, compresses, stores, possibly as a delta).
+	// A new path is stored before rename detection, which then matches its
+	// content by blob ID instead of reading the file once more; an edited
+	// rename is therefore not stored as a delta against its source.
+	blob, err := blobService.CreateVersion(ctx, filePath, previousBlobID(existing))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create file: %w", err)
+		return nil, fmt.Errorf("failed to create blob: %w", err)
+	}
+
+	if existing == nil {
+		source, err := findRenameSource(ctx, projectPath, filePath, blob.ID)
+		if err != nil {
+			log.Warn("Rename detection failed, recording as a new file", "path", relPath, "error", err)
+		}
+		if source != nil {
+			return recordRename(ctx, source, relPath, filetype.FILE, blob.ID, "", attrs)
+		}
 	}
 
-	// Create system tag
-	_, err = tagService.CreateSystemFileTag(ctx, file.ID)
+	file, err := recordVersion(ctx, existing, relPath, filetype.FILE, blob.ID, "", attrs, versionSummary(existing))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create file tag: %w", err)
+		return nil, err
 	}
 
 	log.Debug("Ingested file", "path", relPath, "fileID", file.ID, "blobID", blob.ID)
@@ -46,7 +99,11 @@ func Ingest(ctx context.Context, projectPath, filePath string) (*fileDomain.File
 
 
 // IngestSymlink records a symlink's path and target without hashing content.
-// The target is stored as-is (absolute or relative) since it may point outside the project.
+// The target is stored as-is (absolute or relative) since it may point
+// outside the project, and classified (see ClassifyLink). With relative
+// symlinks enabled an absolute target inside the project is first rewritten
+// to a relative one, on disk too.
+// Links carry no attributes: their mode is meaningless on most systems.
 func IngestSymlink(ctx context.Context, projectPath, linkPath string) (*fileDomain.File, error) {
 	target, err := os.Readlink(linkPath)
 	if err != nil {
@@ -58,11 +115,334 @@ func IngestSymlink(ctx context.Context, projectPath, linkPath string) (*fileDoma
 		return nil, fmt.Errorf("failed to compute relative path: %w", err)
 	}
 
-	file, err := fileDomain.NewSymlink(ctx, relPath, target)
+	linkType := ClassifyLink(projectPath, linkPath, target)
+	if linkType == linktype.INTERNAL_ABSOLUTE && RelativeSymlinksEnabled(ctx) {
+		relative, err := relinkRelative(projectPath, linkPath, target)
+		if err != nil {
+			return nil, err
+		}
+		log.Info("Rewrote symlink to a relative target", "path", relPath, "from", target, "to", relative)
+		target, linkType = relative, linktype.INTERNAL_RELATIVE
+	}
+
+	existing, _ := fileDomain.GetByPath(ctx, session.GetBranchID(ctx), relPath)
+	if existing != nil && !existing.IsDeleted && existing.Type == filetype.SYMLINK && existing.Target == target {
+		if existing.LinkType != linkType {
+			if err := existing.SetLinkType(ctx, linkType); err != nil {
+				return nil, err
+			}
+		}
+		return existing, nil
+	}
+
+	file, err := recordVersion(ctx, existing, relPath, filetype.SYMLINK, "", target, filekit.Attributes{}, versionSummary(existing))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create symlink record: %w", err)
 	}
+	if err := file.SetLinkType(ctx, linkType); err != nil {
+		return nil, err
+	}
+
+	log.Debug("Ingested symlink", "path", relPath, "target", target, "linkType", linkType.ToString(), "fileID", file.ID)
+	return file, nil
+}
+
+
+// IngestDir records a directory with its mode, so it is restored even when
+// empty. What is in it is recorded path by path; its mtime, which follows
+// that content, is not kept.
+func IngestDir(ctx context.Context, projectPath, dirPath string) (*fileDomain.File, error) {
+	relPath, err := filepath.Rel(projectPath, dirPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute relative path: %w", err)
+	}
+	if relPath == "." {
+		return nil, fmt.Errorf("the project root is not recorded as a directory")
+	}
+	info, err := os.Lstat(dirPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat directory: %w", err)
+	}
+	attrs := AttributesOf(ctx, info)
+	attrs.MTime = 0
+
+	existing, _ := fileDomain.GetByPath(ctx, session.GetBranchID(ctx), relPath)
+	summary     := versionSummary(existing)
+	if existing != nil && !existing.IsDeleted && existing.Type == filetype.DIR {
+		if !ModeChanged(existing, info) {
+			return existing, nil
+		}
+		summary = SUMMARY_MODE
+	}
+
+	file, err := recordVersion(ctx, existing, relPath, filetype.DIR, "", "", attrs, summary)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create directory record: %w", err)
+	}
+
+	log.Debug("Ingested directory", "path", relPath, "fileID", file.ID)
+	return file, nil
+}
+
+
+// RecordVersion records relPath at already stored content without reading
+// the working copy: blobID for files, target for symlinks, with attrs. Used
+// when vcx itself wrote the working copy (restore, merge). The blob gains a
+// reference.
+func RecordVersion(ctx context.Context, relPath string, fileType filetype.FileType, blobID, target string, attrs filekit.Attributes, summary string) (*fileDomain.File, error) {
+	existing, _ := fileDomain.GetByPath(ctx, session.GetBranchID(ctx), relPath)
+
+	if blobID != "" {
+		if err := blobService.Retain(ctx, blobID); err != nil {
+			return nil, err
+		}
+	}
+	return recordVersion(ctx, existing, relPath, fileType, blobID, target, attrs, summary)
+}
+
+
+// RecordContent records relPath at content vcx produced itself (a merge
+// result) without reading the working copy. The content is stored like an
+// ingested version, possibly as a delta against the previous one.
+func RecordContent(ctx context.Context, relPath string, data []byte, attrs filekit.Attributes, summary string) (*fileDomain.File, error) {
+	existing, _ := fileDomain.GetByPath(ctx, session.GetBranchID(ctx), relPath)
+
+	blob, err := blobService.CreateFromData(ctx, data, previousBlobID(existing))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create blob: %w", err)
+	}
+	return recordVersion(ctx, existing, relPath, filetype.FILE, blob.ID, "", attrs, summary)
+}
+
+
+// Adopt creates a file record on the branch in ctx for a version recorded on
+// another branch (when forking or merging). The record points at that
+// version, so the file's history carries over and its next version
+// continues the chain without changing the other branch's. Nothing new is
+// recorded and the blob gains no reference.
+func Adopt(ctx context.Context, state *changeDomain.Change) (*fileDomain.File, error) {
+	var file *fileDomain.File
+
+	err := db.WithTransactionContext(ctx, func(ctx context.Context, _ *sql.Tx) error {
+		ctx = session.WithChangeID(ctx, state.ID)
+
+		var err error
+		file, err = newFile(ctx, state.Type(), state.Path, state.BlobID, state.Target, state.Mode)
+		if err != nil {
+			return fmt.Errorf("failed to create file: %w", err)
+		}
+		if _, err := tagService.CreateSystemFileTag(ctx, file.ID); err != nil {
+			return fmt.Errorf("failed to create file tag: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+
+// Remove records the deletion of path. When path was a directory every live
+// file below it is marked deleted as well. Returns the files that were marked.
+func Remove(ctx context.Context, projectPath, path string) ([]*fileDomain.File, error) {
+	relPath, err := filepath.Rel(projectPath, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute relative path: %w", err)
+	}
+	branchID := session.GetBranchID(ctx)
+
+	var targets []*fileDomain.File
+	if file, err := fileDomain.GetByPath(ctx, branchID, relPath); err == nil && !file.IsDeleted {
+		targets = append(targets, file)
+	}
+	children, err := fileDomain.GetUnderPath(ctx, branchID, relPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up files under %s: %w", relPath, err)
+	}
+	targets = append(targets, children...)
+
+	for _, file := range targets {
+		if err := MarkDeleted(ctx, file); err != nil {
+			return nil, err
+		}
+	}
+	return targets, nil
+}
+
 
-	log.Debug("Ingested symlink", "path", relPath, "target", target, "fileID", file.ID)
+// MarkDeleted tombstones a file and records the deletion as a FILE change.
+// The file keeps its last BlobID so the content stays restorable.
+func MarkDeleted(ctx context.Context, file *fileDomain.File) error {
+	err := db.WithTransactionContext(ctx, func(ctx context.Context, _ *sql.Tx) error {
+		change, err := changeService.CreateFileVersion(ctx, file.ID, file.ChangeID, file.Type, "", file.Path, file.Target, filekit.Attributes{}, true, SUMMARY_DELETED)
+		if err != nil {
+			return fmt.Errorf("failed to create change: %w", err)
+		}
+		if err := flagConflicted(ctx, change); err != nil {
+			return err
+		}
+
+		file.IsDeleted = true
+		file.ChangeID  = change.ID
+		if err := file.Update(ctx); err != nil {
+			return fmt.Errorf("failed to update file: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Debug("Removed file", "path", file.Path, "fileID", file.ID, "changeID", file.ChangeID)
+	return nil
+}
+
+
+// recordVersion stores a new version of the file at relPath. It creates the
+// file record on first sight, otherwise points the existing one at the new
+// change. The change, its chain links and the file record are written in one
+// transaction.
+func recordVersion(ctx context.Context, existing *fileDomain.File, relPath string, fileType filetype.FileType, blobID, target string, attrs filekit.Attributes, summary string) (*fileDomain.File, error) {
+	var file *fileDomain.File
+
+	err := db.WithTransactionContext(ctx, func(ctx context.Context, _ *sql.Tx) error {
+		if existing != nil {
+			change, err := changeService.CreateFileVersion(ctx, existing.ID, existing.ChangeID, fileType, blobID, relPath, target, attrs, false, summary)
+			if err != nil {
+				return fmt.Errorf("failed to create change: %w", err)
+			}
+			if err := flagConflicted(ctx, change); err != nil {
+				return err
+			}
+
+			existing.Type      = fileType
+			existing.BlobID    = blobID
+			existing.Target    = target
+			existing.ChangeID  = change.ID
+			existing.IsDeleted = false
+			existing.Mode      = attrs.Mode
+			existing.LinkType  = linktype.NONE // until the link is ingested
+			if err := existing.Update(ctx); err != nil {
+				return fmt.Errorf("failed to update file: %w", err)
+			}
+			file = existing
+			return nil
+		}
+
+		change, err := changeService.CreateFileVersion(ctx, "", "", fileType, blobID, relPath, target, attrs, false, summary)
+		if err != nil {
+			return fmt.Errorf("failed to create change: %w", err)
+		}
+		if err := flagConflicted(ctx, change); err != nil {
+			return err
+		}
+
+		ctx = session.WithChangeID(ctx, change.ID)
+		file, err = newFile(ctx, fileType, relPath, blobID, target, attrs.Mode)
+		if err != nil {
+			return fmt.Errorf("failed to create file: %w", err)
+		}
+
+		change.FileID = file.ID
+		if err := change.Update(ctx); err != nil {
+			return fmt.Errorf("failed to link change to file: %w", err)
+		}
+
+		// Create system tag
+		if _, err := tagService.CreateSystemFileTag(ctx, file.ID); err != nil {
+			return fmt.Errorf("failed to create file tag: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 	return file, nil
 }
+
+
+// newFile creates the record of a path first seen as fileType.
+func newFile(ctx context.Context, fileType filetype.FileType, relPath, blobID, target string, mode uint32) (*fileDomain.File, error) {
+	switch fileType {
+	case filetype.SYMLINK:
+		return fileDomain.NewSymlink(ctx, relPath, target)
+	case filetype.DIR:
+		return fileDomain.NewDir(ctx, relPath, mode)
+	default:
+		return fileDomain.New(ctx, relPath, blobID, mode)
+	}
+}
+
+
+// flagConflicted flags a new version of a path that has an unresolved
+// conflict on its branch, so snapshots taken mid-conflict stand out.
+func flagConflicted(ctx context.Context, change *changeDomain.Change) error {
+	if _, err := conflictDomain.GetOpenByPath(ctx, change.BranchID, change.Path); err != nil {
+		return nil
+	}
+	if err := change.MarkConflicted(ctx); err != nil {
+		return fmt.Errorf("failed to flag conflicted version: %w", err)
+	}
+	return nil
+}
+
+
+// MatchesWorkingCopy reports whether the working copy under projectPath is
+// in the state recorded for file: same content (and mode), symlink target
+// or directory mode for live files, absent for deleted ones.
+func MatchesWorkingCopy(projectPath string, file *fileDomain.File) (bool, error) {
+	absPath := filepath.Join(projectPath, file.Path)
+
+	info, err := os.Lstat(absPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return file.IsDeleted, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if file.IsDeleted {
+		return false, nil
+	}
+
+	switch file.Type {
+	case filetype.SYMLINK:
+		if info.Mode()&os.ModeSymlink == 0 {
+			return false, nil
+		}
+		target, err := os.Readlink(absPath)
+		if err != nil {
+			return false, err
+		}
+		return target == file.Target, nil
+	case filetype.DIR:
+		return info.IsDir() && !ModeChanged(file, info), nil
+	default:
+		if !info.Mode().IsRegular() {
+			return false, nil
+		}
+		hash, err := blobService.HashFile(absPath)
+		if err != nil {
+			return false, err
+		}
+		return hash == file.BlobID && !ModeChanged(file, info), nil
+	}
+}
+
+
+func versionSummary(existing *fileDomain.File) string {
+	if existing == nil || existing.IsDeleted {
+		return SUMMARY_CREATED
+	}
+	return SUMMARY_MODIFIED
+}
+
+
+// previousBlobID returns the blob of the last recorded version of a file, the
+// natural delta base for its next version.
+func previousBlobID(existing *fileDomain.File) string {
+	if existing == nil || existing.Type != filetype.FILE {
+		return ""
+	}
+	return existing.BlobID
+}
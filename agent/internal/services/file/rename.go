@@ -0,0 +1,182 @@
+package file
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"vcx/agent/internal/consts/filetype"
+	"vcx/agent/internal/consts/linktype"
+	blobDomain "vcx/agent/internal/domains/blob"
+	fileDomain "vcx/agent/internal/domains/file"
+	"vcx/agent/internal/infra/db"
+	blobService "vcx/agent/internal/services/blob"
+	changeService "vcx/agent/internal/services/change"
+	"vcx/agent/internal/session"
+	"vcx/pkg/toolkit/diffkit"
+	"vcx/pkg/toolkit/filekit"
+)
+
+
+const (
+	RENAME_WINDOW         = 5 * time.Minute // how long a deleted file stays a rename source
+	RENAME_SIMILARITY     = 0.5             // minimum share of lines kept by an edited rename
+	MAX_RENAME_SIZE       = 1 << 20         // larger content is only matched by hash
+	MAX_RENAME_CANDIDATES = 50              // deleted files compared by content per new path
+
+	SUMMARY_RENAMED = "renamed from"
+)
+
+
+// RecordRename records that file moved to relPath, at already stored
+// content (blobID) or symlink target and attributes, without reading the
+// working copy. The file keeps its identity and history. The blob gains a
+// reference.
+func RecordRename(ctx context.Context, file *fileDomain.File, relPath string, fileType filetype.FileType, blobID, target string, attrs filekit.Attributes) (*fileDomain.File, error) {
+	if blobID != "" {
+		if err := blobService.Retain(ctx, blobID); err != nil {
+			return nil, err
+		}
+	}
+	return recordRename(ctx, file, relPath, fileType, blobID, target, attrs)
+}
+
+
+// recordRename appends a RENAME change to the file's chain and moves the
+// file record to relPath in one transaction.
+func recordRename(ctx context.Context, file *fileDomain.File, relPath string, fileType filetype.FileType, blobID, target string, attrs filekit.Attributes) (*fileDomain.File, error) {
+	fromPath := file.Path
+	summary  := fmt.Sprintf("%s %s", SUMMARY_RENAMED, fromPath)
+
+	err := db.WithTransactionContext(ctx, func(ctx context.Context, _ *sql.Tx) error {
+		change, err := changeService.CreateRename(ctx, file.ID, file.ChangeID, fileType, blobID, relPath, target, attrs, summary)
+		if err != nil {
+			return fmt.Errorf("failed to create change: %w", err)
+		}
+		if err := flagConflicted(ctx, change); err != nil {
+			return err
+		}
+
+		file.Path      = relPath
+		file.Type      = fileType
+		file.BlobID    = blobID
+		file.Target    = target
+		file.ChangeID  = change.ID
+		file.IsDeleted = false
+		file.Mode      = attrs.Mode
+		file.LinkType  = linktype.NONE // until the link is ingested
+		if err := file.Update(ctx); err != nil {
+			return fmt.Errorf("failed to update file: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug("Renamed file", "from", fromPath, "to", relPath, "fileID", file.ID, "changeID", file.ChangeID)
+	return file, nil
+}
+
+
+// findRenameSource looks for the file that the new path filePath, stored as
+// blobID, was moved from on the branch in ctx: first a file with the same
+// content that was just deleted or is gone from the working copy but not yet
+// recorded as deleted, then, for text, the most similar file deleted within
+// RENAME_WINDOW. Returns nil when there is none.
+func findRenameSource(ctx context.Context, projectPath, filePath, blobID string) (*fileDomain.File, error) {
+	branchID := session.GetBranchID(ctx)
+	since    := time.Now().Add(-RENAME_WINDOW)
+
+	sameContent, err := fileDomain.GetByBlob(ctx, branchID, blobID)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range sameContent {
+		if file.Type != filetype.FILE {
+			continue
+		}
+		if file.IsDeleted && deletedSince(file, since) {
+			return file, nil
+		}
+		if !file.IsDeleted && missing(projectPath, file) {
+			return file, nil
+		}
+	}
+
+	return findSimilar(ctx, branchID, filePath, since)
+}
+
+
+// findSimilar returns the text file deleted since since whose last content
+// shares the most lines with filePath, if it reaches RENAME_SIMILARITY.
+func findSimilar(ctx context.Context, branchID, filePath string, since time.Time) (*fileDomain.File, error) {
+	info, err := os.Stat(filePath)
+	if err != nil || info.Size() > MAX_RENAME_SIZE {
+		return nil, err
+	}
+	deleted, err := fileDomain.GetDeletedSince(ctx, branchID, since)
+	if err != nil || len(deleted) == 0 {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if filekit.IsBinary(content) {
+		return nil, nil
+	}
+
+	var best *fileDomain.File
+	bestScore := RENAME_SIMILARITY
+	for i, file := range deleted {
+		if i == MAX_RENAME_CANDIDATES {
+			break
+		}
+		if file.Type != filetype.FILE || file.BlobID == "" || !comparableSize(ctx, file.BlobID, int64(len(content))) {
+			continue
+		}
+		previous, err := blobService.Read(ctx, file.BlobID)
+		if err != nil {
+			log.Warn("Failed to read rename candidate", "path", file.Path, "blobID", file.BlobID, "error", err)
+			continue
+		}
+		if filekit.IsBinary(previous) {
+			continue
+		}
+		if score := diffkit.Similarity(string(previous), string(content), diffkit.MYERS); score >= bestScore {
+			best, bestScore = file, score
+		}
+	}
+	return best, nil
+}
+
+
+// comparableSize reports whether a blob is small enough, and close enough
+// in size to the new content, to possibly reach RENAME_SIMILARITY.
+func comparableSize(ctx context.Context, blobID string, size int64) bool {
+	blobSize, err := blobDomain.GetSize(ctx, blobID)
+	if err != nil || blobSize > MAX_RENAME_SIZE {
+		return false
+	}
+	smaller, larger := min(blobSize, size), max(blobSize, size)
+	return float64(smaller) >= float64(larger)*RENAME_SIMILARITY/2
+}
+
+
+func deletedSince(file *fileDomain.File, since time.Time) bool {
+	deleted, err := time.Parse(time.RFC3339, file.LMD)
+	return err == nil && !deleted.Before(since.Truncate(time.Second))
+}
+
+
+func missing(projectPath string, file *fileDomain.File) bool {
+	_, err := os.Lstat(filepath.Join(projectPath, file.Path))
+	return errors.Is(err, fs.ErrNotExist)
+}
@@ -0,0 +1,82 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"vcx/agent/internal/consts/keys"
+	"vcx/agent/internal/consts/linktype"
+	"vcx/agent/internal/services/simplekv"
+	"vcx/pkg/toolkit/filekit"
+	"vcx/pkg/toolkit/pathkit"
+)
+
+
+// ClassifyLink tells where the symlink at linkPath with target points
+// relative to the project at projectPath. The target is resolved by path
+// only, never followed, so dangling links and loops classify like any other.
+func ClassifyLink(projectPath, linkPath, target string) linktype.LinkType {
+	if !filepath.IsAbs(target) {
+		if pathkit.Within(projectPath, filepath.Join(filepath.Dir(linkPath), target)) {
+			return linktype.INTERNAL_RELATIVE
+		}
+		return linktype.EXTERNAL
+	}
+	if withinProject(projectPath, target) {
+		return linktype.INTERNAL_ABSOLUTE
+	}
+	return linktype.EXTERNAL
+}
+
+
+// relinkRelative points the symlink at linkPath, whose absolute target lies
+// inside the project, at the same place by a relative target, and returns
+// that target.
+func relinkRelative(projectPath, linkPath, target string) (string, error) {
+	// the target may name the project through another path to it
+	if real, err := filepath.EvalSymlinks(projectPath); err == nil && !pathkit.Within(projectPath, target) {
+		if rel, err := filepath.Rel(real, target); err == nil {
+			target = filepath.Join(projectPath, rel)
+		}
+	}
+	relative, err := filepath.Rel(filepath.Dir(linkPath), target)
+	if err != nil {
+		return "", err
+	}
+	if err := filekit.SymlinkAtomic(relative, linkPath); err != nil {
+		return "", fmt.Errorf("failed to rewrite symlink: %w", err)
+	}
+	return relative, nil
+}
+
+
+// withinProject reports whether the absolute path lies in the project,
+// named either by projectPath or by its path with symlinks resolved.
+func withinProject(projectPath, path string) bool {
+	if pathkit.Within(projectPath, path) {
+		return true
+	}
+	real, err := filepath.EvalSymlinks(projectPath)
+	return err == nil && pathkit.Within(real, path)
+}
+
+
+// RelativeSymlinksEnabled reports whether absolute symlink targets inside
+// the project are rewritten to relative ones on ingest.
+func RelativeSymlinksEnabled(ctx context.Context) bool {
+	value, err := simplekv.GetString(ctx, keys.RELATIVE_SYMLINKS)
+	return err == nil && value == "on"
+}
+
+
+// SetRelativeSymlinks turns the rewriting of absolute symlink targets
+// inside the project on or off. Links already recorded are rewritten when
+// next ingested.
+func SetRelativeSymlinks(ctx context.Context, enabled bool) error {
+	value := "on"
+	if !enabled {
+		value = "off"
+	}
+	return simplekv.SetString(ctx, keys.RELATIVE_SYMLINKS, value)
+}
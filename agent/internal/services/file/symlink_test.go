@@ -0,0 +1,48 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"vcx/agent/internal/consts/linktype"
+)
+
+
+func TestClassifyLink(t *testing.T) {
+	project, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	outside := t.TempDir()
+	alias   := filepath.Join(t.TempDir(), "alias")
+	if err := os.Symlink(project, alias); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name    string
+		project string
+		link    string
+		target  string
+		want    linktype.LinkType
+	}{
+		{"relative inside", project, "l", "d/x", linktype.INTERNAL_RELATIVE},
+		{"relative up to a sibling", project, "d/l", "../x", linktype.INTERNAL_RELATIVE},
+		{"relative to the root", project, "d/l", "..", linktype.INTERNAL_RELATIVE},
+		{"relative climbing out", project, "d/l", "../../x", linktype.EXTERNAL},
+		{"relative loop", project, "l", "l", linktype.INTERNAL_RELATIVE},
+		{"absolute inside", project, "l", filepath.Join(project, "d", "x"), linktype.INTERNAL_ABSOLUTE},
+		{"absolute outside", project, "l", outside, linktype.EXTERNAL},
+		{"absolute climbing out", project, "l", filepath.Join(project, "..", "x"), linktype.EXTERNAL},
+		{"through a symlinked root", alias, "l", filepath.Join(project, "d", "x"), linktype.INTERNAL_ABSOLUTE},
+		{"naming the symlinked root", alias, "l", filepath.Join(alias, "d", "x"), linktype.INTERNAL_ABSOLUTE},
+		{"through a link outside", project, "l", filepath.Join(alias, "d", "x"), linktype.EXTERNAL},
+	}
+	for _, c := range cases {
+		got := ClassifyLink(c.project, filepath.Join(c.project, c.link), c.target)
+		if got != c.want {
+			t.Errorf("%s: %s -> %s classified %s, want %s", c.name, c.link, c.target, got.ToString(), c.want.ToString())
+		}
+	}
+}
@@ -3,6 +3,7 @@ package filters
 import (
 	"bufio"
 	"os"
+	"path/filepath"
 	"strings"
 	"vcx/pkg/logging"
 )
@@ -19,6 +20,14 @@ func DefaultFilter(instancePath string) FilterInterface {
     return DefaultQuickFilter(instancePath)
 }
 
+// ForInstance returns the initialized filter used to decide which paths of an
+// instance are versioned.
+func ForInstance(instancePath string) FilterInterface {
+	filter := FromFile(instancePath, filepath.Join(FILTERSAMPLEPATH, FILTERFILE))
+	filter.Init()
+	return filter
+}
+
 func FromFile(instancePath string, path string) FilterInterface {
 	file, err := os.Open(path)
 	if err != nil {
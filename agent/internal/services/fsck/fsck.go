@@ -0,0 +1,257 @@
+// Package fsck verifies the integrity of the blob storage.
+//
+// Checks:
+//   - Every blob's content (rebuilt from DB bytes, blob store files, pack
+//     entries and delta chains, decompressed) still hashes to its SHA-256 ID
+//     and has the recorded size
+//   - Every file, change and snapshot blobID points at an existing blob
+//   - Every RefCounter matches the actual number of references
+//
+// Wrong sizes and reference counters can be repaired from the data; content
+// problems can only be reported.
+package fsck
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	blobDomain "vcx/agent/internal/domains/blob"
+	changeDomain "vcx/agent/internal/domains/change"
+	fileDomain "vcx/agent/internal/domains/file"
+	snapshotDomain "vcx/agent/internal/domains/snapshot"
+	"vcx/agent/internal/infra/db"
+	blobService "vcx/agent/internal/services/blob"
+	"vcx/pkg/logging"
+)
+
+
+var log = logging.GetLogger()
+
+
+const (
+	KIND_UNREADABLE    = "unreadable"     // content missing or cannot be rebuilt
+	KIND_HASH_MISMATCH = "hash-mismatch"  // content does not hash to the blob ID
+	KIND_SIZE_MISMATCH = "size-mismatch"  // recorded size differs from the content
+	KIND_DANGLING      = "dangling"       // a row references a blob that does not exist
+	KIND_REFCOUNT      = "refcount"       // RefCounter differs from the actual references
+)
+
+
+// Options control a verification run.
+//
+// Fields:
+//   - Repair: Fix what can be fixed (sizes, reference counters)
+type Options struct {
+	Repair bool
+}
+
+
+// Problem is one inconsistency found. Table and ID name the referencing row
+// for dangling references.
+type Problem struct {
+	Kind     string `json:"kind"`
+	BlobID   string `json:"blobID"`
+	Table    string `json:"table,omitempty"`
+	ID       string `json:"id,omitempty"`
+	Path     string `json:"path,omitempty"`
+	Detail   string `json:"detail"`
+	Repaired bool   `json:"repaired"`
+}
+
+
+// Report summarises a verification run.
+type Report struct {
+	Blobs     int       `json:"blobs"`
+	Files     int       `json:"files"`
+	Changes   int       `json:"changes"`
+	Snapshots int       `json:"snapshots"`
+	Problems  []Problem `json:"problems"`
+	Repaired  int       `json:"repaired"`
+}
+
+
+func (r *Report) add(problem Problem) {
+	r.Problems = append(r.Problems, problem)
+	if problem.Repaired {
+		r.Repaired++
+	}
+}
+
+
+// Check verifies the blob storage and, with Repair, fixes what it can.
+func Check(ctx context.Context, options Options) (*Report, error) {
+	report := &Report{Problems: []Problem{}}
+
+	blobs, err := blobDomain.GetAllMetadata(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list blobs: %w", err)
+	}
+	report.Blobs = len(blobs)
+
+	for _, blob := range blobs {
+		if err := checkContent(ctx, blob.ID, options.Repair, report); err != nil {
+			return report, err
+		}
+	}
+
+	// References are counted in one transaction, so versions recorded while
+	// counting cannot skew the repaired counters.
+	withSnapshots := snapshotDomain.Available()
+	err = db.WithTransactionContext(ctx, func(ctx context.Context, _ *sql.Tx) error {
+		return checkReferences(ctx, withSnapshots, options.Repair, report)
+	})
+	if err != nil {
+		return report, err
+	}
+
+	log.Info("Storage verification finished", "blobs", report.Blobs, "problems", len(report.Problems), "repaired", report.Repaired)
+	return report, nil
+}
+
+
+func checkContent(ctx context.Context, id string, repair bool, report *Report) error {
+	blob, err := blobDomain.GetByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to load blob %s: %w", id, err)
+	}
+
+	// stream the content, large blobs need not fit in memory
+	content, err := blobService.Open(ctx, id)
+	if err != nil {
+		report.add(Problem{Kind: KIND_UNREADABLE, BlobID: id, Detail: err.Error()})
+		return nil
+	}
+	defer content.Close()
+
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, content)
+	if err != nil {
+		report.add(Problem{Kind: KIND_UNREADABLE, BlobID: id, Detail: err.Error()})
+		return nil
+	}
+
+	if hash := hex.EncodeToString(hasher.Sum(nil)); hash != id {
+		report.add(Problem{Kind: KIND_HASH_MISMATCH, BlobID: id, Detail: fmt.Sprintf("content hashes to %s", hash)})
+		return nil
+	}
+
+	if size != blob.Size {
+		problem := Problem{Kind: KIND_SIZE_MISMATCH, BlobID: id, Detail: fmt.Sprintf("recorded %d bytes, content has %d", blob.Size, size)}
+		if repair {
+			if err := blob.SetSize(ctx, size); err != nil {
+				return err
+			}
+			problem.Repaired = true
+		}
+		report.add(problem)
+	}
+	return nil
+}
+
+
+// checkReferences reports references to missing blobs and compares every
+// RefCounter with the actual count: one per change recording the blob, one
+// per delta stored against it and one per manifest entry listing it. Files
+// and snapshots mirror a change, so they only count for blobs no change
+// refers to (data from before changes carried blob IDs).
+func checkReferences(ctx context.Context, withSnapshots, repair bool, report *Report) error {
+	blobs, err := blobDomain.GetAllMetadata(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list blobs: %w", err)
+	}
+	exists := make(map[string]bool, len(blobs))
+	for _, blob := range blobs {
+		exists[blob.ID] = true
+	}
+
+	counts := make(map[string]int, len(blobs))
+	dangling := func(table, id, path, blobID string) {
+		report.add(Problem{Kind: KIND_DANGLING, BlobID: blobID, Table: table, ID: id, Path: path, Detail: "blob does not exist"})
+	}
+
+	changes, err := changeDomain.GetWithBlob(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list changes: %w", err)
+	}
+	report.Changes = len(changes)
+	for _, change := range changes {
+		if !exists[change.BlobID] {
+			dangling("change", change.ID, change.Path, change.BlobID)
+			continue
+		}
+		counts[change.BlobID]++
+	}
+
+	// rows that are the only reference to their blob
+	legacy := make(map[string]int)
+
+	files, err := fileDomain.GetWithBlob(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list files: %w", err)
+	}
+	report.Files = len(files)
+	for _, file := range files {
+		if !exists[file.BlobID] {
+			dangling("file", file.ID, file.Path, file.BlobID)
+		} else if counts[file.BlobID] == 0 {
+			legacy[file.BlobID]++
+		}
+	}
+
+	if withSnapshots {
+		snapshots, err := snapshotDomain.GetWithBlob(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list snapshots: %w", err)
+		}
+		report.Snapshots = len(snapshots)
+		for _, snapshot := range snapshots {
+			if !exists[snapshot.BlobID] {
+				dangling("snapshot", snapshot.ID, "", snapshot.BlobID)
+			} else if counts[snapshot.BlobID] == 0 {
+				legacy[snapshot.BlobID]++
+			}
+		}
+	}
+
+	for blobID, n := range legacy {
+		counts[blobID] += n
+	}
+
+	for _, blob := range blobs {
+		if blob.IsDelta() && exists[blob.BaseBlobID] {
+			counts[blob.BaseBlobID]++
+		}
+		if blob.IsManifest {
+			// unreadable manifests were reported by the content check
+			chunks, err := blobService.ManifestChunks(ctx, blob.ID)
+			if err != nil {
+				continue
+			}
+			for _, chunk := range chunks {
+				if exists[chunk.ID] {
+					counts[chunk.ID]++
+				}
+			}
+		}
+	}
+
+	for _, blob := range blobs {
+		if blob.RefCounter == counts[blob.ID] {
+			continue
+		}
+
+		problem := Problem{Kind: KIND_REFCOUNT, BlobID: blob.ID, Detail: fmt.Sprintf("RefCounter is %d, %d reference(s) found", blob.RefCounter, counts[blob.ID])}
+		if repair {
+			if err := blob.SetRefCounter(ctx, counts[blob.ID]); err != nil {
+				return err
+			}
+			problem.Repaired = true
+		}
+		report.add(problem)
+	}
+	return nil
+}
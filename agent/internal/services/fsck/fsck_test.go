@@ -0,0 +1,125 @@
+package fsck
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	blobDomain "vcx/agent/internal/domains/blob"
+	fileDomain "vcx/agent/internal/domains/file"
+	"vcx/agent/internal/infra/db"
+	blobStore "vcx/agent/internal/infra/db/store/blob"
+	fileStore "vcx/agent/internal/infra/db/store/file"
+	snapshotStore "vcx/agent/internal/infra/db/store/snapshot"
+	blobService "vcx/agent/internal/services/blob"
+	"vcx/agent/internal/services/servicetest"
+	"vcx/agent/internal/session"
+)
+
+
+func TestCheckRepairsReferenceCounters(t *testing.T) {
+	ctx   := servicetest.Context(t)
+	text  := strings.Repeat("a line that stays the same\n", 400)
+	large := make([]byte, blobService.CHUNK_THRESHOLD+1)
+	rand.New(rand.NewSource(1)).Read(large)
+	project := servicetest.NewProject(t, ctx, map[string]string{
+		"delta.txt":  text,
+		"change.txt": "recorded by a change\n",
+		"legacy.txt": "replaced below\n",
+		"large.bin":  string(large),
+	})
+	project.Write("delta.txt", text+"one more line\n")
+	ctx = project.Context()
+
+	current := func(relPath string) *blobDomain.Blob {
+		t.Helper()
+		file, err := fileDomain.GetByPath(ctx, session.GetBranchID(ctx), relPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		blob, err := blobDomain.GetByID(ctx, file.BlobID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return blob
+	}
+
+	delta := current("delta.txt")
+	if !delta.IsDelta() {
+		t.Fatal("expected the second version to be stored as a delta")
+	}
+	manifest := current("large.bin")
+	if !manifest.IsManifest {
+		t.Fatal("expected the large file to be chunked")
+	}
+	chunks, err := blobService.ManifestChunks(ctx, manifest.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a blob only file and snapshot rows refer to, as before changes
+	// carried blob IDs
+	legacy, err := blobService.CreateFromData(ctx, []byte("only in legacy rows\n"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range []string{"file", "snapshot"} {
+		if _, err := db.QueryWithContext(ctx, "UPDATE "+table+" SET "+fileStore.COL_BLOBID+" = ? WHERE "+fileStore.COL_PATH+" = ?",
+			legacy.ID, "legacy.txt"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rows, err := db.QueryWithContext(ctx,
+		"SELECT (SELECT COUNT(*) FROM file WHERE "+fileStore.COL_BLOBID+" = ?) + (SELECT COUNT(*) FROM snapshot WHERE "+snapshotStore.COL_BLOBID+" = ?) AS n",
+		legacy.ID, legacy.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := legacy.SetRefCounter(ctx, int(rows[0]["n"].(int64))); err != nil {
+		t.Fatal(err)
+	}
+
+	if report, err := Check(ctx, Options{}); err != nil || len(report.Problems) != 0 {
+		t.Fatalf("expected a clean store to start from, got %+v, %v", report, err)
+	}
+
+	cases := []struct {
+		name   string
+		blobID string
+	}{
+		{"recorded by a change", current("change.txt").ID},
+		{"delta base", delta.BaseBlobID},
+		{"manifest chunk", chunks[0].ID},
+		{"legacy file and snapshot rows", legacy.ID},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			blob, err := blobDomain.GetByID(ctx, c.blobID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := blob.RefCounter
+			if _, err := db.QueryWithContext(ctx, "UPDATE blob SET "+blobStore.COL_REFCOUNTER+" = ? WHERE id = ?", want+5, c.blobID); err != nil {
+				t.Fatal(err)
+			}
+
+			report, err := Check(ctx, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(report.Problems) != 1 || report.Problems[0].Kind != KIND_REFCOUNT || report.Problems[0].BlobID != c.blobID {
+				t.Fatalf("got problems %+v, want one refcount problem on %s", report.Problems, c.blobID)
+			}
+
+			if report, err = Check(ctx, Options{Repair: true}); err != nil || report.Repaired != 1 {
+				t.Fatalf("got %d repaired, %v; want the counter repaired", report.Repaired, err)
+			}
+			if blob, err = blobDomain.GetByID(ctx, c.blobID); err != nil || blob.RefCounter != want {
+				t.Errorf("RefCounter is %d after the repair, want %d", blob.RefCounter, want)
+			}
+			if report, err = Check(ctx, Options{}); err != nil || len(report.Problems) != 0 {
+				t.Errorf("problems left after the repair: %+v, %v", report.Problems, err)
+			}
+		})
+	}
+}
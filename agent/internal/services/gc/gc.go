@@ -0,0 +1,300 @@
+// Package gc reclaims storage that no version refers to anymore.
+//
+// Two kinds of garbage are collected:
+//   - Blobs whose RefCounter dropped to zero: the DB row and, for large blobs,
+//     the file in the blob store
+//   - Orphan files in the blob store without a matching blob row, left behind
+//     when the DB insert failed after the content was stored, packs no blob
+//     points into anymore, and staging files below dbsetup.BlobStorePath left
+//     by an interrupted write
+//
+// The entry of a removed packed blob stays in its pack until the repack job
+// rewrites the pack.
+//
+// Both are only touched once they are older than the grace period, so content
+// that an ingestion in flight is about to reference is never removed.
+package gc
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	blobDomain "vcx/agent/internal/domains/blob"
+	"vcx/agent/internal/infra/db"
+	"vcx/agent/internal/infra/db/dbsetup"
+	blobService "vcx/agent/internal/services/blob"
+	"vcx/pkg/logging"
+	"vcx/pkg/toolkit/filekit"
+)
+
+
+var log = logging.GetLogger()
+
+
+const (
+	DEFAULT_GRACE_PERIOD = 24 * time.Hour
+	DEFAULT_INTERVAL     = 6 * time.Hour
+)
+
+
+// Options control a collection run.
+//
+// Fields:
+//   - GracePeriod: Leave garbage younger than this alone
+//   - DryRun: Only report what would be reclaimed
+type Options struct {
+	GracePeriod time.Duration
+	DryRun      bool
+}
+
+
+func DefaultOptions() Options {
+	return Options{GracePeriod: DEFAULT_GRACE_PERIOD}
+}
+
+
+// Report describes what a run reclaimed, or would reclaim in a dry run.
+// Pending counts unreferenced blobs still inside the grace period.
+type Report struct {
+	DryRun      bool     `json:"dryRun"`
+	GracePeriod string   `json:"gracePeriod"`
+	Blobs       []string `json:"blobs"`
+	BlobBytes   int64    `json:"blobBytes"`
+	Orphans     []string `json:"orphans"`
+	OrphanBytes int64    `json:"orphanBytes"`
+	Pending     int      `json:"pending"`
+}
+
+
+// Bytes returns the total storage reclaimed.
+func (r *Report) Bytes() int64 {
+	return r.BlobBytes + r.OrphanBytes
+}
+
+
+// Run performs one collection. Removing a delta blob releases its reference
+// on the base, removing a manifest those on its chunks; a blob reaching zero
+// that way starts its own grace period and is collected by a later run.
+func Run(ctx context.Context, options Options) (*Report, error) {
+	// one collection at a time, and never while blobs are being repacked
+	blobService.Maintenance.Lock()
+	defer blobService.Maintenance.Unlock()
+
+	cutoff := time.Now().Add(-options.GracePeriod)
+	report := &Report{
+		DryRun:      options.DryRun,
+		GracePeriod: options.GracePeriod.String(),
+		Blobs:       []string{},
+		Orphans:     []string{},
+	}
+
+	if err := collectBlobs(ctx, cutoff, options.DryRun, report); err != nil {
+		return report, err
+	}
+	if err := sweepOrphans(ctx, cutoff, options.DryRun, report); err != nil {
+		return report, err
+	}
+
+	log.Info("Blob garbage collection finished", "dryRun", options.DryRun,
+		"blobs", len(report.Blobs), "orphans", len(report.Orphans), "bytes", report.Bytes(), "pending", report.Pending)
+	return report, nil
+}
+
+
+// Start runs a collection with the default options every interval until ctx
+// is cancelled.
+func Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := Run(ctx, DefaultOptions()); err != nil {
+				log.Error("Blob garbage collection failed", "error", err)
+			}
+		}
+	}
+}
+
+
+func collectBlobs(ctx context.Context, cutoff time.Time, dryRun bool, report *Report) error {
+	blobs, err := blobDomain.GetUnreferenced(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list unreferenced blobs: %w", err)
+	}
+
+	for _, blob := range blobs {
+		if !olderThan(blob.LMD, cutoff) {
+			report.Pending++
+			continue
+		}
+
+		size := blobService.StoredSize(ctx, blob)
+		if !dryRun {
+			removed, err := removeBlob(ctx, blob.ID)
+			if err != nil {
+				return err
+			}
+			if !removed {
+				continue
+			}
+		}
+		report.Blobs      = append(report.Blobs, blob.ID)
+		report.BlobBytes += size
+	}
+	return nil
+}
+
+
+// removeBlob deletes an unreferenced blob and releases its delta base or
+// chunks. The blob is re-read inside the transaction so a reference taken
+// since it was listed keeps it alive. The stored file is removed after the
+// commit; if that fails it is swept as an orphan later.
+func removeBlob(ctx context.Context, id string) (bool, error) {
+	var fileKey string
+	removed := false
+
+	err := db.WithTransactionContext(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		blob, err := blobDomain.GetByID(ctx, id)
+		if err != nil || blob.RefCounter > 0 {
+			return nil
+		}
+		var released []string
+		if blob.IsDelta() {
+			released = append(released, blob.BaseBlobID)
+		}
+		if blob.IsManifest {
+			chunks, err := blobService.ManifestChunks(ctx, id)
+			if err != nil {
+				return err
+			}
+			for _, chunk := range chunks {
+				released = append(released, chunk.ID)
+			}
+		}
+
+		if err := blob.Delete(ctx); err != nil {
+			return fmt.Errorf("failed to delete blob %s: %w", id, err)
+		}
+		for _, releasedID := range released {
+			if err := release(ctx, releasedID); err != nil {
+				return err
+			}
+		}
+		fileKey = blob.FilePath
+		removed = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if fileKey != "" {
+		if err := blobService.Store().Delete(ctx, fileKey); err != nil {
+			log.Warn("Failed to remove blob file", "key", fileKey, "error", err)
+		}
+	}
+	return removed, nil
+}
+
+
+// release drops the reference a removed blob held on another blob.
+func release(ctx context.Context, id string) error {
+	blob, err := blobDomain.GetByID(ctx, id)
+	if err != nil {
+		return nil
+	}
+	if err := blob.DecrementRefCounter(ctx); err != nil {
+		return fmt.Errorf("failed to release blob %s: %w", id, err)
+	}
+	return nil
+}
+
+
+// sweepOrphans removes objects in the blob store that no blob row points
+// to, then stale staging files.
+func sweepOrphans(ctx context.Context, cutoff time.Time, dryRun bool, report *Report) error {
+	keys, err := blobService.StoredKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list stored blob files: %w", err)
+	}
+	known := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		known[key] = true
+	}
+
+	store := blobService.Store()
+	objects, err := store.List(ctx, "")
+	if err != nil {
+		return fmt.Errorf("failed to sweep blob store: %w", err)
+	}
+	for _, object := range objects {
+		if known[object.Key] || object.ModTime.After(cutoff) {
+			continue
+		}
+		if !dryRun {
+			if err := store.Delete(ctx, object.Key); err != nil {
+				log.Warn("Failed to remove orphan blob file", "key", object.Key, "error", err)
+				continue
+			}
+		}
+		report.Orphans      = append(report.Orphans, object.Key)
+		report.OrphanBytes += object.Size
+	}
+
+	return sweepStaging(cutoff, dryRun, report)
+}
+
+
+// sweepStaging removes temp files below dbsetup.BlobStorePath that a crash
+// left behind while content was staged or written.
+func sweepStaging(cutoff time.Time, dryRun bool, report *Report) error {
+	err := filepath.WalkDir(dbsetup.BlobStorePath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !entry.Type().IsRegular() || !strings.HasPrefix(entry.Name(), filekit.TEMP_PREFIX) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			return nil
+		}
+
+		if !dryRun {
+			if err := os.Remove(path); err != nil {
+				log.Warn("Failed to remove staging file", "path", path, "error", err)
+				return nil
+			}
+		}
+		report.Orphans      = append(report.Orphans, path)
+		report.OrphanBytes += info.Size()
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to sweep staging files: %w", err)
+	}
+	return nil
+}
+
+
+// olderThan reports whether an RFC3339 timestamp lies before cutoff.
+// Unparseable timestamps count as recent, to err on the side of keeping data.
+func olderThan(timestamp string, cutoff time.Time) bool {
+	t, err := time.Parse(time.RFC3339, timestamp)
+	return err == nil && t.Before(cutoff)
+}
@@ -0,0 +1,243 @@
+package gc
+
+import (
+	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	blobDomain "vcx/agent/internal/domains/blob"
+	"vcx/agent/internal/infra/db"
+	"vcx/agent/internal/infra/db/consts"
+	"vcx/agent/internal/infra/db/dbsetup"
+	blobService "vcx/agent/internal/services/blob"
+	"vcx/agent/internal/services/servicetest"
+	"vcx/pkg/toolkit/filekit"
+)
+
+
+// newBlob stores content as a version of prevID and drops the reference
+// the caller would have taken, aged past the grace period.
+func newBlob(t *testing.T, ctx context.Context, content []byte, prevID string) *blobDomain.Blob {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "content")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	blob, err := blobService.CreateVersion(ctx, path, prevID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := blob.DecrementRefCounter(ctx); err != nil {
+		t.Fatal(err)
+	}
+	age(t, ctx, blob.ID)
+	return blob
+}
+
+
+// age moves the last modification of a blob before the grace period.
+func age(t *testing.T, ctx context.Context, id string) {
+	t.Helper()
+	lmd := time.Now().Add(-2 * DEFAULT_GRACE_PERIOD).UTC().Format(time.RFC3339)
+	if _, err := db.QueryWithContext(ctx, "UPDATE blob SET "+consts.LMD+" = ? WHERE id = ?", lmd, id); err != nil {
+		t.Fatal(err)
+	}
+}
+
+
+// ageFile moves the modification time of a stored file before the grace period.
+func ageFile(t *testing.T, path string) {
+	t.Helper()
+	old := time.Now().Add(-2 * DEFAULT_GRACE_PERIOD)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+}
+
+
+func refCounter(t *testing.T, ctx context.Context, id string) int {
+	t.Helper()
+	blob, err := blobDomain.GetByID(ctx, id)
+	if err != nil {
+		t.Fatalf("blob %s is gone: %v", id, err)
+	}
+	return blob.RefCounter
+}
+
+
+func exists(ctx context.Context, id string) bool {
+	_, err := blobDomain.GetByID(ctx, id)
+	return err == nil
+}
+
+
+func TestRunLeavesRecentBlobsAlone(t *testing.T) {
+	ctx    := servicetest.Context(t)
+	old    := newBlob(t, ctx, []byte("old content\n"), "")
+	recent := newBlob(t, ctx, []byte("recent content\n"), "")
+	if _, err := db.QueryWithContext(ctx, "UPDATE blob SET "+consts.LMD+" = ? WHERE id = ?",
+		time.Now().UTC().Format(time.RFC3339), recent.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Run(ctx, DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(report.Blobs, []string{old.ID}) || report.Pending != 1 {
+		t.Errorf("got blobs %v, %d pending; want only the old blob collected and one pending", report.Blobs, report.Pending)
+	}
+	if exists(ctx, old.ID) || !exists(ctx, recent.ID) {
+		t.Errorf("old blob kept %v, recent blob kept %v; want only the recent one kept", exists(ctx, old.ID), exists(ctx, recent.ID))
+	}
+}
+
+
+func TestDryRunDeletesNothing(t *testing.T) {
+	ctx    := servicetest.Context(t)
+	blob   := newBlob(t, ctx, []byte("unreferenced\n"), "")
+	orphan := filepath.Join(dbsetup.BlobStorePath, "ab", "cdef")
+	if err := os.MkdirAll(filepath.Dir(orphan), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(orphan, []byte("orphan"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ageFile(t, orphan)
+
+	report, err := Run(ctx, Options{GracePeriod: DEFAULT_GRACE_PERIOD, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Blobs) != 1 || len(report.Orphans) != 1 {
+		t.Errorf("got blobs %v, orphans %v; want one of each reported", report.Blobs, report.Orphans)
+	}
+	if !exists(ctx, blob.ID) {
+		t.Error("dry run deleted the blob")
+	}
+	if _, err := os.Stat(orphan); err != nil {
+		t.Errorf("dry run deleted the orphan: %v", err)
+	}
+}
+
+
+func TestRunReleasesDeltaBase(t *testing.T) {
+	ctx     := servicetest.Context(t)
+	content := strings.Repeat("a line that stays the same\n", 200)
+	base    := newBlob(t, ctx, []byte(content), "")
+	if err := base.IncrementRefCounter(ctx); err != nil { // a version still uses the base
+		t.Fatal(err)
+	}
+	delta := newBlob(t, ctx, []byte(content+"one more line\n"), base.ID)
+	if !delta.IsDelta() {
+		t.Fatal("expected the second version to be stored as a delta")
+	}
+	if got := refCounter(t, ctx, base.ID); got != 2 {
+		t.Fatalf("base has %d references before the run, want 2", got)
+	}
+
+	if _, err := Run(ctx, DefaultOptions()); err != nil {
+		t.Fatal(err)
+	}
+	if exists(ctx, delta.ID) {
+		t.Error("unreferenced delta kept")
+	}
+	if got := refCounter(t, ctx, base.ID); got != 1 {
+		t.Errorf("base has %d references after the run, want 1", got)
+	}
+}
+
+
+func TestRunReleasesManifestChunks(t *testing.T) {
+	ctx := servicetest.Context(t)
+	if err := blobService.SetChunkedStorage(ctx, true); err != nil {
+		t.Fatal(err)
+	}
+	content := make([]byte, blobService.CHUNK_THRESHOLD+1)
+	rand.New(rand.NewSource(1)).Read(content)
+	manifest := newBlob(t, ctx, content, "")
+	if !manifest.IsManifest {
+		t.Fatal("expected a manifest blob")
+	}
+	chunks, err := blobService.ManifestChunks(ctx, manifest.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Run(ctx, DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(report.Blobs, []string{manifest.ID}) {
+		t.Errorf("got blobs %v, want only the manifest collected", report.Blobs)
+	}
+	for _, chunk := range chunks {
+		if got := refCounter(t, ctx, chunk.ID); got != 0 {
+			t.Errorf("chunk %s has %d references after the run, want 0", chunk.ID, got)
+		}
+	}
+
+	// released chunks start their own grace period
+	report, err = Run(ctx, DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Pending != len(chunks) {
+		t.Errorf("got %d pending, want the %d chunks", report.Pending, len(chunks))
+	}
+}
+
+
+func TestRunSweepsOrphansAndStagingFiles(t *testing.T) {
+	ctx     := servicetest.Context(t)
+	content := make([]byte, blobService.MAX_DB_BLOB_SIZE+1)
+	rand.New(rand.NewSource(1)).Read(content)
+	kept    := newBlob(t, ctx, content, "")
+	if err := kept.IncrementRefCounter(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if kept.FilePath == "" {
+		t.Fatal("expected the blob in the blob store")
+	}
+	ageFile(t, filepath.Join(dbsetup.BlobStorePath, kept.FilePath))
+
+	write := func(path string, old bool) string {
+		path = filepath.Join(dbsetup.BlobStorePath, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("left behind"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if old {
+			ageFile(t, path)
+		}
+		return path
+	}
+	orphan       := write(filepath.Join("ab", "cdef"), true)
+	recentOrphan := write(filepath.Join("ab", "0123"), false)
+	staging      := write(filepath.Join("ab", filekit.TEMP_PREFIX+"write"), true)
+
+	report, err := Run(ctx, DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Orphans) != 2 {
+		t.Errorf("got orphans %v, want the old orphan and the staging file", report.Orphans)
+	}
+	for path, want := range map[string]bool{
+		orphan:       false,
+		recentOrphan: true,
+		staging:      false,
+		filepath.Join(dbsetup.BlobStorePath, kept.FilePath): true,
+	} {
+		if _, err := os.Stat(path); (err == nil) != want {
+			t.Errorf("%s kept %v, want %v", path, err == nil, want)
+		}
+	}
+}
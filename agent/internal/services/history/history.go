@@ -0,0 +1,120 @@
+// Package history lists the recorded versions of a file.
+//
+// Versions are found by walking the file's version chain backwards from its
+// current change (see changeService.WalkBackward), so only the file's own
+// changes are read, including those inherited from a parent branch.
+package history
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	blobDomain "vcx/agent/internal/domains/blob"
+	changeDomain "vcx/agent/internal/domains/change"
+	fileDomain "vcx/agent/internal/domains/file"
+	changeService "vcx/agent/internal/services/change"
+	"vcx/pkg/logging"
+)
+
+
+var log = logging.GetLogger()
+
+
+var ErrFileNotFound = errors.New("file has no recorded history")
+
+
+// Query narrows a history listing.
+//
+// Fields:
+//   - Limit: Maximum number of versions returned, 0 for all
+//   - Since: Only versions recorded at or after this time (zero: no bound)
+//   - Until: Only versions recorded at or before this time (zero: no bound)
+type Query struct {
+	Limit int
+	Since time.Time
+	Until time.Time
+}
+
+
+// Version is one recorded state of a file.
+type Version struct {
+	ChangeID     string `json:"changeID"`
+	Timestamp    string `json:"timestamp"`
+	Path         string `json:"path"`
+	BlobID       string `json:"blobID"`
+	Size         int64  `json:"size"`
+	Summary      string `json:"summary"`
+	IsDeleted    bool   `json:"isDeleted"`
+	IsConflicted bool   `json:"isConflicted,omitempty"`
+	Target       string `json:"target,omitempty"`
+	Mode         uint32 `json:"mode,omitempty"`
+}
+
+
+// FileHistory returns the versions of relPath on branchID, newest first.
+func FileHistory(ctx context.Context, branchID, relPath string, query Query) ([]Version, error) {
+	file, err := fileDomain.GetByPath(ctx, branchID, relPath)
+	if err != nil || file.ChangeID == "" {
+		return nil, fmt.Errorf("%w: %s", ErrFileNotFound, relPath)
+	}
+
+	change, err := changeDomain.GetByID(ctx, file.ChangeID)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := []Version{}
+	sizes    := make(map[string]int64)
+	visited  := make(map[string]bool)
+	for change != nil && !visited[change.ID] {
+		if query.Limit > 0 && len(versions) >= query.Limit {
+			break
+		}
+		visited[change.ID] = true
+
+		recorded, err := time.Parse(time.RFC3339, change.CreationDate)
+		if err != nil {
+			log.Warn("Change has an invalid creation date", "changeID", change.ID, "creationDate", change.CreationDate)
+		}
+		if !query.Since.IsZero() && recorded.Before(query.Since) {
+			break
+		}
+		if query.Until.IsZero() || !recorded.After(query.Until) {
+			versions = append(versions, toVersion(ctx, change, sizes))
+		}
+
+		change, err = changeService.Previous(ctx, change)
+		if err != nil {
+			return versions, fmt.Errorf("broken version chain for %s: %w", relPath, err)
+		}
+	}
+	return versions, nil
+}
+
+
+func toVersion(ctx context.Context, change *changeDomain.Change, sizes map[string]int64) Version {
+	version := Version{
+		ChangeID:     change.ID,
+		Timestamp:    change.CreationDate,
+		Path:         change.Path,
+		BlobID:       change.BlobID,
+		Summary:      change.Summary,
+		IsDeleted:    change.IsDeleted,
+		IsConflicted: change.IsConflicted,
+		Target:       change.Target,
+		Mode:         change.Mode,
+	}
+	if change.BlobID == "" {
+		return version
+	}
+
+	size, ok := sizes[change.BlobID]
+	if !ok {
+		size, _ = blobDomain.GetSize(ctx, change.BlobID)
+		sizes[change.BlobID] = size
+	}
+	version.Size = size
+	return version
+}
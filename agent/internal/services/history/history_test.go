@@ -0,0 +1,61 @@
+package history
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"vcx/agent/internal/services/servicetest"
+	"vcx/agent/internal/session"
+)
+
+
+func TestFileHistoryWalksBackFromCurrentVersion(t *testing.T) {
+	project := servicetest.NewProject(t, servicetest.Context(t), map[string]string{"a.txt": "1\n"})
+	project.Write("a.txt", "22\n")
+	mainID := project.Instance().BranchID
+	project.Fork("feature")
+	project.Switch("feature")
+	project.Write("a.txt", "333\n")
+	project.Remove("a.txt")
+	ctx := project.Context()
+
+	versions, err := FileHistory(ctx, session.GetBranchID(ctx), "a.txt", Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sizes := []int64{0, 4, 3, 2}
+	if len(versions) != len(sizes) {
+		t.Fatalf("got %d versions, want %d including those inherited from main", len(versions), len(sizes))
+	}
+	if !versions[0].IsDeleted {
+		t.Error("newest version is not the deletion")
+	}
+	for i, version := range versions {
+		if version.Size != sizes[i] {
+			t.Errorf("version %d is %d bytes, want %d", i, version.Size, sizes[i])
+		}
+		if i > 0 && version.ChangeID >= versions[i-1].ChangeID {
+			t.Errorf("version %d is not older than the one before it", i)
+		}
+	}
+
+	if limited, err := FileHistory(ctx, session.GetBranchID(ctx), "a.txt", Query{Limit: 2}); err != nil || len(limited) != 2 {
+		t.Errorf("history limited to 2 returned %d versions, %v", len(limited), err)
+	}
+	if later, err := FileHistory(ctx, session.GetBranchID(ctx), "a.txt", Query{Since: time.Now().Add(time.Hour)}); err != nil || len(later) != 0 {
+		t.Errorf("history since a future time returned %d versions, %v", len(later), err)
+	}
+
+	// main never sees the versions recorded on feature
+	onMain, err := FileHistory(ctx, mainID, "a.txt", Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(onMain) != 2 || onMain[0].ChangeID != versions[2].ChangeID {
+		t.Errorf("got %d versions on main, want the 2 recorded before the fork", len(onMain))
+	}
+	if _, err := FileHistory(ctx, mainID, "missing.txt", Query{}); !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("got %v for an unknown file, want %v", err, ErrFileNotFound)
+	}
+}
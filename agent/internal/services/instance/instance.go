@@ -2,6 +2,10 @@ package instance
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
 
 	instanceDomain "vcx/agent/internal/domains/instance"
 	"vcx/pkg/logging"
@@ -10,6 +14,9 @@ import (
 var log = logging.GetLogger()
 
 
+var ErrNoInstance = errors.New("path is not inside a vcx project")
+
+
 func Create(ctx context.Context, path string) (*instanceDomain.Instance, error) {
 	return instanceDomain.New(ctx, path)
 }
@@ -18,3 +25,41 @@ func Create(ctx context.Context, path string) (*instanceDomain.Instance, error)
 func GetByID(ctx context.Context, id string) (*instanceDomain.Instance, error) {
 	return instanceDomain.GetByID(ctx, id)
 }
+
+
+func GetAll(ctx context.Context) ([]*instanceDomain.Instance, error) {
+	return instanceDomain.GetAll(ctx)
+}
+
+
+// FindByPath returns the instance whose directory contains path, together
+// with path relative to the instance root. Nested instances resolve to the
+// innermost one.
+func FindByPath(ctx context.Context, path string) (*instanceDomain.Instance, string, error) {
+	path = filepath.Clean(path)
+
+	instances, err := instanceDomain.GetAll(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var found *instanceDomain.Instance
+	for _, instance := range instances {
+		root := filepath.Clean(instance.Path)
+		if path != root && !strings.HasPrefix(path, root+string(filepath.Separator)) {
+			continue
+		}
+		if found == nil || len(root) > len(filepath.Clean(found.Path)) {
+			found = instance
+		}
+	}
+	if found == nil {
+		return nil, "", fmt.Errorf("%w: %s", ErrNoInstance, path)
+	}
+
+	relPath, err := filepath.Rel(filepath.Clean(found.Path), path)
+	if err != nil {
+		return nil, "", err
+	}
+	return found, relPath, nil
+}
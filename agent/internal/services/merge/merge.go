@@ -0,0 +1,595 @@
+// Package merge brings the work recorded on one branch into another.
+//
+// The merge base is the newest change both branches share: the point one was
+// forked from the other, or the head merged last time. Every path is then
+// compared in three states (base, ours = the branch merged into, theirs =
+// the branch merged). A path changed on one side only takes that side, text
+// changed on both sides is merged line by line (diff3), anything else that
+// changed on both sides is a conflict (see the conflict package). The result
+// is recorded as a MERGE change on our branch that references both heads.
+package merge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"vcx/agent/internal/consts/filetype"
+	branchDomain "vcx/agent/internal/domains/branch"
+	changeDomain "vcx/agent/internal/domains/change"
+	fileDomain "vcx/agent/internal/domains/file"
+	instanceDomain "vcx/agent/internal/domains/instance"
+	blobService "vcx/agent/internal/services/blob"
+	changeService "vcx/agent/internal/services/change"
+	conflictService "vcx/agent/internal/services/conflict"
+	fileService "vcx/agent/internal/services/file"
+	"vcx/agent/internal/services/filters"
+	"vcx/agent/internal/services/restore"
+	"vcx/agent/internal/session"
+	"vcx/pkg/logging"
+	"vcx/pkg/toolkit/diffkit"
+	"vcx/pkg/toolkit/filekit"
+)
+
+var log = logging.GetLogger()
+
+
+const (
+	OP_MERGE = "merge" // both sides merged line by line
+
+	REASON_CONTENT     = "content"       // both sides changed the same lines
+	REASON_ADDED       = "add/add"       // both sides added the path with different content
+	REASON_DELETED     = "delete/modify" // one side deleted what the other changed
+	REASON_UNMERGEABLE = "unmergeable"   // binary content or symlinks changed on both sides
+)
+
+
+var (
+	ErrSameBranch          = errors.New("cannot merge a branch into itself")
+	ErrUnresolvedConflicts = errors.New("branch has unresolved conflicts")
+)
+
+
+// Options control how a branch is merged into the working copy.
+//
+// Fields:
+//   - DryRun: Only compute the result, touch nothing
+//   - Force: Proceed even if paths the merge writes have unrecorded modifications
+type Options struct {
+	DryRun bool
+	Force  bool
+}
+
+
+// Conflict is a path the merge could not resolve. The IDs are the changes
+// holding the three versions; an empty one means the path is absent there.
+type Conflict struct {
+	Path     string `json:"path"`
+	Reason   string `json:"reason"`
+	BaseID   string `json:"baseID,omitempty"`
+	OursID   string `json:"oursID,omitempty"`
+	TheirsID string `json:"theirsID,omitempty"`
+}
+
+
+// Result describes a merge. ChangeID is the MERGE change once applied.
+// Modified lists paths the merge writes (conflicts included) whose working
+// copy differs from what is recorded; they block the merge unless forced.
+type Result struct {
+	ChangeID   string           `json:"changeID,omitempty"`
+	AncestorID string           `json:"ancestorID,omitempty"`
+	UpToDate   bool             `json:"upToDate"`
+	Actions    []restore.Action `json:"actions"`
+	Conflicts  []Conflict       `json:"conflicts"`
+	Modified   []string         `json:"modified"`
+	Applied    bool             `json:"applied"`
+}
+
+
+// Branch merges the branch sourceID into the instance's branch and updates
+// the working copy. Merged paths are recorded before they are written, so the
+// file monitor finds them unchanged; conflicting paths get a conflict record
+// once the merge is recorded. A branch with unresolved conflicts cannot be
+// merged into.
+func Branch(ctx context.Context, instance *instanceDomain.Instance, sourceID string, options Options) (*Result, error) {
+	if sourceID == instance.BranchID {
+		return nil, ErrSameBranch
+	}
+	ctx = session.WithProjectID(ctx, instance.ProjectID)
+	ctx = session.WithBranchID(ctx, instance.BranchID)
+
+	open, err := conflictService.List(ctx, instance.BranchID)
+	if err != nil {
+		return nil, err
+	}
+	if len(open) > 0 {
+		return nil, fmt.Errorf("%w: %d path(s), resolve them first", ErrUnresolvedConflicts, len(open))
+	}
+
+	source, err := branchDomain.GetByID(ctx, sourceID)
+	if err != nil {
+		return nil, err
+	}
+	oursHead, err := head(ctx, instance.BranchID)
+	if err != nil {
+		return nil, err
+	}
+	theirsHead, err := head(ctx, sourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Actions: []restore.Action{}, Conflicts: []Conflict{}, Modified: []string{}}
+	ancestor, err := CommonAncestor(ctx, instance.BranchID, sourceID)
+	if err != nil {
+		return nil, err
+	}
+	if ancestor != nil {
+		result.AncestorID = ancestor.ID
+		if ancestor.ID == theirsHead {
+			result.UpToDate = true
+			return result, nil
+		}
+	}
+
+	states, merged, err := planMerge(ctx, instance, sourceID, ancestor, result)
+	if err != nil {
+		return nil, err
+	}
+	if err := restore.CheckActions(instance.Path, result.Actions, states); err != nil {
+		return result, err
+	}
+	if len(result.Modified) > 0 && !options.Force {
+		return result, fmt.Errorf("%w: %d path(s), use force to overwrite", restore.ErrLocalModifications, len(result.Modified))
+	}
+	if options.DryRun {
+		return result, nil
+	}
+
+	summary := fmt.Sprintf("merged from %s", source.Name)
+	for _, action := range result.Actions {
+		if err := apply(ctx, instance, action, states[action.Path], merged[action.Path], summary); err != nil {
+			return result, err
+		}
+	}
+	if err := keepDirs(ctx, instance); err != nil {
+		return result, err
+	}
+
+	var unresolved []byte
+	if len(result.Conflicts) > 0 {
+		if unresolved, err = json.Marshal(result.Conflicts); err != nil {
+			return result, err
+		}
+	}
+	merge, err := changeDomain.NewMerge(ctx, oursHead, theirsHead, summary, unresolved)
+	if err != nil {
+		return result, fmt.Errorf("merged files but failed to record the merge: %w", err)
+	}
+	result.ChangeID = merge.ID
+
+	target, err := branchDomain.GetByID(ctx, instance.BranchID)
+	if err != nil {
+		return result, err
+	}
+	labels    := conflictService.Labels{Ours: target.Name, Theirs: source.Name}
+	mergedCtx := session.WithChangeID(ctx, merge.ID)
+	for _, conflict := range result.Conflicts {
+		_, err := conflictService.Record(mergedCtx, instance.Path, conflict.Path, conflict.Reason,
+			conflict.BaseID, conflict.OursID, conflict.TheirsID, labels)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	instance.ChangeID = merge.ID
+	if err := instance.Update(ctx); err != nil {
+		return result, fmt.Errorf("merged files but failed to update instance: %w", err)
+	}
+	result.Applied = true
+
+	log.Info("Merged branch", "path", instance.Path, "from", source.Name, "changeID", merge.ID,
+		"actions", len(result.Actions), "conflicts", len(result.Conflicts))
+	return result, nil
+}
+
+
+// CommonAncestor returns the newest change that is part of the history of
+// both branches, nil when they share none.
+func CommonAncestor(ctx context.Context, oursID, theirsID string) (*changeDomain.Change, error) {
+	ours, err := history(ctx, oursID)
+	if err != nil {
+		return nil, err
+	}
+	theirs, err := history(ctx, theirsID)
+	if err != nil {
+		return nil, err
+	}
+
+	var ancestorID string
+	for branchID, oursCutoff := range ours {
+		theirsCutoff, ok := theirs[branchID]
+		if !ok {
+			continue
+		}
+		shared := min(oursCutoff, theirsCutoff)
+		if shared > ancestorID {
+			ancestorID = shared
+		}
+	}
+	if ancestorID == "" {
+		return nil, nil
+	}
+	return changeDomain.GetByID(ctx, ancestorID)
+}
+
+
+// head returns the newest change recorded on a branch, the change it was
+// created at when nothing is recorded on it yet.
+func head(ctx context.Context, branchID string) (string, error) {
+	if latest, err := changeDomain.GetLatestOnBranch(ctx, branchID); err == nil {
+		return latest.ID, nil
+	}
+	branch, err := branchDomain.GetByID(ctx, branchID)
+	if err != nil {
+		return "", err
+	}
+	return branch.ChangeID, nil
+}
+
+
+// history maps every branch whose changes are part of a branch's history to
+// the newest change that is: the branch itself up to its head, the branch it
+// was forked from up to the fork point, and the heads it merged, each with
+// their own history.
+func history(ctx context.Context, branchID string) (map[string]string, error) {
+	headID, err := head(ctx, branchID)
+	if err != nil {
+		return nil, err
+	}
+
+	reached := make(map[string]string)
+	var visit func(branchID, cutoff string) error
+	visit = func(branchID, cutoff string) error {
+		if branchID == "" || cutoff == "" || reached[branchID] >= cutoff {
+			return nil
+		}
+		reached[branchID] = cutoff
+
+		branch, err := branchDomain.GetByID(ctx, branchID)
+		if err != nil {
+			return fmt.Errorf("failed to load branch %s: %w", branchID, err)
+		}
+		if fork, err := changeDomain.GetByID(ctx, branch.ChangeID); err == nil && fork.BranchID != branchID {
+			if err := visit(fork.BranchID, fork.ID); err != nil {
+				return err
+			}
+		}
+
+		merges, err := changeDomain.GetMerges(ctx, branchID)
+		if err != nil {
+			return err
+		}
+		for _, merge := range merges {
+			if merge.ID > cutoff || merge.ChangeIDMerge == "" {
+				continue
+			}
+			parent, err := changeDomain.GetByID(ctx, merge.ChangeIDMerge)
+			if err != nil {
+				return fmt.Errorf("failed to load merge parent of %s: %w", merge.ID, err)
+			}
+			if err := visit(parent.BranchID, parent.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := visit(branchID, headID); err != nil {
+		return nil, err
+	}
+	return reached, nil
+}
+
+
+// planMerge compares every path in its three states and fills in the
+// actions, conflicts and blocking modifications of result. It returns their
+// version each action takes and the content of the merged paths.
+func planMerge(ctx context.Context, instance *instanceDomain.Instance, sourceID string, ancestor *changeDomain.Change, result *Result) (map[string]*changeDomain.Change, map[string][]byte, error) {
+	oursFiles, err := byPath(ctx, instance.BranchID)
+	if err != nil {
+		return nil, nil, err
+	}
+	theirsFiles, err := byPath(ctx, sourceID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	paths := make([]string, 0, len(theirsFiles))
+	for path := range theirsFiles {
+		paths = append(paths, path)
+	}
+	for path := range oursFiles {
+		if _, ok := theirsFiles[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	filter := filters.ForInstance(instance.Path)
+	states := make(map[string]*changeDomain.Change)
+	merged := make(map[string][]byte)
+	for _, path := range paths {
+		oursFile, theirsFile := oursFiles[path], theirsFiles[path]
+		if filter.ShouldSkip(filepath.Join(instance.Path, path), isDir(oursFile) || isDir(theirsFile)) {
+			continue
+		}
+		if oursFile != nil && theirsFile != nil && oursFile.ChangeID == theirsFile.ChangeID {
+			continue
+		}
+
+		ours, err := current(ctx, oursFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		theirs, err := current(ctx, theirsFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		if sameState(ours, theirs) {
+			continue
+		}
+		base, err := baseState(ctx, ancestor, path)
+		if err != nil {
+			return nil, nil, err
+		}
+		if sameState(theirs, base) {
+			continue
+		}
+
+		var action restore.Action
+		switch {
+		case sameState(ours, base) && absent(theirs):
+			action = restore.Action{Op: restore.OP_DELETE, Path: path, ChangeID: theirs.ID}
+			states[path] = theirs
+
+		case sameState(ours, base):
+			action = restore.Action{Op: restore.OP_WRITE, Path: path, ChangeID: theirs.ID, Target: theirs.Target}
+			states[path] = theirs
+
+		default:
+			content, reason, err := mergeContent(ctx, base, ours, theirs)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to merge %s: %w", path, err)
+			}
+			if reason != "" {
+				result.Conflicts = append(result.Conflicts, Conflict{
+					Path:     path,
+					Reason:   reason,
+					BaseID:   changeID(base),
+					OursID:   changeID(ours),
+					TheirsID: changeID(theirs),
+				})
+			} else {
+				action = restore.Action{Op: OP_MERGE, Path: path, ChangeID: theirs.ID}
+				merged[path] = content
+			}
+		}
+		if action.Op != "" {
+			result.Actions = append(result.Actions, action)
+		}
+
+		modified, err := locallyModified(instance.Path, path, oursFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to inspect %s: %w", path, err)
+		}
+		if modified {
+			result.Modified = append(result.Modified, path)
+		}
+	}
+	return states, merged, nil
+}
+
+
+// mergeContent merges text changed on both sides. reason is set when the
+// sides cannot be merged.
+func mergeContent(ctx context.Context, base, ours, theirs *changeDomain.Change) (merged []byte, reason string, err error) {
+	if absent(ours) || absent(theirs) {
+		return nil, REASON_DELETED, nil
+	}
+	if ours.BlobID == "" || theirs.BlobID == "" {
+		return nil, REASON_UNMERGEABLE, nil
+	}
+
+	var baseText []byte
+	if !absent(base) {
+		if base.BlobID == "" {
+			return nil, REASON_UNMERGEABLE, nil
+		}
+		if baseText, err = blobService.Read(ctx, base.BlobID); err != nil {
+			return nil, "", err
+		}
+	}
+	oursText, err := blobService.Read(ctx, ours.BlobID)
+	if err != nil {
+		return nil, "", err
+	}
+	theirsText, err := blobService.Read(ctx, theirs.BlobID)
+	if err != nil {
+		return nil, "", err
+	}
+	if filekit.IsBinary(baseText) || filekit.IsBinary(oursText) || filekit.IsBinary(theirsText) {
+		return nil, REASON_UNMERGEABLE, nil
+	}
+
+	text, ok := diffkit.Merged(diffkit.Merge3(string(baseText), string(oursText), string(theirsText), diffkit.MYERS))
+	switch {
+	case ok:
+		return []byte(text), "", nil
+	case absent(base):
+		return nil, REASON_ADDED, nil
+	default:
+		return nil, REASON_CONTENT, nil
+	}
+}
+
+
+func apply(ctx context.Context, instance *instanceDomain.Instance, action restore.Action, state *changeDomain.Change, merged []byte, summary string) error {
+	absPath := filepath.Join(instance.Path, action.Path)
+
+	existing, err := fileDomain.GetByPath(ctx, instance.BranchID, action.Path)
+	if err != nil {
+		existing = nil
+	}
+
+	switch action.Op {
+	case restore.OP_DELETE:
+		if err := fileService.MarkDeleted(ctx, existing); err != nil {
+			return err
+		}
+		if err := restore.RemoveEntry(instance.Path, absPath); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", action.Path, err)
+		}
+		restore.RemoveEmptyParents(instance.Path, filepath.Dir(absPath))
+		return nil
+
+	case restore.OP_WRITE:
+		// a path new to our branch continues their version chain
+		if existing == nil {
+			_, err = fileService.Adopt(ctx, state)
+		} else {
+			_, err = fileService.RecordVersion(ctx, action.Path, state.Type(), state.BlobID, state.Target, state.Attributes(), summary)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to record %s: %w", action.Path, err)
+		}
+		_, err = restore.WriteVersion(ctx, instance.Path, absPath, state)
+		return err
+
+	case OP_MERGE:
+		// merged content keeps our mode, and no mtime so it is new to build tools
+		var attrs filekit.Attributes
+		if existing != nil {
+			attrs.Mode = existing.Mode
+		}
+		file, err := fileService.RecordContent(ctx, action.Path, merged, attrs, summary)
+		if err != nil {
+			return fmt.Errorf("failed to record %s: %w", action.Path, err)
+		}
+		recorded, err := changeDomain.GetByID(ctx, file.ChangeID)
+		if err != nil {
+			return err
+		}
+		_, err = restore.WriteVersion(ctx, instance.Path, absPath, recorded)
+		return err
+	}
+	return fmt.Errorf("unknown merge action %q", action.Op)
+}
+
+
+// keepDirs recreates the directories still tracked on our branch after the
+// merge that deletes took away along with the last file in them.
+func keepDirs(ctx context.Context, instance *instanceDomain.Instance) error {
+	files, err := fileDomain.GetCurrent(ctx, instance.BranchID)
+	if err != nil {
+		return err
+	}
+	filter := filters.ForInstance(instance.Path)
+	dirs   := make(map[string]uint32)
+	for _, file := range files {
+		if isDir(file) && !filter.ShouldSkip(filepath.Join(instance.Path, file.Path), true) {
+			dirs[file.Path] = file.Mode
+		}
+	}
+	return restore.KeepDirs(instance.Path, dirs)
+}
+
+
+// locallyModified reports whether the working copy of a path the merge
+// writes holds something that is not recorded on our branch.
+func locallyModified(projectPath, path string, ours *fileDomain.File) (bool, error) {
+	if ours == nil {
+		_, err := os.Lstat(filepath.Join(projectPath, path))
+		return err == nil, nil
+	}
+	matches, err := fileService.MatchesWorkingCopy(projectPath, ours)
+	return !matches, err
+}
+
+
+func byPath(ctx context.Context, branchID string) (map[string]*fileDomain.File, error) {
+	files, err := fileDomain.GetByBranch(ctx, branchID)
+	if err != nil {
+		return nil, err
+	}
+	paths := make(map[string]*fileDomain.File, len(files))
+	for _, file := range files {
+		paths[file.Path] = file
+	}
+	return paths, nil
+}
+
+
+// current returns the latest version of a file, nil without a file.
+func current(ctx context.Context, file *fileDomain.File) (*changeDomain.Change, error) {
+	if file == nil || file.ChangeID == "" {
+		return nil, nil
+	}
+	state, err := changeDomain.GetByID(ctx, file.ChangeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load version of %s: %w", file.Path, err)
+	}
+	return state, nil
+}
+
+
+// baseState returns the version of path at the merge base, nil if it did not
+// exist there.
+func baseState(ctx context.Context, ancestor *changeDomain.Change, path string) (*changeDomain.Change, error) {
+	if ancestor == nil || ancestor.BranchID == "" {
+		return nil, nil
+	}
+	file, err := fileDomain.GetByPath(ctx, ancestor.BranchID, path)
+	if err != nil {
+		return nil, nil
+	}
+	state, err := changeService.StateAt(ctx, file.ChangeID, func(change *changeDomain.Change) bool {
+		return change.ID <= ancestor.ID
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load version of %s: %w", path, err)
+	}
+	return state, nil
+}
+
+
+func absent(state *changeDomain.Change) bool {
+	return state == nil || state.IsDeleted
+}
+
+
+// sameState reports whether two versions hold the same content or target,
+// or are both absent.
+func sameState(a, b *changeDomain.Change) bool {
+	if absent(a) || absent(b) {
+		return absent(a) && absent(b)
+	}
+	return a.Type() == b.Type() && a.BlobID == b.BlobID && a.Target == b.Target
+}
+
+
+func isDir(file *fileDomain.File) bool {
+	return file != nil && file.Type == filetype.DIR
+}
+
+
+func changeID(state *changeDomain.Change) string {
+	if state == nil {
+		return ""
+	}
+	return state.ID
+}
@@ -0,0 +1,146 @@
+package merge_test
+
+import (
+	"os"
+	"testing"
+
+	fileDomain "vcx/agent/internal/domains/file"
+	branchService "vcx/agent/internal/services/branch"
+	"vcx/agent/internal/services/merge"
+	"vcx/agent/internal/services/servicetest"
+	"vcx/agent/internal/session"
+)
+
+
+// newProject returns a project on main with a branch called feature forked
+// from its first state, which the working copy is switched to.
+func newProject(t *testing.T) *servicetest.Project {
+	t.Helper()
+	project := servicetest.NewProject(t, servicetest.Context(t), map[string]string{
+		"a.txt": "1\n2\n3\n4\n5\n",
+		"b.txt": "b\n",
+	})
+	project.Fork("feature")
+	project.Switch("feature")
+	return project
+}
+
+
+func mergeFeature(t *testing.T, project *servicetest.Project) *merge.Result {
+	t.Helper()
+	result, err := branchService.Merge(project.Context(), project.Instance(), "feature", merge.Options{})
+	if err != nil {
+		t.Fatalf("merge failed: %v", err)
+	}
+	if !result.Applied || result.ChangeID == "" {
+		t.Fatalf("merge not applied: %+v", result)
+	}
+	return result
+}
+
+
+func conflictReasons(result *merge.Result) map[string]string {
+	reasons := make(map[string]string)
+	for _, conflict := range result.Conflicts {
+		reasons[conflict.Path] = conflict.Reason
+	}
+	return reasons
+}
+
+
+func TestMergeCombinesChangesOnBothSides(t *testing.T) {
+	project := newProject(t)
+	project.Write("a.txt", "ONE\n2\n3\n4\n5\n")
+	project.Write("new.txt", "new\n")
+	project.Switch("main")
+	project.Write("a.txt", "1\n2\n3\n4\nFIVE\n")
+
+	result := mergeFeature(t, project)
+	if len(result.Conflicts) != 0 {
+		t.Errorf("got conflicts %+v, want none", result.Conflicts)
+	}
+	for path, want := range map[string]string{"a.txt": "ONE\n2\n3\n4\nFIVE\n", "new.txt": "new\n", "b.txt": "b\n"} {
+		if got, _ := project.Read(path); got != want {
+			t.Errorf("%s holds %q, want %q", path, got, want)
+		}
+	}
+
+	again, err := branchService.Merge(project.Context(), project.Instance(), "feature", merge.Options{})
+	if err != nil || !again.UpToDate {
+		t.Errorf("second merge: got %+v, %v; want up to date", again, err)
+	}
+}
+
+
+func TestMergeReportsModifyDeleteConflict(t *testing.T) {
+	project := newProject(t)
+	project.Remove("b.txt")
+	project.Switch("main")
+	project.Write("b.txt", "changed on main\n")
+
+	result := mergeFeature(t, project)
+	if reasons := conflictReasons(result); len(reasons) != 1 || reasons["b.txt"] != merge.REASON_DELETED {
+		t.Errorf("got conflicts %v, want b.txt as %s", reasons, merge.REASON_DELETED)
+	}
+	if got, ok := project.Read("b.txt"); !ok || got != "changed on main\n" {
+		t.Errorf("b.txt holds %q (exists %v), want our version kept", got, ok)
+	}
+}
+
+
+func TestMergeReportsAddAddConflict(t *testing.T) {
+	project := newProject(t)
+	project.Write("c.txt", "added on feature\n")
+	project.Switch("main")
+	project.Write("c.txt", "added on main\n")
+
+	result := mergeFeature(t, project)
+	if reasons := conflictReasons(result); len(reasons) != 1 || reasons["c.txt"] != merge.REASON_ADDED {
+		t.Errorf("got conflicts %v, want c.txt as %s", reasons, merge.REASON_ADDED)
+	}
+}
+
+
+func TestMergeDeletesFile(t *testing.T) {
+	project := newProject(t)
+	project.Remove("b.txt")
+	project.Switch("main")
+
+	result := mergeFeature(t, project)
+	if len(result.Conflicts) != 0 {
+		t.Errorf("got conflicts %+v, want none", result.Conflicts)
+	}
+	if _, ok := project.Read("b.txt"); ok {
+		t.Error("b.txt still in the working copy")
+	}
+	ctx := project.Context()
+	file, err := fileDomain.GetByPath(ctx, session.GetBranchID(ctx), "b.txt")
+	if err == nil && !file.IsDeleted {
+		t.Error("b.txt still recorded as live on main")
+	}
+	if got, _ := project.Read("a.txt"); got != "1\n2\n3\n4\n5\n" {
+		t.Errorf("a.txt holds %q, want it untouched", got)
+	}
+}
+
+
+func TestMergeKeepsTrackedDirectoryOfDeletedFile(t *testing.T) {
+	project := servicetest.NewProject(t, servicetest.Context(t), map[string]string{"sub/last.txt": "the only file in sub\n"})
+	project.Fork("feature")
+	project.Switch("feature")
+	project.Remove("sub/last.txt")
+	project.Switch("main")
+	mergeFeature(t, project)
+
+	if _, ok := project.Read("sub/last.txt"); ok {
+		t.Error("sub/last.txt still in the working copy")
+	}
+	if info, err := os.Stat(project.Path("sub")); err != nil || !info.IsDir() {
+		t.Errorf("tracked directory sub removed with its last file: %v", err)
+	}
+	ctx := project.Context()
+	dir, err := fileDomain.GetByPath(ctx, session.GetBranchID(ctx), "sub")
+	if err != nil || dir.IsDeleted {
+		t.Errorf("sub no longer tracked on main: %+v, %v", dir, err)
+	}
+}
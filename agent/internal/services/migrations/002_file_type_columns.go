@@ -3,7 +3,6 @@ package migrations
 import (
 	"context"
 
-	"vcx/agent/internal/infra/db"
 	fileStore "vcx/agent/internal/infra/db/store/file"
 )
 
@@ -12,10 +11,10 @@ func init() {
 		Version:     2,
 		Description: "Add type and target columns to file table",
 		Up: func(ctx context.Context) error {
-			if err := db.AddColumn("file", fileStore.COL_TYPE,   "TEXT"); err != nil {
+			if err := addColumnIfMissing("file", fileStore.COL_TYPE,   "TEXT"); err != nil {
 				return err
 			}
-			if err := db.AddColumn("file", fileStore.COL_TARGET, "TEXT"); err != nil {
+			if err := addColumnIfMissing("file", fileStore.COL_TARGET, "TEXT"); err != nil {
 				return err
 			}
 			return nil
@@ -0,0 +1,33 @@
+package migrations
+
+import (
+	"context"
+
+	"vcx/agent/internal/infra/db/consts"
+	changeStore "vcx/agent/internal/infra/db/store/change"
+	fileStore "vcx/agent/internal/infra/db/store/file"
+)
+
+func init() {
+	Register(Migration{
+		Version:     3,
+		Description: "Add file state columns to change table",
+		Up: func(ctx context.Context) error {
+			columns := []string{changeStore.COL_BLOBID, changeStore.COL_PATH, changeStore.COL_TARGET}
+			for _, column := range columns {
+				if err := addColumnIfMissing("change", column, consts.TYPE_STRING); err != nil {
+					return err
+				}
+			}
+			if err := addColumnIfMissing("change", changeStore.COL_ISDELETED, consts.TYPE_BOOL); err != nil {
+				return err
+			}
+			return fileStore.CreateIndexes()
+		},
+		Down: func(ctx context.Context) error {
+			// SQLite does not support DROP COLUMN prior to v3.35;
+			// no-op here — reset via database file deletion if needed.
+			return nil
+		},
+	})
+}
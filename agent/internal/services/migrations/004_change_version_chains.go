@@ -0,0 +1,51 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+
+	"vcx/agent/internal/consts/changetype"
+	"vcx/agent/internal/infra/db"
+	changeStore "vcx/agent/internal/infra/db/store/change"
+	"vcx/pkg/toolkit/mapkit"
+)
+
+func init() {
+	Register(Migration{
+		Version:     4,
+		Description: "Link existing file changes into per-file version chains",
+		Up: func(ctx context.Context) error {
+			return db.WithTransactionContext(ctx, func(ctx context.Context, _ *sql.Tx) error {
+				rows, err := db.QueryWithContext(ctx,
+					"SELECT id, fileID, branchID FROM change WHERE changeType = ? AND fileID != '' ORDER BY fileID, branchID, creation_date, id",
+					changetype.FILE.ToString())
+				if err != nil {
+					return err
+				}
+
+				var prevID, prevKey string
+				for _, row := range rows {
+					id  := mapkit.GetString(row, changeStore.COL_ID)
+					key := mapkit.GetString(row, changeStore.COL_FILEID) + "/" + mapkit.GetString(row, changeStore.COL_BRANCHID)
+					if key != prevKey {
+						prevID, prevKey = "", key
+					}
+					if prevID != "" {
+						if _, err := changeStore.Update(ctx, prevID, map[string]any{changeStore.COL_CHANGEID_NEXT: id}); err != nil {
+							return err
+						}
+						if _, err := changeStore.Update(ctx, id, map[string]any{changeStore.COL_CHANGEID_PREV: prevID}); err != nil {
+							return err
+						}
+					}
+					prevID = id
+				}
+				return nil
+			})
+		},
+		Down: func(ctx context.Context) error {
+			// The chain columns already existed; nothing to undo.
+			return nil
+		},
+	})
+}
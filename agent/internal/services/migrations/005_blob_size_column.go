@@ -0,0 +1,83 @@
+package migrations
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"vcx/agent/internal/infra/db"
+	"vcx/agent/internal/infra/db/consts"
+	"vcx/agent/internal/infra/db/dbsetup"
+	blobStore "vcx/agent/internal/infra/db/store/blob"
+	"vcx/pkg/toolkit/compressionkit"
+	"vcx/pkg/toolkit/mapkit"
+)
+
+func init() {
+	Register(Migration{
+		Version:     5,
+		Description: "Add size column to blob table",
+		Up: func(ctx context.Context) error {
+			if err := addColumnIfMissing("blob", blobStore.COL_SIZE, consts.TYPE_INT); err != nil {
+				return err
+			}
+
+			// Backfill from the stored content; unreadable blobs keep size 0
+			rows, err := db.QueryWithContext(ctx, fmt.Sprintf(
+				"SELECT %s, %s, %s, %s FROM blob WHERE COALESCE(%s, 0) = 0",
+				blobStore.COL_ID, blobStore.COL_BLOB, blobStore.COL_FILEPATH, blobStore.COL_ISCOMPRESSED, blobStore.COL_SIZE))
+			if err != nil {
+				return err
+			}
+			for _, row := range rows {
+				id := mapkit.GetString(row, blobStore.COL_ID)
+				size, err := storedContentSize(row)
+				if err != nil {
+					log.Warn("Cannot determine blob size", "blobID", id, "error", err)
+					continue
+				}
+				if _, err := blobStore.Update(ctx, id, map[string]any{blobStore.COL_SIZE: size}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(ctx context.Context) error {
+			// SQLite does not support DROP COLUMN prior to v3.35;
+			// no-op here — reset via database file deletion if needed.
+			return nil
+		},
+	})
+}
+
+
+// storedContentSize counts the content of a blob row as blobs were stored
+// when sizes were introduced: in the row or in a file named by its path
+// (absolute, or relative to the blob store), zstd compressed if flagged.
+func storedContentSize(row map[string]any) (int64, error) {
+	var stored io.Reader = bytes.NewReader(mapkit.GetBytes(row, blobStore.COL_BLOB))
+	if path := mapkit.GetString(row, blobStore.COL_FILEPATH); path != "" {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dbsetup.BlobStorePath, path)
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return 0, err
+		}
+		defer file.Close()
+		stored = file
+	}
+
+	if mapkit.GetBool(row, blobStore.COL_ISCOMPRESSED) {
+		decompressed, err := compressionkit.NewReader(stored)
+		if err != nil {
+			return 0, err
+		}
+		defer decompressed.Close()
+		stored = decompressed
+	}
+	return io.Copy(io.Discard, stored)
+}
@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"context"
+
+	"vcx/agent/internal/infra/db/consts"
+	blobStore "vcx/agent/internal/infra/db/store/blob"
+)
+
+func init() {
+	Register(Migration{
+		Version:     6,
+		Description: "Add delta base columns to blob table",
+		Up: func(ctx context.Context) error {
+			// Existing blobs hold their full content: empty base, depth 0
+			if err := addColumnIfMissing("blob", blobStore.COL_BASEBLOBID, consts.TYPE_STRING); err != nil {
+				return err
+			}
+			return addColumnIfMissing("blob", blobStore.COL_DELTADEPTH, consts.TYPE_INT)
+		},
+		Down: func(ctx context.Context) error {
+			// SQLite does not support DROP COLUMN prior to v3.35;
+			// no-op here — reset via database file deletion if needed.
+			return nil
+		},
+	})
+}
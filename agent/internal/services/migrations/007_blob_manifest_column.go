@@ -0,0 +1,23 @@
+package migrations
+
+import (
+	"context"
+
+	"vcx/agent/internal/infra/db/consts"
+	blobStore "vcx/agent/internal/infra/db/store/blob"
+)
+
+func init() {
+	Register(Migration{
+		Version:     7,
+		Description: "Add manifest flag to blob table",
+		Up: func(ctx context.Context) error {
+			return addColumnIfMissing("blob", blobStore.COL_ISMANIFEST, consts.TYPE_BOOL)
+		},
+		Down: func(ctx context.Context) error {
+			// SQLite does not support DROP COLUMN prior to v3.35;
+			// no-op here — reset via database file deletion if needed.
+			return nil
+		},
+	})
+}
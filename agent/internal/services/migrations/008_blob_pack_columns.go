@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"context"
+
+	"vcx/agent/internal/infra/db/consts"
+	blobStore "vcx/agent/internal/infra/db/store/blob"
+)
+
+func init() {
+	Register(Migration{
+		Version:     8,
+		Description: "Add pack location columns to blob table",
+		Up: func(ctx context.Context) error {
+			if err := addColumnIfMissing("blob", blobStore.COL_PACKID, consts.TYPE_STRING); err != nil {
+				return err
+			}
+			if err := addColumnIfMissing("blob", blobStore.COL_PACKOFFSET, consts.TYPE_INT); err != nil {
+				return err
+			}
+			return addColumnIfMissing("blob", blobStore.COL_PACKLENGTH, consts.TYPE_INT)
+		},
+		Down: func(ctx context.Context) error {
+			// SQLite does not support DROP COLUMN prior to v3.35;
+			// no-op here — reset via database file deletion if needed.
+			return nil
+		},
+	})
+}
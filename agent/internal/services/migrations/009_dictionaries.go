@@ -0,0 +1,26 @@
+package migrations
+
+import (
+	"context"
+
+	"vcx/agent/internal/infra/db/consts"
+	blobStore "vcx/agent/internal/infra/db/store/blob"
+	dictionaryStore "vcx/agent/internal/infra/db/store/dictionary"
+)
+
+func init() {
+	Register(Migration{
+		Version:     9,
+		Description: "Create dictionary table and add dictionary ID to blob table",
+		Up: func(ctx context.Context) error {
+			dictionaryStore.CreateTable()
+			// Existing blobs were compressed without a dictionary: 0
+			return addColumnIfMissing("blob", blobStore.COL_DICTID, consts.TYPE_INT)
+		},
+		Down: func(ctx context.Context) error {
+			// SQLite does not support DROP COLUMN prior to v3.35;
+			// no-op here — reset via database file deletion if needed.
+			return nil
+		},
+	})
+}
@@ -0,0 +1,52 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"vcx/agent/internal/infra/db"
+	"vcx/agent/internal/infra/db/dbsetup"
+	blobStore "vcx/agent/internal/infra/db/store/blob"
+	"vcx/pkg/toolkit/mapkit"
+)
+
+func init() {
+	Register(Migration{
+		Version:     10,
+		Description: "Turn blob file paths into blob store keys",
+		Up: func(ctx context.Context) error {
+			return db.WithTransactionContext(ctx, func(ctx context.Context, _ *sql.Tx) error {
+				rows, err := db.QueryWithContext(ctx, fmt.Sprintf(
+					"SELECT %s, %s FROM blob WHERE COALESCE(%s, '') != ''",
+					blobStore.COL_ID, blobStore.COL_FILEPATH, blobStore.COL_FILEPATH))
+				if err != nil {
+					return err
+				}
+
+				for _, row := range rows {
+					path := mapkit.GetString(row, blobStore.COL_FILEPATH)
+					if !filepath.IsAbs(path) {
+						continue
+					}
+					rel, err := filepath.Rel(dbsetup.BlobStorePath, path)
+					if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+						return fmt.Errorf("blob file %s lies outside the blob store %s", path, dbsetup.BlobStorePath)
+					}
+					key := filepath.ToSlash(rel)
+					if _, err := blobStore.Update(ctx, mapkit.GetString(row, blobStore.COL_ID), map[string]any{blobStore.COL_FILEPATH: key}); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		},
+		Down: func(ctx context.Context) error {
+			// Keys resolve against the configured blob store, which may not
+			// be a local directory; no-op here.
+			return nil
+		},
+	})
+}
@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"context"
+
+	"vcx/agent/internal/infra/db/consts"
+	blobStore "vcx/agent/internal/infra/db/store/blob"
+	dictionaryStore "vcx/agent/internal/infra/db/store/dictionary"
+)
+
+func init() {
+	Register(Migration{
+		Version:     11,
+		Description: "Add encryption key column to blob and dictionary tables",
+		Up: func(ctx context.Context) error {
+			// Existing data is stored in plain: ""
+			if err := addColumnIfMissing("blob", blobStore.COL_KEYID, consts.TYPE_STRING); err != nil {
+				return err
+			}
+			return addColumnIfMissing("dictionary", dictionaryStore.COL_KEYID, consts.TYPE_STRING)
+		},
+		Down: func(ctx context.Context) error {
+			// SQLite does not support DROP COLUMN prior to v3.35;
+			// no-op here — reset via database file deletion if needed.
+			return nil
+		},
+	})
+}
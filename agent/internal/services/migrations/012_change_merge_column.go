@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"context"
+
+	"vcx/agent/internal/infra/db/consts"
+	changeStore "vcx/agent/internal/infra/db/store/change"
+)
+
+func init() {
+	Register(Migration{
+		Version:     12,
+		Description: "Add merge parent column to change table",
+		Up: func(ctx context.Context) error {
+			// Existing changes have a single parent: ""
+			return addColumnIfMissing("change", changeStore.COL_CHANGEID_MERGE, consts.TYPE_FOREIGNKEY)
+		},
+		Down: func(ctx context.Context) error {
+			// SQLite does not support DROP COLUMN prior to v3.35;
+			// no-op here — reset via database file deletion if needed.
+			return nil
+		},
+	})
+}
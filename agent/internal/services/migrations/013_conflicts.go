@@ -0,0 +1,26 @@
+package migrations
+
+import (
+	"context"
+
+	"vcx/agent/internal/infra/db/consts"
+	changeStore "vcx/agent/internal/infra/db/store/change"
+	conflictStore "vcx/agent/internal/infra/db/store/conflict"
+)
+
+func init() {
+	Register(Migration{
+		Version:     13,
+		Description: "Create conflict table and flag changes recorded during a conflict",
+		Up: func(ctx context.Context) error {
+			conflictStore.CreateTable()
+			// Nothing was recorded during a conflict before: 0
+			return addColumnIfMissing("change", changeStore.COL_ISCONFLICTED, consts.TYPE_BOOL)
+		},
+		Down: func(ctx context.Context) error {
+			// SQLite does not support DROP COLUMN prior to v3.35;
+			// no-op here — reset via database file deletion if needed.
+			return nil
+		},
+	})
+}
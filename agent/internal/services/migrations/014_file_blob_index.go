@@ -0,0 +1,21 @@
+package migrations
+
+import (
+	"context"
+
+	fileStore "vcx/agent/internal/infra/db/store/file"
+)
+
+func init() {
+	Register(Migration{
+		Version:     14,
+		Description: "Index file table by content for rename detection",
+		Up: func(ctx context.Context) error {
+			return fileStore.CreateIndexes()
+		},
+		Down: func(ctx context.Context) error {
+			// Indexes are dropped with the database file if needed.
+			return nil
+		},
+	})
+}
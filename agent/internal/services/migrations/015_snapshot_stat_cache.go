@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"context"
+
+	"vcx/agent/internal/infra/db/consts"
+	snapshotStore "vcx/agent/internal/infra/db/store/snapshot"
+)
+
+func init() {
+	Register(Migration{
+		Version:     15,
+		Description: "Create snapshot table as the working copy stat cache",
+		Up: func(ctx context.Context) error {
+			snapshotStore.CreateTable()
+			// Tables from before the stat cache lack its columns
+			columns := map[string]string{
+				snapshotStore.COL_INSTANCEID: consts.TYPE_FOREIGNKEY,
+				snapshotStore.COL_PATH:       consts.TYPE_STRING,
+				snapshotStore.COL_MTIME:      consts.TYPE_INT,
+				snapshotStore.COL_INODE:      consts.TYPE_INT,
+			}
+			for column, colType := range columns {
+				if err := addColumnIfMissing("snapshot", column, colType); err != nil {
+					return err
+				}
+			}
+			return snapshotStore.CreateIndexes()
+		},
+		Down: func(ctx context.Context) error {
+			// SQLite does not support DROP COLUMN prior to v3.35;
+			// no-op here — reset via database file deletion if needed.
+			return nil
+		},
+	})
+}
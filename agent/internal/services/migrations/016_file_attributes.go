@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"context"
+
+	"vcx/agent/internal/infra/db/consts"
+	changeStore "vcx/agent/internal/infra/db/store/change"
+	fileStore "vcx/agent/internal/infra/db/store/file"
+)
+
+func init() {
+	Register(Migration{
+		Version:     16,
+		Description: "Add mode, mtime and owner columns to change, mode to file",
+		Up: func(ctx context.Context) error {
+			// Versions recorded before have no attributes: 0 and empty mean unknown
+			columns := map[string]string{
+				changeStore.COL_MODE:  consts.TYPE_INT,
+				changeStore.COL_MTIME: consts.TYPE_INT,
+				changeStore.COL_OWNER: consts.TYPE_STRING,
+			}
+			for column, colType := range columns {
+				if err := addColumnIfMissing("change", column, colType); err != nil {
+					return err
+				}
+			}
+			return addColumnIfMissing("file", fileStore.COL_MODE, consts.TYPE_INT)
+		},
+		Down: func(ctx context.Context) error {
+			// SQLite does not support DROP COLUMN prior to v3.35;
+			// no-op here — reset via database file deletion if needed.
+			return nil
+		},
+	})
+}
@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"context"
+
+	"vcx/agent/internal/infra/db/consts"
+	changeStore "vcx/agent/internal/infra/db/store/change"
+)
+
+func init() {
+	Register(Migration{
+		Version:     17,
+		Description: "Add fileType column to change",
+		Up: func(ctx context.Context) error {
+			// Versions recorded before keep it empty and are told apart by content
+			return addColumnIfMissing("change", changeStore.COL_FILETYPE, consts.TYPE_STRING)
+		},
+		Down: func(ctx context.Context) error {
+			// SQLite does not support DROP COLUMN prior to v3.35;
+			// no-op here — reset via database file deletion if needed.
+			return nil
+		},
+	})
+}
@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"context"
+
+	"vcx/agent/internal/infra/db/consts"
+	fileStore "vcx/agent/internal/infra/db/store/file"
+)
+
+func init() {
+	Register(Migration{
+		Version:     18,
+		Description: "Add linkType column to file",
+		Up: func(ctx context.Context) error {
+			// Symlinks recorded before are classified when next ingested
+			return addColumnIfMissing("file", fileStore.COL_LINKTYPE, consts.TYPE_STRING)
+		},
+		Down: func(ctx context.Context) error {
+			// SQLite does not support DROP COLUMN prior to v3.35;
+			// no-op here — reset via database file deletion if needed.
+			return nil
+		},
+	})
+}
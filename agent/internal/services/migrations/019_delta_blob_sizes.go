@@ -0,0 +1,56 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"vcx/agent/internal/infra/db"
+	blobStore "vcx/agent/internal/infra/db/store/blob"
+	"vcx/pkg/toolkit/compressionkit"
+	"vcx/pkg/toolkit/deltakit"
+	"vcx/pkg/toolkit/mapkit"
+)
+
+func init() {
+	Register(Migration{
+		Version:     19,
+		Description: "Backfill the size of delta blobs from their delta header",
+		Up: func(ctx context.Context) error {
+			// The size is the rebuilt content's, which the delta states up
+			// front. Only deltas kept in plain in the row are read; the rest
+			// is left to fsck --repair.
+			rows, err := db.QueryWithContext(ctx, fmt.Sprintf(
+				"SELECT %s, %s, %s FROM blob WHERE COALESCE(%s, '') != '' AND COALESCE(%s, 0) = 0"+
+					" AND COALESCE(%s, '') = '' AND COALESCE(%s, '') = '' AND COALESCE(%s, 0) = 0 AND COALESCE(%s, '') = ''",
+				blobStore.COL_ID, blobStore.COL_BLOB, blobStore.COL_ISCOMPRESSED,
+				blobStore.COL_BASEBLOBID, blobStore.COL_SIZE,
+				blobStore.COL_FILEPATH, blobStore.COL_PACKID, blobStore.COL_DICTID, blobStore.COL_KEYID))
+			if err != nil {
+				return err
+			}
+			for _, row := range rows {
+				id    := mapkit.GetString(row, blobStore.COL_ID)
+				delta := mapkit.GetBytes(row, blobStore.COL_BLOB)
+				if mapkit.GetBool(row, blobStore.COL_ISCOMPRESSED) {
+					if delta, err = compressionkit.Decompress(delta); err != nil {
+						log.Warn("Cannot determine blob size", "blobID", id, "error", err)
+						continue
+					}
+				}
+				size, err := deltakit.TargetLen(delta)
+				if err != nil {
+					log.Warn("Cannot determine blob size", "blobID", id, "error", err)
+					continue
+				}
+				if _, err := blobStore.Update(ctx, id, map[string]any{blobStore.COL_SIZE: int64(size)}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(ctx context.Context) error {
+			// Sizes are data, not schema; nothing to undo.
+			return nil
+		},
+	})
+}
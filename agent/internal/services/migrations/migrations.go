@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"sort"
 
+	"vcx/agent/internal/infra/db"
 	migrationsStore "vcx/agent/internal/infra/db/store/migrations"
 	"vcx/pkg/logging"
 )
@@ -112,4 +113,14 @@ func setCurrentVersion(ctx context.Context, version int) error {
 	}
 	_, err := migrationsStore.Create(ctx, data)
 	return err
-}
\ No newline at end of file
+}
+
+// addColumnIfMissing adds a column unless the table already has it.
+// Fresh databases get new columns from the store schema in migration 1,
+// so later migrations must tolerate columns that already exist.
+func addColumnIfMissing(tableName, column, colType string) error {
+	if db.ColumnExists(tableName, column) {
+		return nil
+	}
+	return db.AddColumn(tableName, column, colType)
+}
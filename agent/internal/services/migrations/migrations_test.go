@@ -0,0 +1,47 @@
+package migrations
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"vcx/agent/internal/infra/db/dbsetup"
+	blobStore "vcx/agent/internal/infra/db/store/blob"
+	"vcx/pkg/toolkit/compressionkit"
+)
+
+
+func TestStoredContentSize(t *testing.T) {
+	content := bytes.Repeat([]byte("some text that compresses\n"), 100)
+	compressed, _ := compressionkit.Compress(content)
+
+	dbsetup.BlobStorePath = t.TempDir()
+	absolute := filepath.Join(t.TempDir(), "blob")
+	if err := os.WriteFile(absolute, compressed, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dbsetup.BlobStorePath, "relative"), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name string
+		row  map[string]any
+	}{
+		{"in the row", map[string]any{blobStore.COL_BLOB: content}},
+		{"compressed in the row", map[string]any{blobStore.COL_BLOB: compressed, blobStore.COL_ISCOMPRESSED: int64(1)}},
+		{"compressed in a file", map[string]any{blobStore.COL_FILEPATH: absolute, blobStore.COL_ISCOMPRESSED: int64(1)}},
+		{"in the blob store", map[string]any{blobStore.COL_FILEPATH: "relative"}},
+	}
+	for _, c := range cases {
+		size, err := storedContentSize(c.row)
+		if err != nil || size != int64(len(content)) {
+			t.Errorf("%s: got %d, %v, want %d", c.name, size, err, len(content))
+		}
+	}
+
+	if _, err := storedContentSize(map[string]any{blobStore.COL_FILEPATH: "missing"}); err == nil {
+		t.Error("missing file: expected an error")
+	}
+}
@@ -0,0 +1,153 @@
+package policy
+
+import (
+	"sync"
+	"time"
+)
+
+
+// Engine coalesces file events per path according to a Policy.
+//
+// Every Submit (re)arms a timer for the path. The path is released on the
+// Ready channel once it has been quiet for QuietWindow, or after MaxWait at
+// the latest, but never sooner than MinInterval after its previous release.
+// The Engine only decides when; the receiver records whatever state the
+// path is in at that moment, so a burst of write/rename/touch becomes a
+// single version.
+type Engine struct {
+	mu          sync.Mutex
+	policy      Policy
+	pending     map[string]*pendingPath
+	lastFlushed map[string]time.Time
+	ready       chan string
+	done        chan struct{}
+	stopped     bool
+}
+
+
+type pendingPath struct {
+	firstSeen  time.Time
+	timer      *time.Timer
+	generation int
+}
+
+
+func NewEngine(policy Policy) *Engine {
+	return &Engine{
+		policy:      policy,
+		pending:     make(map[string]*pendingPath),
+		lastFlushed: make(map[string]time.Time),
+		ready:       make(chan string),
+		done:        make(chan struct{}),
+	}
+}
+
+
+// Ready delivers paths that are due to be recorded.
+func (e *Engine) Ready() <-chan string {
+	return e.ready
+}
+
+
+// Policy returns the policy currently applied.
+func (e *Engine) Policy() Policy {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.policy
+}
+
+
+// SetPolicy replaces the policy. Pending paths keep their current deadline
+// and pick up the new timing with their next event.
+func (e *Engine) SetPolicy(policy Policy) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.policy = policy
+}
+
+
+// Submit registers activity on path.
+func (e *Engine) Submit(path string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.stopped {
+		return
+	}
+
+	now := time.Now()
+	entry, ok := e.pending[path]
+	if !ok {
+		entry = &pendingPath{firstSeen: now}
+		e.pending[path] = entry
+	}
+
+	deadline := now.Add(e.policy.QuietWindow)
+	if maxDeadline := entry.firstSeen.Add(e.policy.MaxWait); deadline.After(maxDeadline) {
+		deadline = maxDeadline
+	}
+	if last, ok := e.lastFlushed[path]; ok {
+		if earliest := last.Add(e.policy.MinInterval); deadline.Before(earliest) {
+			deadline = earliest
+		}
+	}
+
+	if entry.timer != nil {
+		entry.timer.Stop()
+	}
+	entry.generation++
+	generation := entry.generation
+	entry.timer = time.AfterFunc(deadline.Sub(now), func() {
+		e.fire(path, entry, generation)
+	})
+}
+
+
+// Pending returns the number of paths waiting to be released.
+func (e *Engine) Pending() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.pending)
+}
+
+
+// Stop discards pending paths and releases nothing further.
+func (e *Engine) Stop() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.stopped {
+		return
+	}
+	e.stopped = true
+	for _, entry := range e.pending {
+		entry.timer.Stop()
+	}
+	e.pending = make(map[string]*pendingPath)
+	close(e.done)
+}
+
+
+func (e *Engine) fire(path string, entry *pendingPath, generation int) {
+	e.mu.Lock()
+	// a newer Submit re-armed the path after this timer was already running
+	if e.stopped || e.pending[path] != entry || entry.generation != generation {
+		e.mu.Unlock()
+		return
+	}
+	delete(e.pending, path)
+
+	now := time.Now()
+	e.lastFlushed[path] = now
+	for other, last := range e.lastFlushed {
+		if now.Sub(last) > e.policy.MinInterval {
+			delete(e.lastFlushed, other)
+		}
+	}
+	e.mu.Unlock()
+
+	select {
+	case e.ready <- path:
+	case <-e.done:
+	}
+}
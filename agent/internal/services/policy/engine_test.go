@@ -0,0 +1,114 @@
+package policy
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+
+func receive(t *testing.T, engine *Engine, timeout time.Duration) (string, time.Time) {
+	t.Helper()
+	select {
+	case path := <-engine.Ready():
+		return path, time.Now()
+	case <-time.After(timeout):
+		t.Fatalf("no path released within %s", timeout)
+	}
+	return "", time.Time{}
+}
+
+
+func TestEngineCoalescesBurst(t *testing.T) {
+	engine := NewEngine(Policy{QuietWindow: 50 * time.Millisecond, MaxWait: time.Second})
+	defer engine.Stop()
+
+	for range 5 {
+		engine.Submit("a.txt")
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	path, _ := receive(t, engine, time.Second)
+	if path != "a.txt" {
+		t.Fatalf("expected a.txt, got %s", path)
+	}
+	select {
+	case extra := <-engine.Ready():
+		t.Fatalf("burst released more than once: %s", extra)
+	case <-time.After(150 * time.Millisecond):
+	}
+}
+
+
+func TestEngineMaxWait(t *testing.T) {
+	engine := NewEngine(Policy{QuietWindow: 50 * time.Millisecond, MaxWait: 120 * time.Millisecond})
+	defer engine.Stop()
+
+	start := time.Now()
+	stop  := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				engine.Submit("busy.log")
+				time.Sleep(10 * time.Millisecond)
+			}
+		}
+	}()
+	defer close(stop)
+
+	_, released := receive(t, engine, time.Second)
+	if elapsed := released.Sub(start); elapsed > 400*time.Millisecond {
+		t.Fatalf("continuously changing path held back for %s", elapsed)
+	}
+}
+
+
+func TestEngineMinInterval(t *testing.T) {
+	engine := NewEngine(Policy{QuietWindow: 20 * time.Millisecond, MinInterval: 200 * time.Millisecond, MaxWait: time.Second})
+	defer engine.Stop()
+
+	engine.Submit("a.txt")
+	_, first := receive(t, engine, time.Second)
+
+	engine.Submit("a.txt")
+	_, second := receive(t, engine, time.Second)
+	if gap := second.Sub(first); gap < 190*time.Millisecond {
+		t.Fatalf("versions released %s apart, expected at least the minimum interval", gap)
+	}
+}
+
+
+func TestEngineStopDropsPending(t *testing.T) {
+	engine := NewEngine(Policy{QuietWindow: 20 * time.Millisecond, MaxWait: time.Second})
+	engine.Submit("a.txt")
+	engine.Stop()
+
+	if engine.Pending() != 0 {
+		t.Fatalf("expected no pending paths after stop")
+	}
+	select {
+	case path := <-engine.Ready():
+		t.Fatalf("path released after stop: %s", path)
+	case <-time.After(60 * time.Millisecond):
+	}
+}
+
+
+func TestPolicyJSON(t *testing.T) {
+	policy := Default()
+	if err := json.Unmarshal([]byte(`{"quietWindow":"500ms"}`), &policy); err != nil {
+		t.Fatal(err)
+	}
+	if policy.QuietWindow != 500*time.Millisecond || policy.MaxWait != DEFAULT_MAX_WAIT {
+		t.Fatalf("unexpected policy %+v", policy)
+	}
+	if err := json.Unmarshal([]byte(`{"maxWait":"soon"}`), &policy); err == nil {
+		t.Fatalf("expected invalid duration to fail")
+	}
+	if err := (Policy{QuietWindow: time.Second, MaxWait: time.Millisecond}).Validate(); err == nil {
+		t.Fatalf("expected max wait below quiet window to fail")
+	}
+}
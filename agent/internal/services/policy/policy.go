@@ -0,0 +1,144 @@
+// Package policy decides when watched file activity becomes a recorded version.
+//
+// Editors save in bursts (temp file, rename, touch), so raw watcher events
+// are coalesced per path by an Engine before they reach fileService.Ingest.
+// The timing is configurable per project and persisted in SimpleKV.
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"vcx/agent/internal/consts/keys"
+	"vcx/agent/internal/services/simplekv"
+	"vcx/pkg/logging"
+)
+
+
+var log = logging.GetLogger()
+
+
+const (
+	DEFAULT_QUIET_WINDOW = 2 * time.Second
+	DEFAULT_MIN_INTERVAL = 10 * time.Second
+	DEFAULT_MAX_WAIT     = 60 * time.Second
+)
+
+
+// Policy controls how events for a single path are turned into versions.
+//
+// Fields:
+//   - QuietWindow: A path is recorded once no event arrived for this long
+//   - MinInterval: Minimum time between two recorded versions of the same path
+//   - MaxWait: Upper bound on how long a continuously changing path is held back
+type Policy struct {
+	QuietWindow time.Duration `json:"quietWindow"`
+	MinInterval time.Duration `json:"minInterval"`
+	MaxWait     time.Duration `json:"maxWait"`
+}
+
+
+// policyRecord is the persisted form; durations are stored as strings like "2s".
+type policyRecord struct {
+	QuietWindow string `json:"quietWindow"`
+	MinInterval string `json:"minInterval"`
+	MaxWait     string `json:"maxWait"`
+}
+
+
+func Default() Policy {
+	return Policy{
+		QuietWindow: DEFAULT_QUIET_WINDOW,
+		MinInterval: DEFAULT_MIN_INTERVAL,
+		MaxWait:     DEFAULT_MAX_WAIT,
+	}
+}
+
+
+// Validate checks that the durations are usable by the Engine.
+func (p Policy) Validate() error {
+	if p.QuietWindow <= 0 {
+		return fmt.Errorf("quiet window must be positive")
+	}
+	if p.MinInterval < 0 {
+		return fmt.Errorf("minimum interval cannot be negative")
+	}
+	if p.MaxWait < p.QuietWindow {
+		return fmt.Errorf("max wait must be at least the quiet window")
+	}
+	return nil
+}
+
+
+func (p Policy) MarshalJSON() ([]byte, error) {
+	return json.Marshal(policyRecord{
+		QuietWindow: p.QuietWindow.String(),
+		MinInterval: p.MinInterval.String(),
+		MaxWait:     p.MaxWait.String(),
+	})
+}
+
+
+// UnmarshalJSON accepts duration strings; omitted fields keep their current value.
+func (p *Policy) UnmarshalJSON(data []byte) error {
+	var record policyRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return err
+	}
+
+	fields := []struct {
+		text  string
+		value *time.Duration
+	}{
+		{record.QuietWindow, &p.QuietWindow},
+		{record.MinInterval, &p.MinInterval},
+		{record.MaxWait,     &p.MaxWait},
+	}
+	for _, field := range fields {
+		if field.text == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(field.text)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q: %w", field.text, err)
+		}
+		*field.value = duration
+	}
+	return nil
+}
+
+
+// GetForProject returns the project's policy, or the default when none is stored.
+func GetForProject(ctx context.Context, projectID string) Policy {
+	policy := Default()
+
+	value, err := simplekv.GetString(ctx, projectKey(projectID))
+	if err != nil || value == "" {
+		return policy
+	}
+	if err := json.Unmarshal([]byte(value), &policy); err != nil {
+		log.Warn("Ignoring invalid auto-snapshot policy", "projectID", projectID, "error", err)
+		return Default()
+	}
+	return policy
+}
+
+
+// SetForProject validates and stores the project's policy.
+func SetForProject(ctx context.Context, projectID string, policy Policy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	value, err := json.Marshal(policy)
+	if err != nil {
+		return err
+	}
+	return simplekv.SetString(ctx, projectKey(projectID), string(value))
+}
+
+
+func projectKey(projectID string) string {
+	return fmt.Sprintf("%s:%s", keys.AUTOSNAPSHOT_POLICY, projectID)
+}
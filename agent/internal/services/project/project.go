@@ -4,8 +4,10 @@ package project
 import (
 	"context"
 	"fmt"
+	"os"
 	"path/filepath"
 
+	instanceDomain "vcx/agent/internal/domains/instance"
 	projectDomain "vcx/agent/internal/domains/project"
 	branchService "vcx/agent/internal/services/branch"
 	changeService "vcx/agent/internal/services/change"
@@ -13,6 +15,7 @@ import (
 	"vcx/agent/internal/services/filters"
 	"vcx/agent/internal/services/fs/walk"
 	instanceService "vcx/agent/internal/services/instance"
+	"vcx/agent/internal/services/statcache"
 	tagService "vcx/agent/internal/services/tag"
 	"vcx/agent/internal/session"
 	"vcx/pkg/logging"
@@ -27,62 +30,67 @@ var log = logging.GetLogger()
 func NewProject(ctx context.Context, projectPath string) (*projectDomain.Project, <-chan message.Event) {
 	msgChan := make(chan message.Event)
 
-	project, ctx, err := initializeProjectEntities(ctx, projectPath)
+	project, instance, ctx, err := initializeProjectEntities(ctx, projectPath)
 	if err != nil {
 		msgChan <- message.Error(fmt.Errorf("failed to create project: %w", err).Error())
 		close(msgChan)
 		return nil, msgChan
 	}
 
-	go ingestProjectFiles(ctx, projectPath, project, msgChan)
+	go ingestProjectFiles(ctx, projectPath, project, instance, msgChan)
 
 	return project, msgChan
 }
 
 
-func initializeProjectEntities(ctx context.Context, projectPath string) (*projectDomain.Project, context.Context, error) {
+func initializeProjectEntities(ctx context.Context, projectPath string) (*projectDomain.Project, *instanceDomain.Instance, context.Context, error) {
 	// new change
 	change, err := changeService.CreateProjectChange(ctx)
 	if err != nil {
-		return nil, ctx, err
+		return nil, nil, ctx, err
 	}
 	ctx = session.WithChangeID(ctx, change.ID)
 
 	// new project
 	project, err := projectDomain.New(ctx, filepath.Base(projectPath))
 	if err != nil {
-		return nil, ctx, err
+		return nil, nil, ctx, err
 	}
 	ctx = session.WithProjectID(ctx, project.ID)
 
 	// New branch
 	branch, err := branchService.Create(ctx, "main")
 	if err != nil {
-		return nil, ctx, err
+		return nil, nil, ctx, err
 	}
 	ctx = session.WithBranchID(ctx, branch.ID)
 
 	// New instance
-	_, err = instanceService.Create(ctx, projectPath)
+	instance, err := instanceService.Create(ctx, projectPath)
 	if err != nil {
-		return nil, ctx, err
+		return nil, nil, ctx, err
 	}
 
 	// New Tag
 	_, err = tagService.CreateSystemProjectTag(ctx)
 	if err != nil {
-		return nil, ctx, err
+		return nil, nil, ctx, err
 	}
 
-	return project, ctx, nil
+	return project, instance, ctx, nil
 }
 
 
-func ingestProjectFiles(ctx context.Context, projectPath string, project *projectDomain.Project, msgChan chan message.Event) {
+func ingestProjectFiles(ctx context.Context, projectPath string, project *projectDomain.Project, instance *instanceDomain.Instance, msgChan chan message.Event) {
 	defer close(msgChan)
 
-	quickFilter := filters.FromFile(projectPath, filepath.Join(filters.FILTERSAMPLEPATH, filters.FILTERFILE))
-	quickFilter.Init()
+	quickFilter := filters.ForInstance(projectPath)
+
+	// index stats as we go, so the first rescan need not read everything again
+	cache, err := statcache.Load(ctx, instance.ID)
+	if err != nil {
+		log.Warn("Failed to load stat cache", "error", err)
+	}
 
 	log.Info("Walking Starting")
 	log.Info(fmt.Sprintf("Project Path: %s", projectPath))
@@ -97,9 +105,18 @@ func ingestProjectFiles(ctx context.Context, projectPath string, project *projec
 		switch event.Type {
 		case walk.ERROR:
 			log.Error("Walk error", "error", event.Data)
+		case walk.DIR:
+			// the project root is the instance itself
+			if event.Data == projectPath {
+				continue
+			}
+			numProcess++
+			if err := ingestDir(ctx, projectPath, event.Data); err != nil {
+				logIngestionFailure("dir", event, err)
+			}
 		case walk.FILE:
 			numProcess++
-			if _, err := fileService.Ingest(ctx, projectPath, event.Data); err != nil {
+			if err := ingestFile(ctx, projectPath, event.Data, cache); err != nil {
 				logIngestionFailure("file", event, err)
 			}
 		case walk.SYM:
@@ -110,6 +127,12 @@ func ingestProjectFiles(ctx context.Context, projectPath string, project *projec
 		}
 	}
 
+	if cache != nil {
+		if err := cache.Save(ctx); err != nil {
+			log.Warn("Failed to save stat cache", "error", err)
+		}
+	}
+
 	log.Info(fmt.Sprintf("Walk processed: %d", numProcess))
 	log.Info("Project initialization completed", "project", project.Name)
 	log.Info(fmt.Sprintf("Project Path: %s", projectPath))
@@ -123,11 +146,25 @@ func logIngestionFailure(pathType string, event walk.Event, err error) {
 }
 
 
-// func ingestDir(ctx context.Context, filePath string) error {
-//     // TODO: Create file records, calculate hashes, etc.
-//     log.Debug("Ingesting dir", "path", filePath)
-//     return nil
-// }
+func ingestDir(ctx context.Context, projectPath, dirPath string) error {
+    if _, err := fileService.IngestDir(ctx, projectPath, dirPath); err != nil {
+        return err
+    }
+    return nil
+}
+
+
+func ingestFile(ctx context.Context, projectPath, filePath string, cache *statcache.Cache) error {
+	info, statErr := os.Lstat(filePath)
+	file, err := fileService.Ingest(ctx, projectPath, filePath)
+	if err != nil || statErr != nil || cache == nil {
+		return err
+	}
+
+	relPath, _ := filepath.Rel(projectPath, filePath)
+	cache.Record(relPath, info, file)
+	return nil
+}
 
 
 func ingestSymlink(ctx context.Context, projectPath, linkPath string) error {
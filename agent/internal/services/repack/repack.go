@@ -0,0 +1,373 @@
+// Package repack moves stored blob data into pack files.
+//
+// Small blobs are kept in the DB and larger ones as a file each in a shard
+// directory, which bloats the SQLite file and the blob store tree. A repack
+// appends the stored data of those blobs to new packs and points the blob
+// rows at their entries. Packs whose entries mostly belong to removed blobs
+// are rewritten the same way, dropping the dead entries.
+//
+// A pack is written to a local staging file and moved into the blob store
+// before any row points into it, and the rows are updated in a transaction
+// that re-checks every blob, so a blob collected or moved in the meantime
+// only leaves a dead entry behind. Old loose files and packs are removed
+// after the commit; what is left over is swept by the garbage collector.
+package repack
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	blobDomain "vcx/agent/internal/domains/blob"
+	"vcx/agent/internal/infra/blobstore"
+	"vcx/agent/internal/infra/db"
+	"vcx/agent/internal/infra/db/dbsetup"
+	blobService "vcx/agent/internal/services/blob"
+	"vcx/pkg/logging"
+	"vcx/pkg/toolkit/filekit"
+	"vcx/pkg/toolkit/packkit"
+	"vcx/pkg/toolkit/uuidkit"
+)
+
+
+var log = logging.GetLogger()
+
+
+const (
+	MAX_PACK_SIZE        = 256 * 1024 * 1024 // a pack is closed once it grows past this
+	MAX_PACKED_FILE_SIZE = 8 * 1024 * 1024   // larger loose files stay loose
+	MIN_PACK_USAGE       = 0.5               // packs with less live data are rewritten
+	DEFAULT_INTERVAL     = 24 * time.Hour
+)
+
+
+// Options control a repack run.
+//
+// Fields:
+//   - DryRun: Only report what would be moved
+type Options struct {
+	DryRun bool
+}
+
+
+// Report describes what a run moved, or would move in a dry run. Reclaimed
+// counts the dead entries dropped with rewritten packs.
+type Report struct {
+	DryRun    bool     `json:"dryRun"`
+	Blobs     int      `json:"blobs"`
+	Bytes     int64    `json:"bytes"`
+	FromDB    int      `json:"fromDB"`
+	FromFiles int      `json:"fromFiles"`
+	FromPacks int      `json:"fromPacks"`
+	Packs     []string `json:"packs"`
+	Rewritten []string `json:"rewritten"`
+	Reclaimed int64    `json:"reclaimed"`
+}
+
+
+// entry is a blob written to the current pack.
+type entry struct {
+	blob   *blobDomain.Blob
+	offset int64
+	length int64
+}
+
+
+// Run performs one repack.
+func Run(ctx context.Context, options Options) (*Report, error) {
+	// never while the garbage collector removes blobs or sweeps packs
+	blobService.Maintenance.Lock()
+	defer blobService.Maintenance.Unlock()
+
+	report := &Report{
+		DryRun:    options.DryRun,
+		Packs:     []string{},
+		Rewritten: []string{},
+	}
+
+	candidates, rewrite, err := plan(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	if options.DryRun {
+		for _, blob := range candidates {
+			report.count(blob, storedSize(ctx, blob))
+		}
+		for packID, dead := range rewrite {
+			report.Rewritten  = append(report.Rewritten, packID)
+			report.Reclaimed += dead
+		}
+		return report, nil
+	}
+
+	if err := pack(ctx, candidates, report); err != nil {
+		return report, err
+	}
+	if err := removeRewritten(ctx, rewrite, report); err != nil {
+		return report, err
+	}
+
+	if report.FromDB > 0 {
+		if err := db.VacuumWithContext(ctx); err != nil {
+			log.Warn("Failed to vacuum the database after repacking", "error", err)
+		}
+	}
+
+	log.Info("Repack finished", "blobs", report.Blobs, "bytes", report.Bytes,
+		"packs", len(report.Packs), "rewritten", len(report.Rewritten), "reclaimed", report.Reclaimed)
+	return report, nil
+}
+
+
+// Start runs a repack every interval until ctx is cancelled.
+func Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := Run(ctx, Options{}); err != nil {
+				log.Error("Repack failed", "error", err)
+			}
+		}
+	}
+}
+
+
+func (r *Report) count(blob *blobDomain.Blob, size int64) {
+	switch {
+	case blob.IsPacked():
+		r.FromPacks++
+	case blob.FilePath != "":
+		r.FromFiles++
+	default:
+		r.FromDB++
+	}
+	r.Blobs++
+	r.Bytes += size
+}
+
+
+// plan returns the blobs to move, without their content, and the packs to
+// rewrite with the number of dead bytes in each.
+func plan(ctx context.Context) ([]*blobDomain.Blob, map[string]int64, error) {
+	blobs, err := blobDomain.GetAllMetadata(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list blobs: %w", err)
+	}
+
+	objects, err := blobService.Store().List(ctx, "")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list blob store: %w", err)
+	}
+	sizes := make(map[string]int64, len(objects))
+	for _, object := range objects {
+		sizes[object.Key] = object.Size
+	}
+
+	live := make(map[string]int64)
+	for _, blob := range blobs {
+		if blob.IsPacked() {
+			live[blob.PackID] += blob.PackLength
+		}
+	}
+	rewrite := make(map[string]int64)
+	for packID, liveBytes := range live {
+		// missing packs are reported by fsck
+		size, ok := sizes[blobService.PackKey(packID)]
+		if ok && float64(liveBytes) < float64(size)*MIN_PACK_USAGE {
+			rewrite[packID] = size - liveBytes
+		}
+	}
+
+	var candidates []*blobDomain.Blob
+	for _, blob := range blobs {
+		switch {
+		case blob.IsPacked():
+			if _, ok := rewrite[blob.PackID]; !ok {
+				continue
+			}
+		case blob.FilePath != "":
+			if sizes[blob.FilePath] > MAX_PACKED_FILE_SIZE {
+				continue
+			}
+		}
+		candidates = append(candidates, blob)
+	}
+	return candidates, rewrite, nil
+}
+
+
+// pack appends the stored data of the candidates to new packs, committing
+// each pack once it is full.
+func pack(ctx context.Context, candidates []*blobDomain.Blob, report *Report) error {
+	if len(candidates) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(dbsetup.BlobStorePath, 0755); err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+
+	var writer *packkit.Writer
+	var packID string
+	var entries []entry
+
+	for _, candidate := range candidates {
+		// the full row, for data kept in the DB
+		blob, err := blobDomain.GetByID(ctx, candidate.ID)
+		if err != nil || !sameLocation(blob, candidate) {
+			continue
+		}
+
+		if writer == nil {
+			packID = uuidkit.NewUUIDv7AsString()
+			if writer, err = packkit.Create(stagingPath(packID)); err != nil {
+				return fmt.Errorf("failed to create pack: %w", err)
+			}
+		}
+
+		stored, err := blobService.OpenStored(ctx, blob)
+		if err != nil {
+			log.Warn("Cannot read blob, leaving it in place", "blob", blob.ID, "error", err)
+			continue
+		}
+		length      := blobService.StoredSize(ctx, blob)
+		offset, err := writer.Append(blob.ID, stored, length)
+		stored.Close()
+		if err != nil {
+			writer.Abort()
+			return err
+		}
+		entries = append(entries, entry{blob: blob, offset: offset, length: length})
+
+		if writer.Size() >= MAX_PACK_SIZE {
+			if err := commit(ctx, writer, packID, entries, report); err != nil {
+				return err
+			}
+			writer, entries = nil, nil
+		}
+	}
+
+	if writer == nil {
+		return nil
+	}
+	if len(entries) == 0 {
+		writer.Abort()
+		return nil
+	}
+	return commit(ctx, writer, packID, entries, report)
+}
+
+
+// commit moves a pack into the blob store and points the rows of its
+// entries at it. Loose files the blobs were moved out of are removed
+// afterwards.
+func commit(ctx context.Context, writer *packkit.Writer, packID string, entries []entry, report *Report) error {
+	path := stagingPath(packID)
+	if err := writer.Close(); err != nil {
+		os.Remove(path)
+		return fmt.Errorf("failed to write pack %s: %w", packID, err)
+	}
+	store := blobService.Store()
+	key   := blobService.PackKey(packID)
+	if err := blobstore.PutFile(ctx, store, key, path); err != nil {
+		os.Remove(path)
+		return fmt.Errorf("failed to store pack %s: %w", packID, err)
+	}
+
+	var moved []entry
+	err := db.WithTransactionContext(ctx, func(ctx context.Context, _ *sql.Tx) error {
+		moved = moved[:0]
+		for _, e := range entries {
+			current, err := blobDomain.GetByID(ctx, e.blob.ID)
+			if err != nil || !sameLocation(current, e.blob) {
+				continue // collected or moved meanwhile
+			}
+			if err := current.MoveToPack(ctx, packID, e.offset, e.length); err != nil {
+				return fmt.Errorf("failed to index blob %s: %w", e.blob.ID, err)
+			}
+			moved = append(moved, e)
+		}
+		return nil
+	})
+	if err != nil {
+		store.Delete(ctx, key)
+		return err
+	}
+
+	report.Packs = append(report.Packs, packID)
+	for _, e := range moved {
+		report.count(e.blob, e.length)
+		if e.blob.FilePath == "" {
+			continue
+		}
+		if err := store.Delete(ctx, e.blob.FilePath); err != nil {
+			log.Warn("Failed to remove packed blob file", "path", e.blob.FilePath, "error", err)
+		}
+	}
+	log.Debug("Wrote pack", "pack", packID, "blobs", len(moved))
+	return nil
+}
+
+
+// removeRewritten deletes the rewritten packs no blob points into anymore.
+func removeRewritten(ctx context.Context, rewrite map[string]int64, report *Report) error {
+	if len(rewrite) == 0 {
+		return nil
+	}
+	packIDs, err := blobDomain.GetPackIDs(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list packs: %w", err)
+	}
+	inUse := make(map[string]bool, len(packIDs))
+	for _, packID := range packIDs {
+		inUse[packID] = true
+	}
+
+	for packID, dead := range rewrite {
+		if inUse[packID] {
+			continue
+		}
+		if err := blobService.Store().Delete(ctx, blobService.PackKey(packID)); err != nil {
+			log.Warn("Failed to remove rewritten pack", "pack", packID, "error", err)
+			continue
+		}
+		report.Rewritten  = append(report.Rewritten, packID)
+		report.Reclaimed += dead
+	}
+	return nil
+}
+
+
+// storedSize returns the stored size of a blob listed without its content.
+func storedSize(ctx context.Context, blob *blobDomain.Blob) int64 {
+	if blob.IsPacked() || blob.FilePath != "" {
+		return blobService.StoredSize(ctx, blob)
+	}
+	full, err := blobDomain.GetByID(ctx, blob.ID)
+	if err != nil {
+		return 0
+	}
+	return blobService.StoredSize(ctx, full)
+}
+
+
+// stagingPath returns where a pack is written before it moves into the blob
+// store. The temp prefix lets the garbage collector sweep it after a crash.
+func stagingPath(packID string) string {
+	return filepath.Join(dbsetup.BlobStorePath, filekit.TEMP_PREFIX+packID+".pack")
+}
+
+
+// sameLocation reports whether two loads of a blob point at the same stored
+// data.
+func sameLocation(a, b *blobDomain.Blob) bool {
+	return a.FilePath == b.FilePath && a.PackID == b.PackID && a.PackOffset == b.PackOffset
+}
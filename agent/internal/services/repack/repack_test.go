@@ -0,0 +1,116 @@
+package repack
+
+import (
+	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	blobDomain "vcx/agent/internal/domains/blob"
+	"vcx/agent/internal/infra/db/dbsetup"
+	blobService "vcx/agent/internal/services/blob"
+	"vcx/agent/internal/services/servicetest"
+	"vcx/pkg/toolkit/packkit"
+	"vcx/pkg/toolkit/uuidkit"
+)
+
+
+func newBlob(t *testing.T, ctx context.Context, content []byte) *blobDomain.Blob {
+	t.Helper()
+	blob, err := blobService.CreateFromData(ctx, content, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return blob
+}
+
+
+func reload(t *testing.T, ctx context.Context, id string) *blobDomain.Blob {
+	t.Helper()
+	blob, err := blobDomain.GetByID(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return blob
+}
+
+
+func TestRunPacksLooseBlobs(t *testing.T) {
+	ctx   := servicetest.Context(t)
+	small := []byte("kept in the database\n")
+	large := make([]byte, blobService.MAX_DB_BLOB_SIZE+1)
+	rand.New(rand.NewSource(1)).Read(large)
+	inDB   := newBlob(t, ctx, small)
+	inFile := newBlob(t, ctx, large)
+	if inFile.FilePath == "" {
+		t.Fatal("expected the large blob in the blob store")
+	}
+
+	report, err := Run(ctx, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Blobs != 2 || report.FromDB != 1 || report.FromFiles != 1 || len(report.Packs) != 1 {
+		t.Errorf("got %+v, want both blobs moved into one pack", report)
+	}
+
+	for _, c := range []struct {
+		blob    *blobDomain.Blob
+		content []byte
+	}{{inDB, small}, {inFile, large}} {
+		blob := reload(t, ctx, c.blob.ID)
+		if !blob.IsPacked() || blob.PackID != report.Packs[0] {
+			t.Errorf("blob %s is in pack %q, want %q", blob.ID, blob.PackID, report.Packs[0])
+		}
+		content, err := blobService.ReadBlob(ctx, blob)
+		if err != nil || string(content) != string(c.content) {
+			t.Errorf("blob %s reads back %d bytes, %v; want its %d bytes", blob.ID, len(content), err, len(c.content))
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dbsetup.BlobStorePath, inFile.FilePath)); !os.IsNotExist(err) {
+		t.Errorf("loose file left behind: %v", err)
+	}
+
+	if report, err = Run(ctx, Options{}); err != nil || report.Blobs != 0 {
+		t.Errorf("second run moved %d blobs, %v; want nothing left to move", report.Blobs, err)
+	}
+}
+
+
+func TestCommitLeavesBlobsMovedMeanwhileAlone(t *testing.T) {
+	ctx  := servicetest.Context(t)
+	blob := reload(t, ctx, newBlob(t, ctx, []byte("moved while being packed\n")).ID)
+
+	packID := uuidkit.NewUUIDv7AsString()
+	writer, err := packkit.Create(stagingPath(packID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := blobService.OpenStored(ctx, blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	length      := blobService.StoredSize(ctx, blob)
+	offset, err := writer.Append(blob.ID, stored, length)
+	stored.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// another pack took the blob after it was appended here
+	if err := reload(t, ctx, blob.ID).MoveToPack(ctx, "elsewhere", 0, length); err != nil {
+		t.Fatal(err)
+	}
+
+	report := &Report{Packs: []string{}, Rewritten: []string{}}
+	if err := commit(ctx, writer, packID, []entry{{blob: blob, offset: offset, length: length}}, report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Blobs != 0 {
+		t.Errorf("committed %d blobs, want none", report.Blobs)
+	}
+	if current := reload(t, ctx, blob.ID); current.PackID != "elsewhere" {
+		t.Errorf("blob points into pack %q, want the one that moved it", current.PackID)
+	}
+}
@@ -0,0 +1,200 @@
+// Package rescan brings the recorded tree of an instance's branch in line
+// with its working copy after changes the file monitor did not see, e.g.
+// while the agent was not running.
+//
+// The working copy is walked like on init. Live files that are gone from
+// disk are marked deleted first, which records each deletion as a change
+// and keeps the last content restorable; then every file, symlink and
+// directory found is ingested, which records nothing for unchanged content.
+// Because the deletions come first, a file moved while unwatched is
+// recorded as a rename.
+//
+// Files the stat cache (see statcache) vouches for are not read at all, so
+// a rescan of an unchanged tree costs one stat per file.
+package rescan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"vcx/agent/internal/consts/filetype"
+	fileDomain "vcx/agent/internal/domains/file"
+	instanceDomain "vcx/agent/internal/domains/instance"
+	fileService "vcx/agent/internal/services/file"
+	"vcx/agent/internal/services/filters"
+	"vcx/agent/internal/services/fs/walk"
+	"vcx/agent/internal/services/statcache"
+	"vcx/agent/internal/session"
+	"vcx/pkg/logging"
+)
+
+
+var log = logging.GetLogger()
+
+
+// Rename is a file recorded under a new path by a rescan.
+type Rename struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+
+// Result lists what a rescan recorded.
+//
+// Fields:
+//   - Scanned: Number of files, symlinks and directories found in the working copy
+//   - Unchanged: Files skipped without reading them, by the stat cache
+//   - Recorded: Paths recorded with a new version (new or modified)
+//   - Renamed: Files found under a new path
+//   - Deleted: Paths that were gone from the working copy
+//   - Failed: Paths that could not be recorded
+type Result struct {
+	Scanned   int      `json:"scanned"`
+	Unchanged int      `json:"unchanged"`
+	Recorded  []string `json:"recorded"`
+	Renamed   []Rename `json:"renamed"`
+	Deleted   []string `json:"deleted"`
+	Failed    []string `json:"failed"`
+}
+
+
+type entry struct {
+	path     string
+	fileType filetype.FileType
+}
+
+
+// Instance rescans the working copy of an instance and records the
+// differences on its current branch.
+func Instance(ctx context.Context, instance *instanceDomain.Instance) (*Result, error) {
+	ctx = session.WithProjectID(ctx, instance.ProjectID)
+	ctx = session.WithBranchID(ctx, instance.BranchID)
+
+	filter  := filters.ForInstance(instance.Path)
+	entries := scan(instance.Path, filter)
+	result  := &Result{Scanned: len(entries), Recorded: []string{}, Renamed: []Rename{}, Deleted: []string{}, Failed: []string{}}
+
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		seen[entry.path] = true
+	}
+
+	cache, err := statcache.Load(ctx, instance.ID)
+	if err != nil {
+		return nil, err
+	}
+	live, err := fileDomain.GetCurrent(ctx, instance.BranchID)
+	if err != nil {
+		return nil, err
+	}
+	before  := make(map[string]*fileDomain.File, len(live))
+	deleted := make(map[string]string) // file ID -> path
+	for _, file := range live {
+		before[file.Path] = file
+		absPath := filepath.Join(instance.Path, file.Path)
+		if seen[file.Path] || filter.ShouldSkip(absPath, file.Type == filetype.DIR) {
+			continue
+		}
+		// only what is really gone; an unreadable directory is not a deletion
+		if _, err := os.Lstat(absPath); !errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err := fileService.MarkDeleted(ctx, file); err != nil {
+			return result, fmt.Errorf("failed to record deletion of %s: %w", file.Path, err)
+		}
+		deleted[file.ID] = file.Path
+	}
+
+	for _, entry := range entries {
+		absPath := filepath.Join(instance.Path, entry.path)
+
+		// stat before reading, so a write during the ingest shows next time
+		info, err := os.Lstat(absPath)
+		if err != nil {
+			log.Error("Failed to stat path during rescan", "path", entry.path, "error", err)
+			result.Failed = append(result.Failed, entry.path)
+			continue
+		}
+		if cache.Unchanged(entry.path, info, before[entry.path]) {
+			result.Unchanged++
+			continue
+		}
+
+		var file *fileDomain.File
+		switch entry.fileType {
+		case filetype.SYMLINK:
+			file, err = fileService.IngestSymlink(ctx, instance.Path, absPath)
+		case filetype.DIR:
+			file, err = fileService.IngestDir(ctx, instance.Path, absPath)
+		default:
+			file, err = fileService.Ingest(ctx, instance.Path, absPath)
+		}
+		if err != nil {
+			log.Error("Failed to record path during rescan", "path", entry.path, "error", err)
+			result.Failed = append(result.Failed, entry.path)
+			continue
+		}
+		cache.Record(entry.path, info, file)
+
+		if from, ok := deleted[file.ID]; ok {
+			result.Renamed = append(result.Renamed, Rename{From: from, To: entry.path})
+			delete(deleted, file.ID)
+			continue
+		}
+		if previous := before[entry.path]; previous == nil || previous.ChangeID != file.ChangeID {
+			result.Recorded = append(result.Recorded, entry.path)
+		}
+	}
+	cache.Prune(seen)
+	if err := cache.Save(ctx); err != nil {
+		log.Warn("Failed to update stat cache", "path", instance.Path, "error", err)
+	}
+
+	for _, path := range deleted {
+		result.Deleted = append(result.Deleted, path)
+	}
+	sort.Strings(result.Deleted)
+
+	log.Info("Rescanned instance", "path", instance.Path, "scanned", result.Scanned, "unchanged", result.Unchanged,
+		"recorded", len(result.Recorded), "renamed", len(result.Renamed), "deleted", len(result.Deleted))
+	return result, nil
+}
+
+
+var entryTypes = map[walk.EventType]filetype.FileType{
+	walk.FILE: filetype.FILE,
+	walk.SYM:  filetype.SYMLINK,
+	walk.DIR:  filetype.DIR,
+}
+
+
+// scan walks the working copy and returns the unfiltered files, symlinks
+// and directories in it, relative to root.
+func scan(root string, filter filters.FilterInterface) []entry {
+	eventChan := make(chan walk.Event, 100)
+	go walk.Stream(root, eventChan, filter)
+
+	var entries []entry
+	for event := range eventChan {
+		switch event.Type {
+		case walk.ERROR:
+			log.Error("Walk error", "error", event.Data)
+		case walk.FILE, walk.SYM, walk.DIR:
+			relPath, err := filepath.Rel(root, event.Data)
+			if err != nil {
+				log.Error("Failed to compute relative path", "path", event.Data, "error", err)
+				continue
+			}
+			if relPath == "." {
+				continue
+			}
+			entries = append(entries, entry{path: relPath, fileType: entryTypes[event.Type]})
+		}
+	}
+	return entries
+}
@@ -0,0 +1,94 @@
+package rescan
+
+import (
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	changeDomain "vcx/agent/internal/domains/change"
+	fileDomain "vcx/agent/internal/domains/file"
+	"vcx/agent/internal/services/restore"
+	"vcx/agent/internal/services/servicetest"
+	"vcx/agent/internal/session"
+)
+
+
+func TestInstanceTombstonesFilesGoneFromDisk(t *testing.T) {
+	project := servicetest.NewProject(t, servicetest.Context(t), map[string]string{
+		"gone.txt":  "deleted while unwatched\n",
+		"moved.txt": "moved while unwatched\n",
+		"kept.txt":  "left alone\n",
+	})
+	if err := os.Remove(project.Path("gone.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(project.Path("sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(project.Path("moved.txt"), project.Path("sub/moved.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Instance(project.Context(), project.Instance())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(result.Deleted, []string{"gone.txt"}) {
+		t.Errorf("got deleted %v, want gone.txt", result.Deleted)
+	}
+	if !slices.Equal(result.Renamed, []Rename{{From: "moved.txt", To: "sub/moved.txt"}}) {
+		t.Errorf("got renamed %v, want moved.txt to sub/moved.txt", result.Renamed)
+	}
+
+	ctx      := project.Context()
+	branchID := session.GetBranchID(ctx)
+	current, err := fileDomain.GetCurrent(ctx, branchID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range current {
+		if file.Path == "gone.txt" {
+			t.Error("deleted file still in the current tree")
+		}
+	}
+
+	// the tombstone records the deletion and keeps the last content
+	gone, err := fileDomain.GetByPath(ctx, branchID, "gone.txt")
+	if err != nil || !gone.IsDeleted {
+		t.Fatalf("gone.txt has no tombstone: %+v, %v", gone, err)
+	}
+	deletion, err := changeDomain.GetByID(ctx, gone.ChangeID)
+	if err != nil || !deletion.IsDeleted || deletion.ChangeIDPrev == "" {
+		t.Fatalf("deletion not recorded as a version: %+v, %v", deletion, err)
+	}
+	if _, err := restore.File(ctx, project.Instance(), "gone.txt", deletion.ChangeIDPrev); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := project.Read("gone.txt"); got != "deleted while unwatched\n" {
+		t.Errorf("restored gone.txt holds %q", got)
+	}
+}
+
+
+func TestInstanceSkipsUnchangedFiles(t *testing.T) {
+	project := servicetest.NewProject(t, servicetest.Context(t), map[string]string{"a.txt": "a\n", "b.txt": "b\n"})
+	old := time.Now().Add(-time.Hour)
+	for _, path := range []string{"a.txt", "b.txt"} {
+		if err := os.Chtimes(project.Path(path), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := Instance(project.Context(), project.Instance()); err != nil {
+		t.Fatal(err)
+	}
+
+	project.WriteFile("b.txt", "b, edited\n")
+	result, err := Instance(project.Context(), project.Instance())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Unchanged != 1 || !slices.Equal(result.Recorded, []string{"b.txt"}) {
+		t.Errorf("got %d unchanged, recorded %v; want a.txt skipped and b.txt recorded", result.Unchanged, result.Recorded)
+	}
+}
@@ -0,0 +1,149 @@
+package restore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"vcx/agent/internal/consts/filetype"
+	changeDomain "vcx/agent/internal/domains/change"
+	fileDomain "vcx/agent/internal/domains/file"
+	instanceDomain "vcx/agent/internal/domains/instance"
+	fileService "vcx/agent/internal/services/file"
+	"vcx/agent/internal/services/filters"
+)
+
+
+// SwitchOptions control how the working copy is switched to a branch.
+//
+// Fields:
+//   - DryRun: Only compute the plan, touch nothing
+//   - Force: Proceed even if the working copy has unrecorded modifications
+type SwitchOptions struct {
+	DryRun bool
+	Force  bool
+}
+
+
+// Branch rewrites the instance's working copy to the latest state of a
+// branch and makes it the instance's branch. Files tracked on the current
+// branch but not on the other one are deleted, untracked and ignored paths
+// are left alone. Nothing is recorded: the branch already holds every
+// version written. Instance.BranchID is updated before the working copy is
+// touched, so the file monitor records the writes on the new branch, where
+// they match what is recorded.
+func Branch(ctx context.Context, instance *instanceDomain.Instance, branchID string, options SwitchOptions) (*Plan, error) {
+	current, err := fileDomain.GetByBranch(ctx, instance.BranchID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := fileDomain.GetCurrent(ctx, branchID)
+	if err != nil {
+		return nil, err
+	}
+
+	filter   := filters.ForInstance(instance.Path)
+	plan     := &Plan{Actions: []Action{}, Modified: []string{}}
+	states   := make(map[string]*changeDomain.Change)
+	tracked  := make(map[string]bool)
+	incoming := make(map[string]*fileDomain.File)
+	dirs     := make(map[string]uint32)
+	for _, file := range target {
+		if filter.ShouldSkip(filepath.Join(instance.Path, file.Path), file.Type == filetype.DIR) {
+			continue
+		}
+		incoming[file.Path] = file
+		if file.Type == filetype.DIR {
+			dirs[file.Path] = file.Mode
+		}
+	}
+
+	for _, file := range current {
+		if filter.ShouldSkip(filepath.Join(instance.Path, file.Path), file.Type == filetype.DIR) {
+			continue
+		}
+		tracked[file.Path] = true
+
+		matches, err := fileService.MatchesWorkingCopy(instance.Path, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect %s: %w", file.Path, err)
+		}
+		if !matches {
+			plan.Modified = append(plan.Modified, file.Path)
+		}
+		if _, ok := incoming[file.Path]; ok {
+			continue
+		}
+		if _, err := os.Lstat(filepath.Join(instance.Path, file.Path)); err == nil {
+			plan.Actions = append(plan.Actions, Action{Op: OP_DELETE, Path: file.Path})
+		}
+	}
+
+	for path, file := range incoming {
+		if file.ChangeID > plan.ChangeID {
+			plan.ChangeID = file.ChangeID
+		}
+		matches, err := fileService.MatchesWorkingCopy(instance.Path, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect %s: %w", path, err)
+		}
+		if matches {
+			continue
+		}
+		// an untracked file in the way would be lost
+		if !tracked[path] {
+			if _, err := os.Lstat(filepath.Join(instance.Path, path)); err == nil {
+				plan.Modified = append(plan.Modified, path)
+			}
+		}
+
+		state, err := changeDomain.GetByID(ctx, file.ChangeID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load version of %s: %w", path, err)
+		}
+		states[path] = state
+		plan.Actions = append(plan.Actions, Action{Op: OP_WRITE, Path: path, ChangeID: state.ID, Target: state.Target})
+	}
+	sort.Slice(plan.Actions, func(i, j int) bool { return plan.Actions[i].Path < plan.Actions[j].Path })
+	sort.Strings(plan.Modified)
+
+	if err := CheckActions(instance.Path, plan.Actions, states); err != nil {
+		return plan, err
+	}
+	if len(plan.Modified) > 0 && !options.Force {
+		return plan, fmt.Errorf("%w: %d path(s), use force to overwrite", ErrLocalModifications, len(plan.Modified))
+	}
+	if options.DryRun {
+		return plan, nil
+	}
+
+	instance.BranchID = branchID
+	instance.ChangeID = plan.ChangeID
+	if err := instance.Update(ctx); err != nil {
+		return plan, fmt.Errorf("failed to update instance: %w", err)
+	}
+
+	for _, action := range plan.Actions {
+		absPath := filepath.Join(instance.Path, action.Path)
+		switch action.Op {
+		case OP_DELETE:
+			if err := RemoveEntry(instance.Path, absPath); err != nil {
+				return plan, fmt.Errorf("failed to remove %s: %w", action.Path, err)
+			}
+			RemoveEmptyParents(instance.Path, filepath.Dir(absPath))
+		case OP_WRITE:
+			if _, err := WriteVersion(ctx, instance.Path, absPath, states[action.Path]); err != nil {
+				return plan, err
+			}
+		}
+	}
+	if err := KeepDirs(instance.Path, dirs); err != nil {
+		return plan, err
+	}
+	plan.Applied = true
+
+	log.Info("Switched branch", "path", instance.Path, "branchID", branchID, "actions", len(plan.Actions))
+	return plan, nil
+}
@@ -0,0 +1,147 @@
+package restore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"vcx/agent/internal/consts/filetype"
+	"vcx/agent/internal/consts/linktype"
+	changeDomain "vcx/agent/internal/domains/change"
+	fileService "vcx/agent/internal/services/file"
+	"vcx/pkg/toolkit/pathkit"
+)
+
+
+const MAX_LINK_HOPS = 40 // links followed in a row before a path is refused as a loop
+
+
+var ErrUnsafePath = errors.New("path leads outside the instance")
+
+
+// CheckPath refuses to touch absPath unless it lies below root once the
+// symlinks on the way to it are followed. The entry itself is not followed:
+// writes replace it and removals remove it, even when it is a link.
+// Links that cannot be resolved, such as loops, are refused too.
+func CheckPath(root, absPath string) error {
+	if !pathkit.Within(root, absPath) || filepath.Clean(absPath) == filepath.Clean(root) {
+		return fmt.Errorf("%w: %s", ErrUnsafePath, absPath)
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", root, err)
+	}
+
+	// the deepest parent that exists decides where the rest gets created
+	parent := filepath.Dir(absPath)
+	for parent != root && pathkit.Within(root, parent) {
+		if _, err := os.Lstat(parent); !errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		parent = filepath.Dir(parent)
+	}
+	real, err := filepath.EvalSymlinks(parent)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrUnsafePath, absPath, err)
+	}
+	if !pathkit.Within(realRoot, real) {
+		return fmt.Errorf("%w: %s resolves to %s", ErrUnsafePath, absPath, filepath.Join(real, filepath.Base(absPath)))
+	}
+	return nil
+}
+
+
+// CheckActions checks every path a plan touches before any of it is
+// applied, so a history that leads outside the instance is refused as a
+// whole. Besides the links already in the working copy this covers the
+// links the plan itself writes, followed from one to the next: nothing may
+// go below one that points outside the instance.
+func CheckActions(root string, actions []Action, states map[string]*changeDomain.Change) error {
+	links := make(map[string]string)
+	for _, action := range actions {
+		state := states[action.Path]
+		if action.Op == OP_DELETE || state == nil || state.Type() != filetype.SYMLINK {
+			continue
+		}
+		links[action.Path] = state.Target
+	}
+
+	for _, action := range actions {
+		for _, path := range []string{action.Path, action.From} {
+			if path == "" {
+				continue
+			}
+			if err := CheckPath(root, filepath.Join(root, path)); err != nil {
+				return err
+			}
+			resolved, err := plannedPath(root, path, links)
+			if err != nil {
+				return err
+			}
+			if resolved != path {
+				if err := CheckPath(root, filepath.Join(root, resolved)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+
+// plannedPath follows the planned links among the parents of path, which
+// is relative to root, and returns the path it leads to. Like CheckPath it
+// refuses a link that points outside the instance and, as the kernel does,
+// more than MAX_LINK_HOPS links in a row, so loops end.
+func plannedPath(root, path string, links map[string]string) (string, error) {
+	for hops := 0; ; hops++ {
+		dir, target := plannedParent(path, links)
+		if dir == "" {
+			return path, nil
+		}
+		if hops == MAX_LINK_HOPS {
+			return "", fmt.Errorf("%w: %s: too many levels of planned links", ErrUnsafePath, path)
+		}
+		linkPath := filepath.Join(root, dir)
+		if fileService.ClassifyLink(root, linkPath, target) == linktype.EXTERNAL {
+			return "", fmt.Errorf("%w: %s is below %s, a link to %s", ErrUnsafePath, path, dir, target)
+		}
+		next, err := relativeTarget(root, linkPath, target)
+		if err != nil {
+			return "", fmt.Errorf("%w: %s: %v", ErrUnsafePath, path, err)
+		}
+		rest, _ := filepath.Rel(dir, path)
+		path = filepath.Join(next, rest)
+	}
+}
+
+
+// plannedParent returns the outermost parent of path that the plan writes
+// as a link, and its target.
+func plannedParent(path string, links map[string]string) (string, string) {
+	parts := strings.Split(path, string(filepath.Separator))
+	for i := 1; i < len(parts); i++ {
+		dir := filepath.Join(parts[:i]...)
+		if target, ok := links[dir]; ok {
+			return dir, target
+		}
+	}
+	return "", ""
+}
+
+
+// relativeTarget names the place an internal link points at relative to
+// root. Absolute targets may name the instance through its resolved path.
+func relativeTarget(root, linkPath, target string) (string, error) {
+	if !filepath.IsAbs(target) {
+		return filepath.Rel(root, filepath.Join(filepath.Dir(linkPath), target))
+	}
+	if !pathkit.Within(root, target) {
+		if real, err := filepath.EvalSymlinks(root); err == nil {
+			root = real
+		}
+	}
+	return filepath.Rel(root, target)
+}
@@ -0,0 +1,129 @@
+package restore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"vcx/agent/internal/consts/filetype"
+	changeDomain "vcx/agent/internal/domains/change"
+)
+
+
+func TestCheckPath(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	outside := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "d"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for link, target := range map[string]string{
+		"out":  outside,        // parent symlink pointing outside
+		"up":   "..",           // relative climb out of the root
+		"in":   "d",            // link to a directory inside
+		"loop": "loop",         // link to itself
+	} {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		name string
+		path string
+		safe bool
+	}{
+		{"plain file", "f", true},
+		{"below a directory", "d/f", true},
+		{"missing intermediate directory", "d/missing/deeper/f", true},
+		{"below a link inside", "in/f", true},
+		{"link itself", "out", true},
+		{"below a link outside", "out/f", false},
+		{"missing directory below a link outside", "out/missing/f", false},
+		{"below a relative link out", "up/f", false},
+		{"below a link loop", "loop/f", false},
+		{"lexically outside", "../f", false},
+		{"root itself", ".", false},
+	}
+	for _, c := range cases {
+		err := CheckPath(root, filepath.Join(root, c.path))
+		if c.safe && err != nil {
+			t.Errorf("%s: %s refused: %v", c.name, c.path, err)
+		}
+		if !c.safe && !errors.Is(err, ErrUnsafePath) {
+			t.Errorf("%s: %s allowed, got %v", c.name, c.path, err)
+		}
+	}
+}
+
+
+func TestCheckActionsRefusesPathsBelowPlannedLinks(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	link := func(target string) *changeDomain.Change {
+		return &changeDomain.Change{FileType: filetype.SYMLINK, Target: target}
+	}
+	states := map[string]*changeDomain.Change{
+		"out":   link(t.TempDir()),
+		"in":    link("d"),
+		"out/x": {FileType: filetype.FILE, BlobID: "b"},
+		"in/x":  {FileType: filetype.FILE, BlobID: "b"},
+	}
+	write := func(path string) Action { return Action{Op: OP_WRITE, Path: path} }
+
+	if err := CheckActions(root, []Action{write("in"), write("in/x")}, states); err != nil {
+		t.Errorf("write below a planned link inside refused: %v", err)
+	}
+	if err := CheckActions(root, []Action{write("out")}, states); err != nil {
+		t.Errorf("planned link outside refused on its own: %v", err)
+	}
+	if err := CheckActions(root, []Action{write("out"), write("out/x")}, states); !errors.Is(err, ErrUnsafePath) {
+		t.Errorf("write below a planned link outside allowed, got %v", err)
+	}
+	rename := Action{Op: OP_RENAME, Path: "in/x", From: "out/x"}
+	if err := CheckActions(root, []Action{write("out"), rename}, states); !errors.Is(err, ErrUnsafePath) {
+		t.Errorf("rename from below a planned link outside allowed, got %v", err)
+	}
+}
+
+
+func TestCheckActionsFollowsChainsOfPlannedLinks(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	link := func(target string) *changeDomain.Change {
+		return &changeDomain.Change{FileType: filetype.SYMLINK, Target: target}
+	}
+	states := map[string]*changeDomain.Change{
+		"a":   link("b"),                      // inside, but b leads out
+		"b":   link(t.TempDir()),
+		"c":   link(filepath.Join(root, "d")), // inside through d
+		"d":   link("e"),
+		"e":   {FileType: filetype.DIR},
+		"p":   link("q"),                      // a loop
+		"q":   link("p"),
+		"a/x": {FileType: filetype.FILE, BlobID: "b"},
+		"c/x": {FileType: filetype.FILE, BlobID: "b"},
+		"p/x": {FileType: filetype.FILE, BlobID: "b"},
+	}
+	write := func(path string) Action { return Action{Op: OP_WRITE, Path: path} }
+
+	if err := CheckActions(root, []Action{write("a"), write("b"), write("a/x")}, states); !errors.Is(err, ErrUnsafePath) {
+		t.Errorf("write through a chain leading outside allowed, got %v", err)
+	}
+	if err := CheckActions(root, []Action{write("a"), write("a/x")}, states); err != nil {
+		t.Errorf("write through a link to a plain path refused: %v", err)
+	}
+	if err := CheckActions(root, []Action{write("c"), write("d"), write("e"), write("c/x")}, states); err != nil {
+		t.Errorf("write through a chain inside refused: %v", err)
+	}
+	if err := CheckActions(root, []Action{write("p"), write("q"), write("p/x")}, states); !errors.Is(err, ErrUnsafePath) {
+		t.Errorf("write through a loop of planned links allowed, got %v", err)
+	}
+}
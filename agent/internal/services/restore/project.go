@@ -0,0 +1,326 @@
+package restore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"vcx/agent/internal/consts/filetype"
+	changeDomain "vcx/agent/internal/domains/change"
+	fileDomain "vcx/agent/internal/domains/file"
+	instanceDomain "vcx/agent/internal/domains/instance"
+	changeService "vcx/agent/internal/services/change"
+	fileService "vcx/agent/internal/services/file"
+	"vcx/agent/internal/services/filters"
+	"vcx/agent/internal/session"
+	"vcx/pkg/toolkit/filekit"
+)
+
+
+const (
+	OP_WRITE  = "write"
+	OP_DELETE = "delete"
+	OP_RENAME = "rename"
+)
+
+
+var ErrLocalModifications = errors.New("working copy has unrecorded modifications")
+
+
+// ProjectOptions selects the point to roll back to and how.
+//
+// Fields:
+//   - ChangeID: Restore the state right after this change
+//   - At: Restore the state at this time (used when ChangeID is empty)
+//   - DryRun: Only compute the plan, touch nothing
+//   - Force: Proceed even if the working copy has unrecorded modifications
+type ProjectOptions struct {
+	ChangeID string
+	At       time.Time
+	DryRun   bool
+	Force    bool
+}
+
+
+// Action is one planned change to the working copy. A rename writes Path
+// and removes From.
+type Action struct {
+	Op       string `json:"op"`
+	Path     string `json:"path"`
+	From     string `json:"from,omitempty"`
+	ChangeID string `json:"changeID,omitempty"`
+	Target   string `json:"target,omitempty"`
+}
+
+
+// Plan describes a project restore. Modified lists paths whose working copy
+// differs from what is recorded; they block the restore unless forced.
+type Plan struct {
+	ChangeID string   `json:"changeID"`
+	Actions  []Action `json:"actions"`
+	Modified []string `json:"modified"`
+	Applied  bool     `json:"applied"`
+}
+
+
+// Project rolls the instance's working copy back to the state it had at a
+// change or a point in time. Files are rewritten or recreated (including
+// symlinks and directories), files created later are deleted, renamed files
+// are moved back where they were, and ignored paths are left alone. Every
+// write and delete is recorded as a new change, so the rollback itself can
+// be undone. Unless DryRun is set, the instance is marked as rewound by
+// pointing Instance.ChangeID at the restored point.
+func Project(ctx context.Context, instance *instanceDomain.Instance, options ProjectOptions) (*Plan, error) {
+	ctx = session.WithProjectID(ctx, instance.ProjectID)
+	ctx = session.WithBranchID(ctx, instance.BranchID)
+
+	recordedBy, err := cutoff(ctx, instance, options)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := fileDomain.GetByBranch(ctx, instance.BranchID)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+
+	filter   := filters.ForInstance(instance.Path)
+	plan     := &Plan{ChangeID: options.ChangeID, Actions: []Action{}, Modified: []string{}}
+	states   := make(map[string]*changeDomain.Change)
+	dirs     := make(map[string]uint32) // directories of the restored state -> mode
+	occupied := make(map[string]bool, len(files))
+	for _, file := range files {
+		occupied[file.Path] = true
+	}
+
+	for _, file := range files {
+		if filter.ShouldSkip(filepath.Join(instance.Path, file.Path), file.Type == filetype.DIR) {
+			continue
+		}
+
+		matches, err := fileService.MatchesWorkingCopy(instance.Path, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect %s: %w", file.Path, err)
+		}
+		if !matches {
+			plan.Modified = append(plan.Modified, file.Path)
+		}
+
+		state, err := stateAt(ctx, file, recordedBy)
+		if err != nil {
+			return nil, err
+		}
+		if state != nil && state.ID > plan.ChangeID {
+			plan.ChangeID = state.ID
+		}
+		if state != nil && !state.IsDeleted && state.Type() == filetype.DIR {
+			dirs[state.Path] = state.Mode
+		}
+
+		if action, ok := planFile(instance.Path, file, state, matches, occupied); ok {
+			plan.Actions = append(plan.Actions, action)
+			states[action.Path] = state
+		}
+	}
+
+	if err := CheckActions(instance.Path, plan.Actions, states); err != nil {
+		return plan, err
+	}
+	if len(plan.Modified) > 0 && !options.Force {
+		return plan, fmt.Errorf("%w: %d path(s), use force to overwrite", ErrLocalModifications, len(plan.Modified))
+	}
+	if options.DryRun {
+		return plan, nil
+	}
+
+	for _, action := range plan.Actions {
+		if err := apply(ctx, instance, action, states[action.Path]); err != nil {
+			return plan, err
+		}
+	}
+	if err := KeepDirs(instance.Path, dirs); err != nil {
+		return plan, err
+	}
+
+	instance.ChangeID = plan.ChangeID
+	if err := instance.Update(ctx); err != nil {
+		return plan, fmt.Errorf("restored files but failed to update instance: %w", err)
+	}
+	plan.Applied = true
+
+	log.Info("Restored project", "path", instance.Path, "changeID", plan.ChangeID, "actions", len(plan.Actions))
+	return plan, nil
+}
+
+
+// cutoff returns a predicate telling whether a change was recorded at or
+// before the requested point. Change IDs are UUIDv7 and therefore sort by
+// creation time, which is finer grained than creation_date.
+func cutoff(ctx context.Context, instance *instanceDomain.Instance, options ProjectOptions) (func(*changeDomain.Change) bool, error) {
+	if options.ChangeID != "" {
+		target, err := changeDomain.GetByID(ctx, options.ChangeID)
+		if err != nil || target.ProjectID != instance.ProjectID {
+			return nil, fmt.Errorf("%w: %s", ErrChangeNotFound, options.ChangeID)
+		}
+		return func(change *changeDomain.Change) bool {
+			return change.ID <= target.ID
+		}, nil
+	}
+
+	if options.At.IsZero() {
+		return nil, fmt.Errorf("a change or a point in time is required")
+	}
+	return func(change *changeDomain.Change) bool {
+		recorded, err := time.Parse(time.RFC3339, change.CreationDate)
+		return err == nil && !recorded.After(options.At)
+	}, nil
+}
+
+
+// stateAt returns the file's version at the cutoff, nil if the file did not
+// exist yet.
+func stateAt(ctx context.Context, file *fileDomain.File, recordedBy func(*changeDomain.Change) bool) (*changeDomain.Change, error) {
+	state, err := changeService.StateAt(ctx, file.ChangeID, recordedBy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load version of %s: %w", file.Path, err)
+	}
+	return state, nil
+}
+
+
+// planFile decides what has to happen to one path to reach state. A file
+// recorded under another path at that point is moved back there, unless
+// another file took that path since.
+func planFile(projectPath string, file *fileDomain.File, state *changeDomain.Change, matchesRecorded bool, occupied map[string]bool) (Action, bool) {
+	absent := state == nil || state.IsDeleted
+
+	if absent {
+		if _, err := os.Lstat(filepath.Join(projectPath, file.Path)); err != nil {
+			return Action{}, false
+		}
+		action := Action{Op: OP_DELETE, Path: file.Path}
+		if state != nil {
+			action.ChangeID = state.ID
+		}
+		return action, true
+	}
+
+	if state.Path != "" && state.Path != file.Path && !occupied[state.Path] {
+		return Action{Op: OP_RENAME, Path: state.Path, From: file.Path, ChangeID: state.ID, Target: state.Target}, true
+	}
+
+	// nothing to do when the working copy is already the recorded version
+	if matchesRecorded && !file.IsDeleted && state.BlobID == file.BlobID && state.Target == file.Target &&
+	   (state.Mode == 0 || state.Mode == file.Mode) {
+		return Action{}, false
+	}
+	return Action{Op: OP_WRITE, Path: file.Path, ChangeID: state.ID, Target: state.Target}, true
+}
+
+
+func apply(ctx context.Context, instance *instanceDomain.Instance, action Action, state *changeDomain.Change) error {
+	absPath := filepath.Join(instance.Path, action.Path)
+
+	recordedPath := action.Path
+	if action.Op == OP_RENAME {
+		recordedPath = action.From
+	}
+	file, err := fileDomain.GetByPath(ctx, instance.BranchID, recordedPath)
+	if err != nil {
+		return fmt.Errorf("failed to load %s: %w", recordedPath, err)
+	}
+
+	switch action.Op {
+	case OP_DELETE:
+		if err := RemoveEntry(instance.Path, absPath); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", action.Path, err)
+		}
+		RemoveEmptyParents(instance.Path, filepath.Dir(absPath))
+		if file.IsDeleted {
+			return nil
+		}
+		return fileService.MarkDeleted(ctx, file)
+
+	case OP_WRITE:
+		fileType, err := WriteVersion(ctx, instance.Path, absPath, state)
+		if err != nil {
+			return err
+		}
+		summary := fmt.Sprintf("restored from %s", state.ID)
+		_, err = fileService.RecordVersion(ctx, action.Path, fileType, state.BlobID, state.Target, state.Attributes(), summary)
+		return err
+
+	case OP_RENAME:
+		fileType, err := WriteVersion(ctx, instance.Path, absPath, state)
+		if err != nil {
+			return err
+		}
+		fromPath := filepath.Join(instance.Path, action.From)
+		if err := RemoveEntry(instance.Path, fromPath); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", action.From, err)
+		}
+		RemoveEmptyParents(instance.Path, filepath.Dir(fromPath))
+		_, err = fileService.RecordRename(ctx, file, action.Path, fileType, state.BlobID, state.Target, state.Attributes())
+		return err
+	}
+	return fmt.Errorf("unknown restore action %q", action.Op)
+}
+
+
+// RemoveEmptyParents removes dir and its parents up to (excluding) root as
+// long as they are empty.
+func RemoveEmptyParents(root, dir string) {
+	for dir != root && len(dir) > len(root) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+
+// RemoveEntry removes the working copy of a path. A directory that is not
+// empty is left in place: what remains in it is untracked or ignored, or
+// goes with a later action, whose RemoveEmptyParents then takes the
+// directory along. Paths that lead outside root are refused with
+// ErrUnsafePath.
+func RemoveEntry(root, absPath string) error {
+	if err := CheckPath(root, absPath); err != nil {
+		return err
+	}
+	err := os.Remove(absPath)
+	if err == nil || errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if entries, readErr := os.ReadDir(absPath); readErr == nil && len(entries) > 0 {
+		return nil
+	}
+	return err
+}
+
+
+// KeepDirs recreates the recorded directories (relative path -> mode) that
+// RemoveEmptyParents took away along with the last file in them.
+func KeepDirs(root string, dirs map[string]uint32) error {
+	for relPath, mode := range dirs {
+		absPath := filepath.Join(root, relPath)
+		if _, err := os.Lstat(absPath); !errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err := CheckPath(root, absPath); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(absPath, DEFAULT_DIR_MODE); err != nil {
+			return fmt.Errorf("failed to recreate directory %s: %w", relPath, err)
+		}
+		if err := (filekit.Attributes{Mode: mode}).Apply(absPath); err != nil {
+			return fmt.Errorf("failed to set attributes of %s: %w", relPath, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package restore_test
+
+import (
+	"errors"
+	"testing"
+
+	fileDomain "vcx/agent/internal/domains/file"
+	"vcx/agent/internal/services/restore"
+	"vcx/agent/internal/services/servicetest"
+	"vcx/agent/internal/session"
+)
+
+
+// currentChange returns the ID of the version relPath is recorded at.
+func currentChange(t *testing.T, project *servicetest.Project, relPath string) string {
+	t.Helper()
+	ctx := project.Context()
+	file, err := fileDomain.GetByPath(ctx, session.GetBranchID(ctx), relPath)
+	if err != nil {
+		t.Fatalf("%s not recorded: %v", relPath, err)
+	}
+	return file.ChangeID
+}
+
+
+func TestProjectRollsBackToChange(t *testing.T) {
+	project := servicetest.NewProject(t, servicetest.Context(t), map[string]string{
+		"a.txt": "a, first\n",
+		"b.txt": "b\n",
+	})
+	project.Write("a.txt", "a, second\n")
+	point := currentChange(t, project, "a.txt")
+
+	project.Write("a.txt", "a, third\n")
+	project.Remove("b.txt")
+	project.Write("sub/c.txt", "created later\n")
+	want := map[string]string{"a.txt": "a, second\n", "b.txt": "b\n"}
+
+	plan, err := restore.Project(project.Context(), project.Instance(), restore.ProjectOptions{ChangeID: point, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Applied || len(plan.Actions) != 3 {
+		t.Errorf("dry run planned %+v, want 3 actions not applied", plan.Actions)
+	}
+	if got, _ := project.Read("a.txt"); got != "a, third\n" {
+		t.Errorf("dry run rewrote a.txt to %q", got)
+	}
+
+	plan, err = restore.Project(project.Context(), project.Instance(), restore.ProjectOptions{ChangeID: point})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.Applied || plan.ChangeID != point {
+		t.Errorf("got plan at %s applied %v, want %s applied", plan.ChangeID, plan.Applied, point)
+	}
+	for path, content := range want {
+		if got, ok := project.Read(path); !ok || got != content {
+			t.Errorf("%s holds %q (exists %v), want %q", path, got, ok, content)
+		}
+	}
+	if _, ok := project.Read("sub/c.txt"); ok {
+		t.Error("file created after the point left in the working copy")
+	}
+	if project.Instance().ChangeID != point {
+		t.Errorf("instance rewound to %s, want %s", project.Instance().ChangeID, point)
+	}
+
+	// the rollback is recorded, so a second one finds nothing to do
+	if currentChange(t, project, "a.txt") <= point {
+		t.Error("restored a.txt not recorded as a new version")
+	}
+	plan, err = restore.Project(project.Context(), project.Instance(), restore.ProjectOptions{ChangeID: point})
+	if err != nil || len(plan.Actions) != 0 {
+		t.Errorf("second rollback planned %+v, %v; want nothing", plan, err)
+	}
+}
+
+
+func TestProjectRefusesLocalModifications(t *testing.T) {
+	project := servicetest.NewProject(t, servicetest.Context(t), map[string]string{"a.txt": "a, first\n"})
+	point := currentChange(t, project, "a.txt")
+	project.Write("a.txt", "a, second\n")
+	project.WriteFile("a.txt", "not recorded yet\n")
+
+	plan, err := restore.Project(project.Context(), project.Instance(), restore.ProjectOptions{ChangeID: point})
+	if !errors.Is(err, restore.ErrLocalModifications) {
+		t.Fatalf("got %v, want %v", err, restore.ErrLocalModifications)
+	}
+	if len(plan.Modified) != 1 || plan.Modified[0] != "a.txt" || plan.Applied {
+		t.Errorf("got modified %v applied %v, want a.txt not applied", plan.Modified, plan.Applied)
+	}
+	if got, _ := project.Read("a.txt"); got != "not recorded yet\n" {
+		t.Errorf("refused rollback touched a.txt: %q", got)
+	}
+
+	if _, err := restore.Project(project.Context(), project.Instance(), restore.ProjectOptions{ChangeID: point, Force: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := project.Read("a.txt"); got != "a, first\n" {
+		t.Errorf("forced rollback left a.txt as %q", got)
+	}
+}
+
+
+func TestProjectRejectsUnknownChange(t *testing.T) {
+	project := servicetest.NewProject(t, servicetest.Context(t), map[string]string{"a.txt": "a\n"})
+	_, err := restore.Project(project.Context(), project.Instance(), restore.ProjectOptions{ChangeID: "no-such-change"})
+	if !errors.Is(err, restore.ErrChangeNotFound) {
+		t.Errorf("got %v, want %v", err, restore.ErrChangeNotFound)
+	}
+}
@@ -0,0 +1,174 @@
+// Package restore brings recorded versions back into a working copy.
+//
+// Content is read from the blob store (DB or FilePath, decompressed) and
+// written atomically, so an interrupted restore never leaves a partial file.
+// The recorded mode and mtime are reapplied, and the owner while owner
+// tracking is on. Symlinks are recreated with their recorded target, but
+// nothing is written or removed through a link that leads outside the
+// instance (see CheckPath).
+// Every restore is itself recorded as a new change on the file's chain,
+// which means it can be undone by restoring the version before it.
+package restore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"vcx/agent/internal/consts/filetype"
+	changeDomain "vcx/agent/internal/domains/change"
+	fileDomain "vcx/agent/internal/domains/file"
+	instanceDomain "vcx/agent/internal/domains/instance"
+	blobService "vcx/agent/internal/services/blob"
+	changeService "vcx/agent/internal/services/change"
+	fileService "vcx/agent/internal/services/file"
+	"vcx/agent/internal/session"
+	"vcx/pkg/logging"
+	"vcx/pkg/toolkit/filekit"
+)
+
+
+var log = logging.GetLogger()
+
+
+const (
+	DEFAULT_FILE_MODE = 0644
+	DEFAULT_DIR_MODE  = 0755
+)
+
+
+var (
+	ErrChangeNotFound = errors.New("change not found")
+	ErrWrongFile      = errors.New("change does not belong to this file")
+)
+
+
+// File restores relPath in the instance to the version recorded by changeID
+// and records the result. Restoring to a deletion removes the working copy.
+func File(ctx context.Context, instance *instanceDomain.Instance, relPath, changeID string) (*fileDomain.File, error) {
+	ctx = session.WithProjectID(ctx, instance.ProjectID)
+	ctx = session.WithBranchID(ctx, instance.BranchID)
+
+	change, err := changeDomain.GetByID(ctx, changeID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrChangeNotFound, changeID)
+	}
+	file, err := fileDomain.GetByPath(ctx, instance.BranchID, relPath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s is not a version of %s", ErrWrongFile, changeID, relPath)
+	}
+	// versions from before the branch was created belong to the parent's record
+	if change.FileID != file.ID {
+		inherited, err := changeService.InChain(ctx, file.ChangeID, change.ID)
+		if err != nil || !inherited {
+			return nil, fmt.Errorf("%w: %s is not a version of %s", ErrWrongFile, changeID, relPath)
+		}
+	}
+
+	absPath := filepath.Join(instance.Path, relPath)
+	summary := fmt.Sprintf("restored from %s", change.ID)
+
+	if change.IsDeleted {
+		if err := RemoveEntry(instance.Path, absPath); err != nil {
+			return nil, fmt.Errorf("failed to remove %s: %w", relPath, err)
+		}
+		if file.IsDeleted {
+			return file, nil
+		}
+		if err := fileService.MarkDeleted(ctx, file); err != nil {
+			return nil, err
+		}
+		log.Info("Restored deletion", "path", relPath, "changeID", change.ID)
+		return file, nil
+	}
+
+	fileType, err := WriteVersion(ctx, instance.Path, absPath, change)
+	if err != nil {
+		return nil, err
+	}
+
+	restored, err := fileService.RecordVersion(ctx, relPath, fileType, change.BlobID, change.Target, change.Attributes(), summary)
+	if err != nil {
+		return nil, fmt.Errorf("restored %s but failed to record it: %w", relPath, err)
+	}
+
+	log.Info("Restored file", "path", relPath, "from", change.ID, "changeID", restored.ChangeID)
+	return restored, nil
+}
+
+
+// WriteVersion writes the content, symlink or directory recorded by change
+// to absPath, creating parent directories as needed, and reports what it
+// wrote. Files and directories get the recorded attributes; versions
+// recorded without a mode keep the permissions of the file being replaced.
+// An owner that cannot be set is logged and skipped. Paths that lead
+// outside root are refused with ErrUnsafePath.
+func WriteVersion(ctx context.Context, root, absPath string, change *changeDomain.Change) (filetype.FileType, error) {
+	if err := CheckPath(root, absPath); err != nil {
+		return filetype.INVALID, err
+	}
+	if err := os.MkdirAll(filepath.Dir(absPath), DEFAULT_DIR_MODE); err != nil {
+		return filetype.INVALID, fmt.Errorf("failed to create parent directory: %w", err)
+	}
+
+	switch change.Type() {
+	case filetype.SYMLINK:
+		if err := filekit.SymlinkAtomic(change.Target, absPath); err != nil {
+			return filetype.INVALID, fmt.Errorf("failed to restore symlink %s: %w", absPath, err)
+		}
+		return filetype.SYMLINK, nil
+	case filetype.DIR:
+		// a link in its place would carry the attributes to where it points
+		if info, err := os.Lstat(absPath); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			if err := os.Remove(absPath); err != nil {
+				return filetype.INVALID, fmt.Errorf("failed to replace symlink %s: %w", absPath, err)
+			}
+		}
+		if err := os.Mkdir(absPath, DEFAULT_DIR_MODE); err != nil && !errors.Is(err, os.ErrExist) {
+			return filetype.INVALID, fmt.Errorf("failed to restore directory %s: %w", absPath, err)
+		}
+		if err := applyAttributes(ctx, absPath, change.Attributes()); err != nil {
+			return filetype.INVALID, err
+		}
+		return filetype.DIR, nil
+	}
+
+	content, err := blobService.Open(ctx, change.BlobID)
+	if err != nil {
+		return filetype.INVALID, err
+	}
+	defer content.Close()
+
+	attrs := change.Attributes()
+	mode  := attrs.FileMode()
+	if attrs.Mode == 0 {
+		// keep the permissions of the file being replaced
+		mode = os.FileMode(DEFAULT_FILE_MODE)
+		if info, err := os.Lstat(absPath); err == nil && info.Mode().IsRegular() {
+			mode = info.Mode().Perm()
+		}
+	}
+	if err := filekit.WriteReaderAtomic(absPath, content, mode); err != nil {
+		return filetype.INVALID, fmt.Errorf("failed to write %s: %w", absPath, err)
+	}
+
+	if err := applyAttributes(ctx, absPath, attrs); err != nil {
+		return filetype.INVALID, err
+	}
+	return filetype.FILE, nil
+}
+
+
+func applyAttributes(ctx context.Context, absPath string, attrs filekit.Attributes) error {
+	if fileService.OwnerTrackingEnabled(ctx) {
+		if err := attrs.Chown(absPath); err != nil {
+			log.Warn("Cannot restore owner", "path", absPath, "owner", attrs.Owner, "error", err)
+		}
+	}
+	if err := attrs.Apply(absPath); err != nil {
+		return fmt.Errorf("failed to set attributes of %s: %w", absPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package restore_test
+
+import (
+	"errors"
+	"testing"
+
+	changeDomain "vcx/agent/internal/domains/change"
+	fileDomain "vcx/agent/internal/domains/file"
+	"vcx/agent/internal/services/restore"
+	"vcx/agent/internal/services/servicetest"
+	"vcx/agent/internal/session"
+)
+
+
+func TestFileRestoresVersionAsNewChange(t *testing.T) {
+	project := servicetest.NewProject(t, servicetest.Context(t), map[string]string{"a.txt": "first\n"})
+	first := currentChange(t, project, "a.txt")
+	project.Write("a.txt", "second\n")
+	second := currentChange(t, project, "a.txt")
+
+	restored, err := restore.File(project.Context(), project.Instance(), "a.txt", first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := project.Read("a.txt"); got != "first\n" {
+		t.Errorf("a.txt holds %q, want the first version", got)
+	}
+	change, err := changeDomain.GetByID(project.Context(), restored.ChangeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if change.ChangeIDPrev != second {
+		t.Errorf("restore recorded after %s, want after %s", change.ChangeIDPrev, second)
+	}
+
+	// and can itself be undone
+	if _, err := restore.File(project.Context(), project.Instance(), "a.txt", second); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := project.Read("a.txt"); got != "second\n" {
+		t.Errorf("a.txt holds %q after undoing the restore", got)
+	}
+}
+
+
+func TestFileRestoresDeletion(t *testing.T) {
+	project := servicetest.NewProject(t, servicetest.Context(t), map[string]string{"a.txt": "a\n"})
+	live := currentChange(t, project, "a.txt")
+	project.Remove("a.txt")
+	deletion := currentChange(t, project, "a.txt")
+
+	if _, err := restore.File(project.Context(), project.Instance(), "a.txt", live); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := project.Read("a.txt"); !ok || got != "a\n" {
+		t.Errorf("a.txt holds %q (exists %v), want it recreated", got, ok)
+	}
+
+	if _, err := restore.File(project.Context(), project.Instance(), "a.txt", deletion); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := project.Read("a.txt"); ok {
+		t.Error("a.txt still in the working copy after restoring its deletion")
+	}
+	ctx := project.Context()
+	if file, err := fileDomain.GetByPath(ctx, session.GetBranchID(ctx), "a.txt"); err != nil || !file.IsDeleted {
+		t.Errorf("a.txt not recorded as deleted: %v", err)
+	}
+}
+
+
+func TestFileRejectsVersionsOfOtherFiles(t *testing.T) {
+	project := servicetest.NewProject(t, servicetest.Context(t), map[string]string{"a.txt": "a\n", "b.txt": "b\n"})
+
+	_, err := restore.File(project.Context(), project.Instance(), "a.txt", currentChange(t, project, "b.txt"))
+	if !errors.Is(err, restore.ErrWrongFile) {
+		t.Errorf("got %v, want %v", err, restore.ErrWrongFile)
+	}
+	_, err = restore.File(project.Context(), project.Instance(), "a.txt", "no-such-change")
+	if !errors.Is(err, restore.ErrChangeNotFound) {
+		t.Errorf("got %v, want %v", err, restore.ErrChangeNotFound)
+	}
+	if got, _ := project.Read("a.txt"); got != "a\n" {
+		t.Errorf("rejected restore touched a.txt: %q", got)
+	}
+}
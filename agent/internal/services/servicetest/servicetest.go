@@ -0,0 +1,161 @@
+// Package servicetest sets up what the service tests share: a database of
+// their own and projects on disk to work on.
+//
+// Only tests import it. Tests of packages the project service depends on
+// use it from an external test package (package foo_test), since an
+// internal one would import itself through it.
+package servicetest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	instanceDomain "vcx/agent/internal/domains/instance"
+	"vcx/agent/internal/infra/db"
+	"vcx/agent/internal/infra/db/dbsetup"
+	branchService "vcx/agent/internal/services/branch"
+	fileService "vcx/agent/internal/services/file"
+	instanceService "vcx/agent/internal/services/instance"
+	"vcx/agent/internal/services/migrations"
+	projectService "vcx/agent/internal/services/project"
+	"vcx/agent/internal/services/restore"
+	"vcx/agent/internal/session"
+)
+
+
+const ACCOUNT_ID = "test-account"
+
+
+// Context opens a migrated database in a temporary directory, keeps blobs
+// in another one and returns a context for a test account.
+func Context(t *testing.T) context.Context {
+	t.Helper()
+	db.Init(filepath.Join(t.TempDir(), "journal.vcx"))
+	dbsetup.BlobStorePath = t.TempDir()
+
+	ctx := context.Background()
+	if err := migrations.RunMigrations(ctx); err != nil {
+		t.Fatalf("failed to migrate the test database: %v", err)
+	}
+	return session.WithAccountID(ctx, ACCOUNT_ID)
+}
+
+
+// Project is a project whose only instance lives in Dir.
+type Project struct {
+	Dir string
+	ID  string
+	t   *testing.T
+	ctx context.Context
+}
+
+
+// NewProject writes files, relative path to content, to a temporary
+// directory and creates a project of it.
+func NewProject(t *testing.T, ctx context.Context, files map[string]string) *Project {
+	t.Helper()
+	project := &Project{Dir: t.TempDir(), t: t, ctx: ctx}
+	for relPath, content := range files {
+		project.WriteFile(relPath, content)
+	}
+
+	created, events := projectService.NewProject(ctx, project.Dir)
+	for range events {
+	}
+	if created == nil {
+		t.Fatalf("failed to create a project in %s", project.Dir)
+	}
+	project.ID = created.ID
+	return project
+}
+
+
+// Instance reloads the instance, so its branch is the current one.
+func (p *Project) Instance() *instanceDomain.Instance {
+	p.t.Helper()
+	instance, _, err := instanceService.FindByPath(p.ctx, p.Dir)
+	if err != nil {
+		p.t.Fatalf("failed to find the instance of %s: %v", p.Dir, err)
+	}
+	return instance
+}
+
+
+// Context returns a context for the project on its current branch.
+func (p *Project) Context() context.Context {
+	instance := p.Instance()
+	return session.WithBranchID(session.WithProjectID(p.ctx, instance.ProjectID), instance.BranchID)
+}
+
+
+// Path returns the absolute path of relPath in the working copy.
+func (p *Project) Path(relPath string) string {
+	return filepath.Join(p.Dir, relPath)
+}
+
+
+// WriteFile writes content to relPath in the working copy, creating its
+// parents, without recording anything.
+func (p *Project) WriteFile(relPath, content string) {
+	p.t.Helper()
+	if err := os.MkdirAll(filepath.Dir(p.Path(relPath)), 0755); err != nil {
+		p.t.Fatal(err)
+	}
+	if err := os.WriteFile(p.Path(relPath), []byte(content), 0644); err != nil {
+		p.t.Fatal(err)
+	}
+}
+
+
+// Write writes content to relPath and records it as a new version.
+func (p *Project) Write(relPath, content string) {
+	p.t.Helper()
+	p.WriteFile(relPath, content)
+	if _, err := fileService.Ingest(p.Context(), p.Dir, p.Path(relPath)); err != nil {
+		p.t.Fatalf("failed to record %s: %v", relPath, err)
+	}
+}
+
+
+// Remove deletes relPath from the working copy and records the deletion.
+func (p *Project) Remove(relPath string) {
+	p.t.Helper()
+	if err := os.RemoveAll(p.Path(relPath)); err != nil {
+		p.t.Fatal(err)
+	}
+	if _, err := fileService.Remove(p.Context(), p.Dir, p.Path(relPath)); err != nil {
+		p.t.Fatalf("failed to record the removal of %s: %v", relPath, err)
+	}
+}
+
+
+// Read returns the content of relPath in the working copy, and whether it
+// exists.
+func (p *Project) Read(relPath string) (string, bool) {
+	content, err := os.ReadFile(p.Path(relPath))
+	if err != nil {
+		return "", false
+	}
+	return string(content), true
+}
+
+
+// Fork creates a branch called name from the current state of the
+// instance's branch, leaving the working copy as it is.
+func (p *Project) Fork(name string) {
+	p.t.Helper()
+	if _, err := branchService.Fork(p.Context(), p.Instance(), name, ""); err != nil {
+		p.t.Fatalf("failed to create branch %s: %v", name, err)
+	}
+}
+
+
+// Switch moves the working copy to the branch called name.
+func (p *Project) Switch(name string) {
+	p.t.Helper()
+	if _, err := branchService.Switch(p.Context(), p.Instance(), name, restore.SwitchOptions{}); err != nil {
+		p.t.Fatalf("failed to switch to %s: %v", name, err)
+	}
+}
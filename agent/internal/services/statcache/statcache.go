@@ -0,0 +1,168 @@
+// Package statcache lets a rescan skip files that did not change since they
+// were last indexed, without reading them.
+//
+// For every indexed file of an instance's working copy the snapshot table
+// keeps size, mtime, inode and the content hash. A file whose stat still
+// matches, and whose recorded content is still that hash, is unchanged.
+// Like git's index, entries indexed within RACY_WINDOW of the file's mtime
+// are not trusted: the file may have changed again within the same tick.
+//
+// Updates are collected and written in one transaction by Save.
+package statcache
+
+import (
+	"context"
+	"database/sql"
+	"io/fs"
+	"time"
+
+	"vcx/agent/internal/consts/filetype"
+	fileDomain "vcx/agent/internal/domains/file"
+	snapshotDomain "vcx/agent/internal/domains/snapshot"
+	"vcx/agent/internal/infra/db"
+	"vcx/pkg/toolkit/filekit"
+)
+
+
+const RACY_WINDOW = 2 * time.Second
+
+
+// Cache is the stat cache of one instance's working copy, by path.
+type Cache struct {
+	instanceID string
+	entries    map[string]*snapshotDomain.Snapshot
+	changed    map[string]*snapshotDomain.Snapshot // nil entry: drop the path
+}
+
+
+// Load reads the stat cache of an instance.
+func Load(ctx context.Context, instanceID string) (*Cache, error) {
+	snapshots, err := snapshotDomain.GetByInstance(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	cache := &Cache{
+		instanceID: instanceID,
+		entries:    make(map[string]*snapshotDomain.Snapshot, len(snapshots)),
+		changed:    make(map[string]*snapshotDomain.Snapshot),
+	}
+	for _, snapshot := range snapshots {
+		cache.entries[snapshot.Path] = snapshot
+	}
+	return cache, nil
+}
+
+
+// Unchanged reports whether the regular file at relPath, as described by
+// info, still holds the content and mode recorded for file.
+func (c *Cache) Unchanged(relPath string, info fs.FileInfo, file *fileDomain.File) bool {
+	entry, ok := c.entries[relPath]
+	if !ok || file == nil || file.IsDeleted || file.Type != filetype.FILE || entry.Hash != file.BlobID {
+		return false
+	}
+	if !info.Mode().IsRegular() || int64(entry.Size) != info.Size() ||
+	   entry.MTime != info.ModTime().UnixNano() || entry.Inode != int64(filekit.Inode(info)) {
+		return false
+	}
+	// chmod leaves size and mtime alone
+	if file.Mode != 0 && file.Mode != filekit.ModeBits(info.Mode()) {
+		return false
+	}
+
+	indexed, err := time.Parse(time.RFC3339, entry.LastIndexed)
+	return err == nil && info.ModTime().Add(RACY_WINDOW).Before(indexed)
+}
+
+
+// Record caches info, taken before file was ingested from relPath, with
+// the content recorded for file.
+func (c *Cache) Record(relPath string, info fs.FileInfo, file *fileDomain.File) {
+	if file.Type != filetype.FILE || !info.Mode().IsRegular() {
+		c.drop(relPath)
+		return
+	}
+
+	entry, ok := c.entries[relPath]
+	if !ok {
+		entry = &snapshotDomain.Snapshot{InstanceID: c.instanceID, Path: relPath}
+		c.entries[relPath] = entry
+	}
+	entry.FileID      = file.ID
+	entry.ChangeID    = file.ChangeID
+	entry.Hash        = file.BlobID
+	entry.Size        = int(info.Size())
+	entry.MTime       = info.ModTime().UnixNano()
+	entry.Inode       = int64(filekit.Inode(info))
+	entry.LastIndexed = time.Now().Format(time.RFC3339)
+	c.changed[relPath] = entry
+}
+
+
+// Prune drops the entries of paths that are not in keep.
+func (c *Cache) Prune(keep map[string]bool) {
+	for path := range c.entries {
+		if !keep[path] {
+			c.drop(path)
+		}
+	}
+}
+
+
+// Save writes the entries recorded or dropped since the last Save.
+func (c *Cache) Save(ctx context.Context) error {
+	if len(c.changed) == 0 {
+		return nil
+	}
+
+	err := db.WithTransactionContext(ctx, func(ctx context.Context, _ *sql.Tx) error {
+		for path, entry := range c.changed {
+			var err error
+			switch {
+			case entry == nil:
+				err = c.delete(ctx, path)
+			case entry.ID == "":
+				var created *snapshotDomain.Snapshot
+				created, err = snapshotDomain.NewStat(ctx, entry.InstanceID, entry.Path, entry.FileID, entry.ChangeID, entry.Hash,
+					entry.Size, entry.MTime, entry.Inode, entry.LastIndexed)
+				if err == nil {
+					entry.Meta = created.Meta
+				}
+			default:
+				err = entry.Update(ctx)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	clear(c.changed)
+	return nil
+}
+
+
+func (c *Cache) drop(relPath string) {
+	if _, ok := c.entries[relPath]; ok {
+		c.changed[relPath] = nil
+	}
+}
+
+
+// delete removes the entry of a dropped path, stored or not.
+func (c *Cache) delete(ctx context.Context, relPath string) error {
+	entry, ok := c.entries[relPath]
+	if !ok {
+		return nil
+	}
+	if entry.ID != "" {
+		if err := entry.Delete(ctx); err != nil {
+			return err
+		}
+	}
+	delete(c.entries, relPath)
+	return nil
+}
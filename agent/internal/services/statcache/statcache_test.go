@@ -0,0 +1,111 @@
+package statcache_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	fileDomain "vcx/agent/internal/domains/file"
+	"vcx/agent/internal/services/servicetest"
+	"vcx/agent/internal/services/statcache"
+	"vcx/agent/internal/session"
+)
+
+
+func TestCacheVouchesOnlyForUnchangedFiles(t *testing.T) {
+	project := servicetest.NewProject(t, servicetest.Context(t), map[string]string{"a.txt": "indexed\n"})
+	ctx      := project.Context()
+	instance := project.Instance()
+
+	// written well before it is indexed, so the entry is trusted
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(project.Path("a.txt"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	stat := func() os.FileInfo {
+		t.Helper()
+		info, err := os.Lstat(project.Path("a.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info
+	}
+	file, err := fileDomain.GetByPath(ctx, session.GetBranchID(ctx), "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache, err := statcache.Load(ctx, instance.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Record("a.txt", stat(), file)
+	if err := cache.Save(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if cache, err = statcache.Load(ctx, instance.ID); err != nil {
+		t.Fatal(err)
+	}
+	if !cache.Unchanged("a.txt", stat(), file) {
+		t.Fatal("untouched file not vouched for after a reload")
+	}
+
+	cases := []struct {
+		name   string
+		change func()
+	}{
+		{"content of another size", func() { project.WriteFile("a.txt", "indexed, then edited\n") }},
+		{"same size, new mtime", func() {
+			later := old.Add(time.Minute)
+			if err := os.Chtimes(project.Path("a.txt"), later, later); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"mode", func() {
+			if err := os.Chmod(project.Path("a.txt"), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"written within the racy window", func() {
+			now := time.Now()
+			if err := os.Chtimes(project.Path("a.txt"), now, now); err != nil {
+				t.Fatal(err)
+			}
+		}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			project.WriteFile("a.txt", "indexed\n")
+			if err := os.Chmod(project.Path("a.txt"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(project.Path("a.txt"), old, old); err != nil {
+				t.Fatal(err)
+			}
+			cache.Record("a.txt", stat(), file)
+			if !cache.Unchanged("a.txt", stat(), file) {
+				t.Fatal("restored file not vouched for")
+			}
+
+			c.change()
+			if cache.Unchanged("a.txt", stat(), file) {
+				t.Error("changed file vouched for")
+			}
+		})
+	}
+
+	if err := os.Chtimes(project.Path("a.txt"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	cache.Record("a.txt", stat(), file)
+	cache.Prune(map[string]bool{})
+	if err := cache.Save(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if cache, err = statcache.Load(ctx, instance.ID); err != nil {
+		t.Fatal(err)
+	}
+	if cache.Unchanged("a.txt", stat(), file) {
+		t.Error("pruned entry still vouched for")
+	}
+}
@@ -13,6 +13,23 @@ func main() {
 		fmt.Println("Commands:")
 		fmt.Println("  init          - Initialize project (simple)")
 		fmt.Println("  init-progress - Initialize project (with progress)")
+		fmt.Println("  policy        - Show or set the auto-snapshot policy")
+		fmt.Println("  log <path>    - List the recorded versions of a file")
+		fmt.Println("  restore <path> --to <change> - Restore a previous version of a file")
+		fmt.Println("  rollback --to <change>       - Roll the whole project back (--at <time>, --dry-run, --force)")
+		fmt.Println("  diff <path>   - Show changes between versions of a file (--from, --to)")
+		fmt.Println("  gc            - Remove unreferenced blobs (--dry-run, --grace <duration>)")
+		fmt.Println("  fsck          - Verify stored content and references (--repair)")
+		fmt.Println("  repack        - Move small blobs into pack files (--dry-run)")
+		fmt.Println("  dict          - Compare compression with the project dictionary (--train)")
+		fmt.Println("  encrypt       - Show or set encryption at rest (--cipher, --keyfile, --off, --reencrypt)")
+		fmt.Println("  branch list | create <name> [--from <change>] - List or create branches")
+		fmt.Println("  switch <name> - Switch the working copy to a branch (--dry-run, --force)")
+		fmt.Println("  merge <name>  - Merge a branch into the current one (--dry-run, --force)")
+		fmt.Println("  conflicts     - List unresolved conflicts")
+		fmt.Println("  resolve <path> - Resolve a conflict with the working copy (--ours, --theirs)")
+		fmt.Println("  ls            - List the files recorded on the current branch (--deleted)")
+		fmt.Println("  rescan        - Record changes made while the agent was not watching")
 		os.Exit(1)
 	}
 
@@ -2,6 +2,7 @@ package api
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -58,3 +59,22 @@ func HandleNonStream(respBody io.ReadCloser) error {
 
 	return nil
 }
+
+
+// DecodeJSON decodes a JSON response into v. Non-2xx responses are turned
+// into an error carrying the agent's {"error": "..."} message.
+func DecodeJSON(resp *http.Response, v any) error {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || apiErr.Error == "" {
+			return fmt.Errorf("agent returned %s", resp.Status)
+		}
+		return fmt.Errorf("%s", apiErr.Error)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("error reading response: %w", err)
+	}
+	return nil
+}
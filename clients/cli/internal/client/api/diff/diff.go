@@ -0,0 +1,40 @@
+package diff
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"vcx/clients/cli/internal/client"
+	"vcx/pkg/toolkit/diffkit"
+)
+
+
+// Result mirrors the agent's /api/diff response.
+type Result struct {
+	Path         string         `json:"path"`
+	FromChangeID string         `json:"fromChangeID"`
+	ToChangeID   string         `json:"toChangeID"`
+	FromBlobID   string         `json:"fromBlobID"`
+	ToBlobID     string         `json:"toBlobID"`
+	FromMode     uint32         `json:"fromMode"`
+	ToMode       uint32         `json:"toMode"`
+	ModeChanged  bool           `json:"modeChanged"`
+	IsBinary     bool           `json:"isBinary"`
+	TooLarge     bool           `json:"tooLarge"`
+	Identical    bool           `json:"identical"`
+	Hunks        []diffkit.Hunk `json:"hunks"`
+}
+
+
+// Versions requests the diff of two versions of the file at the absolute
+// path. params may carry from, to, context and algorithm.
+func Versions(path string, params url.Values) (*http.Response, error) {
+    params.Set("path", path)
+
+    client := client.New()
+    resp, err := client.Get("/api/diff?" + params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("error calling agent: %w", err)
+	}
+    return resp, nil
+}
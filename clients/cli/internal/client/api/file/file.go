@@ -0,0 +1,87 @@
+package file
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"vcx/clients/cli/internal/client"
+)
+
+
+// Version mirrors an entry of the agent's /api/file/history response.
+type Version struct {
+	ChangeID     string `json:"changeID"`
+	Timestamp    string `json:"timestamp"`
+	Path         string `json:"path"`
+	BlobID       string `json:"blobID"`
+	Size         int64  `json:"size"`
+	Summary      string `json:"summary"`
+	IsDeleted    bool   `json:"isDeleted"`
+	IsConflicted bool   `json:"isConflicted"`
+	Target       string `json:"target"`
+}
+
+
+type HistoryResponse struct {
+	ProjectID string    `json:"projectID"`
+	BranchID  string    `json:"branchID"`
+	Path      string    `json:"path"`
+	Versions  []Version `json:"versions"`
+}
+
+
+// History requests the versions of the file at the absolute path.
+// params may carry limit, since and until.
+func History(path string, params url.Values) (*http.Response, error) {
+    params.Set("path", path)
+
+    client := client.New()
+    resp, err := client.Get("/api/file/history?" + params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("error calling agent: %w", err)
+	}
+    return resp, nil
+}
+
+
+type RestoreResponse struct {
+	Path         string `json:"path"`
+	RestoredFrom string `json:"restoredFrom"`
+	ChangeID     string `json:"changeID"`
+}
+
+
+// Restore asks the agent to restore the file at the absolute path to changeID.
+func Restore(path, changeID string) (*http.Response, error) {
+    params := url.Values{"path": {path}, "to": {changeID}}
+
+    client := client.New()
+    resp, err := client.Post("/api/file/restore?" + params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error calling agent: %w", err)
+	}
+    return resp, nil
+}
+
+
+type ResolveResponse struct {
+	Path       string `json:"path"`
+	Resolution string `json:"resolution"`
+}
+
+
+// Resolve asks the agent to close the conflict of the file at the absolute
+// path, taking side (ours or theirs) or, when empty, the working copy.
+func Resolve(path, side string) (*http.Response, error) {
+    params := url.Values{"path": {path}}
+    if side != "" {
+        params.Set("side", side)
+    }
+
+    client := client.New()
+    resp, err := client.Post("/api/file/resolve?" + params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error calling agent: %w", err)
+	}
+    return resp, nil
+}
@@ -3,6 +3,7 @@ package project
 import (
 	"fmt"
 	"net/http"
+	"net/url"
 	"vcx/clients/cli/internal/client"
 )
 
@@ -16,3 +17,287 @@ func Init() (*http.Response, error) {
 	}
     return resp, nil
 }
+
+
+// GetPolicy fetches the auto-snapshot policy of the project containing path.
+func GetPolicy(path string) (*http.Response, error) {
+    client := client.New()
+    resp, err := client.Get("/api/project/policy?path=" + url.QueryEscape(path))
+	if err != nil {
+		return nil, fmt.Errorf("error calling agent: %w", err)
+	}
+    return resp, nil
+}
+
+
+// SetPolicy updates the given policy fields, e.g. {"quietWindow": "2s"}.
+func SetPolicy(path string, fields map[string]string) (*http.Response, error) {
+    client := client.New()
+    resp, err := client.Post("/api/project/policy?path=" + url.QueryEscape(path), fields)
+	if err != nil {
+		return nil, fmt.Errorf("error calling agent: %w", err)
+	}
+    return resp, nil
+}
+
+
+type RestoreAction struct {
+	Op       string `json:"op"`
+	Path     string `json:"path"`
+	From     string `json:"from"`
+	ChangeID string `json:"changeID"`
+	Target   string `json:"target"`
+}
+
+
+// RestorePlan mirrors the agent's project restore response.
+type RestorePlan struct {
+	ChangeID string          `json:"changeID"`
+	Actions  []RestoreAction `json:"actions"`
+	Modified []string        `json:"modified"`
+	Applied  bool            `json:"applied"`
+}
+
+
+// RestoreError is returned when a restore was refused or failed part way.
+type RestoreError struct {
+	Error string       `json:"error"`
+	Plan  *RestorePlan `json:"plan"`
+}
+
+
+// Restore rolls the project containing path back; params carry to or at,
+// and optionally dryRun and force.
+func Restore(path string, params url.Values) (*http.Response, error) {
+    params.Set("path", path)
+
+    client := client.New()
+    resp, err := client.Post("/api/project/restore?" + params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error calling agent: %w", err)
+	}
+    return resp, nil
+}
+
+
+// DictionaryStats mirrors the agent's project dictionary response.
+type DictionaryStats struct {
+	ProjectID      string  `json:"projectID"`
+	DictID         int     `json:"dictID"`
+	DictSize       int     `json:"dictSize"`
+	Samples        int     `json:"samples"`
+	StoredWithDict int     `json:"storedWithDict"`
+	OriginalBytes  int64   `json:"originalBytes"`
+	PlainBytes     int64   `json:"plainBytes"`
+	DictBytes      int64   `json:"dictBytes"`
+	PlainRatio     float64 `json:"plainRatio"`
+	DictRatio      float64 `json:"dictRatio"`
+}
+
+
+// Dictionary fetches the compression dictionary stats of the project
+// containing path, training a new dictionary first when train is set.
+func Dictionary(path string, train bool) (*http.Response, error) {
+    client := client.New()
+
+    var resp *http.Response
+    var err error
+    if train {
+        resp, err = client.Post("/api/project/dictionary?path=" + url.QueryEscape(path), nil)
+    } else {
+        resp, err = client.Get("/api/project/dictionary?path=" + url.QueryEscape(path))
+    }
+	if err != nil {
+		return nil, fmt.Errorf("error calling agent: %w", err)
+	}
+    return resp, nil
+}
+
+
+// Branch mirrors one entry of the agent's project branch response.
+type Branch struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	ChangeID     string `json:"changeID"`
+	CreationDate string `json:"creationDate"`
+	Current      bool   `json:"current"`
+}
+
+
+// ListBranches fetches the branches of the project containing path.
+func ListBranches(path string) (*http.Response, error) {
+    client := client.New()
+    resp, err := client.Get("/api/project/branch?path=" + url.QueryEscape(path))
+	if err != nil {
+		return nil, fmt.Errorf("error calling agent: %w", err)
+	}
+    return resp, nil
+}
+
+
+// CreateBranch creates branch name in the project containing path, from
+// change from or, when empty, the latest state of the current branch.
+func CreateBranch(path, name, from string) (*http.Response, error) {
+    params := url.Values{"path": {path}, "name": {name}}
+    if from != "" {
+        params.Set("from", from)
+    }
+
+    client := client.New()
+    resp, err := client.Post("/api/project/branch?" + params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error calling agent: %w", err)
+	}
+    return resp, nil
+}
+
+
+// Switch rewrites the working copy containing path to branch name; params
+// may carry dryRun and force. Responses are shaped like Restore's.
+func Switch(path, name string, params url.Values) (*http.Response, error) {
+    params.Set("path", path)
+    params.Set("name", name)
+
+    client := client.New()
+    resp, err := client.Post("/api/project/switch?" + params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error calling agent: %w", err)
+	}
+    return resp, nil
+}
+
+
+// MergeConflict mirrors a path the agent's merge could not resolve.
+type MergeConflict struct {
+	Path     string `json:"path"`
+	Reason   string `json:"reason"`
+	BaseID   string `json:"baseID"`
+	OursID   string `json:"oursID"`
+	TheirsID string `json:"theirsID"`
+}
+
+
+// MergeResult mirrors the agent's project merge response.
+type MergeResult struct {
+	ChangeID   string          `json:"changeID"`
+	AncestorID string          `json:"ancestorID"`
+	UpToDate   bool            `json:"upToDate"`
+	Actions    []RestoreAction `json:"actions"`
+	Conflicts  []MergeConflict `json:"conflicts"`
+	Modified   []string        `json:"modified"`
+	Applied    bool            `json:"applied"`
+}
+
+
+// MergeError is returned when a merge was refused or failed part way.
+type MergeError struct {
+	Error  string       `json:"error"`
+	Result *MergeResult `json:"result"`
+}
+
+
+// Merge merges branch name into the current branch of the working copy
+// containing path; params may carry dryRun and force.
+func Merge(path, name string, params url.Values) (*http.Response, error) {
+    params.Set("path", path)
+    params.Set("name", name)
+
+    client := client.New()
+    resp, err := client.Post("/api/project/merge?" + params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error calling agent: %w", err)
+	}
+    return resp, nil
+}
+
+
+// ConflictSide mirrors one version of a conflicting path.
+type ConflictSide struct {
+	ChangeID string `json:"changeID"`
+	BlobID   string `json:"blobID"`
+}
+
+
+// Conflict mirrors one entry of the agent's project conflicts response.
+type Conflict struct {
+	ID           string       `json:"id"`
+	Path         string       `json:"path"`
+	Reason       string       `json:"reason"`
+	ChangeID     string       `json:"changeID"`
+	CreationDate string       `json:"creationDate"`
+	Base         ConflictSide `json:"base"`
+	Ours         ConflictSide `json:"ours"`
+	Theirs       ConflictSide `json:"theirs"`
+}
+
+
+// ListConflicts fetches the unresolved conflicts on the current branch of
+// the project containing path.
+func ListConflicts(path string) (*http.Response, error) {
+    client := client.New()
+    resp, err := client.Get("/api/project/conflicts?path=" + url.QueryEscape(path))
+	if err != nil {
+		return nil, fmt.Errorf("error calling agent: %w", err)
+	}
+    return resp, nil
+}
+
+
+// TreeFile mirrors one entry of the agent's project files response.
+type TreeFile struct {
+	Path      string `json:"path"`
+	Type      string `json:"type"`
+	BlobID    string `json:"blobID"`
+	Target    string `json:"target"`
+	LinkType  string `json:"linkType"`
+	ChangeID  string `json:"changeID"`
+	IsDeleted bool   `json:"isDeleted"`
+}
+
+
+// Files fetches the current tree of the project containing path; with
+// deleted set, deleted files are included.
+func Files(path string, deleted bool) (*http.Response, error) {
+    params := url.Values{}
+    params.Set("path", path)
+    if deleted {
+        params.Set("deleted", "true")
+    }
+
+    client := client.New()
+    resp, err := client.Get("/api/project/files?" + params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("error calling agent: %w", err)
+	}
+    return resp, nil
+}
+
+
+// RescanRename mirrors a file the agent recorded under a new path.
+type RescanRename struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+
+// RescanResult mirrors the agent's project rescan response.
+type RescanResult struct {
+	Scanned   int            `json:"scanned"`
+	Unchanged int            `json:"unchanged"`
+	Recorded  []string       `json:"recorded"`
+	Renamed   []RescanRename `json:"renamed"`
+	Deleted   []string       `json:"deleted"`
+	Failed    []string       `json:"failed"`
+}
+
+
+// Rescan asks the agent to record what changed in the working copy of the
+// project containing path while it was not watching.
+func Rescan(path string) (*http.Response, error) {
+    client := client.New()
+    resp, err := client.Post("/api/project/rescan?path=" + url.QueryEscape(path), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error calling agent: %w", err)
+	}
+    return resp, nil
+}
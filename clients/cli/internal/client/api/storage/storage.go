@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"vcx/clients/cli/internal/client"
+)
+
+
+// GCReport mirrors the agent's /api/storage/gc response.
+type GCReport struct {
+	DryRun      bool     `json:"dryRun"`
+	GracePeriod string   `json:"gracePeriod"`
+	Blobs       []string `json:"blobs"`
+	BlobBytes   int64    `json:"blobBytes"`
+	Orphans     []string `json:"orphans"`
+	OrphanBytes int64    `json:"orphanBytes"`
+	Pending     int      `json:"pending"`
+}
+
+
+// Problem mirrors one entry of the agent's /api/storage/fsck report.
+type Problem struct {
+	Kind     string `json:"kind"`
+	BlobID   string `json:"blobID"`
+	Table    string `json:"table"`
+	ID       string `json:"id"`
+	Path     string `json:"path"`
+	Detail   string `json:"detail"`
+	Repaired bool   `json:"repaired"`
+}
+
+
+// FsckReport mirrors the agent's /api/storage/fsck response.
+type FsckReport struct {
+	Blobs     int       `json:"blobs"`
+	Files     int       `json:"files"`
+	Changes   int       `json:"changes"`
+	Snapshots int       `json:"snapshots"`
+	Problems  []Problem `json:"problems"`
+	Repaired  int       `json:"repaired"`
+}
+
+
+// RepackReport mirrors the agent's /api/storage/repack response.
+type RepackReport struct {
+	DryRun    bool     `json:"dryRun"`
+	Blobs     int      `json:"blobs"`
+	Bytes     int64    `json:"bytes"`
+	FromDB    int      `json:"fromDB"`
+	FromFiles int      `json:"fromFiles"`
+	FromPacks int      `json:"fromPacks"`
+	Packs     []string `json:"packs"`
+	Rewritten []string `json:"rewritten"`
+	Reclaimed int64    `json:"reclaimed"`
+}
+
+
+// GC asks the agent to collect unreferenced blobs. params may carry dryRun
+// and grace.
+func GC(params url.Values) (*http.Response, error) {
+    client := client.New()
+    resp, err := client.Post("/api/storage/gc?" + params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error calling agent: %w", err)
+	}
+    return resp, nil
+}
+
+
+// Fsck asks the agent to verify the blob storage, repairing what it can when
+// repair is set.
+func Fsck(repair bool) (*http.Response, error) {
+    client := client.New()
+
+    var resp *http.Response
+    var err error
+    if repair {
+        resp, err = client.Post("/api/storage/fsck?repair=true", nil)
+    } else {
+        resp, err = client.Get("/api/storage/fsck")
+    }
+	if err != nil {
+		return nil, fmt.Errorf("error calling agent: %w", err)
+	}
+    return resp, nil
+}
+
+
+// Repack asks the agent to move small blobs into pack files. params may
+// carry dryRun.
+func Repack(params url.Values) (*http.Response, error) {
+    client := client.New()
+    resp, err := client.Post("/api/storage/repack?" + params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error calling agent: %w", err)
+	}
+    return resp, nil
+}
+
+
+// EncryptionStatus mirrors the agent's GET /api/storage/encryption response.
+type EncryptionStatus struct {
+	Algorithm string `json:"algorithm"`
+	KeyID     string `json:"keyID"`
+	Keyfile   string `json:"keyfile"`
+	Loaded    bool   `json:"loaded"`
+	Encrypted int    `json:"encrypted"`
+	Plain     int    `json:"plain"`
+	OtherKeys int    `json:"otherKeys"`
+}
+
+
+// EncryptionRequest is the body of POST /api/storage/encryption.
+type EncryptionRequest struct {
+	Algorithm  string `json:"algorithm,omitempty"`
+	Keyfile    string `json:"keyfile,omitempty"`
+	Passphrase string `json:"passphrase,omitempty"`
+	Off        bool   `json:"off,omitempty"`
+	Reencrypt  bool   `json:"reencrypt,omitempty"`
+}
+
+
+// EncryptionReport mirrors the agent's POST /api/storage/encryption response.
+type EncryptionReport struct {
+	Blobs        int `json:"blobs"`
+	Dictionaries int `json:"dictionaries"`
+	Skipped      int `json:"skipped"`
+	Failed       int `json:"failed"`
+}
+
+
+// GetEncryption asks the agent how blob contents are encrypted.
+func GetEncryption() (*http.Response, error) {
+    client := client.New()
+    resp, err := client.Get("/api/storage/encryption")
+	if err != nil {
+		return nil, fmt.Errorf("error calling agent: %w", err)
+	}
+    return resp, nil
+}
+
+
+// SetEncryption asks the agent to turn encryption on or off and reseal the
+// existing blobs.
+func SetEncryption(request EncryptionRequest) (*http.Response, error) {
+    client := client.New()
+    resp, err := client.Post("/api/storage/encryption", request)
+	if err != nil {
+		return nil, fmt.Errorf("error calling agent: %w", err)
+	}
+    return resp, nil
+}
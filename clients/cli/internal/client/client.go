@@ -1,7 +1,11 @@
 package client
 
 import (
+	"bytes"
+	"encoding/json"
 	"net/http"
+
+	"vcx/pkg/toolkit/httpkit"
 )
 
 
@@ -27,3 +31,21 @@ func (c *Client) Get(url string) (*http.Response, error) {
 
 	return c.HTTP.Do(req)
 }
+
+
+// Post sends body encoded as JSON.
+func (c *Client) Post(url string, body any) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", BASEURL + url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(httpkit.REQUEST_HEADER, "1")
+
+	return c.HTTP.Do(req)
+}
@@ -0,0 +1,219 @@
+package commandhandler
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"vcx/clients/cli/internal/client/api"
+	"vcx/clients/cli/internal/client/api/project"
+	"vcx/pkg/toolkit/pathkit"
+)
+
+
+// Branch lists the branches of the project containing the CWD or creates
+// one, from a change or the latest state of the current branch.
+//
+//	vcx branch list
+//	vcx branch create <name> [--from <change>]
+func Branch(args []string) {
+	if len(args) < 3 {
+		fmt.Println("Usage: vcx branch (list | create <name> [--from <change>])")
+		os.Exit(1)
+	}
+
+	switch args[2] {
+	case "list":
+		listBranches()
+	case "create":
+		flags := flag.NewFlagSet("branch create", flag.ExitOnError)
+		from  := flags.String("from", "", "change ID to branch off at (default: the latest state)")
+		positional := parseArgs(flags, args[3:])
+		if len(positional) != 1 {
+			fmt.Println("Usage: vcx branch create <name> [--from <change>]")
+			os.Exit(1)
+		}
+		createBranch(positional[0], *from)
+	default:
+		fmt.Printf("Unknown branch command: %s\n", args[2])
+		os.Exit(1)
+	}
+}
+
+
+func listBranches() {
+	resp, err := project.ListBranches(pathkit.CWD())
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	var branches []project.Branch
+	if err := api.DecodeJSON(resp, &branches); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	for _, branch := range branches {
+		marker := " "
+		if branch.Current {
+			marker = "*"
+		}
+		fmt.Printf("%s %-20s  %s  from %s\n", marker, branch.Name, branch.CreationDate, branch.ChangeID)
+	}
+}
+
+
+func createBranch(name, from string) {
+	resp, err := project.CreateBranch(pathkit.CWD(), name, from)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	var branch project.Branch
+	if err := api.DecodeJSON(resp, &branch); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Printf("Created branch %s at %s (vcx switch %s)\n", branch.Name, branch.ChangeID, branch.Name)
+}
+
+
+// Switch rewrites the working copy containing the CWD to the latest state
+// of a branch; new edits are recorded on that branch.
+//
+//	vcx switch <name> [--dry-run] [--force]
+func Switch(args []string) {
+	flags  := flag.NewFlagSet("switch", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "only list the planned writes and deletes")
+	force  := flags.Bool("force", false, "overwrite unrecorded local modifications")
+	positional := parseArgs(flags, args[2:])
+	if len(positional) != 1 {
+		fmt.Println("Usage: vcx switch <name> [--dry-run] [--force]")
+		os.Exit(1)
+	}
+	name := positional[0]
+
+	params := url.Values{}
+	if *dryRun {
+		params.Set("dryRun", "true")
+	}
+	if *force {
+		params.Set("force", "true")
+	}
+
+	resp, err := project.Switch(pathkit.CWD(), name, params)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var failure project.RestoreError
+		if err := json.NewDecoder(resp.Body).Decode(&failure); err != nil || failure.Error == "" {
+			fmt.Printf("agent returned %s\n", resp.Status)
+			os.Exit(1)
+		}
+		fmt.Println(failure.Error)
+		if failure.Plan != nil {
+			for _, path := range failure.Plan.Modified {
+				fmt.Printf("  modified: %s\n", path)
+			}
+		}
+		os.Exit(1)
+	}
+
+	var plan project.RestorePlan
+	if err := json.NewDecoder(resp.Body).Decode(&plan); err != nil {
+		fmt.Printf("error reading response: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, action := range plan.Actions {
+		fmt.Printf("%-6s  %s\n", action.Op, action.Path)
+	}
+	if plan.Applied {
+		fmt.Printf("Switched to branch %s (%d changes)\n", name, len(plan.Actions))
+	} else {
+		fmt.Printf("Dry run: %d changes would switch to branch %s\n", len(plan.Actions), name)
+	}
+}
+
+
+// Merge merges a branch into the current branch of the working copy
+// containing the CWD. Paths both branches changed in ways that cannot be
+// merged keep the current branch's version and are listed as conflicts.
+//
+//	vcx merge <name> [--dry-run] [--force]
+func Merge(args []string) {
+	flags  := flag.NewFlagSet("merge", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "only list the planned writes, deletes and conflicts")
+	force  := flags.Bool("force", false, "overwrite unrecorded local modifications")
+	positional := parseArgs(flags, args[2:])
+	if len(positional) != 1 {
+		fmt.Println("Usage: vcx merge <name> [--dry-run] [--force]")
+		os.Exit(1)
+	}
+	name := positional[0]
+
+	params := url.Values{}
+	if *dryRun {
+		params.Set("dryRun", "true")
+	}
+	if *force {
+		params.Set("force", "true")
+	}
+
+	resp, err := project.Merge(pathkit.CWD(), name, params)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var failure project.MergeError
+		if err := json.NewDecoder(resp.Body).Decode(&failure); err != nil || failure.Error == "" {
+			fmt.Printf("agent returned %s\n", resp.Status)
+			os.Exit(1)
+		}
+		fmt.Println(failure.Error)
+		if failure.Result != nil {
+			for _, path := range failure.Result.Modified {
+				fmt.Printf("  modified: %s\n", path)
+			}
+		}
+		os.Exit(1)
+	}
+
+	var result project.MergeResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		fmt.Printf("error reading response: %v\n", err)
+		os.Exit(1)
+	}
+	if result.UpToDate {
+		fmt.Printf("Already up to date with %s\n", name)
+		return
+	}
+
+	for _, action := range result.Actions {
+		fmt.Printf("%-6s  %s\n", action.Op, action.Path)
+	}
+	for _, conflict := range result.Conflicts {
+		fmt.Printf("CONFLICT (%s)  %s\n", conflict.Reason, conflict.Path)
+	}
+	if result.Applied {
+		fmt.Printf("Merged %s (%d changes, %d conflicts) as %s\n", name, len(result.Actions), len(result.Conflicts), result.ChangeID)
+		if len(result.Conflicts) > 0 {
+			fmt.Println("Resolve conflicts with vcx resolve <path> [--ours | --theirs]")
+		}
+	} else {
+		fmt.Printf("Dry run: merging %s would make %d changes with %d conflicts\n", name, len(result.Actions), len(result.Conflicts))
+	}
+}
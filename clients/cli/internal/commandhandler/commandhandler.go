@@ -1,7 +1,9 @@
 package commandhandler
 
 import (
+	"flag"
 	"fmt"
+	"net/http"
 	"os"
 	"vcx/clients/cli/internal/client/api"
 	"vcx/clients/cli/internal/client/api/project"
@@ -12,6 +14,40 @@ func Route(args []string) {
 	switch args[1] {
 	case "init":
 		Init(args)
+	case "policy":
+		Policy(args)
+	case "log":
+		Log(args)
+	case "restore":
+		Restore(args)
+	case "rollback":
+		Rollback(args)
+	case "diff":
+		Diff(args)
+	case "gc":
+		GC(args)
+	case "fsck":
+		Fsck(args)
+	case "repack":
+		Repack(args)
+	case "dict":
+		Dict(args)
+	case "encrypt":
+		Encrypt(args)
+	case "branch":
+		Branch(args)
+	case "switch":
+		Switch(args)
+	case "merge":
+		Merge(args)
+	case "conflicts":
+		Conflicts(args)
+	case "resolve":
+		Resolve(args)
+	case "ls":
+		Ls(args)
+	case "rescan":
+		Rescan(args)
 	default:
 		fmt.Printf("Unknown command: %s\n", args[1])
 		os.Exit(1)
@@ -31,3 +67,43 @@ func  Init(args []string) {
 
     api.HandleBody(resp, api.JustPrint)
 }
+
+
+// Policy shows the auto-snapshot policy of the current project, or updates
+// it when any flag is given.
+//
+//	vcx policy [--quiet-window 2s] [--min-interval 10s] [--max-wait 1m]
+func Policy(args []string) {
+	flags := flag.NewFlagSet("policy", flag.ExitOnError)
+	quietWindow := flags.String("quiet-window", "", "record a file once it has been quiet for this long")
+	minInterval := flags.String("min-interval", "", "minimum time between two versions of the same file")
+	maxWait     := flags.String("max-wait", "", "record a continuously changing file at least this often")
+	flags.Parse(args[2:])
+
+	fields := map[string]string{}
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "quiet-window":
+			fields["quietWindow"] = *quietWindow
+		case "min-interval":
+			fields["minInterval"] = *minInterval
+		case "max-wait":
+			fields["maxWait"] = *maxWait
+		}
+	})
+
+	var resp *http.Response
+	var err error
+	if len(fields) == 0 {
+		resp, err = project.GetPolicy(pathkit.CWD())
+	} else {
+		resp, err = project.SetPolicy(pathkit.CWD(), fields)
+	}
+	if err != nil {
+		fmt.Print(err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	api.HandleBody(resp, api.JustPrint)
+}
@@ -0,0 +1,87 @@
+package commandhandler
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"vcx/clients/cli/internal/client/api"
+	"vcx/clients/cli/internal/client/api/file"
+	"vcx/clients/cli/internal/client/api/project"
+	"vcx/pkg/toolkit/pathkit"
+)
+
+
+// Conflicts lists the unresolved conflicts on the current branch of the
+// project containing the CWD.
+//
+//	vcx conflicts
+func Conflicts(args []string) {
+	resp, err := project.ListConflicts(pathkit.CWD())
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	var conflicts []project.Conflict
+	if err := api.DecodeJSON(resp, &conflicts); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	for _, conflict := range conflicts {
+		fmt.Printf("%-14s  %s  (ours %s, theirs %s)\n", conflict.Reason, conflict.Path,
+			sideLabel(conflict.Ours), sideLabel(conflict.Theirs))
+	}
+	if len(conflicts) == 0 {
+		fmt.Println("No unresolved conflicts")
+	}
+}
+
+
+// Resolve closes the conflict of a file by picking a side, or by recording
+// the working copy as it is once the conflict markers have been edited out.
+//
+//	vcx resolve <path> [--ours | --theirs]
+func Resolve(args []string) {
+	flags  := flag.NewFlagSet("resolve", flag.ExitOnError)
+	ours   := flags.Bool("ours", false, "keep the version of the current branch")
+	theirs := flags.Bool("theirs", false, "take the version that was merged in")
+	positional := parseArgs(flags, args[2:])
+	if len(positional) != 1 || (*ours && *theirs) {
+		fmt.Println("Usage: vcx resolve <path> [--ours | --theirs]")
+		os.Exit(1)
+	}
+
+	side := ""
+	if *ours {
+		side = "ours"
+	} else if *theirs {
+		side = "theirs"
+	}
+
+	resp, err := file.Resolve(absPath(positional[0]), side)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	var result file.ResolveResponse
+	if err := api.DecodeJSON(resp, &result); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Printf("Resolved %s (%s)\n", result.Path, result.Resolution)
+}
+
+
+func sideLabel(side project.ConflictSide) string {
+	switch {
+	case side.ChangeID == "":
+		return "absent"
+	case side.BlobID == "":
+		return shortID(side.ChangeID) + ", no content"
+	}
+	return shortID(side.ChangeID)
+}
@@ -0,0 +1,52 @@
+package commandhandler
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"vcx/clients/cli/internal/client/api"
+	"vcx/clients/cli/internal/client/api/project"
+	"vcx/pkg/toolkit/convertkit"
+	"vcx/pkg/toolkit/pathkit"
+)
+
+
+// Dict shows how the compression dictionary of the current project compares
+// with plain compression, training a new one first with --train.
+//
+//	vcx dict [--train]
+func Dict(args []string) {
+	flags := flag.NewFlagSet("dict", flag.ExitOnError)
+	train := flags.Bool("train", false, "train a new dictionary from the project's text files")
+	flags.Parse(args[2:])
+
+	resp, err := project.Dictionary(pathkit.CWD(), *train)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	var stats project.DictionaryStats
+	if err := api.DecodeJSON(resp, &stats); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if stats.DictID == 0 {
+		fmt.Println("No dictionary trained yet (vcx dict --train)")
+	} else {
+		fmt.Printf("Dictionary %d, %s\n", stats.DictID, convertkit.BytesToHuman(int64(stats.DictSize)))
+	}
+	if stats.Samples == 0 {
+		fmt.Println("No small text files to compare")
+		return
+	}
+
+	fmt.Printf("%d small text file(s), %s\n", stats.Samples, convertkit.BytesToHuman(stats.OriginalBytes))
+	fmt.Printf("  without dictionary: %s (%.1f%%)\n", convertkit.BytesToHuman(stats.PlainBytes), stats.PlainRatio*100)
+	if stats.DictID != 0 {
+		fmt.Printf("  with dictionary:    %s (%.1f%%)\n", convertkit.BytesToHuman(stats.DictBytes), stats.DictRatio*100)
+		fmt.Printf("  %d of them stored with a dictionary\n", stats.StoredWithDict)
+	}
+}
@@ -0,0 +1,133 @@
+package commandhandler
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"vcx/clients/cli/internal/client/api"
+	"vcx/clients/cli/internal/client/api/diff"
+	"vcx/pkg/toolkit/diffkit"
+)
+
+
+const (
+	colorReset = "\033[0m"
+	colorBold  = "\033[1m"
+	colorRed   = "\033[31m"
+	colorGreen = "\033[32m"
+	colorCyan  = "\033[36m"
+)
+
+
+// Diff prints a unified diff between two versions of a file. Without
+// --to the current version is used, without --from the one before it.
+//
+//	vcx diff <path> [--from <change>] [--to <change>] [--context N] [--patience] [--no-color]
+func Diff(args []string) {
+	flags    := flag.NewFlagSet("diff", flag.ExitOnError)
+	from     := flags.String("from", "", "change ID of the old version")
+	to       := flags.String("to", "", "change ID of the new version")
+	context  := flags.Int("context", 3, "unchanged lines shown around each change")
+	patience := flags.Bool("patience", false, "use the patience diff algorithm")
+	noColor  := flags.Bool("no-color", false, "disable colored output")
+	positional := parseArgs(flags, args[2:])
+	if len(positional) != 1 {
+		fmt.Println("Usage: vcx diff <path> [--from <change>] [--to <change>] [--context N] [--patience] [--no-color]")
+		os.Exit(1)
+	}
+
+	params := url.Values{"context": {strconv.Itoa(*context)}}
+	if *from != "" {
+		params.Set("from", *from)
+	}
+	if *to != "" {
+		params.Set("to", *to)
+	}
+	if *patience {
+		params.Set("algorithm", "patience")
+	}
+
+	resp, err := diff.Versions(absPath(positional[0]), params)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	var result diff.Result
+	if err := api.DecodeJSON(resp, &result); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	printUnified(&result, !*noColor && isTerminal(os.Stdout))
+}
+
+
+func printUnified(result *diff.Result, color bool) {
+	paint := func(code, text string) string {
+		if !color {
+			return text
+		}
+		return code + text + colorReset
+	}
+
+	fromLabel, toLabel := "a/"+result.Path, "b/"+result.Path
+	if result.FromChangeID == "" {
+		fromLabel = "/dev/null"
+	}
+
+	if result.Identical && !result.ModeChanged {
+		fmt.Println("No differences")
+		return
+	}
+
+	fmt.Println(paint(colorBold, fmt.Sprintf("diff %s %s", shortID(result.FromChangeID), shortID(result.ToChangeID))))
+	if result.ModeChanged {
+		fmt.Println(paint(colorBold, fmt.Sprintf("old mode %s", fileMode(result.FromMode))))
+		fmt.Println(paint(colorBold, fmt.Sprintf("new mode %s", fileMode(result.ToMode))))
+	}
+	switch {
+	case result.Identical:
+		return
+	case result.IsBinary:
+		fmt.Printf("Binary files %s and %s differ\n", fromLabel, toLabel)
+		return
+	case result.TooLarge:
+		fmt.Printf("Files %s and %s differ (too large to diff)\n", fromLabel, toLabel)
+		return
+	}
+	fmt.Println(paint(colorBold, "--- "+fromLabel))
+	fmt.Println(paint(colorBold, "+++ "+toLabel))
+	for _, hunk := range result.Hunks {
+		fmt.Println(paint(colorCyan, hunk.Header()))
+		for _, edit := range hunk.Edits {
+			line := edit.Op.Prefix() + strings.TrimSuffix(edit.Text, "\n")
+			switch edit.Op {
+			case diffkit.DELETE:
+				line = paint(colorRed, line)
+			case diffkit.INSERT:
+				line = paint(colorGreen, line)
+			}
+			fmt.Println(line)
+			if !strings.HasSuffix(edit.Text, "\n") {
+				fmt.Println("\\ No newline at end of file")
+			}
+		}
+	}
+}
+
+
+// fileMode formats permission bits the way git shows them, e.g. 100755.
+func fileMode(mode uint32) string {
+	return fmt.Sprintf("%o", 0o100000|mode)
+}
+
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
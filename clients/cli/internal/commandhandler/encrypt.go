@@ -0,0 +1,105 @@
+package commandhandler
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"vcx/clients/cli/internal/client/api"
+	"vcx/clients/cli/internal/client/api/storage"
+)
+
+
+const PASSPHRASE_ENV = "VCX_PASSPHRASE"
+
+
+// Encrypt shows or changes encryption at rest of blob contents. Turning it
+// on or off reseals the existing blobs. The key comes from --keyfile
+// (created if missing) or a passphrase in VCX_PASSPHRASE; the agent needs
+// the same one when it starts.
+//
+//	vcx encrypt [--cipher aes-gcm|xchacha20] [--keyfile <path>] [--off] [--reencrypt]
+func Encrypt(args []string) {
+	flags     := flag.NewFlagSet("encrypt", flag.ExitOnError)
+	cipher    := flags.String("cipher", "", "turn encryption on with aes-gcm or xchacha20")
+	keyfile   := flags.String("keyfile", "", "keyfile to read the key from, created if missing")
+	off       := flags.Bool("off", false, "turn encryption off and decrypt the stored blobs")
+	reencrypt := flags.Bool("reencrypt", false, "reseal the blobs an earlier run left over")
+	flags.Parse(args[2:])
+
+	if *cipher == "" && !*off && !*reencrypt {
+		showEncryption()
+		return
+	}
+
+	request := storage.EncryptionRequest{
+		Algorithm:  *cipher,
+		Passphrase: os.Getenv(PASSPHRASE_ENV),
+		Off:        *off,
+		Reencrypt:  *reencrypt,
+	}
+	if *keyfile != "" {
+		path, err := filepath.Abs(*keyfile)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		request.Keyfile    = path
+		request.Passphrase = ""
+	}
+
+	resp, err := storage.SetEncryption(request)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	var report storage.EncryptionReport
+	if err := api.DecodeJSON(resp, &report); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Resealed %d blob(s) and %d dictionary(ies)\n", report.Blobs, report.Dictionaries)
+	if report.Skipped > 0 {
+		fmt.Printf("Skipped %d sealed with a key that is not loaded\n", report.Skipped)
+	}
+	if report.Failed > 0 {
+		fmt.Printf("Failed to reseal %d, see the agent log (vcx encrypt --reencrypt to retry)\n", report.Failed)
+	}
+}
+
+
+func showEncryption() {
+	resp, err := storage.GetEncryption()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	var status storage.EncryptionStatus
+	if err := api.DecodeJSON(resp, &status); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Encryption: %s\n", status.Algorithm)
+	if status.KeyID != "" {
+		source := "passphrase"
+		if status.Keyfile != "" {
+			source = status.Keyfile
+		}
+		loaded := "loaded"
+		if !status.Loaded {
+			loaded = "not loaded"
+		}
+		fmt.Printf("Key %s (%s), %s\n", status.KeyID, source, loaded)
+	}
+	fmt.Printf("%d blob(s) encrypted, %d in plain", status.Encrypted, status.Plain)
+	if status.OtherKeys > 0 {
+		fmt.Printf(", %d with another key", status.OtherKeys)
+	}
+	fmt.Println()
+}
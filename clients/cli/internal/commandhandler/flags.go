@@ -0,0 +1,32 @@
+package commandhandler
+
+import (
+	"flag"
+	"path/filepath"
+	"vcx/pkg/toolkit/pathkit"
+)
+
+
+// parseArgs parses flags that may appear before, between or after positional
+// arguments (`vcx log file.txt --limit 5`) and returns the positionals.
+func parseArgs(flags *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		flags.Parse(args)
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+
+// absPath resolves a path given on the command line against the CWD.
+func absPath(path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(pathkit.CWD(), path)
+}
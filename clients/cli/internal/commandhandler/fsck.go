@@ -0,0 +1,68 @@
+package commandhandler
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"vcx/clients/cli/internal/client/api"
+	"vcx/clients/cli/internal/client/api/storage"
+)
+
+
+// Fsck verifies that every blob still matches its hash and that all
+// references and reference counters are consistent. Exits with 1 when
+// problems remain.
+//
+//	vcx fsck [--repair]
+func Fsck(args []string) {
+	flags  := flag.NewFlagSet("fsck", flag.ExitOnError)
+	repair := flags.Bool("repair", false, "fix sizes and reference counters")
+	flags.Parse(args[2:])
+
+	resp, err := storage.Fsck(*repair)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	var report storage.FsckReport
+	if err := api.DecodeJSON(resp, &report); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	repairable := 0
+	for _, problem := range report.Problems {
+		if !problem.Repaired && (problem.Kind == "refcount" || problem.Kind == "size-mismatch") {
+			repairable++
+		}
+		subject := shortID(problem.BlobID)
+		if problem.Table != "" {
+			subject = fmt.Sprintf("%s %s", problem.Table, shortID(problem.ID))
+			if problem.Path != "" {
+				subject += " (" + problem.Path + ")"
+			}
+		}
+		status := ""
+		if problem.Repaired {
+			status = " [repaired]"
+		}
+		fmt.Printf("%-14s %s: %s%s\n", problem.Kind, subject, problem.Detail, status)
+	}
+
+	fmt.Printf("Checked %d blobs, %d files, %d changes, %d snapshots\n", report.Blobs, report.Files, report.Changes, report.Snapshots)
+	remaining := len(report.Problems) - report.Repaired
+	switch {
+	case len(report.Problems) == 0:
+		fmt.Println("No problems found")
+	case remaining == 0:
+		fmt.Printf("Repaired %d problem(s)\n", report.Repaired)
+	default:
+		fmt.Printf("%d problem(s) found, %d repaired\n", len(report.Problems), report.Repaired)
+		if repairable > 0 {
+			fmt.Printf("Run `vcx fsck --repair` to fix %d of them\n", repairable)
+		}
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,53 @@
+package commandhandler
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"vcx/clients/cli/internal/client/api"
+	"vcx/clients/cli/internal/client/api/storage"
+	"vcx/pkg/toolkit/convertkit"
+)
+
+
+// GC removes blobs no version refers to and orphan files in the blob store.
+//
+//	vcx gc [--dry-run] [--grace 24h]
+func GC(args []string) {
+	flags  := flag.NewFlagSet("gc", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "only report what would be reclaimed")
+	grace  := flags.String("grace", "", "leave garbage younger than this alone (default 24h)")
+	flags.Parse(args[2:])
+
+	params := url.Values{}
+	if *dryRun {
+		params.Set("dryRun", "true")
+	}
+	if *grace != "" {
+		params.Set("grace", *grace)
+	}
+
+	resp, err := storage.GC(params)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	var report storage.GCReport
+	if err := api.DecodeJSON(resp, &report); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	verb := "Removed"
+	if report.DryRun {
+		verb = "Would remove"
+	}
+	fmt.Printf("%s %d blob(s), %s\n", verb, len(report.Blobs), convertkit.BytesToHuman(report.BlobBytes))
+	fmt.Printf("%s %d orphan file(s), %s\n", verb, len(report.Orphans), convertkit.BytesToHuman(report.OrphanBytes))
+	if report.Pending > 0 {
+		fmt.Printf("%d unreferenced blob(s) within the %s grace period\n", report.Pending, report.GracePeriod)
+	}
+}
@@ -0,0 +1,80 @@
+package commandhandler
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"vcx/clients/cli/internal/client/api"
+	"vcx/clients/cli/internal/client/api/file"
+	"vcx/pkg/toolkit/convertkit"
+)
+
+
+// Log prints the recorded versions of a file, newest first.
+//
+//	vcx log <path> [--limit N] [--since T] [--until T]
+//
+// T is RFC3339, YYYY-MM-DD[ HH:MM] or an age such as 2h or 3d.
+func Log(args []string) {
+	flags := flag.NewFlagSet("log", flag.ExitOnError)
+	limit := flags.Int("limit", 0, "show at most this many versions")
+	since := flags.String("since", "", "only versions recorded at or after this time")
+	until := flags.String("until", "", "only versions recorded at or before this time")
+	positional := parseArgs(flags, args[2:])
+	if len(positional) != 1 {
+		fmt.Println("Usage: vcx log <path> [--limit N] [--since T] [--until T]")
+		os.Exit(1)
+	}
+
+	params := url.Values{}
+	if *limit > 0 {
+		params.Set("limit", strconv.Itoa(*limit))
+	}
+	if *since != "" {
+		params.Set("since", *since)
+	}
+	if *until != "" {
+		params.Set("until", *until)
+	}
+
+	resp, err := file.History(absPath(positional[0]), params)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	var history file.HistoryResponse
+	if err := api.DecodeJSON(resp, &history); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	for _, version := range history.Versions {
+		size := convertkit.BytesToHuman(version.Size)
+		blob := shortID(version.BlobID)
+		if version.IsDeleted {
+			size, blob = "-", "-"
+		} else if version.Target != "" {
+			size, blob = "-", "-> "+version.Target
+		}
+		summary := version.Summary
+		if version.IsConflicted {
+			summary += " [unresolved conflict]"
+		}
+		fmt.Printf("%s  %s  %10s  %-12s  %s\n", version.ChangeID, version.Timestamp, size, blob, summary)
+	}
+	if len(history.Versions) == 0 {
+		fmt.Println("No versions in the requested range")
+	}
+}
+
+
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
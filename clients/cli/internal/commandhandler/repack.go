@@ -0,0 +1,57 @@
+package commandhandler
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"vcx/clients/cli/internal/client/api"
+	"vcx/clients/cli/internal/client/api/storage"
+	"vcx/pkg/toolkit/convertkit"
+)
+
+
+// Repack moves blobs kept in the database or as small loose files into pack
+// files, and rewrites packs that are mostly dead entries.
+//
+//	vcx repack [--dry-run]
+func Repack(args []string) {
+	flags  := flag.NewFlagSet("repack", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "only report what would be moved")
+	flags.Parse(args[2:])
+
+	params := url.Values{}
+	if *dryRun {
+		params.Set("dryRun", "true")
+	}
+
+	resp, err := storage.Repack(params)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	var report storage.RepackReport
+	if err := api.DecodeJSON(resp, &report); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	verb := "Packed"
+	if report.DryRun {
+		verb = "Would pack"
+	}
+	fmt.Printf("%s %d blob(s), %s (%d from the database, %d loose file(s), %d from old packs)\n",
+		verb, report.Blobs, convertkit.BytesToHuman(report.Bytes), report.FromDB, report.FromFiles, report.FromPacks)
+	if !report.DryRun {
+		fmt.Printf("Wrote %d pack(s)\n", len(report.Packs))
+	}
+	if len(report.Rewritten) > 0 {
+		verb = "Rewrote"
+		if report.DryRun {
+			verb = "Would rewrite"
+		}
+		fmt.Printf("%s %d pack(s), reclaiming %s\n", verb, len(report.Rewritten), convertkit.BytesToHuman(report.Reclaimed))
+	}
+}
@@ -0,0 +1,38 @@
+package commandhandler
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"vcx/clients/cli/internal/client/api"
+	"vcx/clients/cli/internal/client/api/file"
+)
+
+
+// Restore puts a previous version of a file back into the working copy.
+// The restore is recorded as a new version, so it can be undone the same way.
+//
+//	vcx restore <path> --to <change>
+func Restore(args []string) {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	to    := flags.String("to", "", "change ID of the version to restore (see vcx log)")
+	positional := parseArgs(flags, args[2:])
+	if len(positional) != 1 || *to == "" {
+		fmt.Println("Usage: vcx restore <path> --to <change>")
+		os.Exit(1)
+	}
+
+	resp, err := file.Restore(absPath(positional[0]), *to)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	var result file.RestoreResponse
+	if err := api.DecodeJSON(resp, &result); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Printf("Restored %s to %s (recorded as %s)\n", result.Path, result.RestoredFrom, result.ChangeID)
+}
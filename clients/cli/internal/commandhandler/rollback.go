@@ -0,0 +1,88 @@
+package commandhandler
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"vcx/clients/cli/internal/client/api/project"
+	"vcx/pkg/toolkit/pathkit"
+)
+
+
+// Rollback restores the whole project containing the CWD to a change or a
+// point in time. Files created later are deleted, ignored paths are kept.
+//
+//	vcx rollback (--to <change> | --at <time>) [--dry-run] [--force]
+func Rollback(args []string) {
+	flags  := flag.NewFlagSet("rollback", flag.ExitOnError)
+	to     := flags.String("to", "", "change ID to roll back to")
+	at     := flags.String("at", "", "point in time to roll back to (RFC3339, YYYY-MM-DD[ HH:MM] or an age like 2h)")
+	dryRun := flags.Bool("dry-run", false, "only list the planned writes and deletes")
+	force  := flags.Bool("force", false, "overwrite unrecorded local modifications")
+	flags.Parse(args[2:])
+	if (*to == "") == (*at == "") {
+		fmt.Println("Usage: vcx rollback (--to <change> | --at <time>) [--dry-run] [--force]")
+		os.Exit(1)
+	}
+
+	params := url.Values{}
+	if *to != "" {
+		params.Set("to", *to)
+	}
+	if *at != "" {
+		params.Set("at", *at)
+	}
+	if *dryRun {
+		params.Set("dryRun", "true")
+	}
+	if *force {
+		params.Set("force", "true")
+	}
+
+	resp, err := project.Restore(pathkit.CWD(), params)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var failure project.RestoreError
+		if err := json.NewDecoder(resp.Body).Decode(&failure); err != nil || failure.Error == "" {
+			fmt.Printf("agent returned %s\n", resp.Status)
+			os.Exit(1)
+		}
+		fmt.Println(failure.Error)
+		if failure.Plan != nil {
+			for _, path := range failure.Plan.Modified {
+				fmt.Printf("  modified: %s\n", path)
+			}
+		}
+		os.Exit(1)
+	}
+
+	var plan project.RestorePlan
+	if err := json.NewDecoder(resp.Body).Decode(&plan); err != nil {
+		fmt.Printf("error reading response: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, action := range plan.Actions {
+		if action.From != "" {
+			fmt.Printf("%-6s  %s -> %s\n", action.Op, action.From, action.Path)
+			continue
+		}
+		fmt.Printf("%-6s  %s\n", action.Op, action.Path)
+	}
+	switch {
+	case len(plan.Actions) == 0:
+		fmt.Println("Working copy already matches", plan.ChangeID)
+	case plan.Applied:
+		fmt.Printf("Rolled back to %s (%d changes)\n", plan.ChangeID, len(plan.Actions))
+	default:
+		fmt.Printf("Dry run: %d changes would roll back to %s\n", len(plan.Actions), plan.ChangeID)
+	}
+}
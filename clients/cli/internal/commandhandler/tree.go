@@ -0,0 +1,94 @@
+package commandhandler
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"vcx/clients/cli/internal/client/api"
+	"vcx/clients/cli/internal/client/api/project"
+	"vcx/pkg/toolkit/pathkit"
+)
+
+
+// Ls lists the files recorded on the current branch of the project
+// containing the CWD.
+//
+//	vcx ls [--deleted]
+func Ls(args []string) {
+	flags   := flag.NewFlagSet("ls", flag.ExitOnError)
+	deleted := flags.Bool("deleted", false, "include deleted files")
+	parseArgs(flags, args[2:])
+
+	resp, err := project.Files(pathkit.CWD(), *deleted)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	var files []project.TreeFile
+	if err := api.DecodeJSON(resp, &files); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	for _, file := range files {
+		content := shortID(file.BlobID)
+		if file.Target != "" {
+			content = "-> " + file.Target
+		}
+		path := file.Path
+		if file.Type == "DIR" {
+			path += "/"
+		}
+		line := fmt.Sprintf("%-8s  %-12s  %s", file.Type, content, path)
+		if file.LinkType == "EXTERNAL" {
+			line += " (external)"
+		}
+		if file.IsDeleted {
+			line += " (deleted)"
+		}
+		fmt.Println(line)
+	}
+	if len(files) == 0 {
+		fmt.Println("No files recorded")
+	}
+}
+
+
+// Rescan records what changed in the working copy of the project
+// containing the CWD while the agent was not watching, deletions included.
+//
+//	vcx rescan
+func Rescan(args []string) {
+	resp, err := project.Rescan(pathkit.CWD())
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	var result project.RescanResult
+	if err := api.DecodeJSON(resp, &result); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	for _, path := range result.Recorded {
+		fmt.Printf("recorded  %s\n", path)
+	}
+	for _, rename := range result.Renamed {
+		fmt.Printf("renamed   %s -> %s\n", rename.From, rename.To)
+	}
+	for _, path := range result.Deleted {
+		fmt.Printf("deleted   %s\n", path)
+	}
+	for _, path := range result.Failed {
+		fmt.Printf("failed    %s\n", path)
+	}
+	fmt.Printf("Scanned %d paths (%d unchanged): %d recorded, %d renamed, %d deleted\n",
+		result.Scanned, result.Unchanged, len(result.Recorded), len(result.Renamed), len(result.Deleted))
+	if len(result.Failed) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,183 @@
+// Package chunkkit splits content into variable size, content-defined chunks
+// with FastCDC (Xia et al., 2016).
+//
+// Chunk boundaries depend only on the bytes around them, so an insertion or
+// deletion only changes the chunks it touches: the rest of the content cuts
+// into the same chunks as before and deduplicates. Boundaries are found with
+// a Gear rolling hash and normalized chunking, which keeps chunk sizes close
+// to the average.
+//
+// The Gear table is derived from a fixed seed. Changing it (or the masks)
+// changes every boundary and defeats deduplication against stored chunks.
+package chunkkit
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+
+const (
+	DEFAULT_MIN_SIZE = 64 * 1024
+	DEFAULT_AVG_SIZE = 256 * 1024
+	DEFAULT_MAX_SIZE = 1024 * 1024
+
+	gearSeed uint64 = 0x7663782d63646321 // "vcx-cdc!"
+)
+
+
+var gear [256]uint64
+
+
+func init() {
+	// splitmix64
+	state := gearSeed
+	for i := range gear {
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z  = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z  = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		gear[i] = z ^ (z >> 31)
+	}
+}
+
+
+// Options bound the chunk sizes. AvgSize must be a power of two.
+type Options struct {
+	MinSize int
+	AvgSize int
+	MaxSize int
+}
+
+
+func DefaultOptions() Options {
+	return Options{MinSize: DEFAULT_MIN_SIZE, AvgSize: DEFAULT_AVG_SIZE, MaxSize: DEFAULT_MAX_SIZE}
+}
+
+
+func (o Options) Validate() error {
+	if o.MinSize <= 0 || o.AvgSize <= o.MinSize || o.MaxSize <= o.AvgSize {
+		return fmt.Errorf("chunk sizes must satisfy 0 < min < avg < max")
+	}
+	if o.AvgSize&(o.AvgSize-1) != 0 {
+		return fmt.Errorf("average chunk size must be a power of two")
+	}
+	return nil
+}
+
+
+// masks returns the stricter mask used before the average size and the
+// looser one used after it (normalization level 1). The masks select high
+// bits of the hash, which depend on the last 64 bytes.
+func (o Options) masks() (small, large uint64) {
+	avgBits := bits.TrailingZeros(uint(o.AvgSize))
+	small    = ^uint64(0) << (64 - (avgBits + 1))
+	large    = ^uint64(0) << (64 - (avgBits - 1))
+	return small, large
+}
+
+
+// Cut returns the length of the first chunk of data. Data shorter than
+// MinSize is a single chunk.
+func Cut(data []byte, options Options) int {
+	small, large := options.masks()
+	return cut(data, options, small, large)
+}
+
+
+func cut(data []byte, options Options, small, large uint64) int {
+	n := len(data)
+	if n <= options.MinSize {
+		return n
+	}
+	n       = min(n, options.MaxSize)
+	normal := min(n, options.AvgSize)
+
+	var hash uint64
+	i := options.MinSize
+	for ; i < normal; i++ {
+		hash = (hash << 1) + gear[data[i]]
+		if hash&small == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		hash = (hash << 1) + gear[data[i]]
+		if hash&large == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
+
+
+// Chunker reads content from a reader and returns it chunk by chunk, holding
+// at most two maximum size chunks in memory.
+type Chunker struct {
+	reader  io.Reader
+	options Options
+	small   uint64
+	large   uint64
+	buf     []byte
+	start   int
+	end     int
+	eof     bool
+}
+
+
+func NewChunker(r io.Reader, options Options) (*Chunker, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	small, large := options.masks()
+	return &Chunker{
+		reader:  r,
+		options: options,
+		small:   small,
+		large:   large,
+		buf:     make([]byte, 2*options.MaxSize),
+	}, nil
+}
+
+
+// Next returns the next chunk, or io.EOF after the last one. The returned
+// slice is only valid until the next call.
+func (c *Chunker) Next() ([]byte, error) {
+	if c.end-c.start < c.options.MaxSize && !c.eof {
+		if err := c.fill(); err != nil {
+			return nil, err
+		}
+	}
+	if c.start == c.end {
+		return nil, io.EOF
+	}
+
+	n     := cut(c.buf[c.start:c.end], c.options, c.small, c.large)
+	chunk := c.buf[c.start : c.start+n]
+	c.start += n
+	return chunk, nil
+}
+
+
+// fill moves the unread bytes to the front of the buffer and reads until it
+// is full or the reader is exhausted.
+func (c *Chunker) fill() error {
+	copy(c.buf, c.buf[c.start:c.end])
+	c.end  -= c.start
+	c.start = 0
+
+	for c.end < len(c.buf) {
+		n, err := c.reader.Read(c.buf[c.end:])
+		c.end += n
+		if errors.Is(err, io.EOF) {
+			c.eof = true
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package chunkkit
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+
+var testOptions = Options{MinSize: 2 * 1024, AvgSize: 8 * 1024, MaxSize: 32 * 1024}
+
+
+func chunks(t *testing.T, data []byte, options Options) [][]byte {
+	t.Helper()
+	chunker, err := NewChunker(bytes.NewReader(data), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result [][]byte
+	for {
+		chunk, err := chunker.Next()
+		if errors.Is(err, io.EOF) {
+			return result
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		result = append(result, append([]byte(nil), chunk...))
+	}
+}
+
+
+func randomData(seed int64, size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+
+func TestChunksReassembleAndRespectBounds(t *testing.T) {
+	data   := randomData(1, 1024*1024)
+	result := chunks(t, data, testOptions)
+
+	if joined := bytes.Join(result, nil); !bytes.Equal(joined, data) {
+		t.Fatalf("chunks do not reassemble the content")
+	}
+	for i, chunk := range result {
+		if len(chunk) > testOptions.MaxSize {
+			t.Fatalf("chunk %d is %d bytes, above the maximum", i, len(chunk))
+		}
+		if len(chunk) < testOptions.MinSize && i != len(result)-1 {
+			t.Fatalf("chunk %d is %d bytes, below the minimum", i, len(chunk))
+		}
+	}
+
+	average := len(data) / len(result)
+	if average < testOptions.AvgSize/2 || average > testOptions.AvgSize*2 {
+		t.Fatalf("average chunk size %d far from %d", average, testOptions.AvgSize)
+	}
+}
+
+
+func TestInsertionOnlyChangesNearbyChunks(t *testing.T) {
+	data   := randomData(2, 1024*1024)
+	edited := append(append(append([]byte(nil), data[:300000]...), []byte("inserted")...), data[300000:]...)
+
+	before := make(map[string]bool)
+	for _, chunk := range chunks(t, data, testOptions) {
+		before[string(chunk)] = true
+	}
+	after  := chunks(t, edited, testOptions)
+	shared := 0
+	for _, chunk := range after {
+		if before[string(chunk)] {
+			shared++
+		}
+	}
+	if changed := len(after) - shared; changed > 3 {
+		t.Fatalf("%d of %d chunks changed after a small insertion", changed, len(after))
+	}
+}
+
+
+func TestSmallAndEmptyContent(t *testing.T) {
+	if result := chunks(t, nil, testOptions); len(result) != 0 {
+		t.Fatalf("expected no chunks for empty content, got %d", len(result))
+	}
+	if result := chunks(t, []byte("tiny"), testOptions); len(result) != 1 || string(result[0]) != "tiny" {
+		t.Fatalf("expected content below the minimum to be one chunk")
+	}
+}
+
+
+func TestOptionsValidate(t *testing.T) {
+	if err := DefaultOptions().Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := (Options{MinSize: 1024, AvgSize: 3000, MaxSize: 8192}).Validate(); err == nil {
+		t.Fatalf("expected a non power of two average to fail")
+	}
+}
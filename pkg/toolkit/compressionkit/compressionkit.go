@@ -5,10 +5,14 @@
 //   - Compression ratio: compressed must be <95% of original
 //
 // Uses zstd compression with default speed settings for balanced performance.
-// Provides both simple and buffer-reuse APIs for memory efficiency.
+// Provides both simple and buffer-reuse APIs for memory efficiency,
+// streaming writers/readers for content that does not fit in memory, and
+// trained dictionaries for small inputs.
 package compressionkit
 
 import (
+	"io"
+
 	"github.com/klauspost/compress/zstd"
 )
 
@@ -78,3 +82,21 @@ func DecompressBuffer(data []byte, dest []byte) ([]byte, error) {
 
 	return decompressed, nil
 }
+
+
+// NewWriter returns a streaming zstd encoder writing to w. Memory use is
+// bounded by the encoder window, whatever the amount written. Close flushes
+// the last frame but does not close w.
+func NewWriter(w io.Writer) (*zstd.Encoder, error) {
+	return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.SpeedDefault), zstd.WithEncoderConcurrency(1))
+}
+
+
+// NewReader returns a streaming zstd decoder reading from r.
+func NewReader(r io.Reader) (io.ReadCloser, error) {
+	decoder, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return decoder.IOReadCloser(), nil
+}
@@ -0,0 +1,81 @@
+package compressionkit
+
+import (
+	"fmt"
+
+	"github.com/klauspost/compress/dict"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	MIN_DICT_COMPRESS_SIZE = 64 // a dictionary makes much smaller inputs worth compressing
+	DEFAULT_DICT_SIZE      = 64 * 1024
+)
+
+
+// Dictionary compresses with a trained zstd dictionary, which gives small
+// inputs the shared context they lack on their own. Frames written with it
+// carry its ID and can only be decompressed by the same dictionary.
+type Dictionary struct {
+	id      uint32
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+}
+
+
+// TrainDictionary builds a zstd dictionary of at most maxSize bytes from
+// samples of the content it will compress. The dictionary gets a random ID.
+func TrainDictionary(samples [][]byte, maxSize int) ([]byte, error) {
+	return dict.BuildZstdDict(samples, dict.Options{
+		MaxDictSize: maxSize,
+		HashBytes:   6,
+		ZstdLevel:   zstd.SpeedDefault,
+	})
+}
+
+
+// NewDictionary loads a dictionary built by TrainDictionary.
+func NewDictionary(data []byte) (*Dictionary, error) {
+	inspected, err := zstd.InspectDictionary(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dictionary: %w", err)
+	}
+
+	encoder, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault), zstd.WithEncoderDict(data))
+	if err != nil {
+		return nil, err
+	}
+	decoder, err := zstd.NewReader(nil, zstd.WithDecoderDicts(data))
+	if err != nil {
+		encoder.Close()
+		return nil, err
+	}
+	return &Dictionary{id: inspected.ID(), encoder: encoder, decoder: decoder}, nil
+}
+
+
+// ID returns the dictionary ID recorded in every frame it writes.
+func (d *Dictionary) ID() uint32 {
+	return d.id
+}
+
+
+// Compress compresses data with the dictionary if beneficial (at least
+// MIN_DICT_COMPRESS_SIZE bytes, <95% ratio).
+func (d *Dictionary) Compress(data []byte) ([]byte, bool) {
+	if len(data) < MIN_DICT_COMPRESS_SIZE {
+		return data, false
+	}
+
+	compressed := d.encoder.EncodeAll(data, make([]byte, 0, len(data)))
+	if float64(len(compressed)) < float64(len(data))*MIN_COMPRESSION_RATIO {
+		return compressed, true
+	}
+	return data, false
+}
+
+
+// Decompress decompresses data written with the dictionary.
+func (d *Dictionary) Decompress(data []byte) ([]byte, error) {
+	return d.decoder.DecodeAll(data, make([]byte, 0, len(data)*2))
+}
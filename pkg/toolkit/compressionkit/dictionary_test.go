@@ -0,0 +1,51 @@
+package compressionkit
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+
+func source(i int) []byte {
+	return []byte(fmt.Sprintf("package handler\n\nimport \"fmt\"\n\n// Handle%d handles item %d.\nfunc Handle%d(id string) error {\n\tif id == \"\" {\n\t\treturn fmt.Errorf(\"missing id\")\n\t}\n\treturn nil\n}\n", i, i*7, i))
+}
+
+
+func TestDictionaryRoundTrip(t *testing.T) {
+	var samples [][]byte
+	for i := range 100 {
+		samples = append(samples, source(i))
+	}
+	data, err := TrainDictionary(samples, DEFAULT_DICT_SIZE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dictionary, err := NewDictionary(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dictionary.ID() == 0 {
+		t.Fatalf("expected a dictionary ID")
+	}
+
+	content := source(1000)
+	compressed, ok := dictionary.Compress(content)
+	if !ok {
+		t.Fatalf("expected %d bytes of similar content to compress with the dictionary", len(content))
+	}
+	if plain, _ := Compress(content); len(compressed) >= len(plain) {
+		t.Fatalf("dictionary output %d bytes, plain %d", len(compressed), len(plain))
+	}
+
+	decompressed, err := dictionary.Decompress(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decompressed, content) {
+		t.Fatalf("content does not round-trip")
+	}
+	if _, err := Decompress(compressed); err == nil {
+		t.Fatalf("expected decompression without the dictionary to fail")
+	}
+}
@@ -3,6 +3,8 @@
 // an application to ensure uniform behavior.
 package convertkit
 
+import "fmt"
+
 // BoolToInt converts a boolean value to an integer.
 // It returns 1 for true and 0 for false.
 func BoolToInt(value bool) int {
@@ -20,3 +22,17 @@ func IntToBool(value int) bool {
     }
     return false
 }
+
+// BytesToHuman formats a byte count with a binary unit, e.g. 1536 -> "1.5 KiB".
+func BytesToHuman(size int64) string {
+    const unit = 1024
+    if size < unit {
+        return fmt.Sprintf("%d B", size)
+    }
+    div, exp := int64(unit), 0
+    for n := size / unit; n >= unit; n /= unit {
+        div *= unit
+        exp++
+    }
+    return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
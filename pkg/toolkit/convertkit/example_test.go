@@ -16,10 +16,18 @@ func Example() {
 	fmt.Printf("0 as bool: %v\n", convertkit.IntToBool(0))
 	fmt.Printf("-3 as bool: %v\n", convertkit.IntToBool(-3))
 
+	// Format byte counts
+	fmt.Println(convertkit.BytesToHuman(512))
+	fmt.Println(convertkit.BytesToHuman(1536))
+	fmt.Println(convertkit.BytesToHuman(5 * 1024 * 1024))
+
 	// Output:
 	// true as int: 1
 	// false as int: 0
 	// 5 as bool: true
 	// 0 as bool: false
 	// -3 as bool: false
+	// 512 B
+	// 1.5 KiB
+	// 5.0 MiB
 }
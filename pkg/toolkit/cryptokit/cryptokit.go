@@ -1,6 +1,6 @@
-// Package cryptokit provides cryptographic hashing utilities.
+// Package cryptokit provides cryptographic hashing and encryption utilities.
 //
-// Two main use cases:
+// Three main use cases:
 //
 //  1. Short IDs: Blake2b-based Hash() generates compact identifiers
 //     with custom base encoding (b32, b62, b64, hex)
@@ -8,6 +8,9 @@
 //  2. Cryptographic hashing: SHA family functions for content addressing,
 //     integrity verification, and security (MD5Hex, SHA1Hex, SHA256Hex, SHA512Hex)
 //
+//  3. Encryption: Key (random, from a keyfile, or derived from a passphrase)
+//     seals data with AES-GCM or XChaCha20-Poly1305, in memory or streamed
+//
 // Example - Generate short ID:
 //
 //	id, _ := cryptokit.Hash("user@example.com")           // b62 encoding (default)
@@ -17,6 +20,12 @@
 //
 //	hash := cryptokit.SHA256Hex(fileData)                  // for blob deduplication
 //	hash := cryptokit.MD5Hex([]byte("password"))           // for checksums
+//
+// Example - Encryption:
+//
+//	key, _ := cryptokit.DeriveKey(passphrase, salt)
+//	sealed, _ := key.Encrypt(cryptokit.XCHACHA20, data)
+//	data, _ = key.Decrypt(sealed)
 package cryptokit
 
 import (
@@ -27,6 +36,7 @@ import (
 	"encoding/base32"
 	"encoding/hex"
 	"hash"
+	"io"
 	"math/big"
 	"strings"
 
@@ -180,3 +190,12 @@ func SHA512Hex(data []byte) string {
 	h := sha512.Sum512(data)
 	return hex.EncodeToString(h[:])
 }
+
+// SHA256HexReader calculates the SHA256 hash of everything read from r and returns hex string.
+func SHA256HexReader(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,363 @@
+package cryptokit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Encryption uses a chunked AEAD stream, so data of any size can be written
+// and read with bounded memory:
+//
+//	header   MAGIC algorithm(1) nonce-prefix
+//	segment* seal(plaintext[SEGMENT_SIZE])
+//
+// The nonce of segment i is the prefix, i as a big endian uint32 and a
+// flag marking the last segment, so segments cannot be reordered, dropped
+// or cut off at the end unnoticed. The header is authenticated with every
+// segment.
+
+
+const (
+	AES_GCM   = "aes-gcm"
+	XCHACHA20 = "xchacha20"
+
+	KEY_SIZE     = 32
+	SALT_SIZE    = 16
+	SEGMENT_SIZE = 64 * 1024
+
+	ENCRYPTION_MAGIC = "VXE1"
+)
+
+
+var ErrDecrypt = errors.New("cannot decrypt: wrong key or corrupt data")
+
+
+// algorithm IDs as written to the header
+var algorithms = map[string]byte{AES_GCM: 1, XCHACHA20: 2}
+
+
+// Argon2id parameters for DeriveKey (RFC 9106 second recommendation).
+const (
+	argonTime    = 3
+	argonMemory  = 64 * 1024
+	argonThreads = 4
+)
+
+
+// Key is a 256-bit secret key. Each algorithm gets its own subkey.
+type Key struct {
+	raw []byte
+	id  string
+}
+
+
+// IsAlgorithm reports whether algorithm names a supported cipher.
+func IsAlgorithm(algorithm string) bool {
+	_, ok := algorithms[algorithm]
+	return ok
+}
+
+
+// NewKey wraps KEY_SIZE bytes of key material, e.g. read from a keyfile.
+func NewKey(raw []byte) (*Key, error) {
+	if len(raw) != KEY_SIZE {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", KEY_SIZE, len(raw))
+	}
+	mac := hmac.New(sha256.New, raw)
+	mac.Write([]byte("vcx key id"))
+	return &Key{raw: bytes.Clone(raw), id: hex.EncodeToString(mac.Sum(nil))[:16]}, nil
+}
+
+
+// GenerateKey returns a new random key.
+func GenerateKey() (*Key, error) {
+	raw := make([]byte, KEY_SIZE)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	return NewKey(raw)
+}
+
+
+// NewSalt returns a random salt for DeriveKey.
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, SALT_SIZE)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+
+// DeriveKey derives a key from a passphrase with Argon2id. The same
+// passphrase and salt always give the same key.
+func DeriveKey(passphrase string, salt []byte) (*Key, error) {
+	if passphrase == "" {
+		return nil, errors.New("empty passphrase")
+	}
+	if len(salt) < SALT_SIZE {
+		return nil, fmt.Errorf("salt must be at least %d bytes", SALT_SIZE)
+	}
+	return NewKey(argon2.IDKey([]byte(passphrase), salt, argonTime, argonMemory, argonThreads, KEY_SIZE))
+}
+
+
+// ID returns a fingerprint of the key that reveals nothing about it, to
+// tell which key data was encrypted with.
+func (k *Key) ID() string {
+	return k.id
+}
+
+
+// Bytes returns the key material, e.g. to write a keyfile.
+func (k *Key) Bytes() []byte {
+	return bytes.Clone(k.raw)
+}
+
+
+// Encrypt seals plaintext with algorithm.
+func (k *Key) Encrypt(algorithm string, plaintext []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(len(plaintext) + len(plaintext)/SEGMENT_SIZE*32 + 64)
+
+	w, err := k.NewWriter(&buf, algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+
+// Decrypt opens data sealed by Encrypt or a writer of the key.
+func (k *Key) Decrypt(sealed []byte) ([]byte, error) {
+	r, err := k.NewReader(bytes.NewReader(sealed))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+
+// NewWriter returns a writer sealing everything written to it into w. Close
+// writes the last segment; it does not close w.
+func (k *Key) NewWriter(w io.Writer, algorithm string) (io.WriteCloser, error) {
+	id, ok := algorithms[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unknown cipher %q", algorithm)
+	}
+	aead, err := k.aead(id)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, len(ENCRYPTION_MAGIC)+1+aead.NonceSize()-5)
+	copy(header, ENCRYPTION_MAGIC)
+	header[len(ENCRYPTION_MAGIC)] = id
+	if _, err := rand.Read(header[len(ENCRYPTION_MAGIC)+1:]); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &sealWriter{
+		w:      w,
+		stream: newSegmentStream(aead, header),
+		buf:    make([]byte, 0, SEGMENT_SIZE),
+	}, nil
+}
+
+
+// NewReader returns a reader over the plaintext of a sealed stream. Reads
+// fail with ErrDecrypt if the key is wrong or the data was altered.
+func (k *Key) NewReader(r io.Reader) (io.Reader, error) {
+	reader := bufio.NewReaderSize(r, SEGMENT_SIZE+64)
+
+	prefix := make([]byte, len(ENCRYPTION_MAGIC)+1)
+	if _, err := io.ReadFull(reader, prefix); err != nil || string(prefix[:len(ENCRYPTION_MAGIC)]) != ENCRYPTION_MAGIC {
+		return nil, fmt.Errorf("%w: not an encrypted stream", ErrDecrypt)
+	}
+	aead, err := k.aead(prefix[len(ENCRYPTION_MAGIC)])
+	if err != nil {
+		return nil, err
+	}
+
+	header := append(prefix, make([]byte, aead.NonceSize()-5)...)
+	if _, err := io.ReadFull(reader, header[len(prefix):]); err != nil {
+		return nil, fmt.Errorf("%w: truncated header", ErrDecrypt)
+	}
+
+	return &openReader{
+		r:      reader,
+		stream: newSegmentStream(aead, header),
+		buf:    make([]byte, SEGMENT_SIZE+aead.Overhead()),
+	}, nil
+}
+
+
+// aead returns the cipher of an algorithm ID, keyed with its subkey.
+func (k *Key) aead(id byte) (cipher.AEAD, error) {
+	subkey := make([]byte, KEY_SIZE)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, k.raw, nil, []byte{'v', 'c', 'x', id}), subkey); err != nil {
+		return nil, err
+	}
+
+	switch id {
+	case algorithms[AES_GCM]:
+		block, err := aes.NewCipher(subkey)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case algorithms[XCHACHA20]:
+		return chacha20poly1305.NewX(subkey)
+	default:
+		return nil, fmt.Errorf("%w: unknown cipher %d", ErrDecrypt, id)
+	}
+}
+
+
+// segmentStream seals or opens the segments of one stream in order.
+type segmentStream struct {
+	aead    cipher.AEAD
+	header  []byte
+	nonce   []byte
+	counter uint32
+}
+
+func newSegmentStream(aead cipher.AEAD, header []byte) *segmentStream {
+	nonce := make([]byte, aead.NonceSize())
+	copy(nonce, header[len(ENCRYPTION_MAGIC)+1:])
+	return &segmentStream{aead: aead, header: header, nonce: nonce}
+}
+
+func (s *segmentStream) next(last bool) ([]byte, error) {
+	if s.counter == ^uint32(0) {
+		return nil, errors.New("encrypted stream too long")
+	}
+	n := len(s.nonce)
+	binary.BigEndian.PutUint32(s.nonce[n-5:n-1], s.counter)
+	s.nonce[n-1] = 0
+	if last {
+		s.nonce[n-1] = 1
+	}
+	s.counter++
+	return s.nonce, nil
+}
+
+
+type sealWriter struct {
+	w      io.Writer
+	stream *segmentStream
+	buf    []byte
+	closed bool
+}
+
+func (w *sealWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("write to closed encrypted stream")
+	}
+	written := 0
+	for len(p) > 0 {
+		// a full segment is only sealed once more data shows it is not the last
+		if len(w.buf) == SEGMENT_SIZE {
+			if err := w.seal(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(w.buf[len(w.buf):SEGMENT_SIZE], p)
+		w.buf    = w.buf[:len(w.buf)+n]
+		p        = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (w *sealWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.seal(true)
+}
+
+func (w *sealWriter) seal(last bool) error {
+	nonce, err := w.stream.next(last)
+	if err != nil {
+		return err
+	}
+	sealed := w.stream.aead.Seal(nil, nonce, w.buf, w.stream.header)
+	w.buf   = w.buf[:0]
+	_, err  = w.w.Write(sealed)
+	return err
+}
+
+
+type openReader struct {
+	r      *bufio.Reader
+	stream *segmentStream
+	buf    []byte
+	plain  []byte
+	done   bool
+}
+
+func (r *openReader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
+
+// open reads and opens the next segment. A short segment, or a full one
+// followed by the end of the data, must be the last one.
+func (r *openReader) open() error {
+	n, err := io.ReadFull(r.r, r.buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return err
+	}
+	last := n < len(r.buf)
+	if !last {
+		if _, err := r.r.Peek(1); errors.Is(err, io.EOF) {
+			last = true
+		}
+	}
+
+	nonce, err := r.stream.next(last)
+	if err != nil {
+		return err
+	}
+	plain, err := r.stream.aead.Open(r.buf[:0], nonce, r.buf[:n], r.stream.header)
+	if err != nil {
+		return ErrDecrypt
+	}
+	r.plain = plain
+	r.done  = last
+	return nil
+}
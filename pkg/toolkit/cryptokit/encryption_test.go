@@ -0,0 +1,106 @@
+package cryptokit
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+
+func TestEncryptRoundTrip(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sizes := []int{0, 1, SEGMENT_SIZE - 1, SEGMENT_SIZE, SEGMENT_SIZE + 1, 3*SEGMENT_SIZE + 17}
+	for _, algorithm := range []string{AES_GCM, XCHACHA20} {
+		for _, size := range sizes {
+			plaintext := bytes.Repeat([]byte{byte(size)}, size)
+			sealed, err := key.Encrypt(algorithm, plaintext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size >= 16 && bytes.Contains(sealed, plaintext) {
+				t.Fatalf("%s: plaintext visible in sealed data", algorithm)
+			}
+
+			opened, err := key.Decrypt(sealed)
+			if err != nil {
+				t.Fatalf("%s, %d bytes: %v", algorithm, size, err)
+			}
+			if !bytes.Equal(opened, plaintext) {
+				t.Fatalf("%s, %d bytes: does not round-trip", algorithm, size)
+			}
+		}
+	}
+}
+
+
+func TestDecryptDetectsTampering(t *testing.T) {
+	key, _   := GenerateKey()
+	other, _ := GenerateKey()
+	plaintext := bytes.Repeat([]byte("secret "), SEGMENT_SIZE/3)
+	sealed, err := key.Encrypt(XCHACHA20, plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := other.Decrypt(sealed); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("expected a wrong key to fail, got %v", err)
+	}
+
+	flipped := bytes.Clone(sealed)
+	flipped[len(flipped)/2] ^= 1
+	if _, err := key.Decrypt(flipped); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("expected a flipped bit to be detected, got %v", err)
+	}
+
+	// cut off after the first segment: what is left still authenticates as
+	// a segment, but not as the last one
+	headerSize := len(ENCRYPTION_MAGIC) + 1 + 24 - 5
+	truncated  := sealed[:headerSize+SEGMENT_SIZE+16]
+	if _, err := key.Decrypt(truncated); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("expected truncation to be detected, got %v", err)
+	}
+}
+
+
+func TestStreamInSmallWrites(t *testing.T) {
+	key, _ := GenerateKey()
+	plaintext := bytes.Repeat([]byte("0123456789abcdef"), 20000)
+
+	var sealed bytes.Buffer
+	w, err := key.NewWriter(&sealed, AES_GCM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < len(plaintext); i += 1000 {
+		w.Write(plaintext[i:min(i+1000, len(plaintext))])
+	}
+	w.Close()
+
+	r, err := key.NewReader(&sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened, err := io.ReadAll(r)
+	if err != nil || !bytes.Equal(opened, plaintext) {
+		t.Fatalf("stream does not round-trip: %v", err)
+	}
+}
+
+
+func TestDeriveKey(t *testing.T) {
+	salt, _ := NewSalt()
+	a, err  := DeriveKey("correct horse", salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := DeriveKey("correct horse", salt)
+	c, _ := DeriveKey("battery staple", salt)
+	if a.ID() != b.ID() || a.ID() == c.ID() {
+		t.Fatal("key derivation is not deterministic per passphrase")
+	}
+}
@@ -0,0 +1,228 @@
+// Package deltakit encodes one byte sequence as a delta against another.
+//
+// A delta is a list of instructions that rebuild the target from the base:
+// COPY a range of the base, or INSERT literal bytes. Matches are found with a
+// rolling hash over fixed size blocks of the base (in the spirit of rsync and
+// git's pack deltas), so encoding is linear in the size of both inputs.
+//
+// Format (all integers are unsigned varints):
+//
+//	"VXD1" baseLen targetLen { 0x00 len bytes | 0x01 offset len }*
+package deltakit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+
+const (
+	BLOCK_SIZE = 16
+	MAGIC      = "VXD1"
+
+	opInsert byte = 0
+	opCopy   byte = 1
+
+	hashBase uint32 = 257
+)
+
+
+var ErrCorrupt = errors.New("corrupt delta")
+
+
+// Encode returns a delta that rebuilds target from base.
+func Encode(base, target []byte) []byte {
+	out := make([]byte, 0, len(target)/4+16)
+	out  = append(out, MAGIC...)
+	out  = binary.AppendUvarint(out, uint64(len(base)))
+	out  = binary.AppendUvarint(out, uint64(len(target)))
+
+	index := indexBlocks(base)
+	if len(index) == 0 || len(target) < BLOCK_SIZE {
+		return appendInsert(out, target)
+	}
+
+	// B^BLOCK_SIZE, to drop the outgoing byte from the rolling hash
+	var power uint32 = 1
+	for range BLOCK_SIZE {
+		power *= hashBase
+	}
+
+	pending := 0 // start of bytes not yet emitted
+	pos     := 0
+	hash    := blockHash(target[:BLOCK_SIZE])
+	for pos+BLOCK_SIZE <= len(target) {
+		if offset, ok := index[hash]; ok && bytes.Equal(base[offset:offset+BLOCK_SIZE], target[pos:pos+BLOCK_SIZE]) {
+			// extend the match backwards into pending bytes and forwards
+			start, baseStart := pos, offset
+			for start > pending && baseStart > 0 && target[start-1] == base[baseStart-1] {
+				start--
+				baseStart--
+			}
+			end, baseEnd := pos+BLOCK_SIZE, offset+BLOCK_SIZE
+			for end < len(target) && baseEnd < len(base) && target[end] == base[baseEnd] {
+				end++
+				baseEnd++
+			}
+
+			out     = appendInsert(out, target[pending:start])
+			out     = appendCopy(out, baseStart, end-start)
+			pending = end
+			pos     = end
+			if pos+BLOCK_SIZE <= len(target) {
+				hash = blockHash(target[pos : pos+BLOCK_SIZE])
+			}
+			continue
+		}
+
+		if pos+BLOCK_SIZE < len(target) {
+			hash = hash*hashBase + uint32(target[pos+BLOCK_SIZE]) - power*uint32(target[pos])
+		}
+		pos++
+	}
+	return appendInsert(out, target[pending:])
+}
+
+
+// Apply rebuilds the target from base and a delta produced by Encode.
+func Apply(base, delta []byte) ([]byte, error) {
+	baseLen, targetLen, rest, err := header(delta)
+	if err != nil {
+		return nil, err
+	}
+	if baseLen != uint64(len(base)) {
+		return nil, fmt.Errorf("%w: base is %d bytes, delta expects %d", ErrCorrupt, len(base), baseLen)
+	}
+	// every byte of the target comes from an insert or from a copy, and each
+	// copy takes at least 3 bytes of the delta
+	inserts  := uint64(len(rest))
+	copies   := inserts / 3
+	maxLen   := inserts + copies*uint64(len(base))
+	if copies != 0 && (maxLen-inserts)/copies != uint64(len(base)) {
+		maxLen = math.MaxUint64
+	}
+	if targetLen > maxLen {
+		return nil, fmt.Errorf("%w: target of %d bytes cannot be built from %d bytes of delta", ErrCorrupt, targetLen, len(rest))
+	}
+
+	// a header that is wrong but within bounds must not reserve more than
+	// the rebuilt target can plausibly need
+	target := make([]byte, 0, min(targetLen, uint64(len(base))+inserts))
+	for len(rest) > 0 {
+		op := rest[0]
+		rest = rest[1:]
+
+		switch op {
+		case opInsert:
+			var n uint64
+			if n, rest, err = readUvarint(rest); err != nil {
+				return nil, err
+			}
+			if n > uint64(len(rest)) {
+				return nil, fmt.Errorf("%w: insert past end of delta", ErrCorrupt)
+			}
+			if uint64(len(target))+n > targetLen {
+				return nil, fmt.Errorf("%w: rebuilt more than %d bytes", ErrCorrupt, targetLen)
+			}
+			target = append(target, rest[:n]...)
+			rest   = rest[n:]
+		case opCopy:
+			var offset, n uint64
+			if offset, rest, err = readUvarint(rest); err != nil {
+				return nil, err
+			}
+			if n, rest, err = readUvarint(rest); err != nil {
+				return nil, err
+			}
+			if offset+n > uint64(len(base)) || offset+n < offset {
+				return nil, fmt.Errorf("%w: copy outside base", ErrCorrupt)
+			}
+			if uint64(len(target))+n > targetLen {
+				return nil, fmt.Errorf("%w: rebuilt more than %d bytes", ErrCorrupt, targetLen)
+			}
+			target = append(target, base[offset:offset+n]...)
+		default:
+			return nil, fmt.Errorf("%w: unknown instruction %d", ErrCorrupt, op)
+		}
+	}
+
+	if uint64(len(target)) != targetLen {
+		return nil, fmt.Errorf("%w: rebuilt %d bytes, expected %d", ErrCorrupt, len(target), targetLen)
+	}
+	return target, nil
+}
+
+
+// TargetLen returns the length of the target a delta rebuilds, as its
+// header states it.
+func TargetLen(delta []byte) (uint64, error) {
+	_, targetLen, _, err := header(delta)
+	return targetLen, err
+}
+
+
+// header splits a delta into the lengths it starts with and its instructions.
+func header(delta []byte) (baseLen, targetLen uint64, rest []byte, err error) {
+	if !bytes.HasPrefix(delta, []byte(MAGIC)) {
+		return 0, 0, nil, fmt.Errorf("%w: bad magic", ErrCorrupt)
+	}
+	if baseLen, rest, err = readUvarint(delta[len(MAGIC):]); err != nil {
+		return 0, 0, nil, err
+	}
+	if targetLen, rest, err = readUvarint(rest); err != nil {
+		return 0, 0, nil, err
+	}
+	return baseLen, targetLen, rest, nil
+}
+
+
+// indexBlocks maps the hash of every aligned block of base to its offset.
+// The first occurrence wins.
+func indexBlocks(base []byte) map[uint32]int {
+	index := make(map[uint32]int, len(base)/BLOCK_SIZE)
+	for offset := 0; offset+BLOCK_SIZE <= len(base); offset += BLOCK_SIZE {
+		hash := blockHash(base[offset : offset+BLOCK_SIZE])
+		if _, ok := index[hash]; !ok {
+			index[hash] = offset
+		}
+	}
+	return index
+}
+
+
+func blockHash(block []byte) uint32 {
+	var hash uint32
+	for _, b := range block {
+		hash = hash*hashBase + uint32(b)
+	}
+	return hash
+}
+
+
+func appendInsert(out, data []byte) []byte {
+	if len(data) == 0 {
+		return out
+	}
+	out = append(out, opInsert)
+	out = binary.AppendUvarint(out, uint64(len(data)))
+	return append(out, data...)
+}
+
+
+func appendCopy(out []byte, offset, length int) []byte {
+	out = append(out, opCopy)
+	out = binary.AppendUvarint(out, uint64(offset))
+	return binary.AppendUvarint(out, uint64(length))
+}
+
+
+func readUvarint(data []byte) (uint64, []byte, error) {
+	value, n := binary.Uvarint(data)
+	if n <= 0 {
+		return 0, nil, fmt.Errorf("%w: truncated", ErrCorrupt)
+	}
+	return value, data[n:], nil
+}
@@ -0,0 +1,121 @@
+package deltakit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+
+func roundTrip(t *testing.T, base, target []byte) []byte {
+	t.Helper()
+	delta := Encode(base, target)
+	got, err := Apply(base, delta)
+	if err != nil {
+		t.Fatalf("apply failed: %v", err)
+	}
+	if !bytes.Equal(got, target) {
+		t.Fatalf("round trip mismatch: got %d bytes, want %d", len(got), len(target))
+	}
+	return delta
+}
+
+
+func TestRoundTrip(t *testing.T) {
+	cases := [][2]string{
+		{"", ""},
+		{"", "new content"},
+		{"old content", ""},
+		{"short", "shorter"},
+		{strings.Repeat("abcdefghijklmnop", 10), strings.Repeat("abcdefghijklmnop", 10)},
+		{strings.Repeat("line of text\n", 100), strings.Repeat("line of text\n", 100) + "appended\n"},
+		{"header\n" + strings.Repeat("x", 500), strings.Repeat("x", 500) + "\nfooter"},
+	}
+	for _, c := range cases {
+		roundTrip(t, []byte(c[0]), []byte(c[1]))
+	}
+}
+
+
+func TestDeltaIsSmallForSmallEdits(t *testing.T) {
+	random := rand.New(rand.NewSource(7))
+	base   := make([]byte, 64*1024)
+	random.Read(base)
+
+	target := append([]byte(nil), base[:30000]...)
+	target  = append(target, []byte("inserted in the middle")...)
+	target  = append(target, base[30000:]...)
+	target[50000] ^= 0xff
+
+	delta := roundTrip(t, base, target)
+	if len(delta) > 200 {
+		t.Fatalf("delta for a small edit is %d bytes", len(delta))
+	}
+}
+
+
+func TestRandomEdits(t *testing.T) {
+	random := rand.New(rand.NewSource(3))
+	for range 200 {
+		base := make([]byte, random.Intn(2000))
+		random.Read(base)
+
+		target := append([]byte(nil), base...)
+		for range random.Intn(5) {
+			at := random.Intn(len(target) + 1)
+			switch random.Intn(3) {
+			case 0:
+				chunk := make([]byte, random.Intn(40))
+				random.Read(chunk)
+				target = append(target[:at], append(chunk, target[at:]...)...)
+			case 1:
+				end := min(len(target), at+random.Intn(40))
+				target = append(target[:at], target[end:]...)
+			case 2:
+				if at < len(target) {
+					target[at]++
+				}
+			}
+		}
+		roundTrip(t, base, target)
+	}
+}
+
+
+func TestTargetLen(t *testing.T) {
+	base   := []byte(strings.Repeat("0123456789abcdef", 8))
+	target := append([]byte("head "), base...)
+	if n, err := TargetLen(Encode(base, target)); err != nil || n != uint64(len(target)) {
+		t.Errorf("got %d, %v, want %d", n, err, len(target))
+	}
+	if _, err := TargetLen([]byte(MAGIC)); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("expected truncated header to fail, got %v", err)
+	}
+}
+
+
+func TestApplyRejectsCorruptDeltas(t *testing.T) {
+	base  := []byte(strings.Repeat("0123456789abcdef", 8))
+	delta := Encode(base, append(base, '!'))
+
+	if _, err := Apply(base[1:], delta); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("expected base length mismatch to fail, got %v", err)
+	}
+	if _, err := Apply(base, delta[:len(delta)-1]); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("expected truncated delta to fail, got %v", err)
+	}
+	if _, err := Apply(base, []byte("nope")); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("expected bad magic to fail, got %v", err)
+	}
+
+	// a target length no delta of this size can produce
+	huge := binary.AppendUvarint([]byte(MAGIC), uint64(len(base)))
+	huge  = binary.AppendUvarint(huge, 1<<62)
+	huge  = appendCopy(huge, 0, len(base))
+	if _, err := Apply(base, huge); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("expected oversized target length to fail, got %v", err)
+	}
+}
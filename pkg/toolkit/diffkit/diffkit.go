@@ -0,0 +1,422 @@
+// Package diffkit computes line based differences between two texts.
+//
+// Two algorithms are provided:
+//   - Myers: the classic O(ND) shortest edit script (what `diff` does)
+//   - Patience: anchors on lines that are unique in both texts and diffs the
+//     gaps with Myers, which keeps moved blocks and braces readable
+//
+// Lines keep their "\n" terminator so a missing newline at the end of a text
+// is a visible difference. Edit scripts are grouped into hunks with a number
+// of context lines, ready to be rendered as a unified diff.
+package diffkit
+
+import (
+	"fmt"
+	"strings"
+)
+
+
+type Op int
+
+const (
+	EQUAL Op = iota
+	DELETE
+	INSERT
+)
+
+func (op Op) ToString() string {
+	return [...]string{"EQUAL", "DELETE", "INSERT"}[op]
+}
+
+// Prefix returns the unified diff line prefix of the operation.
+func (op Op) Prefix() string {
+	return [...]string{" ", "-", "+"}[op]
+}
+
+func (op Op) MarshalText() ([]byte, error) {
+	return []byte(op.ToString()), nil
+}
+
+func (op *Op) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "EQUAL":
+		*op = EQUAL
+	case "DELETE":
+		*op = DELETE
+	case "INSERT":
+		*op = INSERT
+	default:
+		return fmt.Errorf("unknown diff op %q", text)
+	}
+	return nil
+}
+
+
+type Algorithm int
+
+const (
+	MYERS Algorithm = iota
+	PATIENCE
+)
+
+func AlgorithmFromString(s string) (Algorithm, error) {
+	switch strings.ToLower(s) {
+	case "", "myers":
+		return MYERS, nil
+	case "patience":
+		return PATIENCE, nil
+	}
+	return MYERS, fmt.Errorf("unknown diff algorithm %q", s)
+}
+
+
+// Edit is one line of an edit script.
+type Edit struct {
+	Op   Op     `json:"op"`
+	Text string `json:"text"`
+}
+
+
+// Hunk is a group of edits with surrounding context. Starts are 1-based
+// line numbers; a start of 0 with 0 lines denotes an empty side.
+type Hunk struct {
+	OldStart int    `json:"oldStart"`
+	OldLines int    `json:"oldLines"`
+	NewStart int    `json:"newStart"`
+	NewLines int    `json:"newLines"`
+	Edits    []Edit `json:"edits"`
+}
+
+
+// Header returns the hunk's "@@ -a,b +c,d @@" line.
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%s +%s @@", hunkRange(h.OldStart, h.OldLines), hunkRange(h.NewStart, h.NewLines))
+}
+
+
+func hunkRange(start, lines int) string {
+	if lines == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, lines)
+}
+
+
+// SplitLines splits text into lines that keep their "\n" terminator.
+func SplitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+
+// Lines diffs two texts and returns the full edit script.
+func Lines(a, b string, algorithm Algorithm) []Edit {
+	if algorithm == PATIENCE {
+		return Patience(SplitLines(a), SplitLines(b))
+	}
+	return Myers(SplitLines(a), SplitLines(b))
+}
+
+
+// Myers returns a shortest edit script turning a into b.
+func Myers(a, b []string) []Edit {
+	prefix, suffix := commonAffixes(a, b)
+	edits := make([]Edit, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		edits = append(edits, Edit{EQUAL, line})
+	}
+	edits = append(edits, myersCore(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		edits = append(edits, Edit{EQUAL, line})
+	}
+	return edits
+}
+
+
+func commonAffixes(a, b []string) (prefix, suffix int) {
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	return prefix, suffix
+}
+
+
+// myersCore is the greedy forward algorithm from Myers (1986). The part of
+// the V array reachable in each round (diagonals -d-1..d+1) is kept so the
+// path can be traced back, which needs O(D^2) memory instead of O(D*(N+M)).
+func myersCore(a, b []string) []Edit {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		edits := make([]Edit, 0, n+m)
+		for _, line := range a {
+			edits = append(edits, Edit{DELETE, line})
+		}
+		for _, line := range b {
+			edits = append(edits, Edit{INSERT, line})
+		}
+		return edits
+	}
+
+	limit  := n + m
+	offset := limit + 1
+	v      := make([]int, 2*limit+3)
+	var trace [][]int
+
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace)
+			}
+		}
+	}
+	return nil
+}
+
+
+// backtrack follows the recorded rounds from the end of both texts back to
+// the start. trace[d][k+d+1] holds V[k] as it was before round d.
+func backtrack(a, b []string, trace [][]int) []Edit {
+	x, y  := len(a), len(b)
+	edits := make([]Edit, 0, x+y)
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		at := func(k int) int { return v[k+d+1] }
+
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, Edit{EQUAL, a[x]})
+		}
+		if d > 0 {
+			if x == prevX {
+				y--
+				edits = append(edits, Edit{INSERT, b[y]})
+			} else {
+				x--
+				edits = append(edits, Edit{DELETE, a[x]})
+			}
+		}
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+
+// Patience anchors the diff on lines occurring exactly once in both a and
+// b (in increasing order on both sides) and diffs the gaps recursively,
+// falling back to Myers where no unique lines exist.
+func Patience(a, b []string) []Edit {
+	prefix, suffix := commonAffixes(a, b)
+	edits := make([]Edit, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		edits = append(edits, Edit{EQUAL, line})
+	}
+
+	innerA, innerB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	anchors := uniqueAnchors(innerA, innerB)
+	if len(anchors) == 0 {
+		edits = append(edits, myersCore(innerA, innerB)...)
+	} else {
+		lastA, lastB := 0, 0
+		for _, anchor := range anchors {
+			edits = append(edits, Patience(innerA[lastA:anchor[0]], innerB[lastB:anchor[1]])...)
+			edits = append(edits, Edit{EQUAL, innerA[anchor[0]]})
+			lastA, lastB = anchor[0]+1, anchor[1]+1
+		}
+		edits = append(edits, Patience(innerA[lastA:], innerB[lastB:])...)
+	}
+
+	for _, line := range a[len(a)-suffix:] {
+		edits = append(edits, Edit{EQUAL, line})
+	}
+	return edits
+}
+
+
+// uniqueAnchors returns index pairs of lines unique to both sides, reduced
+// to their longest common subsequence by patience sorting.
+func uniqueAnchors(a, b []string) [][2]int {
+	counts := make(map[string][2]int)
+	for _, line := range a {
+		c := counts[line]
+		c[0]++
+		counts[line] = c
+	}
+	for _, line := range b {
+		c := counts[line]
+		c[1]++
+		counts[line] = c
+	}
+
+	indexInA := make(map[string]int)
+	for i, line := range a {
+		if c := counts[line]; c[0] == 1 && c[1] == 1 {
+			indexInA[line] = i
+		}
+	}
+
+	// candidates in b order, each with its position in a
+	var candidates [][2]int
+	for j, line := range b {
+		if i, ok := indexInA[line]; ok {
+			candidates = append(candidates, [2]int{i, j})
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	// longest increasing subsequence on the a index
+	tails := []int{}
+	prev  := make([]int, len(candidates))
+	for c, candidate := range candidates {
+		lo, hi := 0, len(tails)
+		for lo < hi {
+			mid := (lo + hi) / 2
+			if candidates[tails[mid]][0] < candidate[0] {
+				lo = mid + 1
+			} else {
+				hi = mid
+			}
+		}
+		if lo > 0 {
+			prev[c] = tails[lo-1]
+		} else {
+			prev[c] = -1
+		}
+		if lo == len(tails) {
+			tails = append(tails, c)
+		} else {
+			tails[lo] = c
+		}
+	}
+
+	anchors := make([][2]int, len(tails))
+	for c, i := tails[len(tails)-1], len(tails)-1; i >= 0; c, i = prev[c], i-1 {
+		anchors[i] = candidates[c]
+	}
+	return anchors
+}
+
+
+// Hunks groups an edit script into hunks with up to context unchanged lines
+// around each change. Changes separated by at most 2*context unchanged
+// lines share a hunk.
+func Hunks(edits []Edit, context int) []Hunk {
+	// 1-based line numbers on each side before edit i
+	oldAt := make([]int, len(edits)+1)
+	newAt := make([]int, len(edits)+1)
+	oldAt[0], newAt[0] = 1, 1
+	for i, edit := range edits {
+		oldAt[i+1], newAt[i+1] = oldAt[i], newAt[i]
+		if edit.Op != INSERT {
+			oldAt[i+1]++
+		}
+		if edit.Op != DELETE {
+			newAt[i+1]++
+		}
+	}
+
+	var hunks []Hunk
+	for i := 0; i < len(edits); {
+		if edits[i].Op == EQUAL {
+			i++
+			continue
+		}
+
+		end := i
+		for end < len(edits) {
+			if edits[end].Op != EQUAL {
+				end++
+				continue
+			}
+			gap := end
+			for gap < len(edits) && edits[gap].Op == EQUAL {
+				gap++
+			}
+			if gap == len(edits) || gap-end > 2*context {
+				break
+			}
+			end = gap
+		}
+
+		start := max(0, i-context)
+		stop  := min(len(edits), end+context)
+		hunk  := Hunk{
+			OldStart: oldAt[start],
+			OldLines: oldAt[stop] - oldAt[start],
+			NewStart: newAt[start],
+			NewLines: newAt[stop] - newAt[start],
+			Edits:    append([]Edit(nil), edits[start:stop]...),
+		}
+		// an empty range names the line before it
+		if hunk.OldLines == 0 {
+			hunk.OldStart--
+		}
+		if hunk.NewLines == 0 {
+			hunk.NewStart--
+		}
+		hunks = append(hunks, hunk)
+		i = stop
+	}
+	return hunks
+}
+
+
+// Unified renders hunks as a plain unified diff with the given file labels.
+func Unified(fromLabel, toLabel string, hunks []Hunk) string {
+	if len(hunks) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromLabel, toLabel)
+	for _, hunk := range hunks {
+		sb.WriteString(hunk.Header())
+		sb.WriteString("\n")
+		for _, edit := range hunk.Edits {
+			sb.WriteString(edit.Op.Prefix())
+			sb.WriteString(edit.Text)
+			if !strings.HasSuffix(edit.Text, "\n") {
+				sb.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,139 @@
+package diffkit
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+
+// apply rebuilds both sides from an edit script.
+func apply(edits []Edit) (string, string) {
+	var a, b strings.Builder
+	for _, edit := range edits {
+		if edit.Op != INSERT {
+			a.WriteString(edit.Text)
+		}
+		if edit.Op != DELETE {
+			b.WriteString(edit.Text)
+		}
+	}
+	return a.String(), b.String()
+}
+
+
+func countChanges(edits []Edit) int {
+	n := 0
+	for _, edit := range edits {
+		if edit.Op != EQUAL {
+			n++
+		}
+	}
+	return n
+}
+
+
+func TestEditScriptsRebuildBothSides(t *testing.T) {
+	cases := [][2]string{
+		{"", ""},
+		{"", "a\nb\n"},
+		{"a\nb\n", ""},
+		{"a\nb\nc\n", "a\nb\nc\n"},
+		{"a\nb\nc\n", "a\nc\n"},
+		{"a\nb\nc\na\nb\nb\na\n", "c\nb\na\nb\na\nc\n"},
+		{"one\ntwo", "one\ntwo\n"},
+		{"func a() {\n}\n\nfunc b() {\n}\n", "func b() {\n}\n\nfunc a() {\n}\n"},
+	}
+	for _, algorithm := range []Algorithm{MYERS, PATIENCE} {
+		for _, c := range cases {
+			a, b := apply(Lines(c[0], c[1], algorithm))
+			if a != c[0] || b != c[1] {
+				t.Errorf("algorithm %d: %q -> %q rebuilt as %q -> %q", algorithm, c[0], c[1], a, b)
+			}
+		}
+	}
+}
+
+
+func TestRandomEditScripts(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	text := func() string {
+		var sb strings.Builder
+		for range random.Intn(30) {
+			sb.WriteString(string(rune('a' + random.Intn(4))))
+			sb.WriteString("\n")
+		}
+		return sb.String()
+	}
+
+	for range 500 {
+		a, b := text(), text()
+		for _, algorithm := range []Algorithm{MYERS, PATIENCE} {
+			ra, rb := apply(Lines(a, b, algorithm))
+			if ra != a || rb != b {
+				t.Fatalf("algorithm %d: %q -> %q rebuilt as %q -> %q", algorithm, a, b, ra, rb)
+			}
+		}
+	}
+}
+
+
+func TestMyersIsMinimal(t *testing.T) {
+	// classic example from the Myers paper: ABCABBA -> CBABAC has D = 5
+	a := strings.Split("A B C A B B A", " ")
+	b := strings.Split("C B A B A C", " ")
+	if d := countChanges(Myers(a, b)); d != 5 {
+		t.Fatalf("expected 5 edits, got %d", d)
+	}
+}
+
+
+func TestHunks(t *testing.T) {
+	var a, b []string
+	for i := 1; i <= 20; i++ {
+		line := strings.Repeat("x", i) + "\n"
+		a = append(a, line)
+		if i != 3 && i != 18 {
+			b = append(b, line)
+		}
+	}
+	b = append(b, "tail\n")
+
+	hunks := Hunks(Myers(a, b), 2)
+	if len(hunks) != 2 {
+		t.Fatalf("expected 2 hunks, got %d", len(hunks))
+	}
+	if got := hunks[0].Header(); got != "@@ -1,5 +1,4 @@" {
+		t.Errorf("first hunk header %s", got)
+	}
+	if got := hunks[1].Header(); got != "@@ -16,5 +15,5 @@" {
+		t.Errorf("second hunk header %s", got)
+	}
+
+	// nearby changes merge into one hunk
+	if merged := Hunks(Myers(a, b), 8); len(merged) != 1 {
+		t.Errorf("expected changes to merge with large context, got %d hunks", len(merged))
+	}
+}
+
+
+func TestUnifiedEmptySide(t *testing.T) {
+	got  := Unified("a", "b", Hunks(Lines("", "new\n", MYERS), 3))
+	want := "--- a\n+++ b\n@@ -0,0 +1 @@\n+new\n"
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if Unified("a", "b", Hunks(Lines("same\n", "same\n", MYERS), 3)) != "" {
+		t.Fatalf("expected no output for identical texts")
+	}
+}
+
+
+func TestPatienceKeepsUniqueLinesAligned(t *testing.T) {
+	a := "a\n{\nx\n}\nb\n{\ny\n}\n"
+	b := "b\n{\ny\n}\na\n{\nx\n}\n"
+	edits := Lines(a, b, PATIENCE)
+	if ra, rb := apply(edits); ra != a || rb != b {
+		t.Fatalf("patience script does not rebuild inputs")
+	}
+}
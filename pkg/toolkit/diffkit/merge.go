@@ -0,0 +1,125 @@
+package diffkit
+
+import "strings"
+
+
+// Chunk is a stretch of a three-way merge. A resolved chunk carries the
+// merged Lines; a conflicting one the lines of the three sides instead.
+type Chunk struct {
+	Conflict bool     `json:"conflict"`
+	Lines    []string `json:"lines,omitempty"`
+	Base     []string `json:"base,omitempty"`
+	Ours     []string `json:"ours,omitempty"`
+	Theirs   []string `json:"theirs,omitempty"`
+}
+
+
+// Merge3 merges the changes made to base in ours and in theirs, line by
+// line (diff3). Stretches changed on one side only take that side, the
+// same change made on both sides is taken once, and different changes to
+// the same stretch are a conflict. Adjacent resolved stretches are joined.
+func Merge3(base, ours, theirs string, algorithm Algorithm) []Chunk {
+	baseLines  := SplitLines(base)
+	oursLines  := SplitLines(ours)
+	theirLines := SplitLines(theirs)
+
+	toOurs   := matches(len(baseLines), Lines(base, ours, algorithm))
+	toTheirs := matches(len(baseLines), Lines(base, theirs, algorithm))
+
+	var chunks []Chunk
+	resolve := func(lines []string) {
+		if len(lines) == 0 {
+			return
+		}
+		if n := len(chunks); n > 0 && !chunks[n-1].Conflict {
+			chunks[n-1].Lines = append(chunks[n-1].Lines, lines...)
+			return
+		}
+		chunks = append(chunks, Chunk{Lines: append([]string(nil), lines...)})
+	}
+
+	b, o, t := 0, 0, 0
+	for b < len(baseLines) || o < len(oursLines) || t < len(theirLines) {
+		// stable: the base line is kept at this position on both sides
+		if b < len(baseLines) && toOurs[b] == o && toTheirs[b] == t {
+			resolve(baseLines[b : b+1])
+			b, o, t = b+1, o+1, t+1
+			continue
+		}
+
+		// the unstable stretch ends at the next base line kept on both sides
+		next, nextOurs, nextTheirs := len(baseLines), len(oursLines), len(theirLines)
+		for i := b; i < len(baseLines); i++ {
+			if toOurs[i] >= 0 && toTheirs[i] >= 0 {
+				next, nextOurs, nextTheirs = i, toOurs[i], toTheirs[i]
+				break
+			}
+		}
+
+		baseSide  := baseLines[b:next]
+		oursSide  := oursLines[o:nextOurs]
+		theirSide := theirLines[t:nextTheirs]
+		switch {
+		case equalLines(oursSide, baseSide):
+			resolve(theirSide)
+		case equalLines(theirSide, baseSide), equalLines(oursSide, theirSide):
+			resolve(oursSide)
+		default:
+			chunks = append(chunks, Chunk{Conflict: true, Base: baseSide, Ours: oursSide, Theirs: theirSide})
+		}
+		b, o, t = next, nextOurs, nextTheirs
+	}
+	return chunks
+}
+
+
+// Merged joins the chunks of a merge into the merged text. ok is false when
+// a chunk conflicts; its lines are left out.
+func Merged(chunks []Chunk) (text string, ok bool) {
+	var merged strings.Builder
+	ok = true
+	for _, chunk := range chunks {
+		if chunk.Conflict {
+			ok = false
+			continue
+		}
+		for _, line := range chunk.Lines {
+			merged.WriteString(line)
+		}
+	}
+	return merged.String(), ok
+}
+
+
+// matches maps every base line to its line in the other text when the edit
+// script keeps it, -1 when it is deleted.
+func matches(baseLen int, edits []Edit) []int {
+	kept := make([]int, baseLen)
+	i, j := 0, 0
+	for _, edit := range edits {
+		switch edit.Op {
+		case EQUAL:
+			kept[i] = j
+			i, j = i+1, j+1
+		case DELETE:
+			kept[i] = -1
+			i++
+		case INSERT:
+			j++
+		}
+	}
+	return kept
+}
+
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
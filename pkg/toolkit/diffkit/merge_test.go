@@ -0,0 +1,55 @@
+package diffkit
+
+import "testing"
+
+
+func TestMerge3(t *testing.T) {
+	base := "a\nb\nc\nd\ne\n"
+	cases := []struct {
+		name         string
+		ours, theirs string
+		merged       string
+		ok           bool
+	}{
+		{"unchanged", base, base, base, true},
+		{"ours only", "a\nB\nc\nd\ne\n", base, "a\nB\nc\nd\ne\n", true},
+		{"theirs only", base, "a\nb\nc\nd\nE\n", "a\nb\nc\nd\nE\n", true},
+		{"both, apart", "A\nb\nc\nd\ne\n", "a\nb\nc\nd\ne\nf\n", "A\nb\nc\nd\ne\nf\n", true},
+		{"same change", "a\nx\nc\nd\ne\n", "a\nx\nc\nd\ne\n", "a\nx\nc\nd\ne\n", true},
+		{"delete and edit apart", "a\nc\nd\ne\n", "a\nb\nc\nd\nE\n", "a\nc\nd\nE\n", true},
+		{"same line", "a\nb\nX\nd\ne\n", "a\nb\nY\nd\ne\n", "", false},
+		{"delete vs edit", "a\nb\nd\ne\n", "a\nb\nC\nd\ne\n", "", false},
+		{"inserts at same spot", "a\nb\nc\nours\nd\ne\n", "a\nb\nc\ntheirs\nd\ne\n", "", false},
+	}
+	for _, algorithm := range []Algorithm{MYERS, PATIENCE} {
+		for _, c := range cases {
+			merged, ok := Merged(Merge3(base, c.ours, c.theirs, algorithm))
+			if ok != c.ok || (ok && merged != c.merged) {
+				t.Errorf("algorithm %d, %s: got %q (ok=%v), want %q (ok=%v)", algorithm, c.name, merged, ok, c.merged, c.ok)
+			}
+		}
+	}
+}
+
+
+func TestMerge3ConflictSides(t *testing.T) {
+	chunks := Merge3("a\nb\nc\n", "a\nours\nc\n", "a\ntheirs\nc\n", MYERS)
+	if len(chunks) != 3 || !chunks[1].Conflict {
+		t.Fatalf("expected resolved, conflict, resolved, got %+v", chunks)
+	}
+	conflict := chunks[1]
+	if !equalLines(conflict.Base, []string{"b\n"}) || !equalLines(conflict.Ours, []string{"ours\n"}) || !equalLines(conflict.Theirs, []string{"theirs\n"}) {
+		t.Errorf("unexpected conflict sides %+v", conflict)
+	}
+}
+
+
+func TestMerge3EmptyBase(t *testing.T) {
+	merged, ok := Merged(Merge3("", "same\n", "same\n", MYERS))
+	if !ok || merged != "same\n" {
+		t.Errorf("identical additions: got %q (ok=%v)", merged, ok)
+	}
+	if _, ok := Merged(Merge3("", "one\n", "two\n", MYERS)); ok {
+		t.Error("different additions should conflict")
+	}
+}
@@ -0,0 +1,20 @@
+package diffkit
+
+
+// Similarity scores how much of two texts is shared, from 0 (no line in
+// common) to 1 (identical): twice the lines the edit script keeps over the
+// lines of both texts.
+func Similarity(a, b string, algorithm Algorithm) float64 {
+	total := len(SplitLines(a)) + len(SplitLines(b))
+	if total == 0 {
+		return 1
+	}
+
+	kept := 0
+	for _, edit := range Lines(a, b, algorithm) {
+		if edit.Op == EQUAL {
+			kept++
+		}
+	}
+	return float64(2*kept) / float64(total)
+}
@@ -0,0 +1,24 @@
+package diffkit
+
+import "testing"
+
+
+func TestSimilarity(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b string
+		want float64
+	}{
+		{"identical", "a\nb\nc\nd\n", "a\nb\nc\nd\n", 1},
+		{"both empty", "", "", 1},
+		{"one empty", "a\n", "", 0},
+		{"disjoint", "a\nb\n", "c\nd\n", 0},
+		{"one line edited", "a\nb\nc\nd\n", "a\nB\nc\nd\n", 0.75},
+		{"line appended", "a\nb\nc\n", "a\nb\nc\nd\n", 6.0 / 7},
+	}
+	for _, c := range cases {
+		if got := Similarity(c.a, c.b, MYERS); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
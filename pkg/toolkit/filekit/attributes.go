@@ -0,0 +1,117 @@
+package filekit
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+
+// Attributes are the metadata of a file kept with each version of it.
+//
+// Fields:
+//   - Mode: permission bits plus setuid, setgid and sticky as chmod takes them, 0 if unknown
+//   - MTime: modification time in Unix nanoseconds, 0 if unknown
+//   - Owner: "uid:gid", empty if not recorded
+type Attributes struct {
+	Mode  uint32
+	MTime int64
+	Owner string
+}
+
+
+// AttributesOf returns the attributes of the file info describes; the
+// owner only when withOwner is set.
+func AttributesOf(info fs.FileInfo, withOwner bool) Attributes {
+	attrs := Attributes{
+		Mode:  ModeBits(info.Mode()),
+		MTime: info.ModTime().UnixNano(),
+	}
+	if withOwner {
+		if uid, gid, ok := Owner(info); ok {
+			attrs.Owner = fmt.Sprintf("%d:%d", uid, gid)
+		}
+	}
+	return attrs
+}
+
+
+// ModeBits returns the permission and special bits of mode in the unix
+// layout, e.g. 0o4755.
+func ModeBits(mode fs.FileMode) uint32 {
+	bits := uint32(mode.Perm())
+	if mode&fs.ModeSetuid != 0 {
+		bits |= 0o4000
+	}
+	if mode&fs.ModeSetgid != 0 {
+		bits |= 0o2000
+	}
+	if mode&fs.ModeSticky != 0 {
+		bits |= 0o1000
+	}
+	return bits
+}
+
+
+// FileMode converts the recorded bits back for os.Chmod.
+func (a Attributes) FileMode() fs.FileMode {
+	mode := fs.FileMode(a.Mode & 0o777)
+	if a.Mode&0o4000 != 0 {
+		mode |= fs.ModeSetuid
+	}
+	if a.Mode&0o2000 != 0 {
+		mode |= fs.ModeSetgid
+	}
+	if a.Mode&0o1000 != 0 {
+		mode |= fs.ModeSticky
+	}
+	return mode
+}
+
+
+// Apply sets the recorded mode and mtime on the file at path; what is not
+// recorded is left as it is. Owner is applied by Chown.
+func (a Attributes) Apply(path string) error {
+	if a.Mode != 0 {
+		if err := os.Chmod(path, a.FileMode()); err != nil {
+			return err
+		}
+	}
+	if a.MTime != 0 {
+		if err := os.Chtimes(path, time.Time{}, time.Unix(0, a.MTime)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+
+// Chown gives the file at path the recorded owner, if any. It clears
+// setuid and setgid, so it goes before Apply.
+func (a Attributes) Chown(path string) error {
+	if a.Owner == "" {
+		return nil
+	}
+	uid, gid, err := parseOwner(a.Owner)
+	if err != nil {
+		return err
+	}
+	return os.Lchown(path, uid, gid)
+}
+
+
+func parseOwner(owner string) (uid, gid int, err error) {
+	u, g, ok := strings.Cut(owner, ":")
+	if ok {
+		if uid, err = strconv.Atoi(u); err == nil {
+			gid, err = strconv.Atoi(g)
+		}
+	}
+	if !ok || err != nil {
+		return 0, 0, fmt.Errorf("invalid owner %q", owner)
+	}
+	return uid, gid, nil
+}
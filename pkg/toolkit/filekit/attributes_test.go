@@ -0,0 +1,30 @@
+package filekit
+
+import (
+	"io/fs"
+	"testing"
+)
+
+
+func TestModeBits(t *testing.T) {
+	cases := []struct {
+		name string
+		mode fs.FileMode
+		want uint32
+	}{
+		{"plain", 0o644, 0o644},
+		{"executable", 0o755, 0o755},
+		{"setuid", fs.ModeSetuid | 0o755, 0o4755},
+		{"setgid and sticky", fs.ModeSetgid | fs.ModeSticky | 0o775, 0o3775},
+		{"type bits dropped", fs.ModeDir | 0o700, 0o700},
+	}
+	for _, c := range cases {
+		got := ModeBits(c.mode)
+		if got != c.want {
+			t.Errorf("%s: got %o, want %o", c.name, got, c.want)
+		}
+		if back := ModeBits(Attributes{Mode: got}.FileMode()); back != got {
+			t.Errorf("%s: round trip gave %o", c.name, back)
+		}
+	}
+}
@@ -1,10 +1,25 @@
-// Package filekit provides file content analysis utilities.
+// Package filekit provides file content analysis and safe write utilities.
 //
-// Primary use case is binary detection for determining if files should be compressed.
-// Uses the same heuristic as Git: checks first 8KB for null bytes.
+// Binary detection is used to decide if files should be compressed. It uses
+// the same heuristic as Git: checks first 8KB for null bytes.
+//
+// Atomic writes go to a temporary file in the destination directory which is
+// renamed over the destination, so readers see either the old or the new
+// content, never a partial file.
 package filekit
 
-const binarySampleSize = 8192 // Check first 8KB like Git
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const BINARY_SAMPLE_SIZE = 8192 // Check first 8KB like Git
+
+// Prefix of the temporary names used by atomic writes.
+const TEMP_PREFIX = ".vcx-tmp-"
 
 // IsBinary checks if data contains null bytes (indicating binary content).
 func IsBinary(data []byte) bool {
@@ -12,7 +27,7 @@ func IsBinary(data []byte) bool {
 		return false
 	}
 
-	sampleSize := min(len(data), binarySampleSize)
+	sampleSize := min(len(data), BINARY_SAMPLE_SIZE)
 
 	for _, b := range data[:sampleSize] {
 		if b == 0 {
@@ -22,3 +37,54 @@ func IsBinary(data []byte) bool {
 
 	return false
 }
+
+// WriteFileAtomic replaces path with data. The content is synced to disk
+// before the rename; perm is applied to the new file.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	return WriteReaderAtomic(path, bytes.NewReader(data), perm)
+}
+
+// WriteReaderAtomic works like WriteFileAtomic with the content streamed
+// from r, so it never has to be held in memory.
+func WriteReaderAtomic(path string, r io.Reader, perm os.FileMode) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), TEMP_PREFIX+"*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = io.Copy(tmp, r); err != nil {
+		return err
+	}
+	if err = tmp.Chmod(perm); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// SymlinkAtomic points the symlink at path to target, replacing whatever is
+// at path. A directory at path is not replaced (rename fails).
+func SymlinkAtomic(target, path string) error {
+	tmpDir, err := os.MkdirTemp(filepath.Dir(path), TEMP_PREFIX+"*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tmp := filepath.Join(tmpDir, "link")
+	if err := os.Symlink(target, tmp); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,17 @@
+//go:build !unix
+
+package filekit
+
+import "io/fs"
+
+
+// Inode returns 0: inode numbers are only read on unix.
+func Inode(info fs.FileInfo) uint64 {
+	return 0
+}
+
+
+// Owner reports no owner: ownership is only read on unix.
+func Owner(info fs.FileInfo) (uid, gid int, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,28 @@
+//go:build unix
+
+package filekit
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+
+// Inode returns the inode number of the file info describes, 0 if the
+// platform does not report one.
+func Inode(info fs.FileInfo) uint64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Ino)
+	}
+	return 0
+}
+
+
+// Owner returns the user and group IDs owning the file info describes; ok
+// is false if the platform does not report them.
+func Owner(info fs.FileInfo) (uid, gid int, ok bool) {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return int(stat.Uid), int(stat.Gid), true
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,19 @@
+package httpkit
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+
+// WriteJSON writes v as a JSON response with the given status code.
+func WriteJSON(w http.ResponseWriter, status int, v any) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(v)
+}
+
+// WriteError writes err as a JSON error response: {"error": "..."}.
+func WriteError(w http.ResponseWriter, status int, err error) {
+    WriteJSON(w, status, map[string]string{"error": err.Error()})
+}
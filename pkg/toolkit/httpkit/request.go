@@ -0,0 +1,15 @@
+package httpkit
+
+import "net/http"
+
+
+// REQUEST_HEADER marks requests sent by vcx clients. Browsers send a custom
+// header to another origin only after a preflight the server may refuse, so
+// a page elsewhere cannot make a request that carries it.
+const REQUEST_HEADER = "X-VCX-Request"
+
+
+// IsSafeMethod reports whether method only reads.
+func IsSafeMethod(method string) bool {
+    return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
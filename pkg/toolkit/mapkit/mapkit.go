@@ -29,6 +29,19 @@ func GetInt(data map[string]any, key string) int {
 	return 0
 }
 
+// GetInt64 extracts an int64 value from map, returns 0 if not found.
+func GetInt64(data map[string]any, key string) int64 {
+	if val, ok := data[key]; ok && val != nil {
+		if int64Val, ok := val.(int64); ok {
+			return int64Val
+		}
+		if intVal, ok := val.(int); ok {
+			return int64(intVal)
+		}
+	}
+	return 0
+}
+
 // GetBytes extracts a []byte value from map, returns nil if not found.
 func GetBytes(data map[string]any, key string) []byte {
 	if val, ok := data[key]; ok && val != nil {
@@ -40,11 +53,18 @@ func GetBytes(data map[string]any, key string) []byte {
 }
 
 // GetBool extracts a bool value from map, returns false if not found.
+// Integer values are treated as true when non-zero (SQLite stores bools as INTEGER).
 func GetBool(data map[string]any, key string) bool {
 	if val, ok := data[key]; ok && val != nil {
 		if boolVal, ok := val.(bool); ok {
 			return boolVal
 		}
+		if int64Val, ok := val.(int64); ok {
+			return int64Val != 0
+		}
+		if intVal, ok := val.(int); ok {
+			return intVal != 0
+		}
 	}
 	return false
 }
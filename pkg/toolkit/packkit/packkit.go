@@ -0,0 +1,249 @@
+// Package packkit reads and writes pack files: many small objects appended
+// to one file, so they need neither a file each nor space in the database.
+//
+// Format:
+//
+//	"VXP1"
+//	entry*  uvarint(len(id)) id uvarint(len(data)) data
+//
+// Packs are append-only. The index mapping an object ID to its entry
+// (offset of the entry, length of the data) is kept by the caller. Every
+// entry repeats its ID and length, so reads verify they landed on the right
+// entry and a lost index can be rebuilt with Scan.
+package packkit
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+
+const MAGIC = "VXP1"
+
+
+var ErrCorrupt = errors.New("corrupt pack")
+
+
+// Writer appends entries to a new pack file.
+type Writer struct {
+	file *os.File
+	buf  *bufio.Writer
+	size int64
+}
+
+
+// Create starts a new pack at path. The file must not exist yet.
+func Create(path string) (*Writer, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Writer{file: file, buf: bufio.NewWriterSize(file, 1024*1024)}
+	if _, err := w.buf.WriteString(MAGIC); err != nil {
+		file.Close()
+		return nil, err
+	}
+	w.size = int64(len(MAGIC))
+	return w, nil
+}
+
+
+// Append copies length bytes from r into a new entry for id and returns the
+// offset of the entry.
+func (w *Writer) Append(id string, r io.Reader, length int64) (int64, error) {
+	offset := w.size
+
+	header := binary.AppendUvarint(nil, uint64(len(id)))
+	header  = append(header, id...)
+	header  = binary.AppendUvarint(header, uint64(length))
+	if _, err := w.buf.Write(header); err != nil {
+		return 0, err
+	}
+
+	n, err := io.CopyN(w.buf, r, length)
+	w.size += int64(len(header)) + n
+	if err != nil {
+		return 0, fmt.Errorf("failed to copy %s into pack: %w", id, err)
+	}
+	return offset, nil
+}
+
+
+// Size returns the number of bytes written so far.
+func (w *Writer) Size() int64 {
+	return w.size
+}
+
+
+// Close flushes the pack and syncs it to disk. Entries must not be indexed
+// before Close succeeded.
+func (w *Writer) Close() error {
+	if err := w.buf.Flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+	if err := w.file.Sync(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+
+// Abort closes and removes an unfinished pack.
+func (w *Writer) Abort() {
+	w.file.Close()
+	os.Remove(w.file.Name())
+}
+
+
+// Open returns a reader over the data of the entry at offset, after checking
+// that it holds id with the expected length.
+func Open(path string, offset int64, id string, length int64) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	dataOffset, err := checkEntry(file, offset, id, length)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &entryReader{SectionReader: io.NewSectionReader(file, dataOffset, length), file: file}, nil
+}
+
+
+// Read returns the data of the entry at offset, see Open.
+func Read(path string, offset int64, id string, length int64) ([]byte, error) {
+	r, err := Open(path, offset, id, length)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("%w: entry %s truncated", ErrCorrupt, id)
+	}
+	return data, nil
+}
+
+
+// EntrySize returns the number of bytes an entry takes up in a pack, header
+// included: the range to fetch when a pack is not a local file.
+func EntrySize(id string, length int64) int64 {
+	return int64(uvarintSize(uint64(len(id))) + len(id) + uvarintSize(uint64(length))) + length
+}
+
+
+// ReadEntry checks the header of the entry r starts at, like Open, and
+// returns a reader over its data.
+func ReadEntry(r io.Reader, id string, length int64) (io.Reader, error) {
+	reader := bufio.NewReaderSize(r, 64)
+	entryID, _, entryLength, err := readHeader(reader)
+	if err != nil {
+		return nil, err
+	}
+	if entryID != id || entryLength != length {
+		return nil, fmt.Errorf("%w: entry holds %s (%d bytes), expected %s (%d bytes)", ErrCorrupt, entryID, entryLength, id, length)
+	}
+	return io.LimitReader(reader, length), nil
+}
+
+
+// Scan calls fn for every complete entry of the pack in order. A truncated
+// last entry, left by an interrupted write, ends the scan without an error.
+func Scan(path string, fn func(id string, offset, length int64) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	reader := bufio.NewReader(file)
+
+	magic := make([]byte, len(MAGIC))
+	if _, err := io.ReadFull(reader, magic); err != nil || string(magic) != MAGIC {
+		return fmt.Errorf("%w: bad header", ErrCorrupt)
+	}
+
+	offset := int64(len(MAGIC))
+	for offset < info.Size() {
+		id, headerSize, length, err := readHeader(reader)
+		if err != nil || offset+headerSize+length > info.Size() {
+			return nil
+		}
+		if err := fn(id, offset, length); err != nil {
+			return err
+		}
+		if _, err := reader.Discard(int(length)); err != nil {
+			return nil
+		}
+		offset += headerSize + length
+	}
+	return nil
+}
+
+
+// checkEntry verifies the entry header at offset and returns where its data
+// starts.
+func checkEntry(file *os.File, offset int64, id string, length int64) (int64, error) {
+	if offset < int64(len(MAGIC)) {
+		return 0, fmt.Errorf("%w: offset %d inside the header", ErrCorrupt, offset)
+	}
+
+	reader := bufio.NewReaderSize(io.NewSectionReader(file, offset, int64(binary.MaxVarintLen64*2+len(id))), 64)
+	entryID, headerSize, entryLength, err := readHeader(reader)
+	if err != nil {
+		return 0, err
+	}
+	if entryID != id || entryLength != length {
+		return 0, fmt.Errorf("%w: entry at %d holds %s (%d bytes), expected %s (%d bytes)", ErrCorrupt, offset, entryID, entryLength, id, length)
+	}
+	return offset + headerSize, nil
+}
+
+
+func readHeader(reader *bufio.Reader) (id string, headerSize, length int64, err error) {
+	idLength, err := binary.ReadUvarint(reader)
+	if err != nil || idLength > 1024 {
+		return "", 0, 0, fmt.Errorf("%w: bad entry header", ErrCorrupt)
+	}
+	idBytes := make([]byte, idLength)
+	if _, err := io.ReadFull(reader, idBytes); err != nil {
+		return "", 0, 0, fmt.Errorf("%w: bad entry header", ErrCorrupt)
+	}
+	dataLength, err := binary.ReadUvarint(reader)
+	if err != nil || dataLength > 1<<62 {
+		return "", 0, 0, fmt.Errorf("%w: bad entry header", ErrCorrupt)
+	}
+
+	headerSize = int64(uvarintSize(idLength) + int(idLength) + uvarintSize(dataLength))
+	return string(idBytes), headerSize, int64(dataLength), nil
+}
+
+
+func uvarintSize(v uint64) int {
+	return len(binary.AppendUvarint(nil, v))
+}
+
+
+// entryReader closes the pack file along with the section.
+type entryReader struct {
+	*io.SectionReader
+	file *os.File
+}
+
+func (r *entryReader) Close() error {
+	return r.file.Close()
+}
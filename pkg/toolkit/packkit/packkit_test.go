@@ -0,0 +1,123 @@
+package packkit
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+
+type entry struct {
+	id     string
+	data   []byte
+	offset int64
+}
+
+
+func writePack(t *testing.T, entries []entry) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.pack")
+	w, err := Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range entries {
+		offset, err := w.Append(entries[i].id, bytes.NewReader(entries[i].data), int64(len(entries[i].data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries[i].offset = offset
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+
+func TestAppendAndRead(t *testing.T) {
+	entries := []entry{
+		{id: "a", data: []byte("first")},
+		{id: "b", data: bytes.Repeat([]byte{0, 1, 2}, 100000)},
+		{id: "c", data: nil},
+	}
+	path := writePack(t, entries)
+
+	for _, e := range entries {
+		data, err := Read(path, e.offset, e.id, int64(len(e.data)))
+		if err != nil {
+			t.Fatalf("read %s: %v", e.id, err)
+		}
+		if !bytes.Equal(data, e.data) {
+			t.Fatalf("entry %s does not round-trip", e.id)
+		}
+	}
+
+	if _, err := Read(path, entries[1].offset, "a", int64(len(entries[1].data))); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("expected a wrong ID to be detected, got %v", err)
+	}
+	if _, err := Read(path, entries[0].offset, "a", 3); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("expected a wrong length to be detected, got %v", err)
+	}
+}
+
+
+func TestScanStopsAtTruncatedEntry(t *testing.T) {
+	entries := []entry{
+		{id: "a", data: []byte("first")},
+		{id: "b", data: []byte("second")},
+	}
+	path := writePack(t, entries)
+
+	info, _ := os.Stat(path)
+	if err := os.Truncate(path, info.Size()-2); err != nil {
+		t.Fatal(err)
+	}
+
+	var found []string
+	err := Scan(path, func(id string, offset, length int64) error {
+		found = append(found, id)
+		if offset != entries[len(found)-1].offset {
+			t.Fatalf("entry %s scanned at %d, written at %d", id, offset, entries[len(found)-1].offset)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0] != "a" {
+		t.Fatalf("expected only the complete entry, got %v", found)
+	}
+}
+
+
+func TestReadEntryFromRange(t *testing.T) {
+	entries := []entry{
+		{id: "a", data: []byte("first")},
+		{id: "b", data: bytes.Repeat([]byte("x"), 300)},
+	}
+	path := writePack(t, entries)
+	pack, _ := os.ReadFile(path)
+
+	e    := entries[1]
+	size := EntrySize(e.id, int64(len(e.data)))
+	if e.offset+size != int64(len(pack)) {
+		t.Fatalf("entry size %d does not reach the end of the pack", size)
+	}
+
+	r, err := ReadEntry(bytes.NewReader(pack[e.offset:e.offset+size]), e.id, int64(len(e.data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(r)
+	if !bytes.Equal(data, e.data) {
+		t.Fatal("entry does not round-trip")
+	}
+
+	if _, err := ReadEntry(bytes.NewReader(pack[e.offset:]), "a", int64(len(e.data))); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("expected a wrong ID to be detected, got %v", err)
+	}
+}
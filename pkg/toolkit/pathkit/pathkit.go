@@ -2,12 +2,14 @@
 //
 // Includes functions for:
 //   - Path existence and type checking (file, directory, symlink)
+//   - Containment of one path in another
 //   - Current working directory
 //   - Path splitting and parsing
 package pathkit
 
 import (
 	"os"
+	"path/filepath"
 	"strings"
 )
 
@@ -51,6 +53,17 @@ func IsSymlink(path string) bool {
 }
 
 
+// Within reports whether path is root or lies below it. Both are compared
+// as cleaned strings, symlinks are not resolved.
+func Within(root, path string) bool {
+    rel, err := filepath.Rel(root, path)
+    if err != nil || filepath.IsAbs(rel) {
+        return false
+    }
+    return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+
 // Split splits a path into segments, removing empty strings.
 func Split(path string) []string {
     segments := make([]string, 0)
@@ -0,0 +1,34 @@
+package pathkit
+
+import "testing"
+
+
+func TestWithin(t *testing.T) {
+	cases := []struct {
+		name string
+		root string
+		path string
+		want bool
+	}{
+		{"root itself", "/p", "/p", true},
+		{"root with slash", "/p/", "/p", true},
+		{"child", "/p", "/p/x", true},
+		{"cleaned child", "/p", "/p/x/../y", true},
+		{"dotted name", "/p", "/p/..foo", true},
+		{"parent", "/p", "/p/..", false},
+		{"sibling through parent", "/p", "/p/../x", false},
+		{"sibling with common prefix", "/p", "/pfoo", false},
+		{"filesystem root", "/p", "/", false},
+		{"relative child", "p", "p/x", true},
+		{"relative parent", "p", "..", false},
+		{"relative escape", "p", "p/../../x", false},
+		{"relative other", "p", "x", false},
+		{"absolute in relative", "p", "/p/x", false},
+		{"relative in absolute", "/p", "p/x", false},
+	}
+	for _, c := range cases {
+		if got := Within(c.root, c.path); got != c.want {
+			t.Errorf("%s: Within(%q, %q) = %v, want %v", c.name, c.root, c.path, got, c.want)
+		}
+	}
+}
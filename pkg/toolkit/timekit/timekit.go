@@ -4,10 +4,14 @@
 //   - Unix epoch timestamps
 //   - Fractional time with configurable precision (milliseconds to microseconds)
 //   - RFC3339 formatted date/time strings
+//   - Parsing of user supplied points in time (absolute or relative)
 package timekit
 
 import (
+	"fmt"
 	"math"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -46,3 +50,35 @@ func GetFractionalTime(precision int) int {
 func GetDateTime() string {
     return time.Now().Format(time.RFC3339)
 }
+
+
+// ParseTime parses a user supplied point in time relative to now.
+//
+// Accepted forms:
+//   - RFC3339: "2024-05-01T14:30:00Z"
+//   - Local date and time: "2024-05-01 14:30" or "2024-05-01T14:30"
+//   - Local date: "2024-05-01" (start of day)
+//   - Age: a Go duration or a number of days, e.g. "90m", "2h", "3d"
+func ParseTime(value string, now time.Time) (time.Time, error) {
+    value = strings.TrimSpace(value)
+
+    if t, err := time.Parse(time.RFC3339, value); err == nil {
+        return t, nil
+    }
+    for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02"} {
+        if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+            return t, nil
+        }
+    }
+
+    if days, ok := strings.CutSuffix(value, "d"); ok {
+        if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+            return now.AddDate(0, 0, -n), nil
+        }
+    }
+    if age, err := time.ParseDuration(value); err == nil && age >= 0 {
+        return now.Add(-age), nil
+    }
+
+    return time.Time{}, fmt.Errorf("invalid time %q: use RFC3339, YYYY-MM-DD[ HH:MM] or an age like 2h or 3d", value)
+}